@@ -8,7 +8,14 @@ CREATE TABLE accounts (
     user_id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
     username CITEXT UNIQUE,
     disabled BOOLEAN DEFAULT false,
-    password_hash TEXT NOT NULL
+    password_hash TEXT NOT NULL,
+    tokens_valid_after timestamp DEFAULT NULL, -- tokens issued before this moment are rejected
+    totp_secret TEXT DEFAULT NULL, -- base32, set on enrollment and kept once confirmed
+    totp_enabled BOOLEAN DEFAULT false,
+    totp_last_step BIGINT DEFAULT 0, -- last accepted time step, prevents code replay
+    created_at timestamp DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    last_login_at timestamp DEFAULT NULL,
+    oidc_subject TEXT UNIQUE DEFAULT NULL -- 'sub' claim of the linked identity provider account
 );
 
 -- DONE
@@ -29,17 +36,121 @@ CREATE TABLE users (
     latest_ticket UUID DEFAULT NULL
 );
 
+CREATE TABLE user_absences (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    absence_type TEXT NOT NULL, -- vacation, sick_leave, business_trip or other
+    starts_at timestamp NOT NULL,
+    ends_at timestamp NOT NULL, -- exclusive
+    comment TEXT DEFAULT NULL,
+    created_by UUID REFERENCES accounts(user_id) ON DELETE SET NULL,
+    created_at timestamp DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX user_absences_user_period_idx ON user_absences (user_id, starts_at, ends_at);
+
 -- DONE
 CREATE TABLE roles (
-    id INT PRIMARY KEY,
+    id INT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
     name VARCHAR(50) NOT NULL UNIQUE,
     description VARCHAR(255)
 );
 
 -- DONE
 CREATE TABLE account_roles (
-    user_id UUID PRIMARY KEY REFERENCES accounts(user_id) ON DELETE CASCADE,
-    role_id INT NOT NULL REFERENCES roles(id)
+    user_id UUID NOT NULL REFERENCES accounts(user_id) ON DELETE CASCADE,
+    role_id INT NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, role_id)
+);
+
+CREATE TABLE permissions (
+    name VARCHAR(64) PRIMARY KEY, -- e.g. tickets:assign
+    description VARCHAR(255)
+);
+
+CREATE TABLE role_permissions (
+    role_id INT NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    permission VARCHAR(64) NOT NULL REFERENCES permissions(name) ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission)
+);
+
+CREATE TABLE password_reset_tokens (
+    token_hash TEXT PRIMARY KEY, -- sha256 of the token sent to the user
+    user_id UUID NOT NULL REFERENCES accounts(user_id) ON DELETE CASCADE,
+    created_at timestamp DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    expires_at timestamp NOT NULL,
+    used_at timestamp DEFAULT NULL
+);
+
+CREATE TABLE mfa_recovery_codes (
+    user_id UUID NOT NULL REFERENCES accounts(user_id) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL, -- sha256 of the code shown to the user once
+    created_at timestamp DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    used_at timestamp DEFAULT NULL,
+    PRIMARY KEY (user_id, code_hash)
+);
+
+-- Pending OpenID Connect logins, consumed by the callback
+CREATE TABLE oidc_login_states (
+    state TEXT PRIMARY KEY,
+    code_verifier TEXT NOT NULL,
+    nonce TEXT NOT NULL,
+    expires_at timestamp NOT NULL
+);
+
+-- Failed login tracking, keyed by 'user:<username>' or 'ip:<address>'.
+-- Password reset requests are counted under 'reset:user:...' and 'reset:ip:...'
+CREATE TABLE login_throttle (
+    key TEXT PRIMARY KEY,
+    failures INT NOT NULL DEFAULT 0,
+    last_failure_at timestamp DEFAULT NULL,
+    blocked_until timestamp DEFAULT NULL
+);
+
+CREATE TABLE auth_audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    created_at timestamp DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    event VARCHAR(64) NOT NULL,
+    success BOOLEAN NOT NULL,
+    actor_type VARCHAR(16) NOT NULL DEFAULT 'user', -- 'user' or 'api_key'
+    username TEXT DEFAULT '',
+    user_id UUID DEFAULT NULL,
+    ip TEXT DEFAULT '',
+    details TEXT DEFAULT ''
+);
+
+CREATE INDEX auth_audit_log_created_at_idx ON auth_audit_log (created_at DESC);
+
+-- Every mutating API request, with the impersonating admin if there was one
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    created_at timestamp DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    actor_type VARCHAR(16) NOT NULL DEFAULT 'user',
+    actor_id UUID DEFAULT NULL,
+    impersonator_id UUID DEFAULT NULL,
+    method VARCHAR(10) NOT NULL,
+    path TEXT NOT NULL,
+    status INT NOT NULL,
+    request_id TEXT DEFAULT ''
+);
+
+CREATE INDEX audit_log_created_at_idx ON audit_log (created_at DESC);
+CREATE INDEX audit_log_impersonator_idx ON audit_log (impersonator_id) WHERE impersonator_id IS NOT NULL;
+
+-- Keys for service integrations. Only a hash of the key is stored.
+CREATE TABLE api_keys (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    name TEXT NOT NULL,
+    prefix VARCHAR(16) NOT NULL, -- first characters of the key, to tell keys apart
+    key_hash TEXT NOT NULL UNIQUE,
+    permissions TEXT[] NOT NULL DEFAULT '{}',
+    created_by UUID REFERENCES accounts(user_id) ON DELETE SET NULL,
+    created_at timestamp DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    rotated_at timestamp DEFAULT NULL,
+    expires_at timestamp DEFAULT NULL,
+    last_used_at timestamp DEFAULT NULL,
+    revoked_at timestamp DEFAULT NULL
 );
 
 -- DONE
@@ -57,6 +168,19 @@ CREATE TABLE comments (
     created_at timestamp DEFAULT (NOW() AT TIME ZONE 'UTC')
 );
 
+-- Laboratory information system installations used by clients
+CREATE TABLE laboratory_systems (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    title TEXT NOT NULL,
+    vendor TEXT DEFAULT '',
+    version TEXT DEFAULT '',
+    contact_name TEXT DEFAULT '', -- integration contact on the LIS side
+    contact_phone TEXT DEFAULT '',
+    contact_email TEXT DEFAULT '',
+    notes TEXT DEFAULT '',
+    created_at timestamp DEFAULT (NOW() AT TIME ZONE 'UTC')
+);
+
 -- DONE
 CREATE TABLE clients (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -64,20 +188,79 @@ CREATE TABLE clients (
     region UUID REFERENCES regions(id) ON DELETE SET NULL,
     address TEXT,
     location JSONB DEFAULT NULL,
-    laboratory_system UUID DEFAULT NULL, -- ссылка на конкретный лис (пока не реализововывать)
+    laboratory_system UUID REFERENCES laboratory_systems(id) ON DELETE SET NULL,
     manager UUID[] DEFAULT '{}'
 );
 
+CREATE INDEX clients_manager_idx ON clients USING GIN (manager);
+
+-- Ids of clients merged into another one, so that old links keep resolving
+CREATE TABLE client_redirects (
+    old_id UUID PRIMARY KEY,
+    client_id UUID NOT NULL REFERENCES clients(id) ON DELETE CASCADE,
+    merged_by UUID REFERENCES accounts(user_id) ON DELETE SET NULL,
+    merged_at timestamp DEFAULT (NOW() AT TIME ZONE 'UTC')
+);
+
+CREATE INDEX client_redirects_client_idx ON client_redirects (client_id);
+
+-- Geocoder answers by normalized address, found = false caches misses
+CREATE TABLE geocode_cache (
+    address_key TEXT PRIMARY KEY,
+    found BOOLEAN NOT NULL,
+    lat DOUBLE PRECISION,
+    lng DOUBLE PRECISION,
+    confidence DOUBLE PRECISION,
+    display_name TEXT DEFAULT '',
+    provider TEXT NOT NULL,
+    created_at timestamp DEFAULT (NOW() AT TIME ZONE 'UTC')
+);
+
+-- Latest geocoding of each client address. Confident matches are applied to
+-- clients.location right away, the rest wait for an admin.
+CREATE TABLE client_geocodes (
+    client_id UUID PRIMARY KEY REFERENCES clients(id) ON DELETE CASCADE,
+    address TEXT NOT NULL, -- the address that was geocoded
+    status VARCHAR(16) NOT NULL, -- applied, pending, approved, rejected, not_found or failed
+    lat DOUBLE PRECISION,
+    lng DOUBLE PRECISION,
+    confidence DOUBLE PRECISION,
+    display_name TEXT DEFAULT '',
+    attempts INT NOT NULL DEFAULT 1, -- geocoding attempts for this address
+    created_at timestamp DEFAULT (NOW() AT TIME ZONE 'UTC'), -- time of the last attempt
+    reviewed_by UUID REFERENCES accounts(user_id) ON DELETE SET NULL,
+    reviewed_at timestamp DEFAULT NULL
+);
+
+CREATE INDEX client_geocodes_status_idx ON client_geocodes (status);
+
+-- Uploaded client spreadsheets, validated rows are kept until applied
+CREATE TABLE client_imports (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    filename TEXT NOT NULL,
+    columns JSONB NOT NULL DEFAULT '{}', -- field to spreadsheet header mapping
+    rows JSONB NOT NULL DEFAULT '[]',
+    error_rows INT NOT NULL DEFAULT 0,
+    created_by UUID REFERENCES accounts(user_id) ON DELETE SET NULL,
+    created_at timestamp DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    applied_by UUID REFERENCES accounts(user_id) ON DELETE SET NULL,
+    applied_at timestamp DEFAULT NULL,
+    result JSONB DEFAULT NULL
+);
+
 -- DONE
 CREATE TABLE contacts (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
     name TEXT DEFAULT '',
     position TEXT DEFAULT '',
     phone TEXT DEFAULT '',
+    phone_e164 TEXT DEFAULT '',
     email TEXT DEFAULT '',
     client_id UUID REFERENCES clients(id) ON DELETE CASCADE
 );
 
+CREATE INDEX contacts_phone_e164_idx ON contacts (phone_e164) WHERE phone_e164 <> '';
+
 -- DONE
 CREATE TABLE research_type (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -108,10 +291,32 @@ CREATE TABLE devices (
     classificator UUID REFERENCES classificators(id) ON DELETE SET NULL,
     serial_number TEXT,
     properties JSONB DEFAULT '{}',
-    connected_to_lis BOOLEAN DEFAULT FALSE,
+    connected_to_lis BOOLEAN DEFAULT FALSE, -- kept in sync with device_lis_connections
     is_used BOOLEAN DEFAULT FALSE
 );
 
+CREATE TABLE device_lis_connections (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    device_id UUID NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    laboratory_system UUID NOT NULL REFERENCES laboratory_systems(id) ON DELETE CASCADE,
+    protocol VARCHAR(16) NOT NULL, -- ASTM or HL7
+    parameters JSONB DEFAULT '{}', -- host, port, serial line settings and the like
+    comment TEXT DEFAULT NULL,
+    created_at timestamp DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    UNIQUE (device_id, laboratory_system)
+);
+
+-- Edits of a device for its timeline, one row per update
+CREATE TABLE device_changes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    device_id UUID NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    changed_by UUID REFERENCES accounts(user_id) ON DELETE SET NULL,
+    changed_at timestamp DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    changes JSONB NOT NULL -- field or property name to {"from": ..., "to": ...}
+);
+
+CREATE INDEX device_changes_device_idx ON device_changes (device_id, changed_at);
+
 -- DONE
 CREATE TABLE ticket_statuses (
     type VARCHAR(128) PRIMARY KEY,
@@ -179,6 +384,38 @@ CREATE TABLE attachments (
     ref_id UUID NOT NULL
 );
 
+-- A skill covers a classificator (device model), all models of a manufacturer
+-- or all models of a research type. Certificate scans are attachments with the
+-- skill id as ref_id.
+CREATE TABLE user_skills (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    classificator UUID REFERENCES classificators(id) ON DELETE CASCADE,
+    manufacturer UUID REFERENCES manufacturers(id) ON DELETE CASCADE,
+    research_type UUID REFERENCES research_type(id) ON DELETE CASCADE,
+    certified_at date DEFAULT NULL,
+    expires_at date DEFAULT NULL,
+    comment TEXT DEFAULT NULL,
+    created_at timestamp DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    CHECK (num_nonnulls(classificator, manufacturer, research_type) = 1),
+    CHECK (expires_at IS NULL OR certified_at IS NULL OR expires_at >= certified_at)
+);
+
+CREATE INDEX user_skills_user_idx ON user_skills (user_id);
+
+-- In-app notifications, e.g. for client managers when a ticket is closed
+CREATE TABLE notifications (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES accounts(user_id) ON DELETE CASCADE,
+    kind VARCHAR(64) NOT NULL,
+    reference_id UUID DEFAULT NULL,
+    message TEXT NOT NULL,
+    created_at timestamp DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    read_at timestamp DEFAULT NULL
+);
+
+CREATE INDEX notifications_user_idx ON notifications (user_id, created_at DESC);
+
 CREATE TABLE  agreements (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
     number INT GENERATED ALWAYS AS IDENTITY,
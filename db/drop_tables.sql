@@ -2,20 +2,39 @@ BEGIN;
 
 DROP TABLE IF EXISTS remote_access;
 DROP TABLE IF EXISTS ra_options;
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS user_skills;
 DROP TABLE IF EXISTS agreements;
 DROP TABLE IF EXISTS attachments;
 DROP TABLE IF EXISTS tickets;
 DROP TABLE IF EXISTS ticket_reasons;
 DROP TABLE IF EXISTS ticket_types;
 DROP TABLE IF EXISTS ticket_statuses;
+DROP TABLE IF EXISTS device_lis_connections;
+DROP TABLE IF EXISTS device_changes;
 DROP TABLE IF EXISTS devices;
 DROP TABLE IF EXISTS classificators;
 DROP TABLE IF EXISTS manufacturers;
 DROP TABLE IF EXISTS research_type;
 DROP TABLE IF EXISTS contacts;
+DROP TABLE IF EXISTS client_redirects;
+DROP TABLE IF EXISTS client_geocodes;
+DROP TABLE IF EXISTS geocode_cache;
+DROP TABLE IF EXISTS client_imports;
 DROP TABLE IF EXISTS clients;
+DROP TABLE IF EXISTS laboratory_systems;
 DROP TABLE IF EXISTS comments;
 DROP TABLE IF EXISTS regions;
+DROP TABLE IF EXISTS user_absences;
+DROP TABLE IF EXISTS audit_log;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS auth_audit_log;
+DROP TABLE IF EXISTS login_throttle;
+DROP TABLE IF EXISTS oidc_login_states;
+DROP TABLE IF EXISTS mfa_recovery_codes;
+DROP TABLE IF EXISTS password_reset_tokens;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS permissions;
 DROP TABLE IF EXISTS account_roles;
 DROP TABLE IF EXISTS roles;
 DROP TABLE IF EXISTS users;
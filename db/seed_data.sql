@@ -18,6 +18,26 @@ INSERT INTO roles (id, name, description) VALUES
     (2, 'coordinator', 'Can manage content and users but not system settings'),
     (3, 'user', 'Regular user with basic access');
 
+-- The built-in roles use fixed IDs, move the identity past them
+SELECT setval(pg_get_serial_sequence('roles', 'id'), (SELECT MAX(id) FROM roles));
+
+INSERT INTO permissions (name, description) VALUES
+    ('tickets:assign', 'Assign tickets to executors'),
+    ('tickets:delete', 'Delete tickets'),
+    ('clients:write', 'Create, update and delete clients'),
+    ('devices:write', 'Create, update and delete devices'),
+    ('admin:accounts', 'Manage accounts, roles and permissions');
+
+INSERT INTO role_permissions (role_id, permission) VALUES
+    (1, 'tickets:assign'),
+    (1, 'tickets:delete'),
+    (1, 'clients:write'),
+    (1, 'devices:write'),
+    (1, 'admin:accounts'),
+    (2, 'tickets:assign'),
+    (2, 'clients:write'),
+    (2, 'devices:write');
+
 -- INSERT INTO account_roles (user_id, role_id) VALUES
 --     ('ad9fa963-cad8-4bc3-b8e2-f4a4f70cf95e', 1),
 --     ('84d512de-df6a-4a0b-be28-a8e184bd1d6a', 2),
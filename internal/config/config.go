@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -11,6 +12,10 @@ type Config struct {
 	Server   ServerConfig
 	Database DatabaseConfig
 	Storage  StorageConfig
+	Mail     MailConfig
+	MFA      MFAConfig
+	OIDC     OIDCConfig
+	Geocoder GeocoderConfig
 }
 
 type ServerConfig struct {
@@ -19,8 +24,15 @@ type ServerConfig struct {
 	WriteTimeout time.Duration
 	IdleTimeout  time.Duration
 	Secret       string
-	SSLKey       string
-	SSLSert      string
+	// KeyDir holds the Ed25519 token signing keys, see services.KeyRing
+	KeyDir  string
+	SSLKey  string
+	SSLSert string
+	// PasswordResetURL is the page the reset token is appended to in reset emails
+	PasswordResetURL string
+	// DeviceLabelURL is the page device label QR codes open, the device id
+	// is appended to it
+	DeviceLabelURL string
 }
 
 type DatabaseConfig struct {
@@ -32,6 +44,57 @@ type DatabaseConfig struct {
 	SSLMode  string
 }
 
+type MailConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+type MFAConfig struct {
+	// RequiredRoles can't sign in without a confirmed second factor
+	RequiredRoles []string
+	// Issuer is the account label shown in authenticator apps
+	Issuer string
+}
+
+// OIDCConfig configures single sign-on. It is disabled without an issuer.
+type OIDCConfig struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	// AutoProvision creates accounts for unknown identities
+	AutoProvision     bool
+	DefaultRole       string
+	DefaultDepartment string
+	// RoleRules and DepartmentRules map claim values to roles and department
+	// IDs, e.g. "groups:foxygen-admins=admin,groups:dispatch=coordinator"
+	RoleRules       []string
+	DepartmentRules []string
+}
+
+// GeocoderConfig configures filling in client locations from addresses. It
+// is disabled without a provider.
+type GeocoderConfig struct {
+	// Provider is "nominatim" or "file"
+	Provider string
+	// URL is the base of a Nominatim compatible API
+	URL       string
+	UserAgent string
+	// File maps addresses to coordinates for the file provider
+	File string
+	// RequestInterval is the minimum time between provider requests
+	RequestInterval time.Duration
+	// RunInterval is how often the background job looks for clients to geocode
+	RunInterval time.Duration
+	BatchSize   int
+	// MinConfidence is the confidence below which matches wait for review
+	MinConfidence float64
+}
+
 type StorageConfig struct {
 	Endpoint  string
 	AccessKey string
@@ -49,8 +112,12 @@ func Load() *Config {
 			WriteTimeout: 30 * time.Second,
 			IdleTimeout:  1 * time.Minute,
 			Secret:       GetEnv("JWT_SECRET", ""),
+			KeyDir:       GetEnv("JWT_KEY_DIR", ""),
 			SSLKey:       GetEnv("SSL_KEY_PATH", ""),
 			SSLSert:      GetEnv("SSL_CERT_PATH", ""),
+
+			PasswordResetURL: GetEnv("PASSWORD_RESET_URL", ""),
+			DeviceLabelURL:   GetEnv("DEVICE_LABEL_URL", ""),
 		},
 		Database: DatabaseConfig{
 			Host:     GetEnv("DB_HOST", "db"),
@@ -68,6 +135,39 @@ func Load() *Config {
 			UseSSL:    GetEnvBool("MINIO_USE_SSL", false),
 			Location:  GetEnv("MINIO_LOCATION", "us-east-1"),
 		},
+		Mail: MailConfig{
+			Host:     GetEnv("SMTP_HOST", ""),
+			Port:     GetEnv("SMTP_PORT", "587"),
+			Username: GetEnv("SMTP_USERNAME", ""),
+			Password: GetEnv("SMTP_PASSWORD", ""),
+			From:     GetEnv("SMTP_FROM", ""),
+		},
+		OIDC: OIDCConfig{
+			IssuerURL:         GetEnv("OIDC_ISSUER_URL", ""),
+			ClientID:          GetEnv("OIDC_CLIENT_ID", ""),
+			ClientSecret:      GetEnv("OIDC_CLIENT_SECRET", ""),
+			RedirectURL:       GetEnv("OIDC_REDIRECT_URL", ""),
+			Scopes:            GetEnvList("OIDC_SCOPES", "openid,email,profile"),
+			AutoProvision:     GetEnvBool("OIDC_AUTO_PROVISION", false),
+			DefaultRole:       GetEnv("OIDC_DEFAULT_ROLE", "user"),
+			DefaultDepartment: GetEnv("OIDC_DEFAULT_DEPARTMENT", ""),
+			RoleRules:         GetEnvList("OIDC_ROLE_RULES", ""),
+			DepartmentRules:   GetEnvList("OIDC_DEPARTMENT_RULES", ""),
+		},
+		Geocoder: GeocoderConfig{
+			Provider:        GetEnv("GEOCODER_PROVIDER", ""),
+			URL:             GetEnv("GEOCODER_URL", "https://nominatim.openstreetmap.org"),
+			UserAgent:       GetEnv("GEOCODER_USER_AGENT", "foxygen-server"),
+			File:            GetEnv("GEOCODER_FILE", ""),
+			RequestInterval: GetEnvDuration("GEOCODER_REQUEST_INTERVAL", time.Second),
+			RunInterval:     GetEnvDuration("GEOCODER_RUN_INTERVAL", time.Hour),
+			BatchSize:       GetEnvInt("GEOCODER_BATCH_SIZE", 200),
+			MinConfidence:   GetEnvFloat("GEOCODER_MIN_CONFIDENCE", 0.8),
+		},
+		MFA: MFAConfig{
+			RequiredRoles: GetEnvList("MFA_REQUIRED_ROLES", "admin"),
+			Issuer:        GetEnv("MFA_ISSUER", "Foxygen"),
+		},
 	}
 }
 
@@ -79,6 +179,18 @@ func GetEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// GetEnvList reads a comma separated list, dropping empty items.
+func GetEnvList(key, defaultValue string) []string {
+	var items []string
+	for _, item := range strings.Split(GetEnv(key, defaultValue), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
 func GetEnvBool(key string, defaultValue bool) bool {
 	value, exists := os.LookupEnv(key)
 	if !exists {
@@ -93,6 +205,34 @@ func GetEnvBool(key string, defaultValue bool) bool {
 	return parsed
 }
 
+func GetEnvInt(key string, defaultValue int) int {
+	parsed, err := strconv.Atoi(GetEnv(key, ""))
+	if err != nil {
+		return defaultValue
+	}
+
+	return parsed
+}
+
+func GetEnvFloat(key string, defaultValue float64) float64 {
+	parsed, err := strconv.ParseFloat(GetEnv(key, ""), 64)
+	if err != nil {
+		return defaultValue
+	}
+
+	return parsed
+}
+
+// GetEnvDuration reads a duration such as "500ms" or "1h".
+func GetEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	parsed, err := time.ParseDuration(GetEnv(key, ""))
+	if err != nil {
+		return defaultValue
+	}
+
+	return parsed
+}
+
 func (dc *DatabaseConfig) ConnectionString() string {
 	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 		dc.Host, dc.Port, dc.User, dc.Password, dc.Name, dc.SSLMode)
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/grintheone/foxygen-server/internal/services"
+)
+
+type AbsenceHandler struct {
+	service *services.AbsenceService
+}
+
+type absenceRequest struct {
+	UserID  *uuid.UUID           `json:"user_id"`
+	Type    string               `json:"type"`
+	From    *models.FlexibleTime `json:"from"`
+	To      *models.FlexibleTime `json:"to"`
+	Comment *string              `json:"comment"`
+}
+
+// toAbsence builds the absence from the request, defaulting the user to the actor.
+func (req absenceRequest) toAbsence(actor services.Actor) (models.Absence, bool) {
+	if req.From == nil || req.To == nil {
+		return models.Absence{}, false
+	}
+
+	absence := models.Absence{
+		UserID:  actor.UserID,
+		Type:    strings.TrimSpace(req.Type),
+		From:    req.From.Time,
+		To:      req.To.Time,
+		Comment: req.Comment,
+	}
+	if req.UserID != nil {
+		absence.UserID = *req.UserID
+	}
+
+	return absence, true
+}
+
+// absenceError maps validation errors to 400.
+func absenceError(w http.ResponseWriter, err error) {
+	if errors.Is(err, services.ErrUnknownAbsenceType) || errors.Is(err, services.ErrInvalidAbsencePeriod) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	serviceError(w, err)
+}
+
+// Calendar lists absences overlapping ?from= and ?to= (dates or RFC 3339) of
+// ?department= or ?user=. It defaults to the caller's department and the 31
+// days starting today.
+func (h *AbsenceHandler) Calendar(w http.ResponseWriter, r *http.Request) {
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	from, okFrom := parseTimeParam(r, "from")
+	to, okTo := parseTimeParam(r, "to")
+	department, okDepartment := parseUUIDParam(r, "department")
+	userID, okUser := parseUUIDParam(r, "user")
+	if !okFrom || !okTo || !okDepartment || !okUser {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	if from.IsZero() {
+		from = time.Now().UTC().Truncate(24 * time.Hour)
+	}
+
+	absences, err := h.service.Calendar(r.Context(), actor, models.AbsenceFilter{
+		UserID:     userID,
+		Department: department,
+		From:       from,
+		To:         to,
+	})
+	if err != nil {
+		absenceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, absences)
+}
+
+// CheckConflicts lists the absences of ?user= overlapping ?from= and ?to=,
+// for checking an assignment before making it.
+func (h *AbsenceHandler) CheckConflicts(w http.ResponseWriter, r *http.Request) {
+	userID, okUser := parseUUIDParam(r, "user")
+	from, okFrom := parseTimeParam(r, "from")
+	to, okTo := parseTimeParam(r, "to")
+	if !okUser || !okFrom || !okTo || userID == nil || from.IsZero() || !to.After(from) {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	conflicts, err := h.service.Conflicts(r.Context(), *userID, from, to)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"available": len(conflicts) == 0,
+		"conflicts": conflicts,
+	})
+}
+
+// CreateAbsence records an absence of user_id, or of the caller when omitted
+func (h *AbsenceHandler) CreateAbsence(w http.ResponseWriter, r *http.Request) {
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	var request absenceRequest
+
+	if !decodeJSONBody(w, r, &request) {
+		return
+	}
+
+	absence, ok := request.toAbsence(actor)
+	if !ok {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	created, err := h.service.CreateAbsence(r.Context(), actor, absence)
+	if err != nil {
+		absenceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (h *AbsenceHandler) UpdateAbsence(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	var request absenceRequest
+
+	if !decodeJSONBody(w, r, &request) {
+		return
+	}
+
+	absence, ok := request.toAbsence(actor)
+	if !ok {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+	absence.ID = id
+
+	updated, err := h.service.UpdateAbsence(r.Context(), actor, absence)
+	if err != nil {
+		absenceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (h *AbsenceHandler) DeleteAbsence(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.DeleteAbsence(r.Context(), actor, id); err != nil {
+		serviceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -2,11 +2,15 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/grintheone/foxygen-server/internal/middlewares"
+	"github.com/grintheone/foxygen-server/internal/models"
 	"github.com/grintheone/foxygen-server/internal/services"
 )
 
@@ -14,31 +18,166 @@ type AccountHandler struct {
 	accountService *services.AccountService
 }
 
+// CreateAccount creates an account together with its user profile. Without
+// a password one is generated and returned once.
 func (h *AccountHandler) CreateAccount(w http.ResponseWriter, r *http.Request) {
 	var request struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
-		Database string `json:"database"`
-		Role     string `json:"role"`
+		Username string      `json:"username"`
+		Password string      `json:"password"`
+		Role     string      `json:"role"`
+		Roles    []string    `json:"roles"`
+		Profile  models.User `json:"profile"`
 	}
 
 	if !decodeJSONBody(w, r, &request) {
 		return
 	}
 
-	newUser, err := h.accountService.CreateUser(
-		r.Context(),
-		request.Username,
-		request.Password,
-		request.Role,
-		nil,
-	)
+	if len(request.Roles) == 0 && request.Role != "" {
+		request.Roles = []string{request.Role}
+	}
+
+	account, err := h.accountService.CreateAccount(r.Context(), services.NewAccount{
+		Username: request.Username,
+		Password: request.Password,
+		Roles:    request.Roles,
+		Profile:  request.Profile,
+	})
+	if err != nil {
+		accountError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, account)
+}
+
+// accountError maps validation errors of the account admin API to 400 and 409.
+func accountError(w http.ResponseWriter, err error) {
+	var policyErr *services.PasswordPolicyError
+	switch {
+	case errors.As(err, &policyErr):
+		http.Error(w, policyErr.Reason, http.StatusBadRequest)
+	case errors.Is(err, services.ErrInvalidUsername), errors.Is(err, services.ErrUnknownRole):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrUsernameTaken):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		serviceError(w, err)
+	}
+}
+
+func (h *AccountHandler) ListAccounts(w http.ResponseWriter, r *http.Request) {
+	limit, offset, _, search, ok := parsePaginationParams(r, 50)
+	if !ok {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	filter := models.AccountFilter{
+		Search: search,
+		Role:   r.URL.Query().Get("role"),
+		Limit:  limit,
+		Offset: offset,
+	}
+
+	if raw := r.URL.Query().Get("department"); raw != "" {
+		department, err := uuid.Parse(raw)
+		if err != nil {
+			clientError(w, http.StatusBadRequest)
+			return
+		}
+		filter.Department = &department
+	}
+
+	if raw := r.URL.Query().Get("disabled"); raw != "" {
+		disabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			clientError(w, http.StatusBadRequest)
+			return
+		}
+		filter.Disabled = &disabled
+	}
+
+	accounts, err := h.accountService.ListAccounts(r.Context(), filter)
 	if err != nil {
 		serverError(w, err)
 		return
 	}
 
-	writeJSON(w, http.StatusCreated, newUser)
+	writeJSON(w, http.StatusOK, accounts)
+}
+
+func (h *AccountHandler) GetAccount(w http.ResponseWriter, r *http.Request) {
+	userUUID, err := uuid.Parse(chi.URLParam(r, "userID"))
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	account, err := h.accountService.GetAccountSummary(r.Context(), userUUID)
+	if err != nil {
+		serviceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, account)
+}
+
+func (h *AccountHandler) RenameAccount(w http.ResponseWriter, r *http.Request) {
+	userUUID, err := uuid.Parse(chi.URLParam(r, "userID"))
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	var request struct {
+		Username string `json:"username"`
+	}
+
+	if !decodeJSONBody(w, r, &request) {
+		return
+	}
+
+	account, err := h.accountService.RenameAccount(r.Context(), userUUID, request.Username)
+	if err != nil {
+		accountError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, account)
+}
+
+// ResetPassword sets a generated password and returns it once
+func (h *AccountHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	userUUID, err := uuid.Parse(chi.URLParam(r, "userID"))
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	password, err := h.accountService.ResetPassword(r.Context(), userUUID)
+	if err != nil {
+		serviceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"password": password})
+}
+
+// RevokeTokens signs the account out everywhere without touching its password
+func (h *AccountHandler) RevokeTokens(w http.ResponseWriter, r *http.Request) {
+	userUUID, err := uuid.Parse(chi.URLParam(r, "userID"))
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.accountService.RevokeTokens(r.Context(), userUUID); err != nil {
+		serviceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
 }
 
 func (h *AccountHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
@@ -68,43 +207,98 @@ func (h *AccountHandler) ChangePassword(w http.ResponseWriter, r *http.Request)
 
 	err = h.accountService.ChangeAccountPassword(r.Context(), userUUID, request.New, request.Old)
 	if err != nil {
-		log.Printf("handler: %v", err)
-		http.Error(w, "Unable to change password", http.StatusInternalServerError)
+		var policyErr *services.PasswordPolicyError
+		switch {
+		case errors.As(err, &policyErr):
+			http.Error(w, policyErr.Reason, http.StatusBadRequest)
+		case err == services.ErrInvalidCredentials:
+			http.Error(w, "Old password is incorrect", http.StatusForbidden)
+		default:
+			log.Printf("handler: %v", err)
+			http.Error(w, "Unable to change password", http.StatusInternalServerError)
+		}
+		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
+// ChangeAccountStatus disables or enables the account in the URL. The older
+// PATCH /admin/accounts/status route passes the account as user_id in the body.
 func (h *AccountHandler) ChangeAccountStatus(w http.ResponseWriter, r *http.Request) {
 	var request struct {
-		Disabled bool `json:"disabled"`
+		UserID   *uuid.UUID `json:"user_id"`
+		Disabled bool       `json:"disabled"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+	if !decodeJSONBody(w, r, &request) {
 		return
 	}
 
-	defer r.Body.Close()
+	var userUUID uuid.UUID
+	if param := chi.URLParam(r, "userID"); param != "" {
+		parsed, err := uuid.Parse(param)
+		if err != nil {
+			clientError(w, http.StatusBadRequest)
+			return
+		}
+		userUUID = parsed
+	} else if request.UserID != nil {
+		userUUID = *request.UserID
+	} else {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
 
-	userID, ok := middlewares.GetUserIDFromContext(r.Context())
+	actor, ok := actorFromContext(r.Context())
 	if !ok {
 		http.Error(w, "Unable to prove your identity", http.StatusForbidden)
 		return
 	}
 
-	userUUID, err := uuid.Parse(userID)
+	account, err := h.accountService.ChangeAccountStatus(r.Context(), actor, userUUID, request.Disabled)
 	if err != nil {
-		http.Error(w, "Invalid user ID format", http.StatusForbidden)
+		serviceError(w, err)
 		return
 	}
 
-	err = h.accountService.ChangeAccountStatus(r.Context(), userUUID, request.Disabled)
+	writeJSON(w, http.StatusOK, account)
+}
+
+func (h *AccountHandler) SetAccountRoles(w http.ResponseWriter, r *http.Request) {
+	userUUID, err := uuid.Parse(chi.URLParam(r, "userID"))
 	if err != nil {
-		log.Printf("handler: %v", err)
-		http.Error(w, "Unable to change account status", http.StatusInternalServerError)
+		clientError(w, http.StatusBadRequest)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	var request struct {
+		Roles []string `json:"roles"`
+	}
+
+	if !decodeJSONBody(w, r, &request) {
+		return
+	}
+
+	if len(request.Roles) == 0 {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	account, err := h.accountService.SetAccountRoles(r.Context(), userUUID, request.Roles)
+	if err != nil {
+		if err == services.ErrUnknownRole {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		serverError(w, err)
+		return
+	}
+
+	if account == nil {
+		notFound(w)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, account)
 }
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/services"
+)
+
+type APIKeyHandler struct {
+	service *services.APIKeyService
+}
+
+func (h *APIKeyHandler) ListKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.service.ListKeys(r.Context())
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, keys)
+}
+
+// CreateKey responds with the key itself. It is not retrievable afterwards.
+func (h *APIKeyHandler) CreateKey(w http.ResponseWriter, r *http.Request) {
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	var request struct {
+		Name        string     `json:"name"`
+		Permissions []string   `json:"permissions"`
+		ExpiresAt   *time.Time `json:"expires_at"`
+	}
+
+	if !decodeJSONBody(w, r, &request) {
+		return
+	}
+
+	request.Name = strings.TrimSpace(request.Name)
+	if request.Name == "" {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	key, err := h.service.CreateKey(r.Context(), actor, request.Name, request.Permissions, request.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, services.ErrUnknownPermission) || errors.Is(err, services.ErrAPIKeyExpiryInPast) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		serviceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, key)
+}
+
+func (h *APIKeyHandler) RotateKey(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	key, err := h.service.RotateKey(r.Context(), actor, id)
+	if err != nil {
+		serviceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, key)
+}
+
+func (h *APIKeyHandler) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.RevokeKey(r.Context(), actor, id); err != nil {
+		serviceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
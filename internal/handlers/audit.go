@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/grintheone/foxygen-server/internal/services"
+)
+
+type AuditHandler struct {
+	service *services.AuditService
+}
+
+// ListEntries lists recorded mutations, optionally filtered by ?actor= and ?impersonator=
+func (h *AuditHandler) ListEntries(w http.ResponseWriter, r *http.Request) {
+	limit, offset, _, _, ok := parsePaginationParams(r, 100)
+	if !ok {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	filter := models.AuditFilter{Limit: limit, Offset: offset}
+
+	if raw := r.URL.Query().Get("actor"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			clientError(w, http.StatusBadRequest)
+			return
+		}
+		filter.ActorID = &id
+	}
+
+	if raw := r.URL.Query().Get("impersonator"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			clientError(w, http.StatusBadRequest)
+			return
+		}
+		filter.ImpersonatorID = &id
+	}
+
+	entries, err := h.service.ListEntries(r.Context(), filter)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, entries)
+}
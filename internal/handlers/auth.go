@@ -1,8 +1,14 @@
 package handlers
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/middlewares"
 	"github.com/grintheone/foxygen-server/internal/services"
 )
 
@@ -10,6 +16,34 @@ type AuthHandler struct {
 	authService *services.AuthService
 }
 
+// authError maps the errors shared by the login, refresh, mfa and password
+// reset endpoints.
+func authError(w http.ResponseWriter, err error) {
+	var throttledErr *services.ThrottledError
+	if errors.As(err, &throttledErr) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttledErr.RetryAfter.Seconds()))))
+		http.Error(w, throttledErr.Error(), http.StatusTooManyRequests)
+		return
+	}
+
+	switch {
+	case errors.Is(err, services.ErrInvalidCredentials),
+		errors.Is(err, services.ErrInvalidToken),
+		errors.Is(err, services.ErrTokenRevoked):
+		clientError(w, http.StatusUnauthorized)
+	case errors.Is(err, services.ErrInvalidMFACode):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, services.ErrAccountDisabled),
+		errors.Is(err, services.ErrMFAEnrollmentRequired):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, services.ErrMFAAlreadyEnabled),
+		errors.Is(err, services.ErrMFANotEnabled):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		serviceError(w, err)
+	}
+}
+
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var credentials struct {
 		Username string `json:"username"`
@@ -20,13 +54,14 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response, err := h.authService.Authorize(r.Context(), credentials.Username, credentials.Password)
+	response, challenge, err := h.authService.Authorize(r.Context(), credentials.Username, credentials.Password, clientIP(r))
 	if err != nil {
-		if err == services.ErrInvalidCredentials {
-			clientError(w, http.StatusUnauthorized)
-		} else {
-			serverError(w, err)
-		}
+		authError(w, err)
+		return
+	}
+
+	if challenge != nil {
+		writeJSON(w, http.StatusOK, challenge)
 		return
 	}
 
@@ -50,7 +85,116 @@ func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 
 	response, err := h.authService.RefreshAccessToken(r.Context(), request.RefreshToken)
 	if err != nil {
-		serverError(w, err)
+		authError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+type mfaCodeRequest struct {
+	MFAToken string `json:"mfaToken"`
+	Code     string `json:"code"`
+}
+
+// VerifyMFA completes a login with a TOTP or recovery code
+func (h *AuthHandler) VerifyMFA(w http.ResponseWriter, r *http.Request) {
+	var request mfaCodeRequest
+
+	if !decodeJSONBody(w, r, &request) {
+		return
+	}
+
+	if request.MFAToken == "" || request.Code == "" {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.authService.VerifyMFA(r.Context(), request.MFAToken, request.Code, clientIP(r))
+	if err != nil {
+		authError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// BeginMFAEnrollment returns a new TOTP secret for an account that must
+// enroll before it can finish logging in
+func (h *AuthHandler) BeginMFAEnrollment(w http.ResponseWriter, r *http.Request) {
+	var request mfaCodeRequest
+
+	if !decodeJSONBody(w, r, &request) {
+		return
+	}
+
+	if request.MFAToken == "" {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	enrollment, err := h.authService.BeginMFAEnrollment(r.Context(), request.MFAToken)
+	if err != nil {
+		authError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, enrollment)
+}
+
+func (h *AuthHandler) ConfirmMFAEnrollment(w http.ResponseWriter, r *http.Request) {
+	var request mfaCodeRequest
+
+	if !decodeJSONBody(w, r, &request) {
+		return
+	}
+
+	if request.MFAToken == "" || request.Code == "" {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.authService.ConfirmMFAEnrollment(r.Context(), request.MFAToken, request.Code, clientIP(r))
+	if err != nil {
+		authError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// JWKS publishes the token verification keys
+func (h *AuthHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, h.authService.JWKS())
+}
+
+// Impersonate issues a short-lived access token acting as another user.
+// Pass ?mode=read-write to allow changes, the default is read-only.
+func (h *AuthHandler) Impersonate(w http.ResponseWriter, r *http.Request) {
+	targetID, err := uuid.Parse(chi.URLParam(r, "userID"))
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	mode := r.URL.Query().Get("mode")
+	if mode != "" && mode != services.ImpersonationReadOnly && mode != services.ImpersonationReadWrite {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	username, _ := middlewares.GetUsernameFromContext(r.Context())
+
+	response, err := h.authService.Impersonate(r.Context(), actor, username, targetID, mode == services.ImpersonationReadWrite, clientIP(r))
+	if err != nil {
+		serviceError(w, err)
 		return
 	}
 
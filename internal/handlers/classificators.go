@@ -105,9 +105,15 @@ func (h *ClassificatorHandler) RemoveClassificatorByID(w http.ResponseWriter, r
 		return
 	}
 
-	err = h.classificatorService.RemoveClassificatorByID(r.Context(), uuid)
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	err = h.classificatorService.RemoveClassificatorByID(r.Context(), actor, uuid)
 	if err != nil {
-		serverError(w, err)
+		serviceError(w, err)
 		return
 	}
 
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/grintheone/foxygen-server/internal/services"
+)
+
+type ClientImportHandler struct {
+	service *services.ClientImportService
+}
+
+type applyImportRequest struct {
+	SkipInvalid bool `json:"skip_invalid"`
+}
+
+// clientImportError maps unreadable files and bad mappings to 400 and imports
+// that can't be applied in their current state to 409.
+func clientImportError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrUnreadableSpreadsheet),
+		errors.Is(err, services.ErrUnsupportedImportFile),
+		errors.Is(err, services.ErrImportTooLarge),
+		errors.Is(err, services.ErrEmptyImport),
+		errors.Is(err, services.ErrImportTitleColumn),
+		errors.Is(err, services.ErrUnknownImportField),
+		errors.Is(err, services.ErrUnknownImportColumn):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrImportApplied),
+		errors.Is(err, services.ErrImportHasErrors):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		serviceError(w, err)
+	}
+}
+
+// PreviewImport takes a CSV or XLSX "file" and an optional "mapping" form
+// field, a JSON object of import fields to column headers.
+func (h *ClientImportHandler) PreviewImport(w http.ResponseWriter, r *http.Request) {
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, services.MaxImportFileSize+1<<20)
+	if err := r.ParseMultipartForm(services.MaxImportFileSize); err != nil {
+		clientError(w, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var mapping models.ImportColumns
+	if raw := r.FormValue("mapping"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+			http.Error(w, "mapping must be a JSON object of fields to column headers", http.StatusBadRequest)
+			return
+		}
+	}
+
+	file, fileHeader, err := r.FormFile("file")
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	if fileHeader.Size > services.MaxImportFileSize {
+		clientError(w, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	clientImport, err := h.service.PreviewImport(r.Context(), actor, fileHeader.Filename, data, mapping)
+	if err != nil {
+		clientImportError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, clientImport)
+}
+
+func (h *ClientImportHandler) GetImport(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	clientImport, err := h.service.GetImport(r.Context(), actor, id)
+	if err != nil {
+		clientImportError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, clientImport)
+}
+
+// ApplyImport writes a previewed import. With {"skip_invalid": true} rows
+// with errors are left out instead of failing the import.
+func (h *ClientImportHandler) ApplyImport(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	var payload applyImportRequest
+	if r.ContentLength != 0 && !decodeJSONBody(w, r, &payload) {
+		return
+	}
+
+	clientImport, err := h.service.ApplyImport(r.Context(), actor, id, payload.SkipInvalid)
+	if err != nil {
+		clientImportError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, clientImport)
+}
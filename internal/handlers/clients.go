@@ -1,7 +1,11 @@
 package handlers
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
@@ -13,6 +17,16 @@ type ClientHandler struct {
 	clientService *services.ClientService
 }
 
+// clientWriteError maps references to missing regions or laboratory systems
+// to 400.
+func clientWriteError(w http.ResponseWriter, err error) {
+	if errors.Is(err, services.ErrUnknownClientReference) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	serverError(w, err)
+}
+
 func (h *ClientHandler) ListClients(w http.ResponseWriter, r *http.Request) {
 	limit, offset, sortByTitle, search, ok := parsePaginationParams(r, 100000)
 	if !ok {
@@ -38,7 +52,7 @@ func (h *ClientHandler) CreateClient(w http.ResponseWriter, r *http.Request) {
 
 	err := h.clientService.CreateClient(r.Context(), payload)
 	if err != nil {
-		serverError(w, err)
+		clientWriteError(w, err)
 		return
 	}
 
@@ -67,7 +81,7 @@ func (h *ClientHandler) UpdateClient(w http.ResponseWriter, r *http.Request) {
 
 	updated, err := h.clientService.UpdateClient(r.Context(), uuid, payload)
 	if err != nil {
-		serverError(w, err)
+		clientWriteError(w, err)
 		return
 	}
 
@@ -88,9 +102,15 @@ func (h *ClientHandler) DeleteClient(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.clientService.DeleteClient(r.Context(), uuid)
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	err = h.clientService.DeleteClient(r.Context(), actor, uuid)
 	if err != nil {
-		serverError(w, err)
+		serviceError(w, err)
 		return
 	}
 
@@ -119,3 +139,215 @@ func (h *ClientHandler) GetClientByID(w http.ResponseWriter, r *http.Request) {
 
 	writeJSON(w, http.StatusOK, client)
 }
+
+const defaultNearbyRadiusKm = 25
+
+func parseFloatParam(r *http.Request, name string) (float64, bool) {
+	value, err := strconv.ParseFloat(r.URL.Query().Get(name), 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, false
+	}
+	return value, true
+}
+
+func clientLocationError(w http.ResponseWriter, err error) {
+	if errors.Is(err, services.ErrInvalidCoordinates) || errors.Is(err, services.ErrInvalidRadius) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	serverError(w, err)
+}
+
+// NearbyClients lists clients within ?radius= km (default 25) of ?lat= and
+// ?lng=, sorted by great-circle distance.
+func (h *ClientHandler) NearbyClients(w http.ResponseWriter, r *http.Request) {
+	limit, _, _, _, ok := parsePaginationParams(r, 50)
+	if !ok {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	lat, okLat := parseFloatParam(r, "lat")
+	lng, okLng := parseFloatParam(r, "lng")
+	if !okLat || !okLng {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	radius := float64(defaultNearbyRadiusKm)
+	if r.URL.Query().Get("radius") != "" {
+		radius, ok = parseFloatParam(r, "radius")
+		if !ok {
+			clientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	clients, err := h.clientService.NearbyClients(r.Context(), lat, lng, radius, limit)
+	if err != nil {
+		clientLocationError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, clients)
+}
+
+// ClientMap returns map pins for ?bbox=minLng,minLat,maxLng,maxLat with the
+// open ticket count and most urgent status of each client.
+func (h *ClientHandler) ClientMap(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(r.URL.Query().Get("bbox"), ",")
+	if len(parts) != 4 {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	var coords [4]float64
+	for i, part := range parts {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+			clientError(w, http.StatusBadRequest)
+			return
+		}
+		coords[i] = value
+	}
+
+	pins, err := h.clientService.ClientPins(r.Context(), models.BoundingBox{
+		MinLng: coords[0],
+		MinLat: coords[1],
+		MaxLng: coords[2],
+		MaxLat: coords[3],
+	})
+	if err != nil {
+		clientLocationError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, pins)
+}
+
+// MyClients lists the clients the current user manages.
+func (h *ClientHandler) MyClients(w http.ResponseWriter, r *http.Request) {
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	clients, err := h.clientService.ListManagedClients(r.Context(), actor.UserID)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, clients)
+}
+
+// ReassignManager moves clients from one manager to another, for when a
+// manager leaves.
+func (h *ClientHandler) ReassignManager(w http.ResponseWriter, r *http.Request) {
+	var payload models.ManagerReassignment
+
+	if !decodeJSONBody(w, r, &payload) {
+		return
+	}
+
+	if payload.From == uuid.Nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	count, err := h.clientService.ReassignManager(r.Context(), actor, payload)
+	if err != nil {
+		if errors.Is(err, services.ErrSameManager) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		serviceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]int64{"updated": count})
+}
+
+// DuplicateClients lists client pairs that are probably the same laboratory.
+func (h *ClientHandler) DuplicateClients(w http.ResponseWriter, r *http.Request) {
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	candidates, err := h.clientService.DuplicateCandidates(r.Context(), actor)
+	if err != nil {
+		serviceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, candidates)
+}
+
+// MergeClients merges the clients listed in the body into the one in the URL.
+func (h *ClientHandler) MergeClients(w http.ResponseWriter, r *http.Request) {
+	targetID, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	var payload models.ClientMerge
+
+	if !decodeJSONBody(w, r, &payload) {
+		return
+	}
+
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	result, err := h.clientService.MergeClients(r.Context(), actor, targetID, payload.Duplicates)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidMerge) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		serviceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// ClientOverview returns the client page in one response. ?closed= sets how
+// many of the latest closed tickets are included (default 10).
+func (h *ClientHandler) ClientOverview(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	closedLimit := 10
+	if raw := r.URL.Query().Get("closed"); raw != "" {
+		closedLimit, err = strconv.Atoi(raw)
+		if err != nil || closedLimit < 0 {
+			clientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	overview, err := h.clientService.ClientOverview(r.Context(), id, closedLimit)
+	if err != nil {
+		serviceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, overview)
+}
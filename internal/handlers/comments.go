@@ -43,6 +43,15 @@ func (h *CommentHandler) NewComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	// Comments are always authored by the caller
+	request.AuthorID = actor.UserID
+
 	createdComment, err := h.commentService.NewComment(r.Context(), request)
 	if err != nil {
 		serverError(w, err)
@@ -60,9 +69,15 @@ func (h *CommentHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.commentService.DeleteComment(r.Context(), id)
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	err := h.commentService.DeleteComment(r.Context(), actor, id)
 	if err != nil {
-		serverError(w, err)
+		serviceError(w, err)
 		return
 	}
 
@@ -89,9 +104,15 @@ func (h *CommentHandler) UpdateComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.commentService.UpdateComment(r.Context(), ID, payload)
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	err := h.commentService.UpdateComment(r.Context(), actor, ID, payload)
 	if err != nil {
-		serverError(w, err)
+		serviceError(w, err)
 		return
 	}
 
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
@@ -13,6 +14,27 @@ type ContactHandler struct {
 	contactService *services.ContactService
 }
 
+// contactError maps invalid phones and emails to 400 and a second contact
+// with the same phone or email at a client to 409.
+func contactError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidPhone),
+		errors.Is(err, services.ErrInvalidEmail):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrDuplicateContact):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		serviceError(w, err)
+	}
+}
+
+func writeVCard(w http.ResponseWriter, filename string, data []byte) {
+	w.Header().Set("Content-Type", "text/vcard; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
 func (h *ContactHandler) GetAllByClientID(w http.ResponseWriter, r *http.Request) {
 	clientID := chi.URLParam(r, "clientID")
 
@@ -45,7 +67,7 @@ func (h *ContactHandler) CreateContact(w http.ResponseWriter, r *http.Request) {
 
 	err := h.contactService.CreateContact(r.Context(), body)
 	if err != nil {
-		serverError(w, err)
+		contactError(w, err)
 		return
 	}
 
@@ -66,9 +88,15 @@ func (h *ContactHandler) DeleteContact(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.contactService.DeleteContact(r.Context(), uuid)
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	err = h.contactService.DeleteContact(r.Context(), actor, uuid)
 	if err != nil {
-		serverError(w, err)
+		serviceError(w, err)
 		return
 	}
 
@@ -97,7 +125,7 @@ func (h *ContactHandler) UpdateContact(w http.ResponseWriter, r *http.Request) {
 
 	updated, err := h.contactService.UpdateContact(r.Context(), uuid, updates)
 	if err != nil {
-		serverError(w, err)
+		contactError(w, err)
 		return
 	}
 
@@ -108,3 +136,86 @@ func (h *ContactHandler) UpdateContact(w http.ResponseWriter, r *http.Request) {
 
 	writeJSON(w, http.StatusOK, updated)
 }
+
+// SearchContacts finds contacts by ?phone= in any format.
+func (h *ContactHandler) SearchContacts(w http.ResponseWriter, r *http.Request) {
+	phone := r.URL.Query().Get("phone")
+	if phone == "" {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	contacts, err := h.contactService.SearchByPhone(r.Context(), phone)
+	if err != nil {
+		contactError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, contacts)
+}
+
+func (h *ContactHandler) DuplicateContacts(w http.ResponseWriter, r *http.Request) {
+	clientID, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	duplicates, err := h.contactService.ListDuplicateContacts(r.Context(), clientID)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, duplicates)
+}
+
+func (h *ContactHandler) ContactVCard(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	data, err := h.contactService.ContactVCard(r.Context(), id)
+	if err != nil {
+		serviceError(w, err)
+		return
+	}
+
+	writeVCard(w, id.String()+".vcf", data)
+}
+
+// ClientContactsVCard exports all contacts of a client as one vCard file.
+func (h *ContactHandler) ClientContactsVCard(w http.ResponseWriter, r *http.Request) {
+	clientID, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	data, err := h.contactService.ClientContactsVCard(r.Context(), clientID)
+	if err != nil {
+		serviceError(w, err)
+		return
+	}
+
+	writeVCard(w, clientID.String()+"-contacts.vcf", data)
+}
+
+// NormalizeContacts rewrites stored phones and emails in normalized form.
+func (h *ContactHandler) NormalizeContacts(w http.ResponseWriter, r *http.Request) {
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	result, err := h.contactService.NormalizeContacts(r.Context(), actor)
+	if err != nil {
+		serviceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
@@ -44,3 +44,27 @@ func (h *DepartmentHandler) GetDepartmentByID(w http.ResponseWriter, r *http.Req
 
 	writeJSON(w, http.StatusOK, department)
 }
+
+// GetWorkload serves the workload dashboard: per user active tickets by
+// status, overdue count, current ticket, next assignment and today's absences.
+func (h *DepartmentHandler) GetWorkload(w http.ResponseWriter, r *http.Request) {
+	departmentID, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	workload, err := h.service.GetWorkload(r.Context(), actor, departmentID)
+	if err != nil {
+		serviceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, workload)
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
@@ -66,9 +67,15 @@ func (h *DeviceHandler) RemoveDeviceByID(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = h.deviceService.RemoveDeviceByID(r.Context(), uuid)
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	err = h.deviceService.RemoveDeviceByID(r.Context(), actor, uuid)
 	if err != nil {
-		serverError(w, err)
+		serviceError(w, err)
 		return
 	}
 
@@ -105,18 +112,29 @@ func (h *DeviceHandler) UpdateDeviceByID(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
 	var body models.DeviceUpdates
 
 	if !decodeJSONBody(w, r, &body) {
 		return
 	}
 
-	updated, err := h.deviceService.UpdateDeviceByID(r.Context(), uuid, body)
+	updated, err := h.deviceService.UpdateDeviceByID(r.Context(), actor, uuid, body)
 	if err != nil {
 		serverError(w, err)
 		return
 	}
 
+	if updated == nil {
+		notFound(w)
+		return
+	}
+
 	writeJSON(w, http.StatusOK, updated)
 }
 
@@ -138,3 +156,56 @@ func (h *DeviceHandler) GetDeviceRemoteOptions(w http.ResponseWriter, r *http.Re
 
 	writeJSON(w, http.StatusOK, options)
 }
+
+// relocationError maps an invalid relocation target or period to 400.
+func relocationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrRelocationSameClient),
+		errors.Is(err, services.ErrUnknownRelocationTarget),
+		errors.Is(err, services.ErrInvalidRelocationPeriod):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		serviceError(w, err)
+	}
+}
+
+// GetDeviceTimeline returns the installation, agreements, service tickets
+// and edits of a device as one ordered list.
+func (h *DeviceHandler) GetDeviceTimeline(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	events, err := h.deviceService.GetDeviceTimeline(r.Context(), id)
+	if err != nil {
+		serviceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, events)
+}
+
+// RelocateDevice moves a device to another client.
+func (h *DeviceHandler) RelocateDevice(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	var payload models.DeviceRelocation
+
+	if !decodeJSONBody(w, r, &payload) {
+		return
+	}
+
+	result, err := h.deviceService.RelocateDevice(r.Context(), id, payload)
+	if err != nil {
+		relocationError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, result)
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/grintheone/foxygen-server/internal/services"
+)
+
+type GeocodingHandler struct {
+	service *services.GeocodingService
+}
+
+// geocodingError maps validation errors to 400, a disabled geocoder to 503
+// and a run already in progress to 409.
+func geocodingError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidGeocodeState),
+		errors.Is(err, services.ErrInvalidCoordinates):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrGeocodingDisabled):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case errors.Is(err, services.ErrGeocodingRunning):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		serviceError(w, err)
+	}
+}
+
+// ListGeocodes lists geocoded client addresses by ?status=, the low
+// confidence matches waiting for review by default.
+func (h *GeocodingHandler) ListGeocodes(w http.ResponseWriter, r *http.Request) {
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	geocodes, err := h.service.ListGeocodes(r.Context(), actor, r.URL.Query().Get("status"))
+	if err != nil {
+		geocodingError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, geocodes)
+}
+
+// ApproveGeocode accepts a pending match. The body may carry corrected
+// coordinates.
+func (h *GeocodingHandler) ApproveGeocode(w http.ResponseWriter, r *http.Request) {
+	clientID, err := uuid.Parse(chi.URLParam(r, "clientID"))
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	var approval models.GeocodeApproval
+	if r.ContentLength != 0 && !decodeJSONBody(w, r, &approval) {
+		return
+	}
+
+	if err := h.service.ApproveGeocode(r.Context(), actor, clientID, approval); err != nil {
+		geocodingError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *GeocodingHandler) RejectGeocode(w http.ResponseWriter, r *http.Request) {
+	clientID, err := uuid.Parse(chi.URLParam(r, "clientID"))
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.RejectGeocode(r.Context(), actor, clientID); err != nil {
+		geocodingError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RunGeocoding starts a geocoding run without waiting for the schedule.
+func (h *GeocodingHandler) RunGeocoding(w http.ResponseWriter, r *http.Request) {
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.RunNow(actor); err != nil {
+		geocodingError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -1,11 +1,19 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/middlewares"
+	"github.com/grintheone/foxygen-server/internal/services"
 )
 
 // The serverError helper writes an error message and stack trace to the errorLog,
@@ -27,6 +35,66 @@ func notFound(w http.ResponseWriter) {
 	http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
 }
 
+// serviceError maps policy errors returned by services to 403 with the reason
+// or 404, and anything else to a generic 500.
+func serviceError(w http.ResponseWriter, err error) {
+	var forbiddenErr *services.ForbiddenError
+
+	switch {
+	case errors.As(err, &forbiddenErr):
+		http.Error(w, forbiddenErr.Reason, http.StatusForbidden)
+	case errors.Is(err, services.ErrNotFound):
+		notFound(w)
+	default:
+		serverError(w, err)
+	}
+}
+
+// actorFromContext builds the service-level actor from the token claims
+// stored in the request context by the auth middleware.
+func actorFromContext(ctx context.Context) (services.Actor, bool) {
+	userID, ok := middlewares.GetUserIDFromContext(ctx)
+	if !ok {
+		return services.Actor{}, false
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return services.Actor{}, false
+	}
+
+	role, _ := middlewares.GetUserRoleFromContext(ctx)
+	roles, _ := middlewares.GetUserRolesFromContext(ctx)
+	permissions, _ := middlewares.GetPermissionsFromContext(ctx)
+
+	actor := services.Actor{
+		Type:        middlewares.GetActorTypeFromContext(ctx),
+		UserID:      userUUID,
+		Role:        role,
+		Roles:       roles,
+		Permissions: permissions,
+	}
+
+	if impersonatorID, ok := middlewares.GetImpersonatorIDFromContext(ctx); ok {
+		if parsed, err := uuid.Parse(impersonatorID); err == nil {
+			actor.ImpersonatorID = &parsed
+		}
+	}
+
+	return actor, true
+}
+
+// clientIP returns the address of the connecting peer without the port.
+// The server terminates TLS itself, so forwarding headers are not trusted.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
 func decodeJSONBody[T any](w http.ResponseWriter, r *http.Request, dst *T) bool {
 	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
 		log.Print(err)
@@ -72,3 +140,38 @@ func parsePaginationParams(r *http.Request, defaultLimit int) (limit int, offset
 
 	return limit, offset, sortByTitle, search, true
 }
+
+// parseTimeParam reads a query parameter given as RFC 3339 or a plain date
+// (midnight UTC). A missing parameter yields the zero time.
+func parseTimeParam(r *http.Request, name string) (time.Time, bool) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return time.Time{}, true
+	}
+
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t.UTC(), true
+	}
+
+	t, err := time.Parse(time.DateOnly, raw)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return t, true
+}
+
+// parseUUIDParam reads an optional UUID query parameter.
+func parseUUIDParam(r *http.Request, name string) (*uuid.UUID, bool) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return nil, true
+	}
+
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return nil, false
+	}
+
+	return &id, true
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/services"
+)
+
+type LabelHandler struct {
+	service *services.LabelService
+}
+
+func writeLabel(w http.ResponseWriter, contentType, filename string, data []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `inline; filename="`+filename+`"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+func (h *LabelHandler) DeviceLabelPNG(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	data, err := h.service.DeviceLabelPNG(r.Context(), id)
+	if err != nil {
+		serviceError(w, err)
+		return
+	}
+
+	writeLabel(w, "image/png", id.String()+".png", data)
+}
+
+func (h *LabelHandler) DeviceLabelPDF(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	data, err := h.service.DeviceLabelPDF(r.Context(), id)
+	if err != nil {
+		serviceError(w, err)
+		return
+	}
+
+	writeLabel(w, "application/pdf", id.String()+".pdf", data)
+}
+
+// ClientLabelSheet prints the labels of all devices at a client on A4 sheets.
+func (h *LabelHandler) ClientLabelSheet(w http.ResponseWriter, r *http.Request) {
+	clientID, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	data, err := h.service.ClientLabelSheet(r.Context(), clientID)
+	if err != nil {
+		serviceError(w, err)
+		return
+	}
+
+	writeLabel(w, "application/pdf", clientID.String()+"-labels.pdf", data)
+}
+
+// ResolveDevice opens the device behind ?code=, the payload of a scanned
+// label or a serial number.
+func (h *LabelHandler) ResolveDevice(w http.ResponseWriter, r *http.Request) {
+	resolution, err := h.service.ResolveDevice(r.Context(), r.URL.Query().Get("code"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrEmptyScan):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, services.ErrAmbiguousSerial):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			serviceError(w, err)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resolution)
+}
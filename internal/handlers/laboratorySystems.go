@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/grintheone/foxygen-server/internal/services"
+)
+
+type LaboratorySystemHandler struct {
+	service *services.LaboratorySystemService
+}
+
+type lisConnectionRequest struct {
+	DeviceID         uuid.UUID    `json:"device_id"`
+	LaboratorySystem uuid.UUID    `json:"laboratory_system"`
+	Protocol         string       `json:"protocol"`
+	Parameters       models.JSONB `json:"parameters"`
+	Comment          *string      `json:"comment"`
+}
+
+// laboratorySystemError maps validation errors to 400 and a second connection
+// of the same device to the same system to 409.
+func laboratorySystemError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidLISProtocol),
+		errors.Is(err, services.ErrMissingLISTitle),
+		errors.Is(err, services.ErrUnknownLISConnectionTarget):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrDuplicateLISConnection):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		serviceError(w, err)
+	}
+}
+
+func (h *LaboratorySystemHandler) ListLaboratorySystems(w http.ResponseWriter, r *http.Request) {
+	search := strings.TrimSpace(r.URL.Query().Get("search"))
+
+	systems, err := h.service.ListLaboratorySystems(r.Context(), search)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, systems)
+}
+
+func (h *LaboratorySystemHandler) GetLaboratorySystem(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	system, err := h.service.GetLaboratorySystem(r.Context(), id)
+	if err != nil {
+		serviceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, system)
+}
+
+func (h *LaboratorySystemHandler) CreateLaboratorySystem(w http.ResponseWriter, r *http.Request) {
+	var payload models.LaboratorySystem
+
+	if !decodeJSONBody(w, r, &payload) {
+		return
+	}
+
+	created, err := h.service.CreateLaboratorySystem(r.Context(), payload)
+	if err != nil {
+		laboratorySystemError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (h *LaboratorySystemHandler) UpdateLaboratorySystem(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	var payload models.LaboratorySystemUpdate
+
+	if !decodeJSONBody(w, r, &payload) {
+		return
+	}
+
+	updated, err := h.service.UpdateLaboratorySystem(r.Context(), id, payload)
+	if err != nil {
+		laboratorySystemError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (h *LaboratorySystemHandler) DeleteLaboratorySystem(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.DeleteLaboratorySystem(r.Context(), actor, id); err != nil {
+		serviceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *LaboratorySystemHandler) CreateConnection(w http.ResponseWriter, r *http.Request) {
+	var request lisConnectionRequest
+
+	if !decodeJSONBody(w, r, &request) {
+		return
+	}
+
+	if request.DeviceID == uuid.Nil || request.LaboratorySystem == uuid.Nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	created, err := h.service.CreateConnection(r.Context(), models.LISConnection{
+		DeviceID:         request.DeviceID,
+		LaboratorySystem: request.LaboratorySystem,
+		Protocol:         request.Protocol,
+		Parameters:       request.Parameters,
+		Comment:          request.Comment,
+	})
+	if err != nil {
+		laboratorySystemError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (h *LaboratorySystemHandler) UpdateConnection(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	var payload models.LISConnectionUpdate
+
+	if !decodeJSONBody(w, r, &payload) {
+		return
+	}
+
+	updated, err := h.service.UpdateConnection(r.Context(), id, payload)
+	if err != nil {
+		laboratorySystemError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (h *LaboratorySystemHandler) DeleteConnection(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.DeleteConnection(r.Context(), id); err != nil {
+		serviceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ClientSetup returns a client's laboratory system and the LIS connections of
+// the devices under its active agreements.
+func (h *LaboratorySystemHandler) ClientSetup(w http.ResponseWriter, r *http.Request) {
+	clientID, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	setup, err := h.service.ClientSetup(r.Context(), clientID)
+	if err != nil {
+		serviceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, setup)
+}
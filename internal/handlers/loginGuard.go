@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/grintheone/foxygen-server/internal/services"
+)
+
+type LoginGuardHandler struct {
+	service *services.LoginGuardService
+}
+
+func (h *LoginGuardHandler) ListLockouts(w http.ResponseWriter, r *http.Request) {
+	lockouts, err := h.service.ListLockouts(r.Context())
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, lockouts)
+}
+
+// ClearLockout expects the key as listed, e.g. "user:jdoe" or "ip:10.0.0.7".
+func (h *LoginGuardHandler) ClearLockout(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "key")
+	if !strings.HasPrefix(key, "user:") && !strings.HasPrefix(key, "ip:") {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	cleared, err := h.service.ClearLockout(r.Context(), actor, key)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	if !cleared {
+		notFound(w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *LoginGuardHandler) ListAuditEntries(w http.ResponseWriter, r *http.Request) {
+	limit, offset, _, _, ok := parsePaginationParams(r, 100)
+	if !ok {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	entries, err := h.service.ListAuditEntries(r.Context(), r.URL.Query().Get("username"), limit, offset)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, entries)
+}
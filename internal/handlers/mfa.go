@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/middlewares"
+	"github.com/grintheone/foxygen-server/internal/services"
+)
+
+// MFAHandler manages the second factor of the signed in account
+type MFAHandler struct {
+	service *services.MFAService
+}
+
+func (h *MFAHandler) Status(w http.ResponseWriter, r *http.Request) {
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	status, err := h.service.Status(r.Context(), actor)
+	if err != nil {
+		authError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (h *MFAHandler) BeginEnrollment(w http.ResponseWriter, r *http.Request) {
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	username, _ := middlewares.GetUsernameFromContext(r.Context())
+
+	enrollment, err := h.service.BeginEnrollment(r.Context(), actor.UserID, username)
+	if err != nil {
+		authError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, enrollment)
+}
+
+func (h *MFAHandler) ConfirmEnrollment(w http.ResponseWriter, r *http.Request) {
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	var request struct {
+		Code string `json:"code"`
+	}
+
+	if !decodeJSONBody(w, r, &request) {
+		return
+	}
+
+	codes, err := h.service.ConfirmEnrollment(r.Context(), actor.UserID, request.Code)
+	if err != nil {
+		authError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string][]string{"recoveryCodes": codes})
+}
+
+func (h *MFAHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	var request struct {
+		Code string `json:"code"`
+	}
+
+	if !decodeJSONBody(w, r, &request) {
+		return
+	}
+
+	codes, err := h.service.RegenerateRecoveryCodes(r.Context(), actor.UserID, request.Code)
+	if err != nil {
+		authError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string][]string{"recoveryCodes": codes})
+}
+
+func (h *MFAHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	var request struct {
+		Code string `json:"code"`
+	}
+
+	if !decodeJSONBody(w, r, &request) {
+		return
+	}
+
+	if err := h.service.Disable(r.Context(), actor, request.Code); err != nil {
+		authError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResetAccountMFA lets an administrator remove the second factor of an
+// account whose owner lost their authenticator
+func (h *MFAHandler) ResetAccountMFA(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(chi.URLParam(r, "userID"))
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.Reset(r.Context(), userID); err != nil {
+		serverError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/grintheone/foxygen-server/internal/services"
+)
+
+// oidcStateCookie binds a login to the browser that started it, so a callback
+// replayed into another browser is refused
+const (
+	oidcStateCookie     = "foxygen_oidc_state"
+	oidcStateCookiePath = "/auth/oidc"
+)
+
+// OIDCHandler serves single sign-on. The service is nil when OIDC is not configured.
+type OIDCHandler struct {
+	service *services.OIDCService
+}
+
+// Login redirects to the identity provider
+func (h *OIDCHandler) Login(w http.ResponseWriter, r *http.Request) {
+	if h.service == nil {
+		notFound(w)
+		return
+	}
+
+	authorizationURL, state, err := h.service.BeginLogin(r.Context())
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	setOIDCStateCookie(w, state, int(services.OIDCStateTTL/time.Second))
+	http.Redirect(w, r, authorizationURL, http.StatusFound)
+}
+
+// Callback finishes the login and responds like /auth/login
+func (h *OIDCHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	if h.service == nil {
+		notFound(w)
+		return
+	}
+
+	var browserState string
+	if cookie, err := r.Cookie(oidcStateCookie); err == nil {
+		browserState = cookie.Value
+	}
+	setOIDCStateCookie(w, "", -1)
+
+	query := r.URL.Query()
+	if idpError := query.Get("error"); idpError != "" {
+		log.Printf("oidc: identity provider returned %s: %s", idpError, query.Get("error_description"))
+		http.Error(w, services.ErrOIDCLogin.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	code, state := query.Get("code"), query.Get("state")
+	if code == "" || state == "" {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	response, challenge, err := h.service.CompleteLogin(r.Context(), code, state, browserState, clientIP(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidOIDCState):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, services.ErrOIDCLogin):
+			log.Print(err)
+			http.Error(w, services.ErrOIDCLogin.Error(), http.StatusUnauthorized)
+		case errors.Is(err, services.ErrOIDCNoAccount):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			authError(w, err)
+		}
+		return
+	}
+
+	if challenge != nil {
+		writeJSON(w, http.StatusOK, challenge)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// setOIDCStateCookie sets the login state cookie; a negative maxAge clears it
+func setOIDCStateCookie(w http.ResponseWriter, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    value,
+		Path:     oidcStateCookiePath,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
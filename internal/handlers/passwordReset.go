@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/grintheone/foxygen-server/internal/services"
+)
+
+type PasswordResetHandler struct {
+	service *services.PasswordResetService
+}
+
+// RequestReset always answers 202 so the response doesn't reveal whether the
+// account exists, or 429 once the username or address made too many requests.
+func (h *PasswordResetHandler) RequestReset(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Username string `json:"username"`
+	}
+
+	if !decodeJSONBody(w, r, &request) {
+		return
+	}
+
+	if request.Username == "" {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.RequestReset(r.Context(), request.Username, clientIP(r)); err != nil {
+		authError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *PasswordResetHandler) ConfirmReset(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	if !decodeJSONBody(w, r, &request) {
+		return
+	}
+
+	if request.Token == "" {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err := h.service.ConfirmReset(r.Context(), request.Token, request.Password)
+	if err != nil {
+		var policyErr *services.PasswordPolicyError
+		switch {
+		case errors.As(err, &policyErr):
+			http.Error(w, policyErr.Reason, http.StatusBadRequest)
+		case err == services.ErrInvalidResetToken:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			serverError(w, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/grintheone/foxygen-server/internal/services"
+)
+
+type ReportHandler struct {
+	service *services.ReportService
+}
+
+// EngineerReport serves per engineer KPIs for tickets closed between ?from=
+// and ?to= (dates or RFC 3339, by default the current month) of ?department=.
+// ?format=csv returns a spreadsheet with one column per ticket reason.
+func (h *ReportHandler) EngineerReport(w http.ResponseWriter, r *http.Request) {
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	from, okFrom := parseTimeParam(r, "from")
+	to, okTo := parseTimeParam(r, "to")
+	department, okDepartment := parseUUIDParam(r, "department")
+	if !okFrom || !okTo || !okDepartment {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now().UTC()
+	if from.IsZero() {
+		from = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	if to.IsZero() {
+		to = now
+	}
+
+	reports, err := h.service.EngineerReports(r.Context(), actor, models.ReportFilter{
+		From:       from,
+		To:         to,
+		Department: department,
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidReportPeriod) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		serviceError(w, err)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "csv" {
+		writeEngineerReportCSV(w, reports, from, to)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, reports)
+}
+
+func formatOptionalFloat(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*value, 'f', 2, 64)
+}
+
+func writeEngineerReportCSV(w http.ResponseWriter, reports []*models.EngineerReport, from, to time.Time) {
+	reasonSet := map[string]struct{}{}
+	for _, report := range reports {
+		for reason := range report.Reasons {
+			reasonSet[reason] = struct{}{}
+		}
+	}
+	reasons := make([]string, 0, len(reasonSet))
+	for reason := range reasonSet {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+
+	filename := "engineers_" + from.Format(time.DateOnly) + "_" + to.Format(time.DateOnly) + ".csv"
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	writer := csv.NewWriter(w)
+
+	header := []string{
+		"user_id", "first_name", "last_name", "tickets_closed",
+		"median_assign_to_start_minutes", "median_start_to_finish_minutes",
+		"on_time_ratio", "follow_up_tickets", "double_signed_ratio",
+	}
+	header = append(header, reasons...)
+
+	if err := writer.Write(header); err != nil {
+		log.Printf("handler: writing csv: %v", err)
+		return
+	}
+
+	for _, report := range reports {
+		row := []string{
+			report.UserID.String(),
+			report.FirstName,
+			report.LastName,
+			strconv.Itoa(report.TicketsClosed),
+			formatOptionalFloat(report.MedianAssignToStartMinutes),
+			formatOptionalFloat(report.MedianStartToFinishMinutes),
+			formatOptionalFloat(report.OnTimeRatio),
+			strconv.Itoa(report.FollowUpTickets),
+			strconv.FormatFloat(report.DoubleSignedRatio, 'f', 2, 64),
+		}
+		for _, reason := range reasons {
+			row = append(row, strconv.Itoa(report.Reasons[reason]))
+		}
+
+		if err := writer.Write(row); err != nil {
+			log.Printf("handler: writing csv: %v", err)
+			return
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		log.Printf("handler: writing csv: %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/grintheone/foxygen-server/internal/services"
+)
+
+type RoleHandler struct {
+	service *services.RoleService
+}
+
+func (h *RoleHandler) ListPermissions(w http.ResponseWriter, r *http.Request) {
+	permissions, err := h.service.ListPermissions(r.Context())
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, permissions)
+}
+
+func (h *RoleHandler) ListRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.service.ListRoles(r.Context())
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, roles)
+}
+
+func (h *RoleHandler) CreateRole(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Name        string   `json:"name"`
+		Description string   `json:"description"`
+		Permissions []string `json:"permissions"`
+	}
+
+	if !decodeJSONBody(w, r, &request) {
+		return
+	}
+
+	if request.Name == "" {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	role, err := h.service.CreateRole(r.Context(), models.Role{Name: request.Name, Description: request.Description}, request.Permissions)
+	if err != nil {
+		switch err {
+		case services.ErrUnknownPermission:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case services.ErrRoleNameTaken:
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			serverError(w, err)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, role)
+}
+
+func (h *RoleHandler) SetRolePermissions(w http.ResponseWriter, r *http.Request) {
+	roleID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	var request struct {
+		Permissions []string `json:"permissions"`
+	}
+
+	if !decodeJSONBody(w, r, &request) {
+		return
+	}
+
+	role, err := h.service.SetRolePermissions(r.Context(), roleID, request.Permissions)
+	if err != nil {
+		if err == services.ErrUnknownPermission {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		serverError(w, err)
+		return
+	}
+
+	if role == nil {
+		notFound(w)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, role)
+}
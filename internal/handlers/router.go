@@ -25,6 +25,21 @@ func NewRouter(
 	attachmentService *services.AttachmentService,
 	departmentService *services.DepartmentService,
 	agreementService *services.AgreementService,
+	roleService *services.RoleService,
+	passwordResetService *services.PasswordResetService,
+	loginGuardService *services.LoginGuardService,
+	mfaService *services.MFAService,
+	apiKeyService *services.APIKeyService,
+	auditService *services.AuditService,
+	oidcService *services.OIDCService,
+	absenceService *services.AbsenceService,
+	skillService *services.SkillService,
+	reportService *services.ReportService,
+	notificationService *services.NotificationService,
+	laboratorySystemService *services.LaboratorySystemService,
+	geocodingService *services.GeocodingService,
+	clientImportService *services.ClientImportService,
+	labelService *services.LabelService,
 ) http.Handler {
 	r := chi.NewRouter()
 	// Initialize handlers
@@ -42,6 +57,21 @@ func NewRouter(
 	ticketHandler := &TicketHandler{ticketService}
 	departmentHandler := &DepartmentHandler{departmentService}
 	agreementHandler := &AgreementHandler{agreementService}
+	roleHandler := &RoleHandler{roleService}
+	passwordResetHandler := &PasswordResetHandler{passwordResetService}
+	loginGuardHandler := &LoginGuardHandler{loginGuardService}
+	mfaHandler := &MFAHandler{mfaService}
+	apiKeyHandler := &APIKeyHandler{apiKeyService}
+	auditHandler := &AuditHandler{auditService}
+	oidcHandler := &OIDCHandler{oidcService}
+	absenceHandler := &AbsenceHandler{absenceService}
+	skillHandler := &SkillHandler{skillService}
+	reportHandler := &ReportHandler{reportService}
+	notificationHandler := &NotificationHandler{notificationService}
+	laboratorySystemHandler := &LaboratorySystemHandler{laboratorySystemService}
+	geocodingHandler := &GeocodingHandler{geocodingService}
+	clientImportHandler := &ClientImportHandler{clientImportService}
+	labelHandler := &LabelHandler{labelService}
 
 	attachmentHandler := &AttachmentHandler{attachmentService: attachmentService}
 
@@ -54,13 +84,30 @@ func NewRouter(
 		w.Write([]byte("Health is ok"))
 	})
 
+	r.Get("/.well-known/jwks.json", authHandler.JWKS)
+
 	r.Route("/auth", func(r chi.Router) {
 		r.Post("/login", authHandler.Login) // Main handler for further operations with the app
 		r.Post("/refresh", authHandler.Refresh)
+		r.Post("/password-reset/request", passwordResetHandler.RequestReset)
+		r.Post("/password-reset/confirm", passwordResetHandler.ConfirmReset)
+
+		r.Route("/oidc", func(r chi.Router) {
+			r.Get("/login", oidcHandler.Login)
+			r.Get("/callback", oidcHandler.Callback)
+		})
+
+		// Second step of a login, authenticated by the mfa token from /login
+		r.Route("/mfa", func(r chi.Router) {
+			r.Post("/verify", authHandler.VerifyMFA)
+			r.Post("/enroll", authHandler.BeginMFAEnrollment)
+			r.Post("/enroll/confirm", authHandler.ConfirmMFAEnrollment)
+		})
 	})
 
 	r.Route("/api", func(r chi.Router) {
-		r.Use(middlewares.AuthMiddleware(authService))
+		r.Use(middlewares.AuthMiddleware(authService, apiKeyService))
+		r.Use(middlewares.AuditMutations(auditService))
 
 		r.Route("/v1", func(r chi.Router) {
 			r.Route("/agreements", func(r chi.Router) {
@@ -70,6 +117,7 @@ func NewRouter(
 			r.Route("/departments", func(r chi.Router) {
 				r.Get("/", departmentHandler.ListAllDepartments)
 				r.Get("/{uuid}", departmentHandler.GetDepartmentByID)
+				r.Get("/{uuid}/workload", departmentHandler.GetWorkload)
 			})
 
 			r.Route("/attachments", func(r chi.Router) {
@@ -86,14 +134,59 @@ func NewRouter(
 				r.Delete("/{userID}", userHandler.DeleteUser)
 				r.Patch("/{userID}", userHandler.UpdateUser)
 				r.Get("/department", userHandler.ListDepartmentUsers)
+				r.Get("/{userID}/skills", skillHandler.ListUserSkills)
+			})
+
+			r.Route("/reports", func(r chi.Router) {
+				r.Get("/engineers", reportHandler.EngineerReport)
+			})
+
+			r.Route("/skills", func(r chi.Router) {
+				r.Post("/", skillHandler.CreateSkill)
+				r.Patch("/{id}", skillHandler.UpdateSkill)
+				r.Delete("/{id}", skillHandler.DeleteSkill)
+			})
+
+			r.Route("/absences", func(r chi.Router) {
+				r.Get("/", absenceHandler.Calendar)
+				r.Get("/conflicts", absenceHandler.CheckConflicts)
+				r.Post("/", absenceHandler.CreateAbsence)
+				r.Patch("/{id}", absenceHandler.UpdateAbsence)
+				r.Delete("/{id}", absenceHandler.DeleteAbsence)
 			})
 
 			r.Route("/clients", func(r chi.Router) {
 				r.Get("/", clientHandler.ListClients)
+				r.Get("/nearby", clientHandler.NearbyClients)
+				r.Get("/map", clientHandler.ClientMap)
+				r.Get("/mine", clientHandler.MyClients)
+				r.Get("/duplicates", clientHandler.DuplicateClients)
 				r.Get("/{uuid}", clientHandler.GetClientByID)
-				r.Post("/", clientHandler.CreateClient)
-				r.Patch("/{uuid}", clientHandler.UpdateClient)
-				r.Delete("/{uuid}", clientHandler.DeleteClient)
+				r.Get("/{uuid}/lis", laboratorySystemHandler.ClientSetup)
+				r.Get("/{uuid}/overview", clientHandler.ClientOverview)
+				r.Get("/{uuid}/contacts.vcf", contactHandler.ClientContactsVCard)
+				r.Get("/{uuid}/contacts/duplicates", contactHandler.DuplicateContacts)
+				r.Get("/{uuid}/labels.pdf", labelHandler.ClientLabelSheet)
+				r.With(middlewares.RequirePermission(services.PermClientsWrite)).Post("/", clientHandler.CreateClient)
+				r.With(middlewares.RequirePermission(services.PermClientsWrite)).Patch("/{uuid}", clientHandler.UpdateClient)
+				r.With(middlewares.RequirePermission(services.PermClientsWrite)).Delete("/{uuid}", clientHandler.DeleteClient)
+				r.With(middlewares.RequirePermission(services.PermClientsWrite)).Post("/{uuid}/merge", clientHandler.MergeClients)
+			})
+
+			r.Route("/laboratory-systems", func(r chi.Router) {
+				r.Get("/", laboratorySystemHandler.ListLaboratorySystems)
+				r.Get("/{id}", laboratorySystemHandler.GetLaboratorySystem)
+				r.With(middlewares.RequirePermission(services.PermClientsWrite)).Post("/", laboratorySystemHandler.CreateLaboratorySystem)
+				r.With(middlewares.RequirePermission(services.PermClientsWrite)).Patch("/{id}", laboratorySystemHandler.UpdateLaboratorySystem)
+				r.With(middlewares.RequirePermission(services.PermClientsWrite)).Delete("/{id}", laboratorySystemHandler.DeleteLaboratorySystem)
+
+				r.Route("/connections", func(r chi.Router) {
+					r.Use(middlewares.RequirePermission(services.PermDevicesWrite))
+
+					r.Post("/", laboratorySystemHandler.CreateConnection)
+					r.Patch("/{id}", laboratorySystemHandler.UpdateConnection)
+					r.Delete("/{id}", laboratorySystemHandler.DeleteConnection)
+				})
 			})
 
 			r.Route("/regions", func(r chi.Router) {
@@ -117,11 +210,16 @@ func NewRouter(
 
 			r.Route("/devices", func(r chi.Router) {
 				r.Get("/", deviceHandler.GetAllDevices)
-				r.Post("/", deviceHandler.CreateNewDevice)
+				r.Get("/resolve", labelHandler.ResolveDevice)
+				r.With(middlewares.RequirePermission(services.PermDevicesWrite)).Post("/", deviceHandler.CreateNewDevice)
 				r.Get("/{uuid}", deviceHandler.GetDeviceByID)
-				r.Delete("/{uuid}", deviceHandler.RemoveDeviceByID)
-				r.Patch("/{uuid}", deviceHandler.UpdateDeviceByID)
+				r.With(middlewares.RequirePermission(services.PermDevicesWrite)).Delete("/{uuid}", deviceHandler.RemoveDeviceByID)
+				r.With(middlewares.RequirePermission(services.PermDevicesWrite)).Patch("/{uuid}", deviceHandler.UpdateDeviceByID)
 				r.Get("/remote-options/{uuid}", deviceHandler.GetDeviceRemoteOptions)
+				r.Get("/{uuid}/timeline", deviceHandler.GetDeviceTimeline)
+				r.Get("/{uuid}/label.png", labelHandler.DeviceLabelPNG)
+				r.Get("/{uuid}/label.pdf", labelHandler.DeviceLabelPDF)
+				r.With(middlewares.RequirePermission(services.PermDevicesWrite)).Post("/{uuid}/relocate", deviceHandler.RelocateDevice)
 			})
 
 			r.Route("/classificators", func(r chi.Router) {
@@ -135,8 +233,9 @@ func NewRouter(
 
 			r.Route("/tickets", func(r chi.Router) {
 				r.Get("/", ticketHandler.ListAllTickets)
+				r.Get("/my-clients", ticketHandler.MyClientTickets)
 				r.Get("/{uuid}", ticketHandler.GetTicketByID)
-				r.Delete("/{uuid}", ticketHandler.DeleteTicketByID)
+				r.With(middlewares.RequirePermission(services.PermTicketsDelete)).Delete("/{uuid}", ticketHandler.DeleteTicketByID)
 				r.Post("/", ticketHandler.CreateNewTicket)
 				r.Patch("/{uuid}", ticketHandler.UpdateTicketInfo)
 				r.Patch("/close/{uuid}", ticketHandler.CloseTicket)
@@ -146,7 +245,15 @@ func NewRouter(
 				r.Get("/{field}/{uuid}", ticketHandler.GetTicketsByField)
 			})
 
+			r.Route("/notifications", func(r chi.Router) {
+				r.Get("/", notificationHandler.ListNotifications)
+				r.Patch("/{id}/read", notificationHandler.MarkRead)
+				r.Post("/read-all", notificationHandler.MarkAllRead)
+			})
+
 			r.Route("/contacts", func(r chi.Router) {
+				r.Get("/search", contactHandler.SearchContacts)
+				r.Get("/{id}.vcf", contactHandler.ContactVCard)
 				r.Get("/{clientID}", contactHandler.GetAllByClientID)
 				r.Post("/", contactHandler.CreateContact)
 				r.Delete("/{id}", contactHandler.DeleteContact)
@@ -154,16 +261,87 @@ func NewRouter(
 			})
 
 			r.Route("/accounts", func(r chi.Router) {
+				r.Use(middlewares.ForbidImpersonation)
+
 				r.Patch("/password", accountHandler.ChangePassword)
+
+				r.Route("/mfa", func(r chi.Router) {
+					r.Get("/", mfaHandler.Status)
+					r.Post("/enroll", mfaHandler.BeginEnrollment)
+					r.Post("/confirm", mfaHandler.ConfirmEnrollment)
+					r.Post("/recovery-codes", mfaHandler.RegenerateRecoveryCodes)
+					r.Delete("/", mfaHandler.Disable)
+				})
 			})
 
-			// Router that requires authentication and admin role
+			// Router for administration, never reachable while impersonating
 			r.Route("/admin", func(r chi.Router) {
-				r.Use(middlewares.RequireRole("admin"))
+				r.Use(middlewares.ForbidImpersonation)
+
+				// Accounts, roles and credentials require account administration permission
+				r.Group(func(r chi.Router) {
+					r.Use(middlewares.RequirePermission(services.PermAdminAccounts))
+
+					r.Post("/impersonate/{userID}", authHandler.Impersonate)
+					r.Get("/audit", auditHandler.ListEntries)
+
+					r.Route("/accounts", func(r chi.Router) {
+						r.Get("/", accountHandler.ListAccounts)
+						r.Post("/", accountHandler.CreateAccount)
+						r.Patch("/status", accountHandler.ChangeAccountStatus)
+						r.Get("/{userID}", accountHandler.GetAccount)
+						r.Patch("/{userID}/status", accountHandler.ChangeAccountStatus)
+						r.Patch("/{userID}/username", accountHandler.RenameAccount)
+						r.Post("/{userID}/password-reset", accountHandler.ResetPassword)
+						r.Post("/{userID}/revoke-tokens", accountHandler.RevokeTokens)
+						r.Put("/{userID}/roles", accountHandler.SetAccountRoles)
+						r.Delete("/{userID}/mfa", mfaHandler.ResetAccountMFA)
+					})
+
+					r.Get("/permissions", roleHandler.ListPermissions)
+
+					r.Route("/roles", func(r chi.Router) {
+						r.Get("/", roleHandler.ListRoles)
+						r.Post("/", roleHandler.CreateRole)
+						r.Put("/{id}/permissions", roleHandler.SetRolePermissions)
+					})
+
+					r.Route("/lockouts", func(r chi.Router) {
+						r.Get("/", loginGuardHandler.ListLockouts)
+						r.Delete("/{key}", loginGuardHandler.ClearLockout)
+					})
+
+					r.Get("/auth-audit", loginGuardHandler.ListAuditEntries)
+
+					r.Route("/api-keys", func(r chi.Router) {
+						r.Get("/", apiKeyHandler.ListKeys)
+						r.Post("/", apiKeyHandler.CreateKey)
+						r.Post("/{id}/rotate", apiKeyHandler.RotateKey)
+						r.Delete("/{id}", apiKeyHandler.RevokeKey)
+					})
+				})
+
+				// Client data maintenance requires the clients:write permission, the
+				// services further restrict it to admins
+				r.Group(func(r chi.Router) {
+					r.Use(middlewares.RequirePermission(services.PermClientsWrite))
+
+					r.Post("/clients/managers/reassign", clientHandler.ReassignManager)
+
+					r.Post("/contacts/normalize", contactHandler.NormalizeContacts)
+
+					r.Route("/geocoding", func(r chi.Router) {
+						r.Get("/", geocodingHandler.ListGeocodes)
+						r.Post("/run", geocodingHandler.RunGeocoding)
+						r.Post("/{clientID}/approve", geocodingHandler.ApproveGeocode)
+						r.Post("/{clientID}/reject", geocodingHandler.RejectGeocode)
+					})
 
-				r.Route("/accounts", func(r chi.Router) {
-					r.Post("/", accountHandler.CreateAccount)
-					r.Patch("/status", accountHandler.ChangeAccountStatus)
+					r.Route("/imports/clients", func(r chi.Router) {
+						r.Post("/", clientImportHandler.PreviewImport)
+						r.Get("/{id}", clientImportHandler.GetImport)
+						r.Post("/{id}/apply", clientImportHandler.ApplyImport)
+					})
 				})
 			})
 		})
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/grintheone/foxygen-server/internal/services"
+)
+
+type SkillHandler struct {
+	service *services.SkillService
+}
+
+type skillRequest struct {
+	UserID        uuid.UUID            `json:"user_id"`
+	Classificator *uuid.UUID           `json:"classificator"`
+	Manufacturer  *uuid.UUID           `json:"manufacturer"`
+	ResearchType  *uuid.UUID           `json:"research_type"`
+	CertifiedAt   *models.FlexibleTime `json:"certified_at"`
+	ExpiresAt     *models.FlexibleTime `json:"expires_at"`
+	Comment       *string              `json:"comment"`
+}
+
+func (req skillRequest) toSkill() models.Skill {
+	skill := models.Skill{
+		UserID:        req.UserID,
+		Classificator: req.Classificator,
+		Manufacturer:  req.Manufacturer,
+		ResearchType:  req.ResearchType,
+		Comment:       req.Comment,
+	}
+	if req.CertifiedAt != nil {
+		skill.CertifiedAt = &req.CertifiedAt.Time
+	}
+	if req.ExpiresAt != nil {
+		skill.ExpiresAt = &req.ExpiresAt.Time
+	}
+
+	return skill
+}
+
+// skillError maps validation errors to 400.
+func skillError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidSkillTarget),
+		errors.Is(err, services.ErrInvalidSkillPeriod),
+		errors.Is(err, services.ErrUnknownSkillTarget):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		serviceError(w, err)
+	}
+}
+
+// ListUserSkills lists the skills of a user. Certificate scans are uploaded
+// to /attachments with the skill ID as refID and listed with the skill.
+func (h *SkillHandler) ListUserSkills(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(chi.URLParam(r, "userID"))
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	skills, err := h.service.ListUserSkills(r.Context(), userID)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, skills)
+}
+
+func (h *SkillHandler) CreateSkill(w http.ResponseWriter, r *http.Request) {
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	var request skillRequest
+
+	if !decodeJSONBody(w, r, &request) {
+		return
+	}
+
+	if request.UserID == uuid.Nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	skill, err := h.service.CreateSkill(r.Context(), actor, request.toSkill())
+	if err != nil {
+		skillError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, skill)
+}
+
+func (h *SkillHandler) UpdateSkill(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	var request skillRequest
+
+	if !decodeJSONBody(w, r, &request) {
+		return
+	}
+
+	skill := request.toSkill()
+	skill.ID = id
+
+	updated, err := h.service.UpdateSkill(r.Context(), actor, skill)
+	if err != nil {
+		skillError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (h *SkillHandler) DeleteSkill(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.DeleteSkill(r.Context(), actor, id); err != nil {
+		serviceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
@@ -81,9 +82,15 @@ func (h *TicketHandler) DeleteTicketByID(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = h.ticketService.DeleteTicketByID(r.Context(), uuid)
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	err = h.ticketService.DeleteTicketByID(r.Context(), actor, uuid)
 	if err != nil {
-		serverError(w, err)
+		serviceError(w, err)
 		return
 	}
 
@@ -97,12 +104,22 @@ func (h *TicketHandler) CreateNewTicket(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if body.Executor != uuid.Nil && !middlewares.HasPermission(r.Context(), services.PermTicketsAssign) {
+		clientError(w, http.StatusForbidden)
+		return
+	}
+
 	created, err := h.ticketService.CreateNewTicket(r.Context(), body)
 	if err != nil {
 		serverError(w, err)
 		return
 	}
 
+	if body.Executor != uuid.Nil {
+		writeJSON(w, http.StatusCreated, h.assignmentResult(w, r, *created))
+		return
+	}
+
 	writeJSON(w, http.StatusCreated, created)
 }
 
@@ -120,9 +137,10 @@ func (h *TicketHandler) UpdateTicketInfo(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	userID, ok := middlewares.GetUserIDFromContext(r.Context())
+	actor, ok := actorFromContext(r.Context())
 	if !ok {
-		serverError(w, fmt.Errorf("no user ID present in context"))
+		clientError(w, http.StatusUnauthorized)
+		return
 	}
 
 	var updates models.TicketUpdates
@@ -132,9 +150,19 @@ func (h *TicketHandler) UpdateTicketInfo(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = h.ticketService.UpdateTicketInfo(r.Context(), updates, userID)
+	if updates.Executor != nil && !middlewares.HasPermission(r.Context(), services.PermTicketsAssign) {
+		clientError(w, http.StatusForbidden)
+		return
+	}
+
+	err = h.ticketService.UpdateTicketInfo(r.Context(), actor, updates)
 	if err != nil {
-		serverError(w, err)
+		serviceError(w, err)
+		return
+	}
+
+	if updates.Executor != nil {
+		writeJSON(w, http.StatusOK, h.assignmentResult(w, r, uuidStr))
 		return
 	}
 
@@ -148,20 +176,15 @@ func (h *TicketHandler) CloseTicket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	uuidStr, ok := middlewares.GetUserIDFromContext(r.Context())
+	actor, ok := actorFromContext(r.Context())
 	if !ok {
-		serverError(w, fmt.Errorf("no user ID present in context"))
-	}
-
-	currentUserID, err := uuid.Parse(uuidStr)
-	if err != nil {
-		clientError(w, http.StatusBadRequest)
+		clientError(w, http.StatusUnauthorized)
 		return
 	}
 
-	err = h.ticketService.CloseTicket(r.Context(), ticketInfo, currentUserID)
+	err := h.ticketService.CloseTicket(r.Context(), actor, ticketInfo)
 	if err != nil {
-		serverError(w, err)
+		serviceError(w, err)
 		return
 	}
 
@@ -258,3 +281,64 @@ func (h *TicketHandler) GetTicketsByField(w http.ResponseWriter, r *http.Request
 
 	writeJSON(w, http.StatusOK, tickets)
 }
+
+// assignmentResult is the response to a request that assigned the ticket: its
+// ID and the assignment warnings, which are also repeated as Warning headers.
+// The assignment itself is not refused, and failing to check doesn't fail
+// the request.
+func (h *TicketHandler) assignmentResult(w http.ResponseWriter, r *http.Request, ticketID string) models.TicketAssignmentResult {
+	result := models.TicketAssignmentResult{ID: ticketID, Warnings: []string{}}
+
+	id, err := uuid.Parse(ticketID)
+	if err != nil {
+		return result
+	}
+
+	warnings, err := h.ticketService.AssignmentWarnings(r.Context(), id)
+	if err != nil {
+		log.Printf("handler: %v", err)
+		return result
+	}
+
+	for _, warning := range warnings {
+		w.Header().Add("Warning", fmt.Sprintf(`299 - %q`, warning))
+		result.Warnings = append(result.Warnings, warning)
+	}
+
+	return result
+}
+
+// MyClientTickets is the ticket feed of the clients the current user manages.
+// Only open tickets are listed unless ?status=all.
+func (h *TicketHandler) MyClientTickets(w http.ResponseWriter, r *http.Request) {
+	limit, offset, _, _, ok := parsePaginationParams(r, 50)
+	if !ok {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	var openOnly bool
+	switch r.URL.Query().Get("status") {
+	case "", "open":
+		openOnly = true
+	case "all":
+		openOnly = false
+	default:
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	actor, ok := actorFromContext(r.Context())
+	if !ok {
+		clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	tickets, err := h.ticketService.ListManagedClientTickets(r.Context(), actor.UserID, openOnly, limit, offset)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tickets)
+}
@@ -39,7 +39,16 @@ func (h *UserHandler) ListDepartmentUsers(w http.ResponseWriter, r *http.Request
 		serverError(w, fmt.Errorf("No user ID is present in context"))
 	}
 
-	users, err := h.userService.ListDepartmentUsers(r.Context(), userID)
+	device, okDevice := parseUUIDParam(r, "device")
+	classificator, okClassificator := parseUUIDParam(r, "classificator")
+	if !okDevice || !okClassificator {
+		clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	filter := models.DepartmentUserFilter{Device: device, Classificator: classificator}
+
+	users, err := h.userService.ListDepartmentUsers(r.Context(), userID, filter)
 	if err != nil {
 		serverError(w, err)
 		return
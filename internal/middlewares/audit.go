@@ -0,0 +1,61 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/grintheone/foxygen-server/internal/services"
+)
+
+// auditRecordTimeout bounds writing an audit row once the response is sent
+const auditRecordTimeout = 5 * time.Second
+
+// AuditMutations records every state changing request after it was served,
+// naming both the acting user and, for impersonation tokens, the admin.
+// It must run after AuthMiddleware.
+func AuditMutations(auditService *services.AuditService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isSafeMethod(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			entry := models.AuditEntry{
+				ActorType: GetActorTypeFromContext(r.Context()),
+				Method:    r.Method,
+				Path:      r.URL.Path,
+				Status:    status,
+				RequestID: middleware.GetReqID(r.Context()),
+			}
+
+			if id, ok := GetUserIDFromContext(r.Context()); ok {
+				if parsed, err := uuid.Parse(id); err == nil {
+					entry.ActorID = &parsed
+				}
+			}
+			if id, ok := GetImpersonatorIDFromContext(r.Context()); ok {
+				if parsed, err := uuid.Parse(id); err == nil {
+					entry.ImpersonatorID = &parsed
+				}
+			}
+
+			// The client may be gone by now, the mutation still has to be audited
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), auditRecordTimeout)
+			defer cancel()
+			auditService.Record(ctx, entry)
+		})
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"log"
+	"net"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -19,19 +21,39 @@ const (
 	UserIDKey contextKey = "user_id"
 	// UsernameKey is the key for storing username in the request context.
 	UsernameKey contextKey = "username"
-	// UserRolesKey is the key for storing user roles in the request context.
+	// UserRoleKey is the key for storing the primary user role in the request context.
 	UserRoleKey contextKey = "user_role"
+	// UserRolesKey is the key for storing all roles held by the user.
+	UserRolesKey contextKey = "user_roles"
+	// PermissionsKey is the key for storing the permissions granted by the user's roles.
+	PermissionsKey contextKey = "permissions"
+	// ActorTypeKey tells whether the caller is a user or an API key.
+	ActorTypeKey contextKey = "actor_type"
+	// ImpersonatorIDKey holds the ID of the admin behind an impersonation token.
+	ImpersonatorIDKey contextKey = "impersonator_id"
 )
 
+// isSafeMethod reports whether the request method doesn't change state.
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
 func handleAuthError(w http.ResponseWriter, err error) {
 	log.Print(err)
 	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 }
 
-// AuthMiddleware creates a middleware that validates JWT tokens.
-func AuthMiddleware(authService *services.AuthService) func(http.Handler) http.Handler {
+// AuthMiddleware creates a middleware that validates JWT tokens. API keys are
+// accepted instead, either as "Authorization: ApiKey <key>" or in the
+// X-API-Key header.
+func AuthMiddleware(authService *services.AuthService, apiKeyService *services.APIKeyService) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if apiKey := r.Header.Get("X-API-Key"); apiKey != "" {
+				serveWithAPIKey(w, r, next, apiKeyService, apiKey)
+				return
+			}
+
 			authHeader := r.Header.Get("Authorization")
 			if authHeader == "" {
 				handleAuthError(w, errors.New("auth header is empty"))
@@ -39,6 +61,11 @@ func AuthMiddleware(authService *services.AuthService) func(http.Handler) http.H
 			}
 
 			parts := strings.Split(authHeader, " ")
+			if len(parts) == 2 && parts[0] == "ApiKey" {
+				serveWithAPIKey(w, r, next, apiKeyService, parts[1])
+				return
+			}
+
 			if len(parts) != 2 || parts[0] != "Bearer" {
 				handleAuthError(w, errors.New("invalid token type"))
 				return
@@ -52,6 +79,12 @@ func AuthMiddleware(authService *services.AuthService) func(http.Handler) http.H
 				return
 			}
 
+			// Reject tokens of disabled accounts and tokens issued before a revocation
+			if err := authService.CheckTokenStatus(r.Context(), token); err != nil {
+				handleAuthError(w, err)
+				return
+			}
+
 			// Extract claims and add them to the request context
 			if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
 				ctx := r.Context()
@@ -64,6 +97,20 @@ func AuthMiddleware(authService *services.AuthService) func(http.Handler) http.H
 				if role, exists := claims["role"]; exists {
 					ctx = context.WithValue(ctx, UserRoleKey, role)
 				}
+				ctx = context.WithValue(ctx, UserRolesKey, claimStrings(claims["roles"]))
+				ctx = context.WithValue(ctx, PermissionsKey, claimStrings(claims["permissions"]))
+				ctx = context.WithValue(ctx, ActorTypeKey, services.ActorTypeUser)
+
+				// Impersonation tokens are read-only unless issued otherwise
+				if act, ok := claims["act"].(map[string]any); ok {
+					mode, _ := claims["imp_mode"].(string)
+					if mode != services.ImpersonationReadWrite && !isSafeMethod(r.Method) {
+						http.Error(w, "Impersonation token is read-only", http.StatusForbidden)
+						return
+					}
+					ctx = context.WithValue(ctx, ImpersonatorIDKey, act["sub"])
+				}
+
 				r = r.WithContext(ctx)
 			} else {
 				handleAuthError(w, errors.New("wrong claims"))
@@ -75,19 +122,37 @@ func AuthMiddleware(authService *services.AuthService) func(http.Handler) http.H
 	}
 }
 
-// RequireRole is an example of additional middleware for role-based access control (RBAC)
-func RequireRole(requiredRole string) func(http.Handler) http.Handler {
+// serveWithAPIKey authenticates the request with an API key. The key ID takes
+// the place of the user ID and the key's permissions replace role permissions.
+func serveWithAPIKey(w http.ResponseWriter, r *http.Request, next http.Handler, apiKeyService *services.APIKeyService, rawKey string) {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	key, err := apiKeyService.Authenticate(r.Context(), rawKey, ip)
+	if err != nil {
+		handleAuthError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	ctx = context.WithValue(ctx, UserIDKey, key.ID.String())
+	ctx = context.WithValue(ctx, UsernameKey, "api-key:"+key.Name)
+	ctx = context.WithValue(ctx, UserRoleKey, services.APIKeyRole)
+	ctx = context.WithValue(ctx, UserRolesKey, []string{services.APIKeyRole})
+	ctx = context.WithValue(ctx, PermissionsKey, []string(key.Permissions))
+	ctx = context.WithValue(ctx, ActorTypeKey, services.ActorTypeAPIKey)
+
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// RequirePermission allows the request only if one of the user's roles grants the permission.
+func RequirePermission(permission string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			role, ok := GetUserRoleFromContext(r.Context())
-			if !ok {
-				log.Print("Access denied: no role information")
-				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
-				return
-			}
-
-			if role != requiredRole {
-				log.Print("Access denied: insufficient permissions")
+			if !HasPermission(r.Context(), permission) {
+				log.Printf("Access denied: missing permission %s", permission)
 				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
 				return
 			}
@@ -97,6 +162,36 @@ func RequireRole(requiredRole string) func(http.Handler) http.Handler {
 	}
 }
 
+// ForbidImpersonation rejects requests made with an impersonation token, for
+// routes like password or second factor changes that only the owner may use.
+func ForbidImpersonation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := GetImpersonatorIDFromContext(r.Context()); ok {
+			http.Error(w, "Not allowed while impersonating", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// claimStrings converts a JSON array claim into a string slice.
+func claimStrings(value any) []string {
+	items, ok := value.([]any)
+	if !ok {
+		return nil
+	}
+
+	result := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			result = append(result, s)
+		}
+	}
+
+	return result
+}
+
 // Helper functions to extract data from the context in your handlers
 func GetUserIDFromContext(ctx context.Context) (string, bool) {
 	id, ok := ctx.Value(UserIDKey).(string)
@@ -112,3 +207,31 @@ func GetUserRoleFromContext(ctx context.Context) (string, bool) {
 	roles, ok := ctx.Value(UserRoleKey).(string)
 	return roles, ok
 }
+
+func GetUserRolesFromContext(ctx context.Context) ([]string, bool) {
+	roles, ok := ctx.Value(UserRolesKey).([]string)
+	return roles, ok
+}
+
+func GetPermissionsFromContext(ctx context.Context) ([]string, bool) {
+	permissions, ok := ctx.Value(PermissionsKey).([]string)
+	return permissions, ok
+}
+
+// GetActorTypeFromContext returns services.ActorTypeUser or services.ActorTypeAPIKey.
+func GetActorTypeFromContext(ctx context.Context) string {
+	if actorType, ok := ctx.Value(ActorTypeKey).(string); ok {
+		return actorType
+	}
+	return services.ActorTypeUser
+}
+
+func GetImpersonatorIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(ImpersonatorIDKey).(string)
+	return id, ok
+}
+
+func HasPermission(ctx context.Context, permission string) bool {
+	permissions, _ := GetPermissionsFromContext(ctx)
+	return slices.Contains(permissions, permission)
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Absence is a period a user isn't available for assignments. To is exclusive.
+// FirstName and LastName are only filled in calendar listings.
+type Absence struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	UserID    uuid.UUID  `json:"user_id" db:"user_id"`
+	FirstName string     `json:"firstName,omitempty" db:"first_name"`
+	LastName  string     `json:"lastName,omitempty" db:"last_name"`
+	Type      string     `json:"type" db:"absence_type"`
+	From      time.Time  `json:"from" db:"starts_at"`
+	To        time.Time  `json:"to" db:"ends_at"`
+	Comment   *string    `json:"comment" db:"comment"`
+	CreatedBy *uuid.UUID `json:"created_by" db:"created_by"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// AbsenceFilter selects absences overlapping [From, To) of a user or a department.
+type AbsenceFilter struct {
+	UserID     *uuid.UUID
+	Department *uuid.UUID
+	From       time.Time
+	To         time.Time
+}
@@ -1,15 +1,66 @@
 package models
 
 import (
+	"time"
+
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 type Account struct {
-	UserID       uuid.UUID `json:"user_id" db:"user_id"`
-	Username     string    `json:"username" db:"username"`
-	Disabled     bool      `json:"disabled" db:"disabled"`
-	PasswordHash string    `json:"-" db:"password_hash"`
-	Role         string    `json:"role"` // This is for convenience when fetching a user with their roles
+	UserID           uuid.UUID      `json:"user_id" db:"user_id"`
+	Username         string         `json:"username" db:"username"`
+	Disabled         bool           `json:"disabled" db:"disabled"`
+	PasswordHash     string         `json:"-" db:"password_hash"`
+	TokensValidAfter *time.Time     `json:"-" db:"tokens_valid_after"`
+	TOTPEnabled      bool           `json:"totp_enabled" db:"totp_enabled"`
+	Role             string         `json:"role"` // This is for convenience when fetching a user with their roles
+	Roles            pq.StringArray `json:"roles" db:"roles"`
+	Permissions      pq.StringArray `json:"permissions" db:"permissions"`
+}
+
+// AccountSummary is an account as listed in the admin API, joined with its
+// user profile.
+type AccountSummary struct {
+	UserID          uuid.UUID      `json:"user_id" db:"user_id"`
+	Username        string         `json:"username" db:"username"`
+	Disabled        bool           `json:"disabled" db:"disabled"`
+	Role            string         `json:"role" db:"role"`
+	Roles           pq.StringArray `json:"roles" db:"roles"`
+	FirstName       string         `json:"first_name" db:"first_name"`
+	LastName        string         `json:"last_name" db:"last_name"`
+	Email           string         `json:"email" db:"email"`
+	Department      *uuid.UUID     `json:"department" db:"department"`
+	DepartmentTitle string         `json:"department_title" db:"department_title"`
+	HasProfile      bool           `json:"has_profile" db:"has_profile"`
+	TOTPEnabled     bool           `json:"totp_enabled" db:"totp_enabled"`
+	CreatedAt       *time.Time     `json:"created_at" db:"created_at"`
+	LastLoginAt     *time.Time     `json:"last_login_at" db:"last_login_at"`
+}
+
+// AccountFilter narrows the admin account list. Zero values don't filter.
+type AccountFilter struct {
+	Search     string
+	Role       string
+	Department *uuid.UUID
+	Disabled   *bool
+	Limit      int
+	Offset     int
+}
+
+// AccountState is the minimal account data needed to decide whether
+// an already issued token may still be used.
+type AccountState struct {
+	UserID           uuid.UUID  `db:"user_id"`
+	Disabled         bool       `db:"disabled"`
+	TokensValidAfter *time.Time `db:"tokens_valid_after"`
+}
+
+// TOTPState is the second factor configuration of an account.
+type TOTPState struct {
+	Secret   *string `db:"totp_secret"`
+	Enabled  bool    `db:"totp_enabled"`
+	LastStep int64   `db:"totp_last_step"`
 }
 
 // Role represents a user role
@@ -19,7 +70,39 @@ type Role struct {
 	Description string `json:"description" db:"description"`
 }
 
+// Permission is a single capability such as "tickets:assign".
+// Roles are bundles of permissions.
+type Permission struct {
+	Name        string `json:"name" db:"name"`
+	Description string `json:"description" db:"description"`
+}
+
+type RoleWithPermissions struct {
+	Role
+	Permissions pq.StringArray `json:"permissions" db:"permissions"`
+}
+
 type AccountRole struct {
 	UserID uuid.UUID `db:"user_id"`
 	RoleID int       `db:"role_id"`
 }
+
+// LoginThrottle tracks failed logins for a username ("user:<name>") or a source IP ("ip:<addr>").
+type LoginThrottle struct {
+	Key           string     `json:"key" db:"key"`
+	Failures      int        `json:"failures" db:"failures"`
+	LastFailureAt *time.Time `json:"last_failure_at" db:"last_failure_at"`
+	BlockedUntil  *time.Time `json:"blocked_until" db:"blocked_until"`
+}
+
+type AuthAuditEntry struct {
+	ID        int64      `json:"id" db:"id"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	Event     string     `json:"event" db:"event"`
+	Success   bool       `json:"success" db:"success"`
+	ActorType string     `json:"actor_type" db:"actor_type"`
+	Username  string     `json:"username" db:"username"`
+	UserID    *uuid.UUID `json:"user_id" db:"user_id"`
+	IP        string     `json:"ip" db:"ip"`
+	Details   string     `json:"details" db:"details"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// APIKey authenticates a service integration. The key itself is only shown
+// when it is created or rotated; KeyHash is its sha256.
+type APIKey struct {
+	ID          uuid.UUID      `json:"id" db:"id"`
+	Name        string         `json:"name" db:"name"`
+	Prefix      string         `json:"prefix" db:"prefix"`
+	KeyHash     string         `json:"-" db:"key_hash"`
+	Permissions pq.StringArray `json:"permissions" db:"permissions"`
+	CreatedBy   *uuid.UUID     `json:"created_by" db:"created_by"`
+	CreatedAt   time.Time      `json:"created_at" db:"created_at"`
+	RotatedAt   *time.Time     `json:"rotated_at" db:"rotated_at"`
+	ExpiresAt   *time.Time     `json:"expires_at" db:"expires_at"`
+	LastUsedAt  *time.Time     `json:"last_used_at" db:"last_used_at"`
+	RevokedAt   *time.Time     `json:"revoked_at" db:"revoked_at"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditEntry records a mutating API request. ImpersonatorID is set when an
+// admin made the request with an impersonation token.
+type AuditEntry struct {
+	ID             int64      `json:"id" db:"id"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	ActorType      string     `json:"actor_type" db:"actor_type"`
+	ActorID        *uuid.UUID `json:"actor_id" db:"actor_id"`
+	ImpersonatorID *uuid.UUID `json:"impersonator_id" db:"impersonator_id"`
+	Method         string     `json:"method" db:"method"`
+	Path           string     `json:"path" db:"path"`
+	Status         int        `json:"status" db:"status"`
+	RequestID      string     `json:"request_id" db:"request_id"`
+}
+
+type AuditFilter struct {
+	ActorID        *uuid.UUID
+	ImpersonatorID *uuid.UUID
+	Limit          int
+	Offset         int
+}
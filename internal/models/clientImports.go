@@ -0,0 +1,99 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Fields a spreadsheet column can be mapped to.
+const (
+	ImportFieldClientID        = "client_id"
+	ImportFieldTitle           = "title"
+	ImportFieldAddress         = "address"
+	ImportFieldRegion          = "region"
+	ImportFieldLat             = "lat"
+	ImportFieldLng             = "lng"
+	ImportFieldContactName     = "contact_name"
+	ImportFieldContactPosition = "contact_position"
+	ImportFieldContactPhone    = "contact_phone"
+	ImportFieldContactEmail    = "contact_email"
+)
+
+type ClientImportContact struct {
+	Name      string `json:"name"`
+	Position  string `json:"position"`
+	Phone     string `json:"phone"`
+	PhoneE164 string `json:"phone_e164"`
+	Email     string `json:"email"`
+}
+
+// ClientImportRow is a validated spreadsheet row: a client and optionally one
+// of its contacts. Row is the line number in the file. Action tells whether
+// the client will be created or an existing one updated.
+type ClientImportRow struct {
+	Row         int                  `json:"row"`
+	ClientID    *uuid.UUID           `json:"client_id"`
+	Title       string               `json:"title"`
+	Address     string               `json:"address"`
+	Region      *uuid.UUID           `json:"region"`
+	RegionTitle string               `json:"region_title"`
+	Lat         *float64             `json:"lat"`
+	Lng         *float64             `json:"lng"`
+	Contact     *ClientImportContact `json:"contact"`
+	Action      string               `json:"action"`
+	Errors      []string             `json:"errors"`
+}
+
+type ClientImportRows []*ClientImportRow
+
+func (r *ClientImportRows) Scan(value any) error {
+	return scanJSON(value, (*[]*ClientImportRow)(r))
+}
+
+func (r ClientImportRows) Value() (driver.Value, error) {
+	return json.Marshal(r)
+}
+
+// ImportColumns maps import fields to the spreadsheet headers they are read from.
+type ImportColumns map[string]string
+
+func (c *ImportColumns) Scan(value any) error {
+	return scanJSON(value, (*map[string]string)(c))
+}
+
+func (c ImportColumns) Value() (driver.Value, error) {
+	return json.Marshal(c)
+}
+
+type ClientImportResult struct {
+	ClientsCreated  int `json:"clients_created"`
+	ClientsUpdated  int `json:"clients_updated"`
+	ContactsCreated int `json:"contacts_created"`
+	ContactsUpdated int `json:"contacts_updated"`
+	SkippedRows     int `json:"skipped_rows"`
+}
+
+func (r *ClientImportResult) Scan(value any) error {
+	return scanJSON(value, r)
+}
+
+func (r ClientImportResult) Value() (driver.Value, error) {
+	return json.Marshal(r)
+}
+
+// ClientImport is an uploaded spreadsheet with its validation preview.
+type ClientImport struct {
+	ID        uuid.UUID           `json:"id" db:"id"`
+	Filename  string              `json:"filename" db:"filename"`
+	Columns   ImportColumns       `json:"columns" db:"columns"`
+	Rows      ClientImportRows    `json:"rows" db:"rows"`
+	ErrorRows int                 `json:"error_rows" db:"error_rows"`
+	CreatedBy *uuid.UUID          `json:"created_by" db:"created_by"`
+	CreatedAt time.Time           `json:"created_at" db:"created_at"`
+	AppliedBy *uuid.UUID          `json:"applied_by" db:"applied_by"`
+	AppliedAt *time.Time          `json:"applied_at" db:"applied_at"`
+	Result    *ClientImportResult `json:"result" db:"result"`
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/lib/pq"
@@ -60,6 +61,14 @@ type Client struct {
 	Manager          pq.StringArray `json:"manager" db:"manager"`
 }
 
+// ManagerReassignment moves clients from one manager to another. Without
+// Clients it applies to every client of From; a null To removes From.
+type ManagerReassignment struct {
+	From    uuid.UUID   `json:"from"`
+	To      *uuid.UUID  `json:"to"`
+	Clients []uuid.UUID `json:"clients"`
+}
+
 type ClientUpdate struct {
 	Title            *string         `json:"title,omitempty" db:"title"`
 	Region           *uuid.UUID      `json:"region,omitempty" db:"region"`
@@ -68,3 +77,88 @@ type ClientUpdate struct {
 	LaboratorySystem *uuid.UUID      `json:"laboratory_system,omitempty" db:"laboratory_system"`
 	Manager          *pq.StringArray `json:"manager,omitempty" db:"manager"`
 }
+
+// NearbyClient is a client with its distance from the requested point.
+type NearbyClient struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	Title      string     `json:"title" db:"title"`
+	Address    *string    `json:"address" db:"address"`
+	Region     *uuid.UUID `json:"region" db:"region"`
+	Lat        float64    `json:"lat" db:"lat"`
+	Lng        float64    `json:"lng" db:"lng"`
+	DistanceKm float64    `json:"distance_km" db:"distance_km"`
+}
+
+// ClientPin is the map marker of a client. MostUrgentStatus is the status of
+// its most pressing open ticket: overdue first, then urgent, then the least
+// progressed.
+type ClientPin struct {
+	ID               uuid.UUID `json:"id" db:"id"`
+	Title            string    `json:"title" db:"title"`
+	Lat              float64   `json:"lat" db:"lat"`
+	Lng              float64   `json:"lng" db:"lng"`
+	OpenTickets      int       `json:"open_tickets" db:"open_tickets"`
+	MostUrgentStatus *string   `json:"most_urgent_status" db:"most_urgent_status"`
+	HasOverdue       bool      `json:"has_overdue" db:"has_overdue"`
+	HasUrgent        bool      `json:"has_urgent" db:"has_urgent"`
+}
+
+// BoundingBox is a map viewport. MinLng > MaxLng means the box crosses the
+// antimeridian.
+type BoundingBox struct {
+	MinLng float64
+	MinLat float64
+	MaxLng float64
+	MaxLat float64
+}
+
+// DuplicateCandidate is a pair of clients that may be the same laboratory,
+// with what they have in common. DistanceKm is set when both have a location.
+type DuplicateCandidate struct {
+	ClientID       uuid.UUID `json:"client_id" db:"client_id"`
+	ClientTitle    string    `json:"client_title" db:"client_title"`
+	DuplicateID    uuid.UUID `json:"duplicate_id" db:"duplicate_id"`
+	DuplicateTitle string    `json:"duplicate_title" db:"duplicate_title"`
+	SameTitle      bool      `json:"same_title" db:"same_title"`
+	SameAddress    bool      `json:"same_address" db:"same_address"`
+	DistanceKm     *float64  `json:"distance_km" db:"distance_km"`
+}
+
+type ClientMerge struct {
+	Duplicates []uuid.UUID `json:"duplicates"`
+}
+
+// ClientMergeResult is the surviving client and how many rows were moved to it.
+type ClientMergeResult struct {
+	Client      *Client `json:"client"`
+	Tickets     int64   `json:"tickets"`
+	Contacts    int64   `json:"contacts"`
+	Agreements  int64   `json:"agreements"`
+	Comments    int64   `json:"comments"`
+	Attachments int64   `json:"attachments"`
+}
+
+// ClientDetails is a client with its region title.
+type ClientDetails struct {
+	Client
+	RegionTitle *string `json:"region_title" db:"region_title"`
+}
+
+// WarrantyCounts splits the active agreements of a client by warranty state.
+// Expired agreements are still active but past their finish date.
+type WarrantyCounts struct {
+	OnWarranty  int `json:"on_warranty"`
+	OffWarranty int `json:"off_warranty"`
+	Expired     int `json:"expired"`
+}
+
+// ClientOverview is everything the client page shows, in one response.
+type ClientOverview struct {
+	Client        *ClientDetails   `json:"client"`
+	Contacts      []ContactRow     `json:"contacts"`
+	Agreements    []*AgreementCard `json:"agreements"`
+	OpenTickets   []*TicketCard    `json:"open_tickets"`
+	ClosedTickets []*TicketCard    `json:"closed_tickets"`
+	Warranty      WarrantyCounts   `json:"warranty"`
+	LastServiceAt *time.Time       `json:"last_service_at"`
+}
@@ -7,22 +7,26 @@ import (
 	"github.com/google/uuid"
 )
 
+// Contact is a person at a client. Phone is the display form of the number,
+// PhoneE164 the normalized one used for search.
 type Contact struct {
-	ID       uuid.UUID `json:"id" db:"id"`
-	Name     string    `json:"name" db:"name"`
-	Position string    `json:"position" db:"position"`
-	Phone    string    `json:"phone" db:"phone"`
-	Email    string    `json:"email" db:"email"`
-	ClientID uuid.UUID `json:"client_id,omitempty" db:"client_id"`
+	ID        uuid.UUID `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	Position  string    `json:"position" db:"position"`
+	Phone     string    `json:"phone" db:"phone"`
+	PhoneE164 string    `json:"phone_e164" db:"phone_e164"`
+	Email     string    `json:"email" db:"email"`
+	ClientID  uuid.UUID `json:"client_id,omitempty" db:"client_id"`
 }
 
 type ContactRow struct {
-	ID       uuid.UUID `json:"id" db:"id"`
-	Name     string    `json:"name" db:"name"`
-	Position string    `json:"position" db:"position"`
-	Phone    string    `json:"phone" db:"phone"`
-	Email    string    `json:"email" db:"email"`
-	ClientID uuid.UUID `json:"client_id,omitempty" db:"client_id"`
+	ID        uuid.UUID `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	Position  string    `json:"position" db:"position"`
+	Phone     string    `json:"phone" db:"phone"`
+	PhoneE164 string    `json:"phone_e164" db:"phone_e164"`
+	Email     string    `json:"email" db:"email"`
+	ClientID  uuid.UUID `json:"client_id,omitempty" db:"client_id"`
 }
 
 // Scan implements the sql.Scanner interface
@@ -41,9 +45,41 @@ func (c *Contact) Scan(value any) error {
 }
 
 type ContactUpdate struct {
-	Name     *string    `json:"name,omitempty" db:"name"`
-	Position *string    `json:"position,omitempty" db:"position"`
-	Phone    *string    `json:"phone,omitempty" db:"phone"`
-	Email    *string    `json:"email,omitempty" db:"email"`
-	ClientID *uuid.UUID `json:"client_id,omitempty" db:"client_id"`
+	Name      *string    `json:"name,omitempty" db:"name"`
+	Position  *string    `json:"position,omitempty" db:"position"`
+	Phone     *string    `json:"phone,omitempty" db:"phone"`
+	PhoneE164 *string    `json:"-" db:"phone_e164"`
+	Email     *string    `json:"email,omitempty" db:"email"`
+	ClientID  *uuid.UUID `json:"client_id,omitempty" db:"client_id"`
+}
+
+// ContactCard is a contact with the title of its client, what a vCard holds.
+type ContactCard struct {
+	ID          uuid.UUID `db:"id"`
+	Name        string    `db:"name"`
+	Position    string    `db:"position"`
+	Phone       string    `db:"phone"`
+	PhoneE164   string    `db:"phone_e164"`
+	Email       string    `db:"email"`
+	ClientTitle string    `db:"client_title"`
+}
+
+// DuplicateContact is a pair of contacts of the same client that share a
+// phone number, an email or a name.
+type DuplicateContact struct {
+	ContactID     uuid.UUID `json:"contact_id" db:"contact_id"`
+	ContactName   string    `json:"contact_name" db:"contact_name"`
+	DuplicateID   uuid.UUID `json:"duplicate_id" db:"duplicate_id"`
+	DuplicateName string    `json:"duplicate_name" db:"duplicate_name"`
+	SamePhone     bool      `json:"same_phone" db:"same_phone"`
+	SameEmail     bool      `json:"same_email" db:"same_email"`
+	SameName      bool      `json:"same_name" db:"same_name"`
+}
+
+// ContactNormalization counts what normalizing the stored contacts did.
+// Values that can't be normalized are kept as they are.
+type ContactNormalization struct {
+	Updated       int `json:"updated"`
+	InvalidPhones int `json:"invalid_phones"`
+	InvalidEmails int `json:"invalid_emails"`
 }
@@ -4,6 +4,7 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -55,3 +56,59 @@ type DeviceUpdates struct {
 	ConntectedToLIS *bool      `json:"connected_to_lis" db:"connected_to_lis"`
 	IsUsed          *bool      `json:"is_used" db:"is_used"`
 }
+
+// Device timeline event types. Ticket events are named after the ticket reason.
+const (
+	DeviceEventInstallation   = "installation"
+	DeviceEventCommissioning  = "commissioning"
+	DeviceEventRepair         = "repair"
+	DeviceEventMaintenance    = "maintenance"
+	DeviceEventDeinstallation = "deinstallation"
+	DeviceEventAgreementStart = "agreement_start"
+	DeviceEventAgreementEnd   = "agreement_end"
+	DeviceEventPropertyChange = "property_change"
+)
+
+// DeviceEvent is an entry of a device timeline. Source and SourceID point to
+// the record it comes from: a ticket, an agreement or a device change.
+type DeviceEvent struct {
+	At          time.Time  `json:"at" db:"at"`
+	Type        string     `json:"type" db:"type"`
+	Source      string     `json:"source" db:"source"`
+	SourceID    uuid.UUID  `json:"source_id" db:"source_id"`
+	ClientID    *uuid.UUID `json:"client_id" db:"client_id"`
+	ClientTitle *string    `json:"client_title" db:"client_title"`
+	Details     JSONB      `json:"details" db:"details"`
+}
+
+// DeviceRelocation moves a device to another client. The current agreement
+// ends and a new one starts At, now by default. Type and OnWarranty are
+// carried over from the current agreement when not given.
+type DeviceRelocation struct {
+	Client      uuid.UUID  `json:"client"`
+	Distributor *uuid.UUID `json:"distributor"`
+	At          *time.Time `json:"at"`
+	FinishedAt  *time.Time `json:"finished_at"`
+	Type        *string    `json:"type"`
+	OnWarranty  *bool      `json:"on_warranty"`
+}
+
+type DeviceRelocationResult struct {
+	ClosedAgreements []uuid.UUID `json:"closed_agreements"`
+	Agreement        *Agreement  `json:"agreement"`
+}
+
+// DeviceLabel is what a printed device label shows.
+type DeviceLabel struct {
+	ID                 uuid.UUID `db:"id"`
+	SerialNumber       string    `db:"serial_number"`
+	ClassificatorTitle string    `db:"classificator_title"`
+}
+
+// DeviceResolution is what scanning a device label opens: the device, the
+// client of its active agreement and its open tickets.
+type DeviceResolution struct {
+	Device      *DeviceSinglePage `json:"device"`
+	Client      *Client           `json:"client"`
+	OpenTickets []*TicketCard     `json:"open_tickets"`
+}
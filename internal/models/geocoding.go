@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GeocodeResult is a provider's best match for an address. Confidence is in
+// [0, 1], how precisely the match pins the address down.
+type GeocodeResult struct {
+	Lat         float64 `json:"lat" db:"lat"`
+	Lng         float64 `json:"lng" db:"lng"`
+	Confidence  float64 `json:"confidence" db:"confidence"`
+	DisplayName string  `json:"display_name" db:"display_name"`
+}
+
+type GeocodeCacheEntry struct {
+	AddressKey  string    `db:"address_key"`
+	Found       bool      `db:"found"`
+	Lat         *float64  `db:"lat"`
+	Lng         *float64  `db:"lng"`
+	Confidence  *float64  `db:"confidence"`
+	DisplayName string    `db:"display_name"`
+	Provider    string    `db:"provider"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+// GeocodeCandidate is a client with an address but no location.
+type GeocodeCandidate struct {
+	ID      uuid.UUID `db:"id"`
+	Address string    `db:"address"`
+}
+
+// ClientGeocode is the geocoding outcome for a client address.
+type ClientGeocode struct {
+	ClientID    uuid.UUID  `json:"client_id" db:"client_id"`
+	ClientTitle string     `json:"client_title" db:"client_title"`
+	Address     string     `json:"address" db:"address"`
+	Status      string     `json:"status" db:"status"`
+	Lat         *float64   `json:"lat" db:"lat"`
+	Lng         *float64   `json:"lng" db:"lng"`
+	Confidence  *float64   `json:"confidence" db:"confidence"`
+	DisplayName string     `json:"display_name" db:"display_name"`
+	Attempts    int        `json:"attempts" db:"attempts"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	ReviewedBy  *uuid.UUID `json:"reviewed_by" db:"reviewed_by"`
+	ReviewedAt  *time.Time `json:"reviewed_at" db:"reviewed_at"`
+}
+
+// GeocodeRun summarizes one pass of the geocoding job.
+type GeocodeRun struct {
+	Processed int `json:"processed"`
+	Applied   int `json:"applied"`
+	Pending   int `json:"pending"`
+	NotFound  int `json:"not_found"`
+	Failed    int `json:"failed"`
+}
+
+// GeocodeApproval optionally corrects the coordinates of a pending match.
+type GeocodeApproval struct {
+	Lat *float64 `json:"lat"`
+	Lng *float64 `json:"lng"`
+}
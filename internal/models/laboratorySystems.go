@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LaboratorySystem is a LIS installation that clients and their devices
+// exchange results with.
+type LaboratorySystem struct {
+	ID           uuid.UUID `json:"id" db:"id"`
+	Title        string    `json:"title" db:"title"`
+	Vendor       string    `json:"vendor" db:"vendor"`
+	Version      string    `json:"version" db:"version"`
+	ContactName  string    `json:"contact_name" db:"contact_name"`
+	ContactPhone string    `json:"contact_phone" db:"contact_phone"`
+	ContactEmail string    `json:"contact_email" db:"contact_email"`
+	Notes        string    `json:"notes" db:"notes"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	Clients      int       `json:"clients" db:"clients"`
+	Connections  int       `json:"connections" db:"connections"`
+}
+
+type LaboratorySystemUpdate struct {
+	Title        *string `json:"title"`
+	Vendor       *string `json:"vendor"`
+	Version      *string `json:"version"`
+	ContactName  *string `json:"contact_name"`
+	ContactPhone *string `json:"contact_phone"`
+	ContactEmail *string `json:"contact_email"`
+	Notes        *string `json:"notes"`
+}
+
+// LISConnection is how a device talks to a laboratory system.
+type LISConnection struct {
+	ID                 uuid.UUID `json:"id" db:"id"`
+	DeviceID           uuid.UUID `json:"device_id" db:"device_id"`
+	LaboratorySystem   uuid.UUID `json:"laboratory_system" db:"laboratory_system"`
+	Protocol           string    `json:"protocol" db:"protocol"`
+	Parameters         JSONB     `json:"parameters" db:"parameters"`
+	Comment            *string   `json:"comment" db:"comment"`
+	CreatedAt          time.Time `json:"created_at" db:"created_at"`
+	SerialNumber       *string   `json:"serial_number" db:"serial_number"`
+	ClassificatorTitle *string   `json:"classificator_title" db:"classificator_title"`
+}
+
+type LISConnectionUpdate struct {
+	Protocol   *string `json:"protocol"`
+	Parameters *JSONB  `json:"parameters"`
+	Comment    *string `json:"comment"`
+}
+
+// LaboratorySystemDetails is a laboratory system with the clients using it
+// and all device connections to it.
+type LaboratorySystemDetails struct {
+	*LaboratorySystem
+	ClientList     []*Client        `json:"client_list"`
+	ConnectionList []*LISConnection `json:"connection_list"`
+}
+
+// ClientLISSetup is a client's laboratory system and the connections of the
+// devices under its active agreements.
+type ClientLISSetup struct {
+	LaboratorySystem *LaboratorySystem `json:"laboratory_system"`
+	Connections      []*LISConnection  `json:"connections"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Notification struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	UserID      uuid.UUID  `json:"user_id" db:"user_id"`
+	Kind        string     `json:"kind" db:"kind"`
+	ReferenceID *uuid.UUID `json:"reference_id" db:"reference_id"`
+	Message     string     `json:"message" db:"message"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	ReadAt      *time.Time `json:"read_at" db:"read_at"`
+}
+
+// NotificationRecipient is a user to notify with the email from their profile.
+type NotificationRecipient struct {
+	UserID uuid.UUID `db:"user_id"`
+	Email  string    `db:"email"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EngineerReport holds the KPIs of one executor over the tickets closed in the
+// report period. Durations are in minutes. Medians and the on-time ratio are
+// nil when no ticket has the timestamps they need.
+type EngineerReport struct {
+	UserID                     uuid.UUID      `json:"user_id" db:"user_id"`
+	FirstName                  string         `json:"firstName" db:"first_name"`
+	LastName                   string         `json:"lastName" db:"last_name"`
+	Department                 *uuid.UUID     `json:"department" db:"department"`
+	TicketsClosed              int            `json:"tickets_closed" db:"tickets_closed"`
+	MedianAssignToStartMinutes *float64       `json:"median_assign_to_start_minutes" db:"median_assign_to_start_minutes"`
+	MedianStartToFinishMinutes *float64       `json:"median_start_to_finish_minutes" db:"median_start_to_finish_minutes"`
+	OnTimeRatio                *float64       `json:"on_time_ratio" db:"on_time_ratio"`
+	FollowUpTickets            int            `json:"follow_up_tickets" db:"follow_up_tickets"`
+	DoubleSignedRatio          float64        `json:"double_signed_ratio" db:"double_signed_ratio"`
+	Reasons                    map[string]int `json:"reasons" db:"-"`
+}
+
+// EngineerReasonCount is a row of the per-reason breakdown.
+type EngineerReasonCount struct {
+	UserID uuid.UUID `db:"user_id"`
+	Reason string    `db:"reason"`
+	Count  int       `db:"count"`
+}
+
+// ReportFilter selects tickets closed in [From, To), optionally of one department.
+type ReportFilter struct {
+	From       time.Time
+	To         time.Time
+	Department *uuid.UUID
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Skill records that a user is trained on a classificator, or on all models
+// of a manufacturer or research type. Exactly one of the three is set. A
+// skill counts as a valid certification while CertifiedAt is set and
+// ExpiresAt hasn't passed.
+type Skill struct {
+	ID                 uuid.UUID     `json:"id" db:"id"`
+	UserID             uuid.UUID     `json:"user_id" db:"user_id"`
+	Classificator      *uuid.UUID    `json:"classificator" db:"classificator"`
+	ClassificatorTitle *string       `json:"classificator_title,omitempty" db:"classificator_title"`
+	Manufacturer       *uuid.UUID    `json:"manufacturer" db:"manufacturer"`
+	ManufacturerTitle  *string       `json:"manufacturer_title,omitempty" db:"manufacturer_title"`
+	ResearchType       *uuid.UUID    `json:"research_type" db:"research_type"`
+	ResearchTypeTitle  *string       `json:"research_type_title,omitempty" db:"research_type_title"`
+	CertifiedAt        *time.Time    `json:"certified_at" db:"certified_at"`
+	ExpiresAt          *time.Time    `json:"expires_at" db:"expires_at"`
+	Comment            *string       `json:"comment" db:"comment"`
+	CreatedAt          time.Time     `json:"created_at" db:"created_at"`
+	Certificates       []*Attachment `json:"certificates" db:"-"`
+}
+
+// DepartmentUserFilter narrows department listings to users holding a valid
+// certification for a device or classificator.
+type DepartmentUserFilter struct {
+	Device        *uuid.UUID
+	Classificator *uuid.UUID
+}
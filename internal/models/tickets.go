@@ -39,9 +39,12 @@ func (ft *FlexibleTime) UnmarshalJSON(b []byte) error {
 		t, err := time.Parse(time.RFC3339Nano, value)
 		if err != nil {
 			t, err = time.Parse("2006-01-02T15:04:05", value)
-			if err != nil {
-				return fmt.Errorf("invalid time format: %s", value)
-			}
+		}
+		if err != nil {
+			t, err = time.Parse(time.DateOnly, value)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid time format: %s", value)
 		}
 		ft.Time = t.UTC()
 	default:
@@ -198,6 +201,36 @@ type CloseTicket struct {
 	DoubleSigned   bool       `json:"double_signed" db:"double_signed"`
 }
 
+// TicketAccess holds the ticket fields that authorization decisions depend on.
+type TicketAccess struct {
+	ID         uuid.UUID  `db:"id"`
+	Executor   *uuid.UUID `db:"executor"`
+	Department *uuid.UUID `db:"department"`
+}
+
+// TicketAssignment is the executor, device and assigned interval of a ticket.
+type TicketAssignment struct {
+	Executor      *uuid.UUID `db:"executor"`
+	Device        *uuid.UUID `db:"device"`
+	AssignedStart *time.Time `db:"assigned_start"`
+	AssignedEnd   *time.Time `db:"assigned_end"`
+}
+
+// TicketAssignmentResult answers a create or update that sets the executor.
+// Warnings don't block the assignment, it is saved either way.
+type TicketAssignmentResult struct {
+	ID       string   `json:"id"`
+	Warnings []string `json:"warnings"`
+}
+
+// TicketClientManagers identifies a ticket's client and the users managing it.
+type TicketClientManagers struct {
+	ID          uuid.UUID      `db:"id"`
+	Number      string         `db:"number"`
+	ClientTitle string         `db:"client_title"`
+	Managers    pq.StringArray `db:"managers"`
+}
+
 type TicketFilters struct {
 	Department string     `json:"department"`
 	Status     string     `json:"status"`
@@ -4,6 +4,7 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -65,6 +66,10 @@ type User struct {
 	Properties    *JSONBMap  `json:"properties" db:"properties"` // INSTEAD OF latest_ticket field from DB
 	ActiveTickets *int       `json:"active_tickets" db:"active_tickets"`
 	LatestTicket  *uuid.UUID `json:"latest_ticket" db:"latest_ticket"`
+	// Availability is "available" or the type of the current absence, only
+	// filled in department listings
+	Availability string     `json:"availability,omitempty" db:"availability"`
+	AbsentUntil  *time.Time `json:"absent_until,omitempty" db:"absent_until"`
 }
 
 type UserProfile struct {
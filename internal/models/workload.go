@@ -0,0 +1,69 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// scanJSON decodes a json or jsonb column into dst.
+func scanJSON(value any, dst any) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("cannot scan %T into %T", value, dst)
+	}
+
+	return json.Unmarshal(bytes, dst)
+}
+
+// StatusCounts maps ticket statuses to ticket counts.
+type StatusCounts map[string]int
+
+func (c *StatusCounts) Scan(value any) error {
+	return scanJSON(value, (*map[string]int)(c))
+}
+
+// WorkloadTicket is the current or next ticket of a user in the workload
+// dashboard. ElapsedMinutes is only set for the current ticket.
+type WorkloadTicket struct {
+	ID             uuid.UUID  `json:"id"`
+	Number         int        `json:"number"`
+	Status         string     `json:"status"`
+	ClientName     *string    `json:"client_name"`
+	WorkStartedAt  *time.Time `json:"workstarted_at,omitempty"`
+	ElapsedMinutes *float64   `json:"elapsed_minutes,omitempty"`
+	AssignedStart  *time.Time `json:"assigned_start"`
+	AssignedEnd    *time.Time `json:"assigned_end"`
+}
+
+func (t *WorkloadTicket) Scan(value any) error {
+	return scanJSON(value, t)
+}
+
+type WorkloadAbsence struct {
+	ID   uuid.UUID `json:"id"`
+	Type string    `json:"type"`
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+type WorkloadAbsences []WorkloadAbsence
+
+func (a *WorkloadAbsences) Scan(value any) error {
+	return scanJSON(value, (*[]WorkloadAbsence)(a))
+}
+
+// UserWorkload is a row of the department workload dashboard.
+type UserWorkload struct {
+	UserID          uuid.UUID        `json:"user_id" db:"user_id"`
+	FirstName       string           `json:"firstName" db:"first_name"`
+	LastName        string           `json:"lastName" db:"last_name"`
+	ActiveTickets   int              `json:"active_tickets" db:"active_tickets"`
+	TicketsByStatus StatusCounts     `json:"tickets_by_status" db:"tickets_by_status"`
+	Overdue         int              `json:"overdue" db:"overdue"`
+	CurrentTicket   *WorkloadTicket  `json:"current_ticket" db:"current_ticket"`
+	NextAssignment  *WorkloadTicket  `json:"next_assignment" db:"next_assignment"`
+	AbsencesToday   WorkloadAbsences `json:"absences_today" db:"absences_today"`
+}
@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+type AbsenceRepo interface {
+	ListAbsences(ctx context.Context, filter models.AbsenceFilter) ([]*models.Absence, error)
+	GetAbsence(ctx context.Context, id uuid.UUID) (*models.Absence, error)
+	CreateAbsence(ctx context.Context, absence models.Absence) (*models.Absence, error)
+	UpdateAbsence(ctx context.Context, absence models.Absence) (*models.Absence, error)
+	DeleteAbsence(ctx context.Context, id uuid.UUID) (bool, error)
+	GetUserDepartment(ctx context.Context, userID uuid.UUID) (department *uuid.UUID, exists bool, err error)
+}
+
+type absenceRepo struct {
+	db *sqlx.DB
+}
+
+func NewAbsenceRepo(db *sqlx.DB) AbsenceRepo {
+	return &absenceRepo{db}
+}
+
+func (r *absenceRepo) ListAbsences(ctx context.Context, filter models.AbsenceFilter) ([]*models.Absence, error) {
+	query := `
+		SELECT a.*, u.first_name, u.last_name
+		FROM user_absences a
+		JOIN users u ON u.user_id = a.user_id
+		WHERE a.starts_at < $4 AND a.ends_at > $3
+			AND ($1::uuid IS NULL OR a.user_id = $1)
+			AND ($2::uuid IS NULL OR u.department = $2)
+		ORDER BY a.starts_at, u.last_name, u.first_name
+	`
+
+	absences := []*models.Absence{}
+
+	err := r.db.SelectContext(ctx, &absences, query, filter.UserID, filter.Department, filter.From, filter.To)
+	if err != nil {
+		return nil, err
+	}
+
+	return absences, nil
+}
+
+func (r *absenceRepo) GetAbsence(ctx context.Context, id uuid.UUID) (*models.Absence, error) {
+	var absence models.Absence
+
+	err := r.db.GetContext(ctx, &absence, `SELECT * FROM user_absences WHERE id = $1`, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &absence, nil
+}
+
+func (r *absenceRepo) CreateAbsence(ctx context.Context, absence models.Absence) (*models.Absence, error) {
+	query := `
+		INSERT INTO user_absences (user_id, absence_type, starts_at, ends_at, comment, created_by)
+		VALUES (:user_id, :absence_type, :starts_at, :ends_at, :comment, :created_by)
+		RETURNING *
+	`
+
+	stmt, err := r.db.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var created models.Absence
+
+	err = stmt.GetContext(ctx, &created, absence)
+	if err != nil {
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+func (r *absenceRepo) UpdateAbsence(ctx context.Context, absence models.Absence) (*models.Absence, error) {
+	query := `
+		UPDATE user_absences
+		SET absence_type = :absence_type, starts_at = :starts_at, ends_at = :ends_at, comment = :comment
+		WHERE id = :id
+		RETURNING *
+	`
+
+	stmt, err := r.db.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var updated models.Absence
+
+	err = stmt.GetContext(ctx, &updated, absence)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+func (r *absenceRepo) DeleteAbsence(ctx context.Context, id uuid.UUID) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM user_absences WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func (r *absenceRepo) GetUserDepartment(ctx context.Context, userID uuid.UUID) (*uuid.UUID, bool, error) {
+	var department *uuid.UUID
+
+	err := r.db.GetContext(ctx, &department, `SELECT department FROM users WHERE user_id = $1`, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	return department, true, nil
+}
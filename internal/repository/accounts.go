@@ -3,19 +3,41 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 
 	"github.com/google/uuid"
 	"github.com/grintheone/foxygen-server/internal/models"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
+var (
+	ErrUnknownRole   = errors.New("unknown role")
+	ErrUsernameTaken = errors.New("username is already taken")
+)
+
+// isUniqueViolation reports whether err is a Postgres unique constraint violation.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
 type AccountRepository interface {
-	CreateAccountWithRoles(ctx context.Context, account *models.Account, roleID int) (*models.Account, error)
+	CreateAccountWithRoles(ctx context.Context, account *models.Account, roles []string) (*models.Account, error)
+	SetAccountRoles(ctx context.Context, userID uuid.UUID, roles []string) error
 	GetByUsername(ctx context.Context, username string) (*models.Account, error)
 	GetByID(ctx context.Context, userID uuid.UUID) (*models.Account, error)
 	ChangeAccountPassword(ctx context.Context, userID uuid.UUID, hash string) error
 	ChangeAccountStatus(ctx context.Context, userID uuid.UUID, disabled bool) error
+	GetAccountState(ctx context.Context, userID uuid.UUID) (*models.AccountState, error)
+	RevokeTokens(ctx context.Context, userID uuid.UUID) error
+	ListAccounts(ctx context.Context, filter models.AccountFilter) ([]*models.AccountSummary, error)
+	GetAccountSummary(ctx context.Context, userID uuid.UUID) (*models.AccountSummary, error)
+	CreateAccountWithProfile(ctx context.Context, account *models.Account, roles []string, profile models.User) error
+	RenameAccount(ctx context.Context, userID uuid.UUID, username string) error
+	ResetAccountPassword(ctx context.Context, userID uuid.UUID, hash string) error
+	SetLastLogin(ctx context.Context, userID uuid.UUID) error
 }
 
 type accountRepository struct {
@@ -26,7 +48,7 @@ func NewAccountRepository(db *sqlx.DB) AccountRepository {
 	return &accountRepository{db}
 }
 
-func (r *accountRepository) CreateAccountWithRoles(ctx context.Context, account *models.Account, roleID int) (*models.Account, error) {
+func (r *accountRepository) CreateAccountWithRoles(ctx context.Context, account *models.Account, roles []string) (*models.Account, error) {
 	// Begin a transaction
 	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
@@ -40,16 +62,6 @@ func (r *accountRepository) CreateAccountWithRoles(ctx context.Context, account
 		account.UserID = userID
 	}
 
-	// Set current account role
-	switch roleID {
-	case 1:
-		account.Role = "admin"
-	case 2:
-		account.Role = "coordinator"
-	case 3:
-		account.Role = "user"
-	}
-
 	// Insert the new user into the 'accounts' table
 	query := `INSERT INTO accounts (user_id, username, password_hash) VALUES ($1, $2, $3) ON CONFLICT (username) DO NOTHING`
 	result, err := tx.ExecContext(ctx, query, account.UserID, account.Username, account.PasswordHash)
@@ -63,11 +75,8 @@ func (r *accountRepository) CreateAccountWithRoles(ctx context.Context, account
 	}
 
 	if affected > 0 {
-		// Insert the role associations into the 'account_roles' table
-		accRoleQuery := `INSERT INTO account_roles (user_id, role_id) VALUES ($1, $2) ON CONFLICT (user_id) DO NOTHING`
-		_, err = tx.ExecContext(ctx, accRoleQuery, account.UserID, roleID)
-		if err != nil {
-			return nil, fmt.Errorf("could not assign roles to account: %w", err)
+		if err := setAccountRoles(ctx, tx, account.UserID, roles); err != nil {
+			return nil, err
 		}
 	}
 
@@ -76,22 +85,88 @@ func (r *accountRepository) CreateAccountWithRoles(ctx context.Context, account
 		return nil, fmt.Errorf("could not commit transaction: %w", err)
 	}
 
+	account.Role = roles[0]
+	account.Roles = roles
+
 	return account, nil
 }
 
-func (r *accountRepository) GetByUsername(ctx context.Context, username string) (*models.Account, error) {
+// setAccountRoles replaces the roles of an account with the given role names.
+// It fails if any of the names doesn't exist.
+func setAccountRoles(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID, roles []string) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM account_roles WHERE user_id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("could not clear account roles: %w", err)
+	}
+
 	query := `
-	   	SELECT
-	        a.user_id,
-	        a.username,
-	        a.disabled,
-	        a.password_hash,
-	        r.name as role
-	    FROM accounts a
-	    LEFT JOIN account_roles ar ON a.user_id = ar.user_id
-	    LEFT JOIN roles r ON ar.role_id = r.id
-	    WHERE a.username = $1;
-    `
+		INSERT INTO account_roles (user_id, role_id)
+		SELECT $1, id FROM roles WHERE name = ANY($2)
+		ON CONFLICT DO NOTHING
+	`
+	result, err := tx.ExecContext(ctx, query, userID, pq.Array(roles))
+	if err != nil {
+		return fmt.Errorf("could not assign roles to account: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if int(affected) != len(roles) {
+		return ErrUnknownRole
+	}
+
+	return nil
+}
+
+func (r *accountRepository) SetAccountRoles(ctx context.Context, userID uuid.UUID, roles []string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := setAccountRoles(ctx, tx, userID, roles); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// accountColumns selects an account together with its roles and the union of
+// their permissions. The primary role is the most privileged one, i.e. the
+// role with the lowest ID.
+const accountColumns = `
+	a.user_id,
+	a.username,
+	a.disabled,
+	a.password_hash,
+	a.tokens_valid_after,
+	COALESCE(a.totp_enabled, false) as totp_enabled,
+	COALESCE((
+		SELECT r.name FROM account_roles ar
+		JOIN roles r ON ar.role_id = r.id
+		WHERE ar.user_id = a.user_id
+		ORDER BY r.id LIMIT 1
+	), '') as role,
+	ARRAY(
+		SELECT r.name FROM account_roles ar
+		JOIN roles r ON ar.role_id = r.id
+		WHERE ar.user_id = a.user_id
+		ORDER BY r.id
+	) as roles,
+	ARRAY(
+		SELECT DISTINCT rp.permission FROM account_roles ar
+		JOIN role_permissions rp ON ar.role_id = rp.role_id
+		WHERE ar.user_id = a.user_id
+		ORDER BY rp.permission
+	) as permissions
+`
+
+func (r *accountRepository) GetByUsername(ctx context.Context, username string) (*models.Account, error) {
+	query := `SELECT ` + accountColumns + ` FROM accounts a WHERE a.username = $1`
 
 	var account models.Account
 
@@ -109,18 +184,7 @@ func (r *accountRepository) GetByUsername(ctx context.Context, username string)
 }
 
 func (r *accountRepository) GetByID(ctx context.Context, userID uuid.UUID) (*models.Account, error) {
-	query := `
-	   	SELECT
-	        a.user_id,
-	        a.username,
-	        a.disabled,
-	        a.password_hash,
-	        r.name as role
-	    FROM accounts a
-	    LEFT JOIN account_roles ar ON a.user_id = ar.user_id
-	    LEFT JOIN roles r ON ar.role_id = r.id
-	    WHERE a.user_id = $1;
-    `
+	query := `SELECT ` + accountColumns + ` FROM accounts a WHERE a.user_id = $1`
 
 	var account models.Account
 
@@ -147,7 +211,14 @@ func (r *accountRepository) ChangeAccountPassword(ctx context.Context, userID uu
 }
 
 func (r *accountRepository) ChangeAccountStatus(ctx context.Context, userID uuid.UUID, disabled bool) error {
-	query := `UPDATE accounts SET disabled = $1 WHERE user_id = $2`
+	// Disabling an account also revokes every token issued so far,
+	// so re-enabling it later doesn't bring old sessions back to life.
+	query := `
+		UPDATE accounts
+		SET disabled = $1,
+			tokens_valid_after = CASE WHEN $1 THEN NOW() AT TIME ZONE 'UTC' ELSE tokens_valid_after END
+		WHERE user_id = $2
+	`
 
 	_, err := r.db.ExecContext(ctx, query, disabled, userID)
 	if err != nil {
@@ -156,3 +227,180 @@ func (r *accountRepository) ChangeAccountStatus(ctx context.Context, userID uuid
 
 	return nil
 }
+
+func (r *accountRepository) GetAccountState(ctx context.Context, userID uuid.UUID) (*models.AccountState, error) {
+	query := `SELECT user_id, disabled, tokens_valid_after FROM accounts WHERE user_id = $1`
+
+	var state models.AccountState
+
+	err := r.db.GetContext(ctx, &state, query, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get account state: %w", err)
+	}
+
+	return &state, nil
+}
+
+func (r *accountRepository) RevokeTokens(ctx context.Context, userID uuid.UUID) error {
+	query := `UPDATE accounts SET tokens_valid_after = NOW() AT TIME ZONE 'UTC' WHERE user_id = $1`
+
+	_, err := r.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// accountSummaryQuery selects accounts for the admin API. The primary role
+// follows the same rule as accountColumns.
+const accountSummaryQuery = `
+	SELECT
+		a.user_id,
+		a.username,
+		COALESCE(a.disabled, false) as disabled,
+		COALESCE((
+			SELECT r.name FROM account_roles ar
+			JOIN roles r ON ar.role_id = r.id
+			WHERE ar.user_id = a.user_id
+			ORDER BY r.id LIMIT 1
+		), '') as role,
+		ARRAY(
+			SELECT r.name FROM account_roles ar
+			JOIN roles r ON ar.role_id = r.id
+			WHERE ar.user_id = a.user_id
+			ORDER BY r.id
+		) as roles,
+		COALESCE(u.first_name, '') as first_name,
+		COALESCE(u.last_name, '') as last_name,
+		COALESCE(u.email, '') as email,
+		u.department,
+		COALESCE(d.title, '') as department_title,
+		u.user_id IS NOT NULL as has_profile,
+		COALESCE(a.totp_enabled, false) as totp_enabled,
+		a.created_at,
+		a.last_login_at
+	FROM accounts a
+	LEFT JOIN users u ON u.user_id = a.user_id
+	LEFT JOIN departments d ON d.id = u.department
+`
+
+func (r *accountRepository) ListAccounts(ctx context.Context, filter models.AccountFilter) ([]*models.AccountSummary, error) {
+	query := accountSummaryQuery + `
+		WHERE ($1 = '' OR a.username ILIKE '%' || $1 || '%'
+				OR CONCAT(u.first_name, ' ', u.last_name) ILIKE '%' || $1 || '%'
+				OR u.email ILIKE '%' || $1 || '%')
+			AND ($2 = '' OR EXISTS (
+				SELECT 1 FROM account_roles ar
+				JOIN roles r ON ar.role_id = r.id
+				WHERE ar.user_id = a.user_id AND r.name = $2
+			))
+			AND ($3::uuid IS NULL OR u.department = $3)
+			AND ($4::boolean IS NULL OR COALESCE(a.disabled, false) = $4)
+		ORDER BY a.username
+		LIMIT $5 OFFSET $6
+	`
+
+	var accounts []*models.AccountSummary
+
+	err := r.db.SelectContext(ctx, &accounts, query, filter.Search, filter.Role, filter.Department, filter.Disabled, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list accounts: %w", err)
+	}
+
+	return accounts, nil
+}
+
+func (r *accountRepository) GetAccountSummary(ctx context.Context, userID uuid.UUID) (*models.AccountSummary, error) {
+	query := accountSummaryQuery + ` WHERE a.user_id = $1`
+
+	var account models.AccountSummary
+
+	err := r.db.GetContext(ctx, &account, query, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get account: %w", err)
+	}
+
+	return &account, nil
+}
+
+// CreateAccountWithProfile creates the account, its roles and its users row
+// in one transaction, so an engineer never ends up without a profile.
+func (r *accountRepository) CreateAccountWithProfile(ctx context.Context, account *models.Account, roles []string, profile models.User) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if account.UserID == uuid.Nil {
+		account.UserID = uuid.New()
+	}
+
+	query := `INSERT INTO accounts (user_id, username, password_hash) VALUES ($1, $2, $3)`
+	_, err = tx.ExecContext(ctx, query, account.UserID, account.Username, account.PasswordHash)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrUsernameTaken
+		}
+		return fmt.Errorf("could not insert account: %w", err)
+	}
+
+	if err := setAccountRoles(ctx, tx, account.UserID, roles); err != nil {
+		return err
+	}
+
+	profile.UserID = account.UserID
+	query = `
+		INSERT INTO users (user_id, first_name, last_name, department, email, phone, logo)
+		VALUES (:user_id, :first_name, :last_name, :department, :email, :phone, :logo)
+	`
+	if _, err := tx.NamedExecContext(ctx, query, profile); err != nil {
+		return fmt.Errorf("could not insert user profile: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (r *accountRepository) RenameAccount(ctx context.Context, userID uuid.UUID, username string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE accounts SET username = $1 WHERE user_id = $2`, username, userID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrUsernameTaken
+		}
+		return err
+	}
+
+	return nil
+}
+
+// ResetAccountPassword sets a new password hash and revokes all tokens issued so far.
+func (r *accountRepository) ResetAccountPassword(ctx context.Context, userID uuid.UUID, hash string) error {
+	query := `
+		UPDATE accounts
+		SET password_hash = $1, tokens_valid_after = NOW() AT TIME ZONE 'UTC'
+		WHERE user_id = $2
+	`
+
+	_, err := r.db.ExecContext(ctx, query, hash, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *accountRepository) SetLastLogin(ctx context.Context, userID uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE accounts SET last_login_at = NOW() AT TIME ZONE 'UTC' WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
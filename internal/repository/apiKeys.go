@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type APIKeyRepo interface {
+	ListKeys(ctx context.Context) ([]*models.APIKey, error)
+	GetKeyByID(ctx context.Context, id uuid.UUID) (*models.APIKey, error)
+	GetKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error)
+	CreateKey(ctx context.Context, key models.APIKey) (*models.APIKey, error)
+	RotateKey(ctx context.Context, id uuid.UUID, prefix, keyHash string) (*models.APIKey, error)
+	RevokeKey(ctx context.Context, id uuid.UUID) (bool, error)
+	TouchKey(ctx context.Context, id uuid.UUID) error
+}
+
+type apiKeyRepo struct {
+	db *sqlx.DB
+}
+
+func NewAPIKeyRepo(db *sqlx.DB) APIKeyRepo {
+	return &apiKeyRepo{db}
+}
+
+func (r *apiKeyRepo) ListKeys(ctx context.Context) ([]*models.APIKey, error) {
+	var keys []*models.APIKey
+
+	err := r.db.SelectContext(ctx, &keys, `SELECT * FROM api_keys ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (r *apiKeyRepo) GetKeyByID(ctx context.Context, id uuid.UUID) (*models.APIKey, error) {
+	var key models.APIKey
+
+	err := r.db.GetContext(ctx, &key, `SELECT * FROM api_keys WHERE id = $1`, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+func (r *apiKeyRepo) GetKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	var key models.APIKey
+
+	err := r.db.GetContext(ctx, &key, `SELECT * FROM api_keys WHERE key_hash = $1`, keyHash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// CreateKey inserts a key after checking that every permission exists.
+func (r *apiKeyRepo) CreateKey(ctx context.Context, key models.APIKey) (*models.APIKey, error) {
+	var known int
+
+	err := r.db.GetContext(ctx, &known, `SELECT COUNT(*) FROM permissions WHERE name = ANY($1)`, pq.Array(key.Permissions))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check permissions: %w", err)
+	}
+	if known != len(key.Permissions) {
+		return nil, ErrUnknownPermission
+	}
+
+	query := `
+		INSERT INTO api_keys (name, prefix, key_hash, permissions, created_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING *
+	`
+
+	var created models.APIKey
+
+	err = r.db.GetContext(ctx, &created, query, key.Name, key.Prefix, key.KeyHash, key.Permissions, key.CreatedBy, key.ExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create api key: %w", err)
+	}
+
+	return &created, nil
+}
+
+// RotateKey replaces the secret of a key that is not revoked. The old secret
+// stops working immediately.
+func (r *apiKeyRepo) RotateKey(ctx context.Context, id uuid.UUID, prefix, keyHash string) (*models.APIKey, error) {
+	query := `
+		UPDATE api_keys
+		SET prefix = $1, key_hash = $2, rotated_at = NOW() AT TIME ZONE 'UTC'
+		WHERE id = $3 AND revoked_at IS NULL
+		RETURNING *
+	`
+
+	var key models.APIKey
+
+	err := r.db.GetContext(ctx, &key, query, prefix, keyHash, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to rotate api key: %w", err)
+	}
+
+	return &key, nil
+}
+
+func (r *apiKeyRepo) RevokeKey(ctx context.Context, id uuid.UUID) (bool, error) {
+	query := `UPDATE api_keys SET revoked_at = NOW() AT TIME ZONE 'UTC' WHERE id = $1 AND revoked_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// TouchKey updates last_used_at, at most once a minute per key.
+func (r *apiKeyRepo) TouchKey(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE api_keys SET last_used_at = NOW() AT TIME ZONE 'UTC'
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < (NOW() AT TIME ZONE 'UTC') - INTERVAL '1 minute')
+	`
+
+	_, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
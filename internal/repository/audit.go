@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+type AuditRepo interface {
+	AddEntry(ctx context.Context, entry models.AuditEntry) error
+	ListEntries(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEntry, error)
+}
+
+type auditRepo struct {
+	db *sqlx.DB
+}
+
+func NewAuditRepo(db *sqlx.DB) AuditRepo {
+	return &auditRepo{db}
+}
+
+func (r *auditRepo) AddEntry(ctx context.Context, entry models.AuditEntry) error {
+	query := `
+		INSERT INTO audit_log (actor_type, actor_id, impersonator_id, method, path, status, request_id)
+		VALUES (:actor_type, :actor_id, :impersonator_id, :method, :path, :status, :request_id)
+	`
+
+	_, err := r.db.NamedExecContext(ctx, query, entry)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *auditRepo) ListEntries(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEntry, error) {
+	query := `
+		SELECT * FROM audit_log
+		WHERE ($1::uuid IS NULL OR actor_id = $1)
+			AND ($2::uuid IS NULL OR impersonator_id = $2)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $4
+	`
+
+	var entries []*models.AuditEntry
+
+	err := r.db.SelectContext(ctx, &entries, query, filter.ActorID, filter.ImpersonatorID, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type AuthAuditRepo interface {
+	GetThrottles(ctx context.Context, keys []string) ([]*models.LoginThrottle, error)
+	RegisterFailure(ctx context.Context, key string, window time.Duration) (int, error)
+	SetBlockedUntil(ctx context.Context, key string, until time.Time) error
+	ClearThrottle(ctx context.Context, key string) (bool, error)
+	ListActiveThrottles(ctx context.Context, minFailures int) ([]*models.LoginThrottle, error)
+	AddAuditEntry(ctx context.Context, entry models.AuthAuditEntry) error
+	ListAuditEntries(ctx context.Context, username string, limit int, offset int) ([]*models.AuthAuditEntry, error)
+}
+
+type authAuditRepo struct {
+	db *sqlx.DB
+}
+
+func NewAuthAuditRepo(db *sqlx.DB) AuthAuditRepo {
+	return &authAuditRepo{db}
+}
+
+func (r *authAuditRepo) GetThrottles(ctx context.Context, keys []string) ([]*models.LoginThrottle, error) {
+	var throttles []*models.LoginThrottle
+
+	err := r.db.SelectContext(ctx, &throttles, `SELECT * FROM login_throttle WHERE key = ANY($1)`, pq.Array(keys))
+	if err != nil {
+		return nil, err
+	}
+
+	return throttles, nil
+}
+
+// RegisterFailure increments the failure counter of the key and returns the new value.
+// The counter starts over when the previous failure is older than window.
+func (r *authAuditRepo) RegisterFailure(ctx context.Context, key string, window time.Duration) (int, error) {
+	query := `
+		INSERT INTO login_throttle (key, failures, last_failure_at)
+		VALUES ($1, 1, NOW() AT TIME ZONE 'UTC')
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE
+				WHEN login_throttle.last_failure_at < (NOW() AT TIME ZONE 'UTC') - $2 * INTERVAL '1 second' THEN 1
+				ELSE login_throttle.failures + 1
+			END,
+			last_failure_at = NOW() AT TIME ZONE 'UTC'
+		RETURNING failures
+	`
+
+	var failures int
+
+	err := r.db.GetContext(ctx, &failures, query, key, int(window.Seconds()))
+	if err != nil {
+		return 0, fmt.Errorf("failed to register login failure: %w", err)
+	}
+
+	return failures, nil
+}
+
+func (r *authAuditRepo) SetBlockedUntil(ctx context.Context, key string, until time.Time) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE login_throttle SET blocked_until = $1 WHERE key = $2`, until.UTC(), key)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *authAuditRepo) ClearThrottle(ctx context.Context, key string) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM login_throttle WHERE key = $1`, key)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// ListActiveThrottles returns keys that are currently blocked or have
+// accumulated at least minFailures failures.
+func (r *authAuditRepo) ListActiveThrottles(ctx context.Context, minFailures int) ([]*models.LoginThrottle, error) {
+	query := `
+		SELECT * FROM login_throttle
+		WHERE blocked_until > (NOW() AT TIME ZONE 'UTC') OR failures >= $1
+		ORDER BY last_failure_at DESC
+	`
+
+	var throttles []*models.LoginThrottle
+
+	err := r.db.SelectContext(ctx, &throttles, query, minFailures)
+	if err != nil {
+		return nil, err
+	}
+
+	return throttles, nil
+}
+
+func (r *authAuditRepo) AddAuditEntry(ctx context.Context, entry models.AuthAuditEntry) error {
+	query := `
+		INSERT INTO auth_audit_log (event, success, actor_type, username, user_id, ip, details)
+		VALUES (:event, :success, :actor_type, :username, :user_id, :ip, :details)
+	`
+
+	_, err := r.db.NamedExecContext(ctx, query, entry)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *authAuditRepo) ListAuditEntries(ctx context.Context, username string, limit int, offset int) ([]*models.AuthAuditEntry, error) {
+	query := `
+		SELECT * FROM auth_audit_log
+		WHERE ($1 = '' OR username = $1)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	var entries []*models.AuthAuditEntry
+
+	err := r.db.SelectContext(ctx, &entries, query, username, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,258 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrImportApplied   = errors.New("import has already been applied")
+	ErrImportHasErrors = errors.New("import has rows with errors")
+)
+
+// clientKeyExpr is the title and address of client c without case, spaces
+// and punctuation. Imported rows are matched to clients by it.
+const clientKeyExpr = `regexp_replace(lower(c.title), '[^[:alnum:]]+', '', 'g') || '|' || regexp_replace(lower(COALESCE(c.address, '')), '[^[:alnum:]]+', '', 'g')`
+
+type ClientImportRepo interface {
+	CreateImport(ctx context.Context, clientImport models.ClientImport) (*models.ClientImport, error)
+	GetImport(ctx context.Context, id uuid.UUID) (*models.ClientImport, error)
+	ExistingClients(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]bool, error)
+	MatchClientKeys(ctx context.Context, keys []string) (map[string]uuid.UUID, error)
+	ApplyImport(ctx context.Context, id, appliedBy uuid.UUID, keys map[int]string, skipInvalid bool) (*models.ClientImport, error)
+}
+
+type clientImportRepo struct {
+	db *sqlx.DB
+}
+
+func NewClientImportRepo(db *sqlx.DB) ClientImportRepo {
+	return &clientImportRepo{db}
+}
+
+func (r *clientImportRepo) CreateImport(ctx context.Context, clientImport models.ClientImport) (*models.ClientImport, error) {
+	query := `
+		INSERT INTO client_imports (filename, columns, rows, error_rows, created_by)
+		VALUES (:filename, :columns, :rows, :error_rows, :created_by)
+		RETURNING *
+	`
+
+	stmt, err := r.db.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var created models.ClientImport
+
+	err = stmt.GetContext(ctx, &created, clientImport)
+	if err != nil {
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+func (r *clientImportRepo) GetImport(ctx context.Context, id uuid.UUID) (*models.ClientImport, error) {
+	var clientImport models.ClientImport
+
+	err := r.db.GetContext(ctx, &clientImport, `SELECT * FROM client_imports WHERE id = $1`, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &clientImport, nil
+}
+
+func (r *clientImportRepo) ExistingClients(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]bool, error) {
+	existing := map[uuid.UUID]bool{}
+	if len(ids) == 0 {
+		return existing, nil
+	}
+
+	var found []uuid.UUID
+
+	err := r.db.SelectContext(ctx, &found, `SELECT id FROM clients WHERE id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range found {
+		existing[id] = true
+	}
+
+	return existing, nil
+}
+
+// MatchClientKeys finds clients by title and address key, see clientKeyExpr.
+func (r *clientImportRepo) MatchClientKeys(ctx context.Context, keys []string) (map[string]uuid.UUID, error) {
+	matches := map[string]uuid.UUID{}
+	if len(keys) == 0 {
+		return matches, nil
+	}
+
+	query := `
+		SELECT DISTINCT ON (key) k.key, c.id
+		FROM clients c
+		JOIN unnest($1::text[]) AS k(key) ON k.key = ` + clientKeyExpr + `
+		ORDER BY key, c.id
+	`
+
+	var rows []struct {
+		Key string    `db:"key"`
+		ID  uuid.UUID `db:"id"`
+	}
+
+	err := r.db.SelectContext(ctx, &rows, query, pq.Array(keys))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		matches[row.Key] = row.ID
+	}
+
+	return matches, nil
+}
+
+// ApplyImport upserts the clients and contacts of the import in one
+// transaction. keys holds the client key of each row by row number. Rows with
+// errors are skipped with skipInvalid, otherwise they fail the import.
+func (r *clientImportRepo) ApplyImport(ctx context.Context, id, appliedBy uuid.UUID, keys map[int]string, skipInvalid bool) (*models.ClientImport, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var clientImport models.ClientImport
+
+	err = tx.GetContext(ctx, &clientImport, `SELECT * FROM client_imports WHERE id = $1 FOR UPDATE`, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if clientImport.AppliedAt != nil {
+		return nil, ErrImportApplied
+	}
+	if clientImport.ErrorRows > 0 && !skipInvalid {
+		return nil, ErrImportHasErrors
+	}
+
+	upsertClient := `
+		INSERT INTO clients (id, title, address, region, location)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (id) DO UPDATE SET
+			title = EXCLUDED.title,
+			address = COALESCE(NULLIF(EXCLUDED.address, ''), clients.address),
+			region = COALESCE(EXCLUDED.region, clients.region),
+			location = COALESCE(EXCLUDED.location, clients.location)
+		RETURNING xmax = 0 AS inserted
+	`
+
+	result := models.ClientImportResult{}
+	clientIDs := map[string]uuid.UUID{}
+	seen := map[uuid.UUID]bool{}
+
+	for _, row := range clientImport.Rows {
+		if len(row.Errors) > 0 {
+			result.SkippedRows++
+			continue
+		}
+
+		key := keys[row.Row]
+		clientID, known := clientIDs[key]
+		if row.ClientID != nil {
+			clientID, known = *row.ClientID, true
+		}
+		if !known {
+			query := `SELECT c.id FROM clients c WHERE ` + clientKeyExpr + ` = $1 ORDER BY c.id LIMIT 1`
+			err := tx.GetContext(ctx, &clientID, query, key)
+			switch {
+			case err == sql.ErrNoRows:
+				clientID = uuid.New()
+			case err != nil:
+				return nil, fmt.Errorf("row %d: %w", row.Row, err)
+			}
+		}
+		clientIDs[key] = clientID
+
+		var location *models.Locations
+		if row.Lat != nil && row.Lng != nil {
+			location = &models.Locations{{Lat: *row.Lat, Lng: *row.Lng}}
+		}
+
+		var inserted bool
+		err := tx.GetContext(ctx, &inserted, upsertClient, clientID, row.Title, row.Address, row.Region, location)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", row.Row, err)
+		}
+		if !seen[clientID] {
+			seen[clientID] = true
+			if inserted {
+				result.ClientsCreated++
+			} else {
+				result.ClientsUpdated++
+			}
+		}
+
+		if row.Contact == nil {
+			continue
+		}
+
+		var contactID uuid.UUID
+		err = tx.GetContext(ctx, &contactID, `SELECT id FROM contacts WHERE client_id = $1 AND lower(name) = lower($2) ORDER BY id LIMIT 1`, clientID, row.Contact.Name)
+		switch {
+		case err == sql.ErrNoRows:
+			_, err = tx.ExecContext(ctx, `INSERT INTO contacts (name, position, phone, phone_e164, email, client_id) VALUES ($1, $2, $3, $4, $5, $6)`,
+				row.Contact.Name, row.Contact.Position, row.Contact.Phone, row.Contact.PhoneE164, row.Contact.Email, clientID)
+			if err != nil {
+				return nil, fmt.Errorf("row %d: %w", row.Row, err)
+			}
+			result.ContactsCreated++
+		case err != nil:
+			return nil, fmt.Errorf("row %d: %w", row.Row, err)
+		default:
+			query := `
+				UPDATE contacts SET
+					position = COALESCE(NULLIF($2, ''), position),
+					phone = COALESCE(NULLIF($3, ''), phone),
+					phone_e164 = CASE WHEN $3 = '' THEN phone_e164 ELSE $4 END,
+					email = COALESCE(NULLIF($5, ''), email)
+				WHERE id = $1
+			`
+			_, err = tx.ExecContext(ctx, query, contactID, row.Contact.Position, row.Contact.Phone, row.Contact.PhoneE164, row.Contact.Email)
+			if err != nil {
+				return nil, fmt.Errorf("row %d: %w", row.Row, err)
+			}
+			result.ContactsUpdated++
+		}
+	}
+
+	err = tx.GetContext(ctx, &clientImport, `
+		UPDATE client_imports SET applied_by = $2, applied_at = NOW() AT TIME ZONE 'UTC', result = $3
+		WHERE id = $1
+		RETURNING *
+	`, id, appliedBy, result)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &clientImport, nil
+}
@@ -3,18 +3,38 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 
 	"github.com/google/uuid"
 	"github.com/grintheone/foxygen-server/internal/models"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
+var ErrUnknownClientReference = errors.New("unknown region or laboratory system")
+
 type ClientsRepository interface {
 	ListClients(ctx context.Context, limit int, offset int, sortByTitle bool, search string) (*[]models.Client, error)
 	CreateClient(ctx context.Context, payload models.Client) error
 	UpdateClient(ctx context.Context, uuid uuid.UUID, payload models.ClientUpdate) (*models.Client, error)
 	DeleteClient(ctx context.Context, uuid uuid.UUID) error
 	GetClientByID(ctx context.Context, uuid uuid.UUID) (*models.Client, error)
+	ListNearbyClients(ctx context.Context, lat, lng, radiusKm float64, box models.BoundingBox, limit int) ([]*models.NearbyClient, error)
+	ListClientPins(ctx context.Context, box models.BoundingBox, limit int) ([]*models.ClientPin, error)
+	ListManagedClients(ctx context.Context, managerID uuid.UUID) ([]*models.Client, error)
+	ReassignManager(ctx context.Context, from uuid.UUID, to *uuid.UUID, clientIDs []uuid.UUID) (int64, error)
+	ListDuplicateCandidates(ctx context.Context, maxDistanceKm float64) ([]*models.DuplicateCandidate, error)
+	MergeClients(ctx context.Context, targetID uuid.UUID, duplicateIDs []uuid.UUID, mergedBy uuid.UUID) (*models.ClientMergeResult, error)
+	GetClientOverview(ctx context.Context, id uuid.UUID, closedLimit int) (*models.ClientOverview, error)
+}
+
+// resolvedClientID is the SQL expression for the client id in param after
+// following the redirect left by a merge, so the ids of merged clients keep
+// addressing the surviving one. Every query that looks a client up by an id
+// taken from a request goes through it.
+func resolvedClientID(param string) string {
+	return `COALESCE((SELECT client_id FROM client_redirects WHERE old_id = ` + param + `), ` + param + `)`
 }
 
 type clientsRepository struct {
@@ -50,6 +70,9 @@ func (r *clientsRepository) CreateClient(ctx context.Context, payload models.Cli
 
 	_, err := r.db.NamedExecContext(ctx, query, payload)
 	if err != nil {
+		if isForeignKeyViolation(err) {
+			return ErrUnknownClientReference
+		}
 		return err
 	}
 
@@ -59,7 +82,7 @@ func (r *clientsRepository) CreateClient(ctx context.Context, payload models.Cli
 func (r *clientsRepository) UpdateClient(ctx context.Context, uuid uuid.UUID, payload models.ClientUpdate) (*models.Client, error) {
 	var existing models.Client
 
-	err := r.db.GetContext(ctx, &existing, `SELECT * FROM clients WHERE id = $1`, uuid)
+	err := r.db.GetContext(ctx, &existing, `SELECT * FROM clients WHERE id = `+resolvedClientID("$1"), uuid)
 	if err != nil {
 		return nil, err
 	}
@@ -92,6 +115,9 @@ func (r *clientsRepository) UpdateClient(ctx context.Context, uuid uuid.UUID, pa
 
 	_, err = r.db.NamedExecContext(ctx, query, &existing)
 	if err != nil {
+		if isForeignKeyViolation(err) {
+			return nil, ErrUnknownClientReference
+		}
 		return nil, err
 	}
 
@@ -99,7 +125,7 @@ func (r *clientsRepository) UpdateClient(ctx context.Context, uuid uuid.UUID, pa
 }
 
 func (r *clientsRepository) DeleteClient(ctx context.Context, uuid uuid.UUID) error {
-	query := `DELETE FROM clients WHERE id = $1`
+	query := `DELETE FROM clients WHERE id = ` + resolvedClientID("$1")
 
 	_, err := r.db.ExecContext(ctx, query, uuid)
 	if err != nil {
@@ -109,8 +135,10 @@ func (r *clientsRepository) DeleteClient(ctx context.Context, uuid uuid.UUID) er
 	return nil
 }
 
+// GetClientByID follows the redirect left by a merge, so the ids of merged
+// clients resolve to the surviving one.
 func (r *clientsRepository) GetClientByID(ctx context.Context, uuid uuid.UUID) (*models.Client, error) {
-	query := `SELECT * FROM clients WHERE id = $1`
+	query := `SELECT * FROM clients WHERE id = ` + resolvedClientID("$1")
 
 	var client models.Client
 
@@ -125,3 +153,460 @@ func (r *clientsRepository) GetClientByID(ctx context.Context, uuid uuid.UUID) (
 
 	return &client, nil
 }
+
+// clientPointsCTE extracts a point per client from the location column, which
+// holds an array of {lat, lng} (the first one is used) or a single object.
+// $1..$4 are the bounding box as min lng, min lat, max lng, max lat.
+const clientPointsCTE = `
+	WITH located AS (
+		SELECT c.*, CASE jsonb_typeof(c.location)
+			WHEN 'array' THEN c.location->0
+			WHEN 'object' THEN c.location
+		END AS point
+		FROM clients c
+	),
+	points AS (
+		SELECT id, title, address, region,
+			(point->>'lat')::float8 AS lat,
+			(point->>'lng')::float8 AS lng
+		FROM located
+		WHERE jsonb_typeof(point->'lat') = 'number' AND jsonb_typeof(point->'lng') = 'number'
+	),
+	boxed AS (
+		SELECT * FROM points
+		WHERE lat BETWEEN $2::float8 AND $4::float8
+			AND CASE WHEN $1::float8 <= $3::float8
+				THEN lng BETWEEN $1::float8 AND $3::float8
+				ELSE lng >= $1::float8 OR lng <= $3::float8
+			END
+	)
+`
+
+// ListNearbyClients returns the clients within radiusKm of the point, by
+// haversine distance. box must contain the circle, it only narrows the scan.
+func (r *clientsRepository) ListNearbyClients(ctx context.Context, lat, lng, radiusKm float64, box models.BoundingBox, limit int) ([]*models.NearbyClient, error) {
+	query := clientPointsCTE + `
+	SELECT * FROM (
+		SELECT id, title, address, region, lat, lng,
+			6371.0088 * 2 * asin(LEAST(1, sqrt(
+				power(sin(radians(lat - $5::float8) / 2), 2) +
+				cos(radians($5::float8)) * cos(radians(lat)) * power(sin(radians(lng - $6::float8) / 2), 2)
+			))) AS distance_km
+		FROM boxed
+	) distances
+	WHERE distance_km <= $7::float8
+	ORDER BY distance_km
+	LIMIT $8
+	`
+
+	clients := []*models.NearbyClient{}
+
+	err := r.db.SelectContext(ctx, &clients, query, box.MinLng, box.MinLat, box.MaxLng, box.MaxLat, lat, lng, radiusKm, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return clients, nil
+}
+
+// ListClientPins returns the map pins inside the box with open ticket stats.
+func (r *clientsRepository) ListClientPins(ctx context.Context, box models.BoundingBox, limit int) ([]*models.ClientPin, error) {
+	query := clientPointsCTE + `,
+	open_tickets AS (
+		SELECT t.client, t.status, t.urgent,
+			t.assigned_end < (NOW() AT TIME ZONE 'UTC') AS overdue
+		FROM tickets t
+		JOIN boxed b ON b.id = t.client
+		WHERE t.status NOT IN ('closed', 'cancelled')
+	),
+	ranked AS (
+		SELECT DISTINCT ON (client) client, status
+		FROM open_tickets
+		ORDER BY client,
+			COALESCE(overdue, false) DESC,
+			urgent DESC,
+			CASE status WHEN 'created' THEN 0 WHEN 'assigned' THEN 1 WHEN 'inWork' THEN 2 WHEN 'worksDone' THEN 3 ELSE 4 END
+	),
+	stats AS (
+		SELECT client,
+			COUNT(*) AS open_tickets,
+			bool_or(COALESCE(overdue, false)) AS has_overdue,
+			bool_or(urgent) AS has_urgent
+		FROM open_tickets
+		GROUP BY client
+	)
+	SELECT b.id, b.title, b.lat, b.lng,
+		COALESCE(s.open_tickets, 0) AS open_tickets,
+		r.status AS most_urgent_status,
+		COALESCE(s.has_overdue, false) AS has_overdue,
+		COALESCE(s.has_urgent, false) AS has_urgent
+	FROM boxed b
+	LEFT JOIN stats s ON s.client = b.id
+	LEFT JOIN ranked r ON r.client = b.id
+	ORDER BY COALESCE(s.has_overdue, false) DESC, COALESCE(s.open_tickets, 0) DESC, b.title
+	LIMIT $5
+	`
+
+	pins := []*models.ClientPin{}
+
+	err := r.db.SelectContext(ctx, &pins, query, box.MinLng, box.MinLat, box.MaxLng, box.MaxLat, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return pins, nil
+}
+
+func (r *clientsRepository) ListManagedClients(ctx context.Context, managerID uuid.UUID) ([]*models.Client, error) {
+	query := `SELECT * FROM clients WHERE $1 = ANY(manager) ORDER BY title ASC`
+
+	clients := []*models.Client{}
+
+	err := r.db.SelectContext(ctx, &clients, query, managerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return clients, nil
+}
+
+// ReassignManager replaces from with to in the manager list of the given
+// clients, or of all clients when none are given. A nil to removes from.
+// Managers keep their order and are not duplicated.
+func (r *clientsRepository) ReassignManager(ctx context.Context, from uuid.UUID, to *uuid.UUID, clientIDs []uuid.UUID) (int64, error) {
+	query := `
+	UPDATE clients SET manager = (
+		SELECT COALESCE(array_agg(m.user_id ORDER BY m.ord), '{}')
+		FROM (
+			SELECT user_id, MIN(ord) AS ord
+			FROM unnest(
+				CASE WHEN $2::uuid IS NULL
+					THEN array_remove(manager, $1::uuid)
+					ELSE array_replace(manager, $1::uuid, $2::uuid)
+				END
+			) WITH ORDINALITY AS x(user_id, ord)
+			GROUP BY user_id
+		) m
+	)
+	WHERE $1::uuid = ANY(manager)
+	AND (cardinality($3::uuid[]) = 0 OR id = ANY($3::uuid[]))
+	`
+
+	if clientIDs == nil {
+		clientIDs = []uuid.UUID{}
+	}
+
+	result, err := r.db.ExecContext(ctx, query, from, to, pq.Array(clientIDs))
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// ListDuplicateCandidates pairs clients whose titles or addresses match once
+// case, spaces and punctuation are dropped, or whose locations are within
+// maxDistanceKm of each other.
+func (r *clientsRepository) ListDuplicateCandidates(ctx context.Context, maxDistanceKm float64) ([]*models.DuplicateCandidate, error) {
+	query := clientPointsCTE + `,
+	normalized AS (
+		SELECT c.id, c.title,
+			regexp_replace(lower(c.title), '[^[:alnum:]]+', '', 'g') AS norm_title,
+			regexp_replace(lower(COALESCE(c.address, '')), '[^[:alnum:]]+', '', 'g') AS norm_address,
+			p.lat, p.lng
+		FROM clients c
+		LEFT JOIN boxed p ON p.id = c.id
+	),
+	pairs AS (
+		SELECT a.id AS client_id, a.title AS client_title,
+			b.id AS duplicate_id, b.title AS duplicate_title,
+			a.norm_title <> '' AND a.norm_title = b.norm_title AS same_title,
+			a.norm_address <> '' AND a.norm_address = b.norm_address AS same_address,
+			6371.0088 * 2 * asin(LEAST(1, sqrt(
+				power(sin(radians(b.lat - a.lat) / 2), 2) +
+				cos(radians(a.lat)) * cos(radians(b.lat)) * power(sin(radians(b.lng - a.lng) / 2), 2)
+			))) AS distance_km
+		FROM normalized a
+		JOIN normalized b ON a.id < b.id
+	)
+	SELECT * FROM pairs
+	WHERE same_title OR same_address OR distance_km <= $5::float8
+	ORDER BY same_title DESC, same_address DESC, distance_km NULLS LAST, client_title
+	`
+
+	candidates := []*models.DuplicateCandidate{}
+
+	err := r.db.SelectContext(ctx, &candidates, query, -180.0, -90.0, 180.0, 90.0, maxDistanceKm)
+	if err != nil {
+		return nil, err
+	}
+
+	return candidates, nil
+}
+
+// MergeClients moves everything that references the duplicates to the target
+// client, fills the target's empty fields from them, then deletes them and
+// leaves redirects to the target. A target that was merged itself resolves to
+// the client it was merged into. It returns nil if any client doesn't exist.
+func (r *clientsRepository) MergeClients(ctx context.Context, targetID uuid.UUID, duplicateIDs []uuid.UUID, mergedBy uuid.UUID) (*models.ClientMergeResult, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = tx.GetContext(ctx, &targetID, `SELECT `+resolvedClientID("$1::uuid"), targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := append([]uuid.UUID{targetID}, duplicateIDs...)
+
+	var locked []uuid.UUID
+	err = tx.SelectContext(ctx, &locked, `SELECT id FROM clients WHERE id = ANY($1) ORDER BY id FOR UPDATE`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	if len(locked) != len(ids) {
+		return nil, nil
+	}
+
+	duplicates := pq.Array(duplicateIDs)
+	result := models.ClientMergeResult{}
+
+	moves := []struct {
+		query string
+		count *int64
+	}{
+		{`UPDATE tickets SET client = $1 WHERE client = ANY($2)`, &result.Tickets},
+		{`UPDATE contacts SET client_id = $1 WHERE client_id = ANY($2)`, &result.Contacts},
+		{`
+		UPDATE agreements SET
+			actual_client = CASE WHEN actual_client = ANY($2) THEN $1 ELSE actual_client END,
+			distributor = CASE WHEN distributor = ANY($2) THEN $1 ELSE distributor END
+		WHERE actual_client = ANY($2) OR distributor = ANY($2)
+		`, &result.Agreements},
+		{`UPDATE comments SET reference_id = $1 WHERE reference_id = ANY($2)`, &result.Comments},
+		{`UPDATE attachments SET ref_id = $1 WHERE ref_id = ANY($2)`, &result.Attachments},
+		// Earlier merges into a duplicate now point at the target
+		{`UPDATE client_redirects SET client_id = $1 WHERE client_id = ANY($2)`, nil},
+	}
+
+	for _, move := range moves {
+		res, err := tx.ExecContext(ctx, move.query, targetID, duplicates)
+		if err != nil {
+			return nil, err
+		}
+		if move.count != nil {
+			if *move.count, err = res.RowsAffected(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Duplicates are used in the order given, managers keep their order
+	fillQuery := `
+	WITH sources AS (
+		SELECT c.*, d.ord
+		FROM unnest($2::uuid[]) WITH ORDINALITY AS d(id, ord)
+		JOIN clients c ON c.id = d.id
+	),
+	managers AS (
+		SELECT DISTINCT ON (user_id) user_id, src, ord
+		FROM (
+			SELECT x.user_id, 0 AS src, x.ord
+			FROM clients, unnest(manager) WITH ORDINALITY AS x(user_id, ord)
+			WHERE id = $1
+			UNION ALL
+			SELECT x.user_id, s.ord AS src, x.ord
+			FROM sources s, unnest(s.manager) WITH ORDINALITY AS x(user_id, ord)
+		) m
+		ORDER BY user_id, src, ord
+	)
+	UPDATE clients c SET
+		region = COALESCE(c.region, (SELECT region FROM sources WHERE region IS NOT NULL ORDER BY ord LIMIT 1)),
+		address = COALESCE(NULLIF(c.address, ''), (SELECT address FROM sources WHERE address <> '' ORDER BY ord LIMIT 1)),
+		location = CASE WHEN c.location IS NULL OR c.location IN ('[]'::jsonb, 'null'::jsonb)
+			THEN COALESCE((SELECT location FROM sources WHERE location NOT IN ('[]'::jsonb, 'null'::jsonb) ORDER BY ord LIMIT 1), c.location)
+			ELSE c.location
+		END,
+		laboratory_system = COALESCE(c.laboratory_system, (SELECT laboratory_system FROM sources WHERE laboratory_system IS NOT NULL ORDER BY ord LIMIT 1)),
+		manager = (SELECT COALESCE(array_agg(user_id ORDER BY src, ord), '{}') FROM managers)
+	WHERE c.id = $1
+	`
+
+	_, err = tx.ExecContext(ctx, fillQuery, targetID, duplicates)
+	if err != nil {
+		return nil, err
+	}
+
+	// A client has one geocoding row. The target keeps its own, otherwise it
+	// takes the one of the duplicate whose address it now has, or of the
+	// first duplicate geocoded
+	geocodeQuery := `
+	UPDATE client_geocodes SET client_id = $1
+	WHERE client_id = (
+		SELECT g.client_id
+		FROM unnest($2::uuid[]) WITH ORDINALITY AS d(id, ord)
+		JOIN client_geocodes g ON g.client_id = d.id
+		JOIN clients c ON c.id = $1
+		ORDER BY (g.address = c.address) IS TRUE DESC, d.ord
+		LIMIT 1
+	)
+	AND NOT EXISTS (SELECT 1 FROM client_geocodes WHERE client_id = $1)
+	`
+
+	_, err = tx.ExecContext(ctx, geocodeQuery, targetID, duplicates)
+	if err != nil {
+		return nil, err
+	}
+
+	redirectQuery := `
+	INSERT INTO client_redirects (old_id, client_id, merged_by)
+	SELECT id, $1, $3 FROM unnest($2::uuid[]) AS d(id)
+	ON CONFLICT (old_id) DO UPDATE SET client_id = EXCLUDED.client_id, merged_by = EXCLUDED.merged_by, merged_at = EXCLUDED.merged_at
+	`
+
+	_, err = tx.ExecContext(ctx, redirectQuery, targetID, duplicates, mergedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM clients WHERE id = ANY($1)`, duplicates)
+	if err != nil {
+		return nil, err
+	}
+
+	var client models.Client
+	err = tx.GetContext(ctx, &client, `SELECT * FROM clients WHERE id = $1`, targetID)
+	if err != nil {
+		return nil, err
+	}
+	result.Client = &client
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &result, nil
+}
+
+// ticketCardQuery selects ticket cards, the caller adds the conditions.
+const ticketCardQuery = `
+	SELECT
+		t.id,
+		t.number,
+		t.assigned_end,
+		t.urgent,
+		t.status,
+		t.result,
+		t.workstarted_at,
+		t.workfinished_at,
+		t.description,
+		t.created_at,
+		TRIM(CONCAT(ex.first_name, ' ', ex.last_name)) AS executor,
+		dep.title AS department,
+		d.serial_number AS device_serial_number,
+		c.title AS device_classificator_title,
+		cl.title AS client_name,
+		cl.address AS client_address,
+		tr.title AS reason
+	FROM tickets t
+	LEFT JOIN devices d ON t.device = d.id
+	LEFT JOIN classificators c ON d.classificator = c.id
+	LEFT JOIN clients cl ON t.client = cl.id
+	LEFT JOIN ticket_reasons tr ON t.reason = tr.id
+	LEFT JOIN users ex ON t.executor = ex.user_id
+	LEFT JOIN departments dep ON t.department = dep.id
+`
+
+// overviewTicketsQuery selects the ticket cards of client $1.
+const overviewTicketsQuery = ticketCardQuery + `WHERE t.client = $1`
+
+// GetClientOverview loads the client page in a fixed number of queries run in
+// one snapshot. Merged client ids resolve to the surviving client. It returns
+// nil if the client doesn't exist.
+func (r *clientsRepository) GetClientOverview(ctx context.Context, id uuid.UUID, closedLimit int) (*models.ClientOverview, error) {
+	tx, err := r.db.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	clientQuery := `
+		SELECT c.*, rg.title AS region_title
+		FROM clients c
+		LEFT JOIN regions rg ON rg.id = c.region
+		WHERE c.id = ` + resolvedClientID("$1")
+
+	var client models.ClientDetails
+
+	err = tx.GetContext(ctx, &client, clientQuery, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	overview := models.ClientOverview{
+		Client:        &client,
+		Contacts:      []models.ContactRow{},
+		Agreements:    []*models.AgreementCard{},
+		OpenTickets:   []*models.TicketCard{},
+		ClosedTickets: []*models.TicketCard{},
+	}
+
+	contactsQuery := `SELECT id, name, position, phone, phone_e164, email FROM contacts WHERE client_id = $1 ORDER BY name`
+	if err := tx.SelectContext(ctx, &overview.Contacts, contactsQuery, client.ID); err != nil {
+		return nil, fmt.Errorf("contacts: %w", err)
+	}
+
+	agreementsQuery := `
+		SELECT
+			a.*,
+			c.title AS client_name,
+			COALESCE(c.address, '') AS client_address,
+			d.serial_number AS device_serial,
+			cl.title AS device_name
+		FROM agreements a
+		JOIN clients c ON a.actual_client = c.id
+		LEFT JOIN devices d ON a.device = d.id
+		LEFT JOIN classificators cl ON d.classificator = cl.id
+		WHERE a.actual_client = $1 AND a.is_active
+		ORDER BY cl.title, d.serial_number
+	`
+	if err := tx.SelectContext(ctx, &overview.Agreements, agreementsQuery, client.ID); err != nil {
+		return nil, fmt.Errorf("agreements: %w", err)
+	}
+
+	openQuery := overviewTicketsQuery + `
+		AND t.status NOT IN ('closed', 'cancelled')
+		ORDER BY t.urgent DESC, t.assigned_end NULLS LAST, t.created_at
+	`
+	if err := tx.SelectContext(ctx, &overview.OpenTickets, openQuery, client.ID); err != nil {
+		return nil, fmt.Errorf("open tickets: %w", err)
+	}
+
+	closedQuery := overviewTicketsQuery + `
+		AND t.status = 'closed'
+		ORDER BY COALESCE(t.closed_at, t.workfinished_at) DESC NULLS LAST
+		LIMIT $2
+	`
+	if err := tx.SelectContext(ctx, &overview.ClosedTickets, closedQuery, client.ID, closedLimit); err != nil {
+		return nil, fmt.Errorf("closed tickets: %w", err)
+	}
+
+	// The last visit is when work on a closed ticket finished, tickets closed
+	// without that mark count by their closing time
+	lastServiceQuery := `
+		SELECT MAX(COALESCE(workfinished_at, closed_at))
+		FROM tickets
+		WHERE client = $1 AND status = 'closed'
+	`
+	if err := tx.GetContext(ctx, &overview.LastServiceAt, lastServiceQuery, client.ID); err != nil {
+		return nil, fmt.Errorf("last service: %w", err)
+	}
+
+	return &overview, nil
+}
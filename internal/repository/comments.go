@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
 	"github.com/grintheone/foxygen-server/internal/models"
@@ -13,6 +14,7 @@ type CommentsRepository interface {
 	NewComment(ctx context.Context, payload models.Comment) (*models.Comment, error)
 	DeleteComment(ctx context.Context, id int) error
 	UpdateComment(ctx context.Context, id int, payload models.CommentUpdate) error
+	GetCommentByID(ctx context.Context, id int) (*models.Comment, error)
 }
 
 type commentsRepository struct {
@@ -77,3 +79,19 @@ func (r *commentsRepository) UpdateComment(ctx context.Context, id int, payload
 
 	return nil
 }
+
+func (r *commentsRepository) GetCommentByID(ctx context.Context, id int) (*models.Comment, error) {
+	query := `SELECT * FROM comments WHERE id = $1`
+
+	var comment models.Comment
+
+	err := r.db.GetContext(ctx, &comment, query, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &comment, nil
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
 	"github.com/grintheone/foxygen-server/internal/models"
@@ -14,6 +15,13 @@ type ContactsRepository interface {
 	DeleteContact(ctx context.Context, uuid uuid.UUID) error
 	GetContactByID(ctx context.Context, uuid uuid.UUID) (*models.Contact, error)
 	UpdateContact(ctx context.Context, uuid uuid.UUID, payload models.ContactUpdate) (*models.Contact, error)
+	FindDuplicateContact(ctx context.Context, clientID, excludeID uuid.UUID, phoneE164, email string) (*models.ContactRow, error)
+	ListDuplicateContacts(ctx context.Context, clientID uuid.UUID) ([]*models.DuplicateContact, error)
+	SearchByPhone(ctx context.Context, phoneE164 string) ([]models.ContactRow, error)
+	GetContactCard(ctx context.Context, id uuid.UUID) (*models.ContactCard, error)
+	ListContactCards(ctx context.Context, clientID uuid.UUID) ([]*models.ContactCard, error)
+	ListAllContacts(ctx context.Context) ([]*models.ContactRow, error)
+	SetContactFormat(ctx context.Context, id uuid.UUID, phone, phoneE164, email string) error
 }
 
 type contactRepository struct {
@@ -26,10 +34,9 @@ func NewContactRepository(db *sqlx.DB) *contactRepository {
 
 func (r *contactRepository) GetAllByClientID(ctx context.Context, uuid uuid.UUID) (*[]models.ContactRow, error) {
 	query := `
-		SELECT id, name, position, phone, email
+		SELECT id, name, position, phone, phone_e164, email
 		FROM contacts
-		WHERE client_id = $1
-	`
+		WHERE client_id = ` + resolvedClientID("$1")
 	var contacts []models.ContactRow
 	err := r.db.SelectContext(ctx, &contacts, query, uuid)
 	if err != nil {
@@ -40,22 +47,28 @@ func (r *contactRepository) GetAllByClientID(ctx context.Context, uuid uuid.UUID
 }
 
 func (r *contactRepository) GetContactByID(ctx context.Context, uuid uuid.UUID) (*models.Contact, error) {
-	query := `SELECT FROM contacts WHERE id = $1`
+	query := `SELECT * FROM contacts WHERE id = $1`
 
-	var contact models.Contact
+	// Contact scans itself from JSON, so rows are read into ContactRow
+	var row models.ContactRow
 
-	err := r.db.GetContext(ctx, &contact, query, uuid)
+	err := r.db.GetContext(ctx, &row, query, uuid)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
 		return nil, err
 	}
 
+	contact := models.Contact(row)
+
 	return &contact, nil
 }
 
 func (r *contactRepository) CreateContact(ctx context.Context, data models.Contact) error {
 	query := `
-		INSERT INTO contacts (id, name, position, phone, email, client_id)
-		VALUES (:id, :name, :position, :phone, :email, :client_id)
+		INSERT INTO contacts (id, name, position, phone, phone_e164, email, client_id)
+		VALUES (:id, :name, :position, :phone, :phone_e164, :email, :client_id)
 	`
 	_, err := r.db.NamedExecContext(ctx, query, data)
 	if err != nil {
@@ -78,12 +91,14 @@ func (r *contactRepository) DeleteContact(ctx context.Context, uuid uuid.UUID) e
 
 func (r *contactRepository) UpdateContact(ctx context.Context, uuid uuid.UUID, payload models.ContactUpdate) (*models.Contact, error) {
 	// Get existing contact to merge changes
-	var existing models.Contact
-	err := r.db.GetContext(ctx, &existing, `SELECT * FROM contacts WHERE id = $1`, uuid)
+	var row models.ContactRow
+	err := r.db.GetContext(ctx, &row, `SELECT * FROM contacts WHERE id = $1`, uuid)
 	if err != nil {
 		return nil, err
 	}
 
+	existing := models.Contact(row)
+
 	if payload.Name != nil {
 		existing.Name = *payload.Name
 	}
@@ -96,6 +111,10 @@ func (r *contactRepository) UpdateContact(ctx context.Context, uuid uuid.UUID, p
 		existing.Phone = *payload.Phone
 	}
 
+	if payload.PhoneE164 != nil {
+		existing.PhoneE164 = *payload.PhoneE164
+	}
+
 	if payload.Email != nil {
 		existing.Email = *payload.Email
 	}
@@ -106,7 +125,7 @@ func (r *contactRepository) UpdateContact(ctx context.Context, uuid uuid.UUID, p
 
 	query := `
         UPDATE contacts
-        SET name = :name, position = :position, phone = :phone, email = :email, client_id = :client_id
+        SET name = :name, position = :position, phone = :phone, phone_e164 = :phone_e164, email = :email, client_id = :client_id
         WHERE id = :id
     `
 
@@ -117,3 +136,129 @@ func (r *contactRepository) UpdateContact(ctx context.Context, uuid uuid.UUID, p
 
 	return &existing, nil
 }
+
+// FindDuplicateContact returns another contact of the client with the same
+// phone number or email, empty values never match.
+func (r *contactRepository) FindDuplicateContact(ctx context.Context, clientID, excludeID uuid.UUID, phoneE164, email string) (*models.ContactRow, error) {
+	query := `
+		SELECT * FROM contacts
+		WHERE client_id = $1 AND id <> $2
+			AND (($3 <> '' AND phone_e164 = $3) OR ($4 <> '' AND lower(email) = lower($4)))
+		ORDER BY name
+		LIMIT 1
+	`
+
+	var contact models.ContactRow
+
+	err := r.db.GetContext(ctx, &contact, query, clientID, excludeID, phoneE164, email)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &contact, nil
+}
+
+func (r *contactRepository) ListDuplicateContacts(ctx context.Context, clientID uuid.UUID) ([]*models.DuplicateContact, error) {
+	query := `
+		SELECT
+			a.id AS contact_id,
+			a.name AS contact_name,
+			b.id AS duplicate_id,
+			b.name AS duplicate_name,
+			a.phone_e164 <> '' AND a.phone_e164 = b.phone_e164 AS same_phone,
+			a.email <> '' AND lower(a.email) = lower(b.email) AS same_email,
+			a.name <> '' AND lower(a.name) = lower(b.name) AS same_name
+		FROM contacts a
+		JOIN contacts b ON b.client_id = a.client_id AND b.id > a.id
+		WHERE a.client_id = ` + resolvedClientID("$1") + `
+			AND (
+				(a.phone_e164 <> '' AND a.phone_e164 = b.phone_e164)
+				OR (a.email <> '' AND lower(a.email) = lower(b.email))
+				OR (a.name <> '' AND lower(a.name) = lower(b.name))
+			)
+		ORDER BY a.name, b.name
+	`
+
+	duplicates := []*models.DuplicateContact{}
+
+	err := r.db.SelectContext(ctx, &duplicates, query, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	return duplicates, nil
+}
+
+func (r *contactRepository) SearchByPhone(ctx context.Context, phoneE164 string) ([]models.ContactRow, error) {
+	query := `
+		SELECT id, name, position, phone, phone_e164, email, client_id
+		FROM contacts
+		WHERE phone_e164 = $1
+		ORDER BY name
+	`
+
+	contacts := []models.ContactRow{}
+
+	err := r.db.SelectContext(ctx, &contacts, query, phoneE164)
+	if err != nil {
+		return nil, err
+	}
+
+	return contacts, nil
+}
+
+const contactCardQuery = `
+	SELECT con.id, con.name, con.position, con.phone, con.phone_e164, con.email, COALESCE(c.title, '') AS client_title
+	FROM contacts con
+	LEFT JOIN clients c ON c.id = con.client_id
+`
+
+func (r *contactRepository) GetContactCard(ctx context.Context, id uuid.UUID) (*models.ContactCard, error) {
+	var card models.ContactCard
+
+	err := r.db.GetContext(ctx, &card, contactCardQuery+`WHERE con.id = $1`, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &card, nil
+}
+
+func (r *contactRepository) ListContactCards(ctx context.Context, clientID uuid.UUID) ([]*models.ContactCard, error) {
+	var cards []*models.ContactCard
+
+	err := r.db.SelectContext(ctx, &cards, contactCardQuery+`WHERE con.client_id = `+resolvedClientID("$1")+` ORDER BY con.name`, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	return cards, nil
+}
+
+func (r *contactRepository) ListAllContacts(ctx context.Context) ([]*models.ContactRow, error) {
+	var contacts []*models.ContactRow
+
+	err := r.db.SelectContext(ctx, &contacts, `SELECT * FROM contacts ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+
+	return contacts, nil
+}
+
+func (r *contactRepository) SetContactFormat(ctx context.Context, id uuid.UUID, phone, phoneE164, email string) error {
+	query := `UPDATE contacts SET phone = $2, phone_e164 = $3, email = $4 WHERE id = $1`
+
+	_, err := r.db.ExecContext(ctx, query, id, phone, phoneE164, email)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
 	"github.com/grintheone/foxygen-server/internal/models"
@@ -12,6 +13,8 @@ type DepartmentRepo interface {
 	ListAllDepartments(ctx context.Context) ([]*models.Department, error)
 	GetDepartmentByID(ctx context.Context, uuid uuid.UUID) (*models.Department, error)
 	AddNewDepartment(ctx context.Context, data models.Department) error
+	GetWorkload(ctx context.Context, departmentID uuid.UUID) ([]*models.UserWorkload, error)
+	GetUserDepartment(ctx context.Context, userID uuid.UUID) (*uuid.UUID, error)
 }
 
 type departmentRepo struct {
@@ -52,3 +55,147 @@ func (r *departmentRepo) AddNewDepartment(ctx context.Context, data models.Depar
 
 	return nil
 }
+
+// workloadCTEs is the one definition of the workload of department $1: every
+// CTE aggregates the department's tickets or absences per user, and both the
+// workload dashboard and the department user listing select from them. The
+// next assignment is the earliest assigned interval that hasn't ended yet.
+// Timestamps are stored in UTC and converted to timestamptz so the JSON
+// carries the offset.
+const workloadCTEs = `
+	WITH now_utc AS (
+		SELECT NOW() AT TIME ZONE 'UTC' AS ts
+	),
+	department_users AS (
+		SELECT user_id, first_name, last_name FROM users WHERE department = $1
+	),
+	active AS (
+		SELECT t.*
+		FROM tickets t
+		JOIN department_users du ON du.user_id = t.executor
+		WHERE t.status NOT IN ('closed', 'cancelled')
+	),
+	by_status AS (
+		SELECT executor, jsonb_object_agg(status, n) AS tickets_by_status, SUM(n)::int AS active_tickets
+		FROM (
+			SELECT executor, COALESCE(status, 'unknown') AS status, COUNT(*) AS n
+			FROM active
+			GROUP BY executor, COALESCE(status, 'unknown')
+		) counts
+		GROUP BY executor
+	),
+	overdue AS (
+		SELECT a.executor, COUNT(*) AS overdue
+		FROM active a, now_utc
+		WHERE a.assigned_end < now_utc.ts
+		GROUP BY a.executor
+	),
+	current_ticket AS (
+		SELECT DISTINCT ON (a.executor)
+			a.executor,
+			jsonb_build_object(
+				'id', a.id,
+				'number', a.number,
+				'status', a.status,
+				'client_name', c.title,
+				'workstarted_at', a.workstarted_at AT TIME ZONE 'UTC',
+				'elapsed_minutes', EXTRACT(EPOCH FROM now_utc.ts - a.workstarted_at) / 60,
+				'assigned_start', a.assigned_start AT TIME ZONE 'UTC',
+				'assigned_end', a.assigned_end AT TIME ZONE 'UTC'
+			) AS ticket
+		FROM active a
+		CROSS JOIN now_utc
+		LEFT JOIN clients c ON c.id = a.client
+		WHERE a.status = 'inWork' AND a.workstarted_at IS NOT NULL
+		ORDER BY a.executor, a.workstarted_at DESC
+	),
+	next_assignment AS (
+		SELECT DISTINCT ON (a.executor)
+			a.executor,
+			jsonb_build_object(
+				'id', a.id,
+				'number', a.number,
+				'status', a.status,
+				'client_name', c.title,
+				'assigned_start', a.assigned_start AT TIME ZONE 'UTC',
+				'assigned_end', a.assigned_end AT TIME ZONE 'UTC'
+			) AS ticket
+		FROM active a
+		CROSS JOIN now_utc
+		LEFT JOIN clients c ON c.id = a.client
+		WHERE a.status = 'assigned' AND a.assigned_start IS NOT NULL
+			AND COALESCE(a.assigned_end, a.assigned_start) > now_utc.ts
+		ORDER BY a.executor, a.assigned_start
+	),
+	absences_today AS (
+		SELECT
+			ua.user_id,
+			jsonb_agg(jsonb_build_object(
+				'id', ua.id,
+				'type', ua.absence_type,
+				'from', ua.starts_at AT TIME ZONE 'UTC',
+				'to', ua.ends_at AT TIME ZONE 'UTC'
+			) ORDER BY ua.starts_at) AS absences
+		FROM user_absences ua
+		JOIN department_users du ON du.user_id = ua.user_id
+		CROSS JOIN now_utc
+		WHERE ua.starts_at < date_trunc('day', now_utc.ts) + INTERVAL '1 day'
+			AND ua.ends_at > date_trunc('day', now_utc.ts)
+		GROUP BY ua.user_id
+	),
+	absent_now AS (
+		SELECT DISTINCT ON (ua.user_id) ua.user_id, ua.absence_type, ua.ends_at
+		FROM user_absences ua
+		JOIN department_users du ON du.user_id = ua.user_id
+		CROSS JOIN now_utc
+		WHERE ua.starts_at <= now_utc.ts AND ua.ends_at > now_utc.ts
+		ORDER BY ua.user_id, ua.ends_at DESC
+	)
+`
+
+// workloadQuery builds the workload dashboard of department $1 in one pass by
+// joining the workload CTEs onto the users.
+const workloadQuery = workloadCTEs + `
+	SELECT
+		du.user_id,
+		COALESCE(du.first_name, '') AS first_name,
+		COALESCE(du.last_name, '') AS last_name,
+		COALESCE(bs.active_tickets, 0) AS active_tickets,
+		COALESCE(bs.tickets_by_status, '{}'::jsonb) AS tickets_by_status,
+		COALESCE(o.overdue, 0) AS overdue,
+		ct.ticket AS current_ticket,
+		na.ticket AS next_assignment,
+		COALESCE(ab.absences, '[]'::jsonb) AS absences_today
+	FROM department_users du
+	LEFT JOIN by_status bs ON bs.executor = du.user_id
+	LEFT JOIN overdue o ON o.executor = du.user_id
+	LEFT JOIN current_ticket ct ON ct.executor = du.user_id
+	LEFT JOIN next_assignment na ON na.executor = du.user_id
+	LEFT JOIN absences_today ab ON ab.user_id = du.user_id
+	ORDER BY du.last_name, du.first_name
+`
+
+func (r *departmentRepo) GetWorkload(ctx context.Context, departmentID uuid.UUID) ([]*models.UserWorkload, error) {
+	workload := []*models.UserWorkload{}
+
+	err := r.db.SelectContext(ctx, &workload, workloadQuery, departmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	return workload, nil
+}
+
+func (r *departmentRepo) GetUserDepartment(ctx context.Context, userID uuid.UUID) (*uuid.UUID, error) {
+	var department *uuid.UUID
+
+	err := r.db.GetContext(ctx, &department, `SELECT department FROM users WHERE user_id = $1`, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return department, nil
+}
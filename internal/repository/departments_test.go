@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+)
+
+// openTestDB connects to FOXYGEN_TEST_DATABASE_URL and creates the tables of
+// db/create_tables.sql in a schema of its own, dropped when the test ends.
+// Tests are skipped when the variable is not set.
+func openTestDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+
+	dsn := os.Getenv("FOXYGEN_TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("FOXYGEN_TEST_DATABASE_URL is not set")
+	}
+
+	db, err := sqlx.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// search_path is per connection, keep a single one
+	db.SetMaxOpenConns(1)
+
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	t.Cleanup(func() {
+		db.Exec(`DROP SCHEMA ` + schema + ` CASCADE`)
+		db.Close()
+	})
+
+	statements, err := os.ReadFile("../../db/create_tables.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range []string{
+		`CREATE SCHEMA ` + schema,
+		`SET search_path TO ` + schema + `, public`,
+		string(statements),
+	} {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatalf("failed to prepare the test schema: %v", err)
+		}
+	}
+
+	return db
+}
+
+// mustExec runs a fixture statement.
+func mustExec(t *testing.T, db *sqlx.DB, query string, args ...any) {
+	t.Helper()
+
+	if _, err := db.Exec(query, args...); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+}
+
+func TestWorkloadQuery(t *testing.T) {
+	db := openTestDB(t)
+	now := time.Now().UTC()
+
+	department := uuid.New()
+	otherDepartment := uuid.New()
+	client := uuid.New()
+	ivanov, petrov, sidorov := uuid.New(), uuid.New(), uuid.New()
+
+	mustExec(t, db, `INSERT INTO ticket_statuses (type) VALUES ('created'), ('assigned'), ('inWork'), ('worksDone'), ('closed'), ('cancelled')`)
+	mustExec(t, db, `INSERT INTO departments (id, title) VALUES ($1, 'Инженерная служба'), ($2, 'Отдел ПЦР')`, department, otherDepartment)
+	mustExec(t, db, `INSERT INTO clients (id, title) VALUES ($1, 'ГКБ №1')`, client)
+	for _, user := range []struct {
+		id         uuid.UUID
+		lastName   string
+		department uuid.UUID
+	}{
+		{ivanov, "Иванов", department},
+		{petrov, "Петров", department},
+		{sidorov, "Сидоров", otherDepartment},
+	} {
+		mustExec(t, db, `INSERT INTO accounts (user_id, username, password_hash) VALUES ($1, $2, '')`, user.id, user.lastName)
+		mustExec(t, db, `INSERT INTO users (user_id, last_name, department) VALUES ($1, $2, $3)`, user.id, user.lastName, user.department)
+	}
+
+	ticket := func(executor uuid.UUID, status string, start, end, workStarted *time.Time) uuid.UUID {
+		id := uuid.New()
+		mustExec(t, db, `
+			INSERT INTO tickets (id, executor, status, client, department, assigned_start, assigned_end, workstarted_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		`, id, executor, status, client, department, start, end, workStarted)
+		return id
+	}
+	at := func(d time.Duration) *time.Time {
+		ts := now.Add(d)
+		return &ts
+	}
+
+	// Started 30 minutes ago, should have been done an hour ago
+	current := ticket(ivanov, "inWork", at(-3*time.Hour), at(-time.Hour), at(-30*time.Minute))
+	// Planned for two days ago and never started: overdue, not next
+	ticket(ivanov, "assigned", at(-48*time.Hour), at(-24*time.Hour), nil)
+	next := ticket(ivanov, "assigned", at(2*time.Hour), at(4*time.Hour), nil)
+	ticket(ivanov, "assigned", at(24*time.Hour), at(26*time.Hour), nil)
+	ticket(ivanov, "closed", at(-time.Hour), at(time.Hour), nil)
+	ticket(sidorov, "inWork", at(-time.Hour), at(-30*time.Minute), at(-time.Hour))
+
+	absence := uuid.New()
+	mustExec(t, db, `INSERT INTO user_absences (id, user_id, absence_type, starts_at, ends_at) VALUES ($1, $2, 'sick_leave', $3, $4)`,
+		absence, ivanov, *at(-time.Hour), *at(time.Hour))
+	tomorrow := now.Truncate(24 * time.Hour).Add(24*time.Hour + time.Hour)
+	mustExec(t, db, `INSERT INTO user_absences (user_id, absence_type, starts_at, ends_at) VALUES ($1, 'vacation', $2, $3)`,
+		ivanov, tomorrow, tomorrow.Add(72*time.Hour))
+
+	workload, err := NewDepartmentRepo(db).GetWorkload(context.Background(), department)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(workload) != 2 {
+		t.Fatalf("got %d rows, want the 2 users of the department", len(workload))
+	}
+
+	rows := map[uuid.UUID]*models.UserWorkload{}
+	for _, row := range workload {
+		rows[row.UserID] = row
+	}
+
+	busy := rows[ivanov]
+	if busy == nil {
+		t.Fatal("no row for Иванов")
+	}
+	if busy.ActiveTickets != 4 {
+		t.Errorf("active tickets = %d, want 4", busy.ActiveTickets)
+	}
+	if busy.TicketsByStatus["inWork"] != 1 || busy.TicketsByStatus["assigned"] != 3 || len(busy.TicketsByStatus) != 2 {
+		t.Errorf("tickets by status = %v", busy.TicketsByStatus)
+	}
+	if busy.Overdue != 2 {
+		t.Errorf("overdue = %d, want 2", busy.Overdue)
+	}
+	if busy.CurrentTicket == nil || busy.CurrentTicket.ID != current {
+		t.Errorf("current ticket = %+v, want %s", busy.CurrentTicket, current)
+	} else if busy.CurrentTicket.ElapsedMinutes == nil || *busy.CurrentTicket.ElapsedMinutes < 29 || *busy.CurrentTicket.ElapsedMinutes > 35 {
+		t.Errorf("elapsed minutes = %v, want about 30", busy.CurrentTicket.ElapsedMinutes)
+	}
+	if busy.NextAssignment == nil || busy.NextAssignment.ID != next {
+		t.Errorf("next assignment = %+v, want %s", busy.NextAssignment, next)
+	}
+	if len(busy.AbsencesToday) != 1 || busy.AbsencesToday[0].ID != absence {
+		t.Errorf("absences today = %+v, want only %s", busy.AbsencesToday, absence)
+	}
+
+	idle := rows[petrov]
+	if idle == nil {
+		t.Fatal("no row for Петров")
+	}
+	if idle.ActiveTickets != 0 || idle.Overdue != 0 || len(idle.TicketsByStatus) != 0 {
+		t.Errorf("idle user has counts: %+v", idle)
+	}
+	if idle.CurrentTicket != nil || idle.NextAssignment != nil {
+		t.Errorf("idle user has tickets: %+v, %+v", idle.CurrentTicket, idle.NextAssignment)
+	}
+	if idle.AbsencesToday == nil || len(idle.AbsencesToday) != 0 {
+		t.Errorf("absences today = %#v, want an empty list", idle.AbsencesToday)
+	}
+	// The department user listing reads the same workload CTEs
+	users, err := NewUsersRepository(db).ListDepartmentUsers(context.Background(), petrov.String(), models.DepartmentUserFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 {
+		t.Fatalf("listed %d users, want 2", len(users))
+	}
+	for _, user := range users {
+		if user.UserID != ivanov {
+			continue
+		}
+		if user.ActiveTickets == nil || *user.ActiveTickets != busy.ActiveTickets {
+			t.Errorf("listed active tickets = %v, want %d", user.ActiveTickets, busy.ActiveTickets)
+		}
+		if user.Availability != "sick_leave" {
+			t.Errorf("availability = %q, want sick_leave", user.Availability)
+		}
+	}
+}
@@ -2,20 +2,46 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"reflect"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/grintheone/foxygen-server/internal/models"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
+var (
+	ErrRelocationSameClient    = errors.New("device is already at this client")
+	ErrUnknownRelocationTarget = errors.New("client or distributor does not exist")
+	ErrInvalidRelocationPeriod = errors.New("relocation must fall after the start of the current agreement and before the end of the new one")
+)
+
+// deviceTimelineReasons are the ticket reasons shown on a device timeline.
+var deviceTimelineReasons = []string{
+	models.DeviceEventInstallation,
+	models.DeviceEventCommissioning,
+	models.DeviceEventRepair,
+	models.DeviceEventMaintenance,
+	models.DeviceEventDeinstallation,
+}
+
 type DevicesRepository interface {
 	GetAllDevices(ctx context.Context, limit int, offset int, sortByTitle bool, search string) (*[]models.Device, error)
 	GetDeviceByID(ctx context.Context, uuid uuid.UUID) (*models.DeviceSinglePage, error)
 	RemoveDeviceByID(ctx context.Context, uuid uuid.UUID) error
 	CreateNewDevice(ctx context.Context, payload models.Device) error
-	UpdateDeviceByID(ctx context.Context, uuid uuid.UUID, payload models.DeviceUpdates) (*models.DeviceSinglePage, error)
+	UpdateDeviceByID(ctx context.Context, uuid uuid.UUID, payload models.DeviceUpdates, changedBy *uuid.UUID) (*models.DeviceSinglePage, error)
 	GetDeviceRemoteOptions(ctx context.Context, uuid uuid.UUID) ([]*models.DeviceRemoteOption, error)
+	GetDeviceTimeline(ctx context.Context, id uuid.UUID) ([]*models.DeviceEvent, bool, error)
+	RelocateDevice(ctx context.Context, id uuid.UUID, relocation models.DeviceRelocation) (*models.DeviceRelocationResult, error)
+	GetDeviceLabel(ctx context.Context, id uuid.UUID) (*models.DeviceLabel, error)
+	ListClientDeviceLabels(ctx context.Context, clientID uuid.UUID) ([]*models.DeviceLabel, error)
+	FindDevicesBySerial(ctx context.Context, serial string) ([]uuid.UUID, error)
+	GetDeviceResolution(ctx context.Context, id uuid.UUID) (*models.DeviceResolution, error)
 }
 
 type deviceRepository struct {
@@ -86,7 +112,52 @@ func (r *deviceRepository) CreateNewDevice(ctx context.Context, payload models.D
 	return nil
 }
 
-func (r *deviceRepository) UpdateDeviceByID(ctx context.Context, uuid uuid.UUID, payload models.DeviceUpdates) (*models.DeviceSinglePage, error) {
+// deviceChanges lists the fields and properties that differ between two
+// versions of a device as name to {"from", "to"}.
+func deviceChanges(before, after models.Device) models.JSONB {
+	changes := models.JSONB{}
+
+	change := func(name string, from, to any) {
+		if !reflect.DeepEqual(from, to) {
+			changes[name] = map[string]any{"from": from, "to": to}
+		}
+	}
+
+	change("classificator", before.Classificator, after.Classificator)
+	change("serial_number", before.SerialNumber, after.SerialNumber)
+	change("connected_to_lis", before.ConntectedToLIS, after.ConntectedToLIS)
+	change("is_used", before.IsUsed, after.IsUsed)
+
+	for key, value := range after.Properties {
+		change("properties."+key, before.Properties[key], value)
+	}
+	for key, value := range before.Properties {
+		if _, ok := after.Properties[key]; !ok {
+			change("properties."+key, value, nil)
+		}
+	}
+
+	return changes
+}
+
+// UpdateDeviceByID updates a device and records what changed in
+// device_changes.
+func (r *deviceRepository) UpdateDeviceByID(ctx context.Context, uuid uuid.UUID, payload models.DeviceUpdates, changedBy *uuid.UUID) (*models.DeviceSinglePage, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var before models.Device
+	err = tx.GetContext(ctx, &before, `SELECT * FROM devices WHERE id = $1 FOR UPDATE`, uuid)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
 	query := `
 		UPDATE devices
 		SET
@@ -96,10 +167,13 @@ func (r *deviceRepository) UpdateDeviceByID(ctx context.Context, uuid uuid.UUID,
 			connected_to_lis = COALESCE($5, connected_to_lis),
 			is_used = COALESCE($6, is_used)
 		WHERE id = $1
+		RETURNING *
 	`
 
-	_, err := r.db.ExecContext(
+	var after models.Device
+	err = tx.GetContext(
 		ctx,
+		&after,
 		query,
 		uuid,
 		payload.Classificator,
@@ -112,6 +186,17 @@ func (r *deviceRepository) UpdateDeviceByID(ctx context.Context, uuid uuid.UUID,
 		return nil, err
 	}
 
+	if changes := deviceChanges(before, after); len(changes) > 0 {
+		_, err = tx.ExecContext(ctx, `INSERT INTO device_changes (device_id, changed_by, changes) VALUES ($1, $2, $3)`, uuid, changedBy, changes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return r.GetDeviceByID(ctx, uuid)
 }
 
@@ -134,3 +219,269 @@ func (r *deviceRepository) GetDeviceRemoteOptions(ctx context.Context, uuid uuid
 
 	return options, nil
 }
+
+// GetDeviceTimeline merges the tickets, agreements and recorded changes of a
+// device into one list ordered by time. At the same moment an agreement end
+// comes before the start of the next one and both before tickets.
+func (r *deviceRepository) GetDeviceTimeline(ctx context.Context, id uuid.UUID) ([]*models.DeviceEvent, bool, error) {
+	var exists bool
+	err := r.db.GetContext(ctx, &exists, `SELECT EXISTS (SELECT 1 FROM devices WHERE id = $1)`, id)
+	if err != nil {
+		return nil, false, err
+	}
+	if !exists {
+		return nil, false, nil
+	}
+
+	query := `
+		SELECT * FROM (
+			SELECT
+				COALESCE(t.workfinished_at, t.closed_at, t.workstarted_at, t.created_at) AS at,
+				t.reason AS type,
+				'ticket' AS source,
+				t.id AS source_id,
+				t.client AS client_id,
+				c.title AS client_title,
+				jsonb_build_object(
+					'number', t.number,
+					'reason', tr.title,
+					'status', t.status,
+					'executor', t.executor,
+					'closed_at', t.closed_at,
+					'result', t.result
+				) AS details
+			FROM tickets t
+			LEFT JOIN ticket_reasons tr ON tr.id = t.reason
+			LEFT JOIN clients c ON c.id = t.client
+			WHERE t.device = $1 AND t.reason = ANY($2)
+
+			UNION ALL
+
+			SELECT
+				a.assigned_at,
+				$3::text,
+				'agreement',
+				a.id,
+				a.actual_client,
+				c.title,
+				jsonb_build_object('number', a.number, 'type', a.type, 'distributor', a.distributor, 'on_warranty', a.on_warranty)
+			FROM agreements a
+			LEFT JOIN clients c ON c.id = a.actual_client
+			WHERE a.device = $1 AND a.assigned_at IS NOT NULL
+
+			UNION ALL
+
+			SELECT
+				a.finished_at,
+				$4::text,
+				'agreement',
+				a.id,
+				a.actual_client,
+				c.title,
+				jsonb_build_object('number', a.number, 'type', a.type, 'distributor', a.distributor, 'on_warranty', a.on_warranty)
+			FROM agreements a
+			LEFT JOIN clients c ON c.id = a.actual_client
+			WHERE a.device = $1 AND a.finished_at IS NOT NULL AND a.is_active = false
+
+			UNION ALL
+
+			SELECT
+				dc.changed_at,
+				$5::text,
+				'device_change',
+				dc.id,
+				NULL::uuid,
+				NULL::text,
+				jsonb_build_object('changed_by', dc.changed_by, 'changes', dc.changes)
+			FROM device_changes dc
+			WHERE dc.device_id = $1
+		) events
+		ORDER BY at, CASE type WHEN $4::text THEN 0 WHEN $3::text THEN 1 ELSE 2 END
+	`
+
+	events := []*models.DeviceEvent{}
+
+	err = r.db.SelectContext(ctx, &events, query, id, pq.Array(deviceTimelineReasons),
+		models.DeviceEventAgreementStart, models.DeviceEventAgreementEnd, models.DeviceEventPropertyChange)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return events, true, nil
+}
+
+// RelocateDevice ends the active agreements of a device at relocation.At and
+// opens one with the new client in a single transaction. relocation.At can't
+// precede the start of the latest active agreement.
+func (r *deviceRepository) RelocateDevice(ctx context.Context, id uuid.UUID, relocation models.DeviceRelocation) (*models.DeviceRelocationResult, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var locked uuid.UUID
+	err = tx.GetContext(ctx, &locked, `SELECT id FROM devices WHERE id = $1 FOR UPDATE`, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var current []struct {
+		ActualClient *uuid.UUID `db:"actual_client"`
+		AssignedAt   *time.Time `db:"assigned_at"`
+		Type         *string    `db:"type"`
+		OnWarranty   *bool      `db:"on_warranty"`
+	}
+	err = tx.SelectContext(ctx, &current, `
+		SELECT actual_client, assigned_at, type, on_warranty
+		FROM agreements
+		WHERE device = $1 AND is_active
+		ORDER BY assigned_at DESC NULLS LAST
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(current) == 1 && current[0].ActualClient != nil && *current[0].ActualClient == relocation.Client {
+		return nil, ErrRelocationSameClient
+	}
+	if len(current) > 0 {
+		if current[0].AssignedAt != nil && relocation.At.Before(*current[0].AssignedAt) {
+			return nil, ErrInvalidRelocationPeriod
+		}
+		if relocation.Type == nil {
+			relocation.Type = current[0].Type
+		}
+		if relocation.OnWarranty == nil {
+			relocation.OnWarranty = current[0].OnWarranty
+		}
+	}
+
+	at := relocation.At
+
+	result := &models.DeviceRelocationResult{ClosedAgreements: []uuid.UUID{}}
+
+	err = tx.SelectContext(ctx, &result.ClosedAgreements, `
+		UPDATE agreements SET is_active = false, finished_at = $2
+		WHERE device = $1 AND is_active
+		RETURNING id
+	`, id, at)
+	if err != nil {
+		return nil, err
+	}
+
+	var agreement models.Agreement
+	err = tx.GetContext(ctx, &agreement, `
+		INSERT INTO agreements (actual_client, distributor, device, assigned_at, finished_at, is_active, on_warranty, type)
+		VALUES ($1, $2, $3, $4, $5, true, COALESCE($6, true), $7)
+		RETURNING *
+	`, relocation.Client, relocation.Distributor, id, at, relocation.FinishedAt, relocation.OnWarranty, relocation.Type)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return nil, ErrUnknownRelocationTarget
+		}
+		return nil, err
+	}
+	result.Agreement = &agreement
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return result, nil
+}
+
+const deviceLabelQuery = `
+	SELECT d.id, COALESCE(d.serial_number, '') AS serial_number, COALESCE(cl.title, '') AS classificator_title
+	FROM devices d
+	LEFT JOIN classificators cl ON cl.id = d.classificator
+`
+
+func (r *deviceRepository) GetDeviceLabel(ctx context.Context, id uuid.UUID) (*models.DeviceLabel, error) {
+	var label models.DeviceLabel
+
+	err := r.db.GetContext(ctx, &label, deviceLabelQuery+`WHERE d.id = $1`, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &label, nil
+}
+
+// ListClientDeviceLabels returns the labels of the devices under active
+// agreements of a client.
+func (r *deviceRepository) ListClientDeviceLabels(ctx context.Context, clientID uuid.UUID) ([]*models.DeviceLabel, error) {
+	query := deviceLabelQuery + `
+		WHERE d.id IN (SELECT device FROM agreements WHERE actual_client = ` + resolvedClientID("$1") + ` AND is_active)
+		ORDER BY classificator_title, serial_number
+	`
+
+	var labels []*models.DeviceLabel
+
+	err := r.db.SelectContext(ctx, &labels, query, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	return labels, nil
+}
+
+// FindDevicesBySerial matches serial numbers ignoring case and surrounding
+// spaces.
+func (r *deviceRepository) FindDevicesBySerial(ctx context.Context, serial string) ([]uuid.UUID, error) {
+	query := `SELECT id FROM devices WHERE lower(trim(serial_number)) = lower(trim($1)) ORDER BY id`
+
+	var ids []uuid.UUID
+
+	err := r.db.SelectContext(ctx, &ids, query, serial)
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+func (r *deviceRepository) GetDeviceResolution(ctx context.Context, id uuid.UUID) (*models.DeviceResolution, error) {
+	device, err := r.GetDeviceByID(ctx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	resolution := models.DeviceResolution{Device: device, OpenTickets: []*models.TicketCard{}}
+
+	clientQuery := `
+		SELECT c.*
+		FROM agreements a
+		JOIN clients c ON c.id = a.actual_client
+		WHERE a.device = $1 AND a.is_active
+		ORDER BY a.assigned_at DESC NULLS LAST
+		LIMIT 1
+	`
+	var client models.Client
+	err = r.db.GetContext(ctx, &client, clientQuery, id)
+	switch {
+	case err == nil:
+		resolution.Client = &client
+	case err != sql.ErrNoRows:
+		return nil, fmt.Errorf("client: %w", err)
+	}
+
+	ticketsQuery := ticketCardQuery + `
+		WHERE t.device = $1 AND t.status NOT IN ('closed', 'cancelled')
+		ORDER BY t.urgent DESC, t.assigned_end NULLS LAST, t.created_at
+	`
+	if err := r.db.SelectContext(ctx, &resolution.OpenTickets, ticketsQuery, id); err != nil {
+		return nil, fmt.Errorf("open tickets: %w", err)
+	}
+
+	return &resolution, nil
+}
@@ -0,0 +1,232 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// setClientLocation stores a single point as the client's location.
+const setClientLocation = `
+	UPDATE clients c SET location = jsonb_build_array(jsonb_build_object('lat', $2::float8, 'lng', $3::float8))
+	WHERE c.id = $1
+`
+
+// missingLocation matches clients c without a usable location.
+const missingLocation = `(c.location IS NULL OR c.location IN ('[]'::jsonb, 'null'::jsonb, '{}'::jsonb))`
+
+type GeocodingRepo interface {
+	ListClientsToGeocode(ctx context.Context, limit int) ([]*models.GeocodeCandidate, error)
+	GetCachedGeocode(ctx context.Context, addressKey string, notOlderThan time.Time) (*models.GeocodeCacheEntry, error)
+	CacheGeocode(ctx context.Context, entry models.GeocodeCacheEntry) error
+	SaveClientGeocode(ctx context.Context, geocode models.ClientGeocode, apply bool) error
+	ListClientGeocodes(ctx context.Context, status string) ([]*models.ClientGeocode, error)
+	ApproveClientGeocode(ctx context.Context, clientID, reviewer uuid.UUID, lat, lng *float64) (bool, error)
+	RejectClientGeocode(ctx context.Context, clientID, reviewer uuid.UUID) (bool, error)
+}
+
+type geocodingRepo struct {
+	db *sqlx.DB
+}
+
+func NewGeocodingRepo(db *sqlx.DB) GeocodingRepo {
+	return &geocodingRepo{db}
+}
+
+// ListClientsToGeocode returns clients with an address and no location whose
+// current address hasn't been geocoded yet or failed to geocode. Failed ones
+// come last, the longest waiting first, so they can't starve new addresses.
+func (r *geocodingRepo) ListClientsToGeocode(ctx context.Context, limit int) ([]*models.GeocodeCandidate, error) {
+	query := `
+		SELECT c.id, c.address
+		FROM clients c
+		LEFT JOIN client_geocodes g ON g.client_id = c.id AND g.address = c.address
+		WHERE TRIM(COALESCE(c.address, '')) <> ''
+			AND ` + missingLocation + `
+			AND (g.client_id IS NULL OR g.status = 'failed')
+		ORDER BY g.created_at NULLS FIRST, c.title
+		LIMIT $1
+	`
+
+	var candidates []*models.GeocodeCandidate
+
+	err := r.db.SelectContext(ctx, &candidates, query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return candidates, nil
+}
+
+func (r *geocodingRepo) GetCachedGeocode(ctx context.Context, addressKey string, notOlderThan time.Time) (*models.GeocodeCacheEntry, error) {
+	query := `SELECT * FROM geocode_cache WHERE address_key = $1 AND created_at >= $2`
+
+	var entry models.GeocodeCacheEntry
+
+	err := r.db.GetContext(ctx, &entry, query, addressKey, notOlderThan)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+func (r *geocodingRepo) CacheGeocode(ctx context.Context, entry models.GeocodeCacheEntry) error {
+	query := `
+		INSERT INTO geocode_cache (address_key, found, lat, lng, confidence, display_name, provider)
+		VALUES (:address_key, :found, :lat, :lng, :confidence, :display_name, :provider)
+		ON CONFLICT (address_key) DO UPDATE SET
+			found = EXCLUDED.found,
+			lat = EXCLUDED.lat,
+			lng = EXCLUDED.lng,
+			confidence = EXCLUDED.confidence,
+			display_name = EXCLUDED.display_name,
+			provider = EXCLUDED.provider,
+			created_at = NOW() AT TIME ZONE 'UTC'
+	`
+
+	_, err := r.db.NamedExecContext(ctx, query, entry)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SaveClientGeocode records the outcome for the client and counts the attempts
+// made for its current address. With apply the match also becomes the client's
+// location unless one was set in the meantime.
+func (r *geocodingRepo) SaveClientGeocode(ctx context.Context, geocode models.ClientGeocode, apply bool) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO client_geocodes (client_id, address, status, lat, lng, confidence, display_name)
+		VALUES (:client_id, :address, :status, :lat, :lng, :confidence, :display_name)
+		ON CONFLICT (client_id) DO UPDATE SET
+			address = EXCLUDED.address,
+			status = EXCLUDED.status,
+			lat = EXCLUDED.lat,
+			lng = EXCLUDED.lng,
+			confidence = EXCLUDED.confidence,
+			display_name = EXCLUDED.display_name,
+			attempts = CASE WHEN client_geocodes.address = EXCLUDED.address THEN client_geocodes.attempts + 1 ELSE 1 END,
+			created_at = NOW() AT TIME ZONE 'UTC',
+			reviewed_by = NULL,
+			reviewed_at = NULL
+	`
+
+	_, err = tx.NamedExecContext(ctx, query, geocode)
+	if err != nil {
+		return err
+	}
+
+	if apply && geocode.Lat != nil && geocode.Lng != nil {
+		_, err = tx.ExecContext(ctx, setClientLocation+` AND `+missingLocation, geocode.ClientID, *geocode.Lat, *geocode.Lng)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (r *geocodingRepo) ListClientGeocodes(ctx context.Context, status string) ([]*models.ClientGeocode, error) {
+	query := `
+		SELECT g.*, c.title AS client_title
+		FROM client_geocodes g
+		JOIN clients c ON c.id = g.client_id
+		WHERE g.status = $1
+		ORDER BY g.confidence DESC NULLS LAST, c.title
+	`
+
+	geocodes := []*models.ClientGeocode{}
+
+	err := r.db.SelectContext(ctx, &geocodes, query, status)
+	if err != nil {
+		return nil, err
+	}
+
+	return geocodes, nil
+}
+
+// ApproveClientGeocode accepts a pending match, with corrected coordinates
+// when given, and sets it as the client's location.
+func (r *geocodingRepo) ApproveClientGeocode(ctx context.Context, clientID, reviewer uuid.UUID, lat, lng *float64) (bool, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE client_geocodes SET
+			status = 'approved',
+			lat = COALESCE($3, lat),
+			lng = COALESCE($4, lng),
+			reviewed_by = $2,
+			reviewed_at = NOW() AT TIME ZONE 'UTC'
+		WHERE client_id = $1 AND status = 'pending'
+		RETURNING lat, lng
+	`
+
+	var point struct {
+		Lat float64 `db:"lat"`
+		Lng float64 `db:"lng"`
+	}
+
+	err = tx.GetContext(ctx, &point, query, clientID, reviewer, lat, lng)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if _, err := tx.ExecContext(ctx, setClientLocation, clientID, point.Lat, point.Lng); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, nil
+}
+
+func (r *geocodingRepo) RejectClientGeocode(ctx context.Context, clientID, reviewer uuid.UUID) (bool, error) {
+	query := `
+		UPDATE client_geocodes SET
+			status = 'rejected',
+			reviewed_by = $2,
+			reviewed_at = NOW() AT TIME ZONE 'UTC'
+		WHERE client_id = $1 AND status = 'pending'
+	`
+
+	result, err := r.db.ExecContext(ctx, query, clientID, reviewer)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
@@ -0,0 +1,378 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrUnknownLISConnectionTarget = errors.New("unknown device or laboratory system")
+	ErrDuplicateLISConnection     = errors.New("device is already connected to this laboratory system")
+)
+
+// laboratorySystemsQuery selects laboratory systems with usage counts.
+const laboratorySystemsQuery = `
+	SELECT ls.*,
+		(SELECT COUNT(*) FROM clients c WHERE c.laboratory_system = ls.id) AS clients,
+		(SELECT COUNT(*) FROM device_lis_connections dc WHERE dc.laboratory_system = ls.id) AS connections
+	FROM laboratory_systems ls
+`
+
+// lisConnectionsQuery selects connections with the device they belong to.
+const lisConnectionsQuery = `
+	SELECT dc.*, d.serial_number, cl.title AS classificator_title
+	FROM device_lis_connections dc
+	JOIN devices d ON d.id = dc.device_id
+	LEFT JOIN classificators cl ON cl.id = d.classificator
+`
+
+// syncConnectedToLIS keeps the devices.connected_to_lis flag of the device
+// matching its connections.
+const syncConnectedToLIS = `
+	UPDATE devices SET connected_to_lis = EXISTS (
+		SELECT 1 FROM device_lis_connections WHERE device_id = $1
+	)
+	WHERE id = $1
+`
+
+type LaboratorySystemRepo interface {
+	ListLaboratorySystems(ctx context.Context, search string) ([]*models.LaboratorySystem, error)
+	GetLaboratorySystem(ctx context.Context, id uuid.UUID) (*models.LaboratorySystem, error)
+	CreateLaboratorySystem(ctx context.Context, system models.LaboratorySystem) (*models.LaboratorySystem, error)
+	UpdateLaboratorySystem(ctx context.Context, id uuid.UUID, update models.LaboratorySystemUpdate) (*models.LaboratorySystem, error)
+	DeleteLaboratorySystem(ctx context.Context, id uuid.UUID) (bool, error)
+	ListSystemClients(ctx context.Context, id uuid.UUID) ([]*models.Client, error)
+	ListSystemConnections(ctx context.Context, id uuid.UUID) ([]*models.LISConnection, error)
+	GetConnection(ctx context.Context, id uuid.UUID) (*models.LISConnection, error)
+	CreateConnection(ctx context.Context, connection models.LISConnection) (*models.LISConnection, error)
+	UpdateConnection(ctx context.Context, id uuid.UUID, update models.LISConnectionUpdate) (*models.LISConnection, error)
+	DeleteConnection(ctx context.Context, id uuid.UUID) (bool, error)
+	GetClientSystem(ctx context.Context, clientID uuid.UUID) (system *models.LaboratorySystem, exists bool, err error)
+	ListClientConnections(ctx context.Context, clientID uuid.UUID) ([]*models.LISConnection, error)
+}
+
+type laboratorySystemRepo struct {
+	db *sqlx.DB
+}
+
+func NewLaboratorySystemRepo(db *sqlx.DB) LaboratorySystemRepo {
+	return &laboratorySystemRepo{db}
+}
+
+func (r *laboratorySystemRepo) ListLaboratorySystems(ctx context.Context, search string) ([]*models.LaboratorySystem, error) {
+	query := laboratorySystemsQuery + `
+		WHERE $1 = '' OR ls.title ILIKE '%' || $1 || '%' OR ls.vendor ILIKE '%' || $1 || '%'
+		ORDER BY ls.title
+	`
+
+	systems := []*models.LaboratorySystem{}
+
+	err := r.db.SelectContext(ctx, &systems, query, search)
+	if err != nil {
+		return nil, err
+	}
+
+	return systems, nil
+}
+
+func (r *laboratorySystemRepo) GetLaboratorySystem(ctx context.Context, id uuid.UUID) (*models.LaboratorySystem, error) {
+	var system models.LaboratorySystem
+
+	err := r.db.GetContext(ctx, &system, laboratorySystemsQuery+` WHERE ls.id = $1`, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &system, nil
+}
+
+func (r *laboratorySystemRepo) CreateLaboratorySystem(ctx context.Context, system models.LaboratorySystem) (*models.LaboratorySystem, error) {
+	query := `
+		INSERT INTO laboratory_systems (title, vendor, version, contact_name, contact_phone, contact_email, notes)
+		VALUES (:title, :vendor, :version, :contact_name, :contact_phone, :contact_email, :notes)
+		RETURNING *
+	`
+
+	stmt, err := r.db.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var created models.LaboratorySystem
+
+	err = stmt.GetContext(ctx, &created, system)
+	if err != nil {
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+func (r *laboratorySystemRepo) UpdateLaboratorySystem(ctx context.Context, id uuid.UUID, update models.LaboratorySystemUpdate) (*models.LaboratorySystem, error) {
+	query := `
+		UPDATE laboratory_systems
+		SET
+			title = COALESCE($2, title),
+			vendor = COALESCE($3, vendor),
+			version = COALESCE($4, version),
+			contact_name = COALESCE($5, contact_name),
+			contact_phone = COALESCE($6, contact_phone),
+			contact_email = COALESCE($7, contact_email),
+			notes = COALESCE($8, notes)
+		WHERE id = $1
+	`
+
+	result, err := r.db.ExecContext(
+		ctx,
+		query,
+		id,
+		update.Title,
+		update.Vendor,
+		update.Version,
+		update.ContactName,
+		update.ContactPhone,
+		update.ContactEmail,
+		update.Notes,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if affected == 0 {
+		return nil, nil
+	}
+
+	return r.GetLaboratorySystem(ctx, id)
+}
+
+// DeleteLaboratorySystem removes the system and its device connections, and
+// clears the connected flag of devices left without one.
+func (r *laboratorySystemRepo) DeleteLaboratorySystem(ctx context.Context, id uuid.UUID) (bool, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var devices []uuid.UUID
+	err = tx.SelectContext(ctx, &devices, `SELECT device_id FROM device_lis_connections WHERE laboratory_system = $1`, id)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM laboratory_systems WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	for _, device := range devices {
+		if _, err := tx.ExecContext(ctx, syncConnectedToLIS, device); err != nil {
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, nil
+}
+
+func (r *laboratorySystemRepo) ListSystemClients(ctx context.Context, id uuid.UUID) ([]*models.Client, error) {
+	clients := []*models.Client{}
+
+	err := r.db.SelectContext(ctx, &clients, `SELECT * FROM clients WHERE laboratory_system = $1 ORDER BY title`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return clients, nil
+}
+
+func (r *laboratorySystemRepo) ListSystemConnections(ctx context.Context, id uuid.UUID) ([]*models.LISConnection, error) {
+	query := lisConnectionsQuery + `
+		WHERE dc.laboratory_system = $1
+		ORDER BY cl.title, d.serial_number
+	`
+
+	connections := []*models.LISConnection{}
+
+	err := r.db.SelectContext(ctx, &connections, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return connections, nil
+}
+
+func (r *laboratorySystemRepo) GetConnection(ctx context.Context, id uuid.UUID) (*models.LISConnection, error) {
+	var connection models.LISConnection
+
+	err := r.db.GetContext(ctx, &connection, lisConnectionsQuery+` WHERE dc.id = $1`, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &connection, nil
+}
+
+func (r *laboratorySystemRepo) CreateConnection(ctx context.Context, connection models.LISConnection) (*models.LISConnection, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO device_lis_connections (device_id, laboratory_system, protocol, parameters, comment)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+
+	var id uuid.UUID
+
+	err = tx.GetContext(ctx, &id, query, connection.DeviceID, connection.LaboratorySystem, connection.Protocol, connection.Parameters, connection.Comment)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return nil, ErrUnknownLISConnectionTarget
+		}
+		if isUniqueViolation(err) {
+			return nil, ErrDuplicateLISConnection
+		}
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, syncConnectedToLIS, connection.DeviceID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return r.GetConnection(ctx, id)
+}
+
+func (r *laboratorySystemRepo) UpdateConnection(ctx context.Context, id uuid.UUID, update models.LISConnectionUpdate) (*models.LISConnection, error) {
+	query := `
+		UPDATE device_lis_connections
+		SET
+			protocol = COALESCE($2, protocol),
+			parameters = COALESCE($3, parameters),
+			comment = COALESCE($4, comment)
+		WHERE id = $1
+	`
+
+	result, err := r.db.ExecContext(ctx, query, id, update.Protocol, update.Parameters, update.Comment)
+	if err != nil {
+		return nil, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if affected == 0 {
+		return nil, nil
+	}
+
+	return r.GetConnection(ctx, id)
+}
+
+func (r *laboratorySystemRepo) DeleteConnection(ctx context.Context, id uuid.UUID) (bool, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var device uuid.UUID
+
+	err = tx.GetContext(ctx, &device, `DELETE FROM device_lis_connections WHERE id = $1 RETURNING device_id`, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if _, err := tx.ExecContext(ctx, syncConnectedToLIS, device); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, nil
+}
+
+// GetClientSystem returns the laboratory system of the client, nil if it has
+// none, and whether the client exists.
+func (r *laboratorySystemRepo) GetClientSystem(ctx context.Context, clientID uuid.UUID) (*models.LaboratorySystem, bool, error) {
+	var systemID *uuid.UUID
+
+	err := r.db.GetContext(ctx, &systemID, `SELECT laboratory_system FROM clients WHERE id = `+resolvedClientID("$1"), clientID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	if systemID == nil {
+		return nil, true, nil
+	}
+
+	system, err := r.GetLaboratorySystem(ctx, *systemID)
+	if err != nil {
+		return nil, true, err
+	}
+
+	return system, true, nil
+}
+
+// ListClientConnections lists the LIS connections of the devices under the
+// client's active agreements.
+func (r *laboratorySystemRepo) ListClientConnections(ctx context.Context, clientID uuid.UUID) ([]*models.LISConnection, error) {
+	query := lisConnectionsQuery + `
+		WHERE dc.device_id IN (
+			SELECT device FROM agreements WHERE actual_client = ` + resolvedClientID("$1") + ` AND is_active
+		)
+		ORDER BY cl.title, d.serial_number
+	`
+
+	connections := []*models.LISConnection{}
+
+	err := r.db.SelectContext(ctx, &connections, query, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	return connections, nil
+}
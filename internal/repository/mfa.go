@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+type MFARepo interface {
+	GetTOTPState(ctx context.Context, userID uuid.UUID) (*models.TOTPState, error)
+	SetPendingTOTPSecret(ctx context.Context, userID uuid.UUID, secret string) error
+	EnableTOTP(ctx context.Context, userID uuid.UUID, step int64, recoveryCodeHashes []string) error
+	DisableTOTP(ctx context.Context, userID uuid.UUID) error
+	AdvanceTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error
+	UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error)
+	CountRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error)
+}
+
+type mfaRepo struct {
+	db *sqlx.DB
+}
+
+func NewMFARepo(db *sqlx.DB) MFARepo {
+	return &mfaRepo{db}
+}
+
+func (r *mfaRepo) GetTOTPState(ctx context.Context, userID uuid.UUID) (*models.TOTPState, error) {
+	query := `
+		SELECT totp_secret, COALESCE(totp_enabled, false) as totp_enabled, COALESCE(totp_last_step, 0) as totp_last_step
+		FROM accounts WHERE user_id = $1
+	`
+
+	var state models.TOTPState
+
+	err := r.db.GetContext(ctx, &state, query, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get totp state: %w", err)
+	}
+
+	return &state, nil
+}
+
+// SetPendingTOTPSecret stores a secret for an account that has not confirmed
+// enrollment yet. An already enabled second factor is never overwritten.
+func (r *mfaRepo) SetPendingTOTPSecret(ctx context.Context, userID uuid.UUID, secret string) error {
+	query := `UPDATE accounts SET totp_secret = $1 WHERE user_id = $2 AND NOT COALESCE(totp_enabled, false)`
+
+	_, err := r.db.ExecContext(ctx, query, secret, userID)
+	if err != nil {
+		return fmt.Errorf("failed to store totp secret: %w", err)
+	}
+
+	return nil
+}
+
+// EnableTOTP confirms the enrollment and stores the initial recovery codes in one transaction.
+func (r *mfaRepo) EnableTOTP(ctx context.Context, userID uuid.UUID, step int64, recoveryCodeHashes []string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `UPDATE accounts SET totp_enabled = true, totp_last_step = $1 WHERE user_id = $2`, step, userID)
+	if err != nil {
+		return fmt.Errorf("could not enable totp: %w", err)
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *mfaRepo) DisableTOTP(ctx context.Context, userID uuid.UUID) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE accounts SET totp_secret = NULL, totp_enabled = false, totp_last_step = 0 WHERE user_id = $1`
+	if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("could not disable totp: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("could not delete recovery codes: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// AdvanceTOTPStep records step as the last accepted one. It returns false if
+// the same or a later step was already used, i.e. the code is a replay.
+func (r *mfaRepo) AdvanceTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	query := `UPDATE accounts SET totp_last_step = $1 WHERE user_id = $2 AND COALESCE(totp_last_step, 0) < $1`
+
+	result, err := r.db.ExecContext(ctx, query, step, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to update totp step: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func (r *mfaRepo) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sqlx.Tx, userID uuid.UUID, codeHashes []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("could not delete recovery codes: %w", err)
+	}
+
+	for _, hash := range codeHashes {
+		_, err := tx.ExecContext(ctx, `INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hash)
+		if err != nil {
+			return fmt.Errorf("could not insert recovery code: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// UseRecoveryCode marks an unused code as used and reports whether there was one.
+func (r *mfaRepo) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error) {
+	query := `
+		UPDATE mfa_recovery_codes SET used_at = NOW() AT TIME ZONE 'UTC'
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func (r *mfaRepo) CountRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error) {
+	var count int
+
+	err := r.db.GetContext(ctx, &count, `SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL`, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+
+	return count, nil
+}
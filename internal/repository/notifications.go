@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type NotificationRepo interface {
+	AddNotifications(ctx context.Context, notifications []models.Notification) error
+	ListNotifications(ctx context.Context, userID uuid.UUID, unreadOnly bool, limit, offset int) ([]*models.Notification, error)
+	MarkRead(ctx context.Context, userID, id uuid.UUID) (bool, error)
+	MarkAllRead(ctx context.Context, userID uuid.UUID) (int64, error)
+	GetRecipients(ctx context.Context, userIDs []uuid.UUID) ([]*models.NotificationRecipient, error)
+}
+
+type notificationRepo struct {
+	db *sqlx.DB
+}
+
+func NewNotificationRepo(db *sqlx.DB) NotificationRepo {
+	return &notificationRepo{db}
+}
+
+func (r *notificationRepo) AddNotifications(ctx context.Context, notifications []models.Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO notifications (user_id, kind, reference_id, message)
+		VALUES (:user_id, :kind, :reference_id, :message)
+	`
+
+	_, err := r.db.NamedExecContext(ctx, query, notifications)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *notificationRepo) ListNotifications(ctx context.Context, userID uuid.UUID, unreadOnly bool, limit, offset int) ([]*models.Notification, error) {
+	query := `
+		SELECT * FROM notifications
+		WHERE user_id = $1 AND (NOT $2 OR read_at IS NULL)
+		ORDER BY created_at DESC
+		LIMIT $3 OFFSET $4
+	`
+
+	notifications := []*models.Notification{}
+
+	err := r.db.SelectContext(ctx, &notifications, query, userID, unreadOnly, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+func (r *notificationRepo) MarkRead(ctx context.Context, userID, id uuid.UUID) (bool, error) {
+	query := `
+		UPDATE notifications SET read_at = COALESCE(read_at, NOW() AT TIME ZONE 'UTC')
+		WHERE id = $1 AND user_id = $2
+	`
+
+	result, err := r.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func (r *notificationRepo) MarkAllRead(ctx context.Context, userID uuid.UUID) (int64, error) {
+	query := `
+		UPDATE notifications SET read_at = NOW() AT TIME ZONE 'UTC'
+		WHERE user_id = $1 AND read_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// GetRecipients returns the enabled accounts among userIDs with their profile email.
+func (r *notificationRepo) GetRecipients(ctx context.Context, userIDs []uuid.UUID) ([]*models.NotificationRecipient, error) {
+	query := `
+		SELECT a.user_id, COALESCE(u.email, '') AS email
+		FROM accounts a
+		LEFT JOIN users u ON u.user_id = a.user_id
+		WHERE a.user_id = ANY($1) AND NOT COALESCE(a.disabled, false)
+	`
+
+	var recipients []*models.NotificationRecipient
+
+	err := r.db.SelectContext(ctx, &recipients, query, pq.Array(userIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	return recipients, nil
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrInvalidOIDCState = errors.New("unknown or expired login state")
+
+type OIDCLoginState struct {
+	State        string    `db:"state"`
+	CodeVerifier string    `db:"code_verifier"`
+	Nonce        string    `db:"nonce"`
+	ExpiresAt    time.Time `db:"expires_at"`
+}
+
+type OIDCRepo interface {
+	SaveState(ctx context.Context, state OIDCLoginState) error
+	ConsumeState(ctx context.Context, state string) (*OIDCLoginState, error)
+	FindAccountBySubject(ctx context.Context, subject string) (uuid.UUID, error)
+	FindUnlinkedAccountByEmail(ctx context.Context, email string) (uuid.UUID, error)
+	LinkSubject(ctx context.Context, userID uuid.UUID, subject string) error
+}
+
+type oidcRepo struct {
+	db *sqlx.DB
+}
+
+func NewOIDCRepo(db *sqlx.DB) OIDCRepo {
+	return &oidcRepo{db}
+}
+
+// SaveState stores a pending login and clears expired ones.
+func (r *oidcRepo) SaveState(ctx context.Context, state OIDCLoginState) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM oidc_login_states WHERE expires_at < NOW() AT TIME ZONE 'UTC'`)
+	if err != nil {
+		return fmt.Errorf("failed to clear expired login states: %w", err)
+	}
+
+	query := `
+		INSERT INTO oidc_login_states (state, code_verifier, nonce, expires_at)
+		VALUES (:state, :code_verifier, :nonce, :expires_at)
+	`
+	if _, err := r.db.NamedExecContext(ctx, query, state); err != nil {
+		return fmt.Errorf("failed to save login state: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeState deletes and returns a pending login so that it can be used
+// only once. Expired logins are returned too, the caller checks ExpiresAt.
+func (r *oidcRepo) ConsumeState(ctx context.Context, state string) (*OIDCLoginState, error) {
+	query := `DELETE FROM oidc_login_states WHERE state = $1 RETURNING *`
+
+	var loginState OIDCLoginState
+
+	err := r.db.GetContext(ctx, &loginState, query, state)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInvalidOIDCState
+		}
+		return nil, fmt.Errorf("failed to consume login state: %w", err)
+	}
+
+	return &loginState, nil
+}
+
+// FindAccountBySubject returns uuid.Nil if no account is linked to the subject.
+func (r *oidcRepo) FindAccountBySubject(ctx context.Context, subject string) (uuid.UUID, error) {
+	var userID uuid.UUID
+
+	err := r.db.GetContext(ctx, &userID, `SELECT user_id FROM accounts WHERE oidc_subject = $1`, subject)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return uuid.Nil, nil
+		}
+		return uuid.Nil, err
+	}
+
+	return userID, nil
+}
+
+// FindUnlinkedAccountByEmail looks for exactly one account whose profile has
+// the email and that is not linked to another identity yet. It returns
+// uuid.Nil when there is none or the email is ambiguous.
+func (r *oidcRepo) FindUnlinkedAccountByEmail(ctx context.Context, email string) (uuid.UUID, error) {
+	query := `
+		SELECT a.user_id FROM accounts a
+		JOIN users u ON u.user_id = a.user_id
+		WHERE lower(u.email) = lower($1) AND a.oidc_subject IS NULL
+		LIMIT 2
+	`
+
+	var ids []uuid.UUID
+
+	err := r.db.SelectContext(ctx, &ids, query, email)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if len(ids) != 1 {
+		return uuid.Nil, nil
+	}
+
+	return ids[0], nil
+}
+
+func (r *oidcRepo) LinkSubject(ctx context.Context, userID uuid.UUID, subject string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE accounts SET oidc_subject = $1 WHERE user_id = $2`, subject, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
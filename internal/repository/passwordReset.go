@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+
+type PasswordResetRepo interface {
+	CreateToken(ctx context.Context, userID uuid.UUID, tokenHash string, ttl time.Duration) error
+	ResetPassword(ctx context.Context, tokenHash string, passwordHash string) (uuid.UUID, error)
+	GetTokenOwner(ctx context.Context, tokenHash string) (uuid.UUID, error)
+	GetAccountEmail(ctx context.Context, userID uuid.UUID) (string, error)
+}
+
+type passwordResetRepo struct {
+	db *sqlx.DB
+}
+
+func NewPasswordResetRepo(db *sqlx.DB) PasswordResetRepo {
+	return &passwordResetRepo{db}
+}
+
+// CreateToken stores a new reset token and drops the expired ones of the
+// account. Earlier links keep working until they expire or one of them is used.
+func (r *passwordResetRepo) CreateToken(ctx context.Context, userID uuid.UUID, tokenHash string, ttl time.Duration) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		DELETE FROM password_reset_tokens
+		WHERE user_id = $1 AND used_at IS NULL AND expires_at <= (NOW() AT TIME ZONE 'UTC')
+	`
+	_, err = tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return fmt.Errorf("could not delete expired tokens: %w", err)
+	}
+
+	query = `
+		INSERT INTO password_reset_tokens (token_hash, user_id, expires_at)
+		VALUES ($1, $2, (NOW() AT TIME ZONE 'UTC') + $3 * INTERVAL '1 second')
+	`
+	_, err = tx.ExecContext(ctx, query, tokenHash, userID, int(ttl.Seconds()))
+	if err != nil {
+		return fmt.Errorf("could not insert reset token: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// GetTokenOwner returns the account a still usable token belongs to.
+func (r *passwordResetRepo) GetTokenOwner(ctx context.Context, tokenHash string) (uuid.UUID, error) {
+	query := `
+		SELECT user_id FROM password_reset_tokens
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > (NOW() AT TIME ZONE 'UTC')
+	`
+
+	var userID uuid.UUID
+
+	err := r.db.GetContext(ctx, &userID, query, tokenHash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return uuid.Nil, ErrInvalidResetToken
+		}
+		return uuid.Nil, err
+	}
+
+	return userID, nil
+}
+
+// ResetPassword consumes the token, drops the other outstanding reset tokens
+// of the account, sets the new password hash and revokes every token issued
+// to the account so far, all in one transaction.
+func (r *passwordResetRepo) ResetPassword(ctx context.Context, tokenHash string, passwordHash string) (uuid.UUID, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE password_reset_tokens
+		SET used_at = NOW() AT TIME ZONE 'UTC'
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > (NOW() AT TIME ZONE 'UTC')
+		RETURNING user_id
+	`
+
+	var userID uuid.UUID
+
+	err = tx.GetContext(ctx, &userID, query, tokenHash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return uuid.Nil, ErrInvalidResetToken
+		}
+		return uuid.Nil, fmt.Errorf("could not consume reset token: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM password_reset_tokens WHERE user_id = $1 AND used_at IS NULL`, userID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("could not delete outstanding tokens: %w", err)
+	}
+
+	query = `
+		UPDATE accounts
+		SET password_hash = $1, tokens_valid_after = NOW() AT TIME ZONE 'UTC'
+		WHERE user_id = $2
+	`
+	_, err = tx.ExecContext(ctx, query, passwordHash, userID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("could not update password: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return uuid.Nil, fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	return userID, nil
+}
+
+func (r *passwordResetRepo) GetAccountEmail(ctx context.Context, userID uuid.UUID) (string, error) {
+	var email string
+
+	err := r.db.GetContext(ctx, &email, `SELECT COALESCE(email, '') FROM users WHERE user_id = $1`, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return email, nil
+}
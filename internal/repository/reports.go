@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// closedTicketsCTE selects the tickets closed in the report period. Both
+// report queries start from it so their rows agree.
+const closedTicketsCTE = `
+	WITH closed AS (
+		SELECT t.*
+		FROM tickets t
+		WHERE t.status = 'closed'
+			AND t.executor IS NOT NULL
+			AND t.closed_at >= $1 AND t.closed_at < $2
+			AND ($3::uuid IS NULL OR t.department = $3)
+	)
+`
+
+type ReportRepo interface {
+	EngineerKPIs(ctx context.Context, filter models.ReportFilter) ([]*models.EngineerReport, error)
+	EngineerReasons(ctx context.Context, filter models.ReportFilter) ([]*models.EngineerReasonCount, error)
+	GetUserDepartment(ctx context.Context, userID uuid.UUID) (*uuid.UUID, error)
+}
+
+type reportRepo struct {
+	db *sqlx.DB
+}
+
+func NewReportRepo(db *sqlx.DB) ReportRepo {
+	return &reportRepo{db}
+}
+
+func (r *reportRepo) EngineerKPIs(ctx context.Context, filter models.ReportFilter) ([]*models.EngineerReport, error) {
+	query := closedTicketsCTE + `,
+	follow_ups AS (
+		SELECT c.executor, COUNT(f.id) AS follow_up_tickets
+		FROM closed c
+		JOIN tickets f ON f.reference_ticket = c.id
+		GROUP BY c.executor
+	)
+	SELECT
+		c.executor AS user_id,
+		COALESCE(u.first_name, '') AS first_name,
+		COALESCE(u.last_name, '') AS last_name,
+		u.department,
+		COUNT(*) AS tickets_closed,
+		percentile_cont(0.5) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM c.workstarted_at - c.assigned_at) / 60)
+			FILTER (WHERE c.assigned_at IS NOT NULL AND c.workstarted_at IS NOT NULL) AS median_assign_to_start_minutes,
+		percentile_cont(0.5) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM c.workfinished_at - c.workstarted_at) / 60)
+			FILTER (WHERE c.workstarted_at IS NOT NULL AND c.workfinished_at IS NOT NULL) AS median_start_to_finish_minutes,
+		AVG(CASE WHEN c.workfinished_at <= c.assigned_end THEN 1.0 ELSE 0.0 END)
+			FILTER (WHERE c.assigned_end IS NOT NULL AND c.workfinished_at IS NOT NULL)::float8 AS on_time_ratio,
+		COALESCE(MAX(fu.follow_up_tickets), 0) AS follow_up_tickets,
+		AVG(CASE WHEN c.double_signed THEN 1.0 ELSE 0.0 END)::float8 AS double_signed_ratio
+	FROM closed c
+	LEFT JOIN users u ON u.user_id = c.executor
+	LEFT JOIN follow_ups fu ON fu.executor = c.executor
+	GROUP BY c.executor, u.first_name, u.last_name, u.department
+	ORDER BY tickets_closed DESC, last_name, first_name
+	`
+
+	reports := []*models.EngineerReport{}
+
+	err := r.db.SelectContext(ctx, &reports, query, filter.From, filter.To, filter.Department)
+	if err != nil {
+		return nil, err
+	}
+
+	return reports, nil
+}
+
+func (r *reportRepo) EngineerReasons(ctx context.Context, filter models.ReportFilter) ([]*models.EngineerReasonCount, error) {
+	query := closedTicketsCTE + `
+	SELECT c.executor AS user_id, COALESCE(tr.title, c.reason, '') AS reason, COUNT(*) AS count
+	FROM closed c
+	LEFT JOIN ticket_reasons tr ON tr.id = c.reason
+	GROUP BY c.executor, COALESCE(tr.title, c.reason, '')
+	`
+
+	var counts []*models.EngineerReasonCount
+
+	err := r.db.SelectContext(ctx, &counts, query, filter.From, filter.To, filter.Department)
+	if err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+func (r *reportRepo) GetUserDepartment(ctx context.Context, userID uuid.UUID) (*uuid.UUID, error) {
+	var department *uuid.UUID
+
+	err := r.db.GetContext(ctx, &department, `SELECT department FROM users WHERE user_id = $1`, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return department, nil
+}
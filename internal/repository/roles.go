@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrUnknownPermission = errors.New("unknown permission")
+	ErrRoleNameTaken     = errors.New("role name is already taken")
+)
+
+type RolesRepo interface {
+	ListPermissions(ctx context.Context) ([]*models.Permission, error)
+	ListRoles(ctx context.Context) ([]*models.RoleWithPermissions, error)
+	GetRoleByID(ctx context.Context, id int) (*models.RoleWithPermissions, error)
+	CreateRole(ctx context.Context, role models.Role, permissions []string) (*models.RoleWithPermissions, error)
+	SetRolePermissions(ctx context.Context, roleID int, permissions []string) error
+	ListRoleMembers(ctx context.Context, roleID int) ([]uuid.UUID, error)
+}
+
+type rolesRepo struct {
+	db *sqlx.DB
+}
+
+func NewRolesRepo(db *sqlx.DB) RolesRepo {
+	return &rolesRepo{db}
+}
+
+const roleColumns = `
+	r.id,
+	r.name,
+	COALESCE(r.description, '') as description,
+	ARRAY(
+		SELECT rp.permission FROM role_permissions rp
+		WHERE rp.role_id = r.id
+		ORDER BY rp.permission
+	) as permissions
+`
+
+func (r *rolesRepo) ListPermissions(ctx context.Context) ([]*models.Permission, error) {
+	var permissions []*models.Permission
+
+	query := `SELECT name, COALESCE(description, '') as description FROM permissions ORDER BY name`
+
+	err := r.db.SelectContext(ctx, &permissions, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+func (r *rolesRepo) ListRoles(ctx context.Context) ([]*models.RoleWithPermissions, error) {
+	var roles []*models.RoleWithPermissions
+
+	err := r.db.SelectContext(ctx, &roles, `SELECT `+roleColumns+` FROM roles r ORDER BY r.id`)
+	if err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+func (r *rolesRepo) GetRoleByID(ctx context.Context, id int) (*models.RoleWithPermissions, error) {
+	var role models.RoleWithPermissions
+
+	err := r.db.GetContext(ctx, &role, `SELECT `+roleColumns+` FROM roles r WHERE r.id = $1`, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &role, nil
+}
+
+func (r *rolesRepo) CreateRole(ctx context.Context, role models.Role, permissions []string) (*models.RoleWithPermissions, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO roles (name, description) VALUES ($1, $2) RETURNING id`
+	err = tx.GetContext(ctx, &role.ID, query, role.Name, role.Description)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrRoleNameTaken
+		}
+		return nil, fmt.Errorf("could not insert role: %w", err)
+	}
+
+	if err := setRolePermissions(ctx, tx, role.ID, permissions); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	return &models.RoleWithPermissions{Role: role, Permissions: permissions}, nil
+}
+
+func (r *rolesRepo) SetRolePermissions(ctx context.Context, roleID int, permissions []string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := setRolePermissions(ctx, tx, roleID, permissions); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ListRoleMembers returns the accounts holding the role.
+func (r *rolesRepo) ListRoleMembers(ctx context.Context, roleID int) ([]uuid.UUID, error) {
+	var members []uuid.UUID
+
+	err := r.db.SelectContext(ctx, &members, `SELECT user_id FROM account_roles WHERE role_id = $1`, roleID)
+	if err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+func setRolePermissions(ctx context.Context, tx *sqlx.Tx, roleID int, permissions []string) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM role_permissions WHERE role_id = $1`, roleID)
+	if err != nil {
+		return fmt.Errorf("could not clear role permissions: %w", err)
+	}
+
+	if len(permissions) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO role_permissions (role_id, permission)
+		SELECT $1, name FROM permissions WHERE name = ANY($2)
+		ON CONFLICT DO NOTHING
+	`
+	result, err := tx.ExecContext(ctx, query, roleID, pq.Array(permissions))
+	if err != nil {
+		return fmt.Errorf("could not assign permissions to role: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if int(affected) != len(permissions) {
+		return ErrUnknownPermission
+	}
+
+	return nil
+}
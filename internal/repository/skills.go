@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// qualifiedSkillCondition matches a valid certification of user u for
+// classificator cl, either for the model itself or through its manufacturer
+// or research type.
+const qualifiedSkillCondition = `EXISTS (
+	SELECT 1 FROM user_skills s
+	WHERE s.user_id = u.user_id
+		AND (s.classificator = cl.id OR s.manufacturer = cl.manufacturer OR s.research_type = cl.research_type)
+		AND s.certified_at IS NOT NULL
+		AND (s.expires_at IS NULL OR s.expires_at >= CURRENT_DATE)
+)`
+
+var ErrUnknownSkillTarget = errors.New("unknown classificator, manufacturer or research type")
+
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
+
+type SkillRepo interface {
+	ListUserSkills(ctx context.Context, userID uuid.UUID) ([]*models.Skill, error)
+	GetSkill(ctx context.Context, id uuid.UUID) (*models.Skill, error)
+	CreateSkill(ctx context.Context, skill models.Skill) (*models.Skill, error)
+	UpdateSkill(ctx context.Context, skill models.Skill) (*models.Skill, error)
+	DeleteSkill(ctx context.Context, id uuid.UUID) (bool, error)
+	ListCertificates(ctx context.Context, skillIDs []uuid.UUID) ([]*models.Attachment, error)
+	HasValidCertification(ctx context.Context, userID, deviceID uuid.UUID) (bool, error)
+	GetUserDepartment(ctx context.Context, userID uuid.UUID) (department *uuid.UUID, exists bool, err error)
+}
+
+type skillRepo struct {
+	db *sqlx.DB
+}
+
+func NewSkillRepo(db *sqlx.DB) SkillRepo {
+	return &skillRepo{db}
+}
+
+func (r *skillRepo) ListUserSkills(ctx context.Context, userID uuid.UUID) ([]*models.Skill, error) {
+	query := `
+		SELECT s.*, cl.title AS classificator_title, m.title AS manufacturer_title, rt.title AS research_type_title
+		FROM user_skills s
+		LEFT JOIN classificators cl ON cl.id = s.classificator
+		LEFT JOIN manufacturers m ON m.id = s.manufacturer
+		LEFT JOIN research_type rt ON rt.id = s.research_type
+		WHERE s.user_id = $1
+		ORDER BY s.expires_at NULLS LAST, s.created_at
+	`
+
+	skills := []*models.Skill{}
+
+	err := r.db.SelectContext(ctx, &skills, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return skills, nil
+}
+
+func (r *skillRepo) GetSkill(ctx context.Context, id uuid.UUID) (*models.Skill, error) {
+	var skill models.Skill
+
+	err := r.db.GetContext(ctx, &skill, `SELECT * FROM user_skills WHERE id = $1`, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &skill, nil
+}
+
+func (r *skillRepo) CreateSkill(ctx context.Context, skill models.Skill) (*models.Skill, error) {
+	query := `
+		INSERT INTO user_skills (user_id, classificator, manufacturer, research_type, certified_at, expires_at, comment)
+		VALUES (:user_id, :classificator, :manufacturer, :research_type, :certified_at, :expires_at, :comment)
+		RETURNING *
+	`
+
+	stmt, err := r.db.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var created models.Skill
+
+	err = stmt.GetContext(ctx, &created, skill)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return nil, ErrUnknownSkillTarget
+		}
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+func (r *skillRepo) UpdateSkill(ctx context.Context, skill models.Skill) (*models.Skill, error) {
+	query := `
+		UPDATE user_skills
+		SET classificator = :classificator, manufacturer = :manufacturer, research_type = :research_type,
+			certified_at = :certified_at, expires_at = :expires_at, comment = :comment
+		WHERE id = :id
+		RETURNING *
+	`
+
+	stmt, err := r.db.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var updated models.Skill
+
+	err = stmt.GetContext(ctx, &updated, skill)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		if isForeignKeyViolation(err) {
+			return nil, ErrUnknownSkillTarget
+		}
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+func (r *skillRepo) DeleteSkill(ctx context.Context, id uuid.UUID) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM user_skills WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func (r *skillRepo) ListCertificates(ctx context.Context, skillIDs []uuid.UUID) ([]*models.Attachment, error) {
+	var attachments []*models.Attachment
+
+	err := r.db.SelectContext(ctx, &attachments, `SELECT * FROM attachments WHERE ref_id = ANY($1)`, pq.Array(skillIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	return attachments, nil
+}
+
+// HasValidCertification reports whether the user may work on the device.
+// Devices without a classificator, or unknown devices, require nothing.
+func (r *skillRepo) HasValidCertification(ctx context.Context, userID, deviceID uuid.UUID) (bool, error) {
+	query := `
+		SELECT cl.id IS NULL OR ` + qualifiedSkillCondition + `
+		FROM users u
+		CROSS JOIN devices d
+		LEFT JOIN classificators cl ON cl.id = d.classificator
+		WHERE u.user_id = $1 AND d.id = $2
+	`
+
+	var qualified bool
+
+	err := r.db.GetContext(ctx, &qualified, query, userID, deviceID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return true, nil
+		}
+		return false, err
+	}
+
+	return qualified, nil
+}
+
+func (r *skillRepo) GetUserDepartment(ctx context.Context, userID uuid.UUID) (*uuid.UUID, bool, error) {
+	var department *uuid.UUID
+
+	err := r.db.GetContext(ctx, &department, `SELECT department FROM users WHERE user_id = $1`, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	return department, true, nil
+}
@@ -25,6 +25,11 @@ type TicketsRepository interface {
 	GetTicketContactPerson(ctx context.Context, uuid uuid.UUID) (*models.Contact, error)
 	// GetClientTicketIDs(ctx context.Context, clientUUID uuid.UUID) ([]*uuid.UUID, error)
 	GetTicketsByField(ctx context.Context, field string, fieldUUID uuid.UUID, filters models.TicketFilters, userID string) (*models.TicketArchiveResponse, error)
+	GetTicketAccess(ctx context.Context, uuid uuid.UUID) (*models.TicketAccess, error)
+	GetUserDepartment(ctx context.Context, userID uuid.UUID) (*uuid.UUID, error)
+	GetTicketAssignment(ctx context.Context, uuid uuid.UUID) (*models.TicketAssignment, error)
+	ListManagedClientTickets(ctx context.Context, managerID uuid.UUID, openOnly bool, limit int, offset int) ([]*models.TicketCard, error)
+	GetTicketClientManagers(ctx context.Context, uuid uuid.UUID) (*models.TicketClientManagers, error)
 }
 
 type ticketsRepository struct {
@@ -262,13 +267,51 @@ func (r *ticketsRepository) CreateNewTicket(ctx context.Context, payload models.
 	}
 	defer tx.Rollback()
 
-	var department uuid.UUID
-	err = tx.GetContext(ctx, &department, `SELECT department FROM users WHERE user_id = $1`, payload.Author)
-	if err != nil {
-		return nil, err
+	// A department given in the payload is kept. Otherwise the ticket goes to
+	// the author's department, and to the department of the first client
+	// manager that has one only when the author has none
+	if payload.Department == uuid.Nil {
+		departmentQuery := `
+		SELECT COALESCE(
+			(SELECT department FROM users WHERE user_id = $2),
+			(
+				SELECT u.department
+				FROM clients cl
+				CROSS JOIN LATERAL unnest(cl.manager) WITH ORDINALITY AS m(user_id, ord)
+				JOIN users u ON u.user_id = m.user_id
+				WHERE cl.id = $1 AND u.department IS NOT NULL
+				ORDER BY m.ord
+				LIMIT 1
+			)
+		)
+		`
+
+		err = tx.GetContext(ctx, &payload.Department, departmentQuery, payload.Client, payload.Author)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	payload.Department = department
+	// Without an explicit contact use the client's contact entry of its first
+	// manager that has one. Managers are users, contacts are matched to them by
+	// email
+	if payload.ContactPerson == nil && payload.Client != uuid.Nil {
+		contactQuery := `
+		SELECT con.id
+		FROM clients cl
+		CROSS JOIN LATERAL unnest(cl.manager) WITH ORDINALITY AS m(user_id, ord)
+		JOIN users u ON u.user_id = m.user_id
+		JOIN contacts con ON con.client_id = cl.id AND lower(con.email) = lower(u.email)
+		WHERE cl.id = $1 AND u.email <> ''
+		ORDER BY m.ord, con.id
+		LIMIT 1
+		`
+
+		err = tx.GetContext(ctx, &payload.ContactPerson, contactQuery, payload.Client)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+	}
 
 	query := `
 	INSERT INTO tickets (status, assigned_at, assigned_by, executor, description, planned_start, planned_end, assigned_start, assigned_end, device, reason, client, ticket_type, author, urgent, department, contact_person)
@@ -519,6 +562,14 @@ func (r *ticketsRepository) GetTicketsByField(ctx context.Context, field string,
 		return nil, err
 	}
 
+	// Merged client ids resolve to the surviving client
+	if field == "client" {
+		err = tx.GetContext(ctx, &fieldUUID, `SELECT `+resolvedClientID("$1::uuid"), fieldUUID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	query := fmt.Sprintf(`
 	SELECT
     t.id,
@@ -662,3 +713,115 @@ func (r *ticketsRepository) GetTicketsByField(ctx context.Context, field string,
 
 	return &response, nil
 }
+
+func (r *ticketsRepository) GetTicketAccess(ctx context.Context, uuid uuid.UUID) (*models.TicketAccess, error) {
+	query := `SELECT id, executor, department FROM tickets WHERE id = $1`
+
+	var access models.TicketAccess
+
+	err := r.db.GetContext(ctx, &access, query, uuid)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &access, nil
+}
+
+func (r *ticketsRepository) GetTicketAssignment(ctx context.Context, uuid uuid.UUID) (*models.TicketAssignment, error) {
+	query := `SELECT executor, device, assigned_start, assigned_end FROM tickets WHERE id = $1`
+
+	var assignment models.TicketAssignment
+
+	err := r.db.GetContext(ctx, &assignment, query, uuid)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &assignment, nil
+}
+
+func (r *ticketsRepository) GetUserDepartment(ctx context.Context, userID uuid.UUID) (*uuid.UUID, error) {
+	query := `SELECT department FROM users WHERE user_id = $1`
+
+	var department *uuid.UUID
+
+	err := r.db.GetContext(ctx, &department, query, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return department, nil
+}
+
+// ListManagedClientTickets lists the tickets of every client the user manages,
+// most recent first.
+func (r *ticketsRepository) ListManagedClientTickets(ctx context.Context, managerID uuid.UUID, openOnly bool, limit int, offset int) ([]*models.TicketCard, error) {
+	query := `
+	SELECT
+    t.id,
+    t.number,
+    t.assigned_end,
+    t.urgent,
+    t.status,
+    t.workstarted_at,
+    t.workfinished_at,
+  	t.description,
+    TRIM(CONCAT(ex.first_name, ' ', ex.last_name)) as executor,
+    dep.title as department,
+    d.serial_number AS device_serial_number,
+    c.title AS device_classificator_title,
+    cl.title as client_name,
+    cl.address as client_address,
+    tr.title as reason
+	FROM tickets t
+	JOIN clients cl ON t.client = cl.id
+	LEFT JOIN devices d ON t.device = d.id
+	LEFT JOIN classificators c ON d.classificator = c.id
+	LEFT JOIN ticket_reasons tr on t.reason = tr.id
+	LEFT JOIN users ex ON t.executor = ex.user_id
+	LEFT JOIN departments dep ON t.department = dep.id
+	WHERE $1 = ANY(cl.manager)
+	AND (NOT $2 OR t.status NOT IN ('closed', 'cancelled'))
+	ORDER BY t.created_at DESC, t.number DESC
+	LIMIT $3 OFFSET $4;
+	`
+
+	var tickets []*models.TicketCard
+
+	err := r.db.SelectContext(ctx, &tickets, query, managerID, openOnly, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return tickets, nil
+}
+
+func (r *ticketsRepository) GetTicketClientManagers(ctx context.Context, uuid uuid.UUID) (*models.TicketClientManagers, error) {
+	query := `
+	SELECT t.id, t.number, COALESCE(cl.title, '') AS client_title, COALESCE(cl.manager, '{}') AS managers
+	FROM tickets t
+	LEFT JOIN clients cl ON t.client = cl.id
+	WHERE t.id = $1
+	`
+
+	var info models.TicketClientManagers
+
+	err := r.db.GetContext(ctx, &info, query, uuid)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &info, nil
+}
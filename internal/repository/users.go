@@ -12,7 +12,7 @@ import (
 type UsersRepository interface {
 	CreateUser(ctx context.Context, userData models.User) error
 	GetProfile(ctx context.Context, userID uuid.UUID) (*models.UserProfile, error)
-	ListDepartmentUsers(ctx context.Context, userID string) ([]*models.User, error)
+	ListDepartmentUsers(ctx context.Context, userID string, filter models.DepartmentUserFilter) ([]*models.User, error)
 	GetUserByID(ctx context.Context, userID uuid.UUID) (*models.User, error)
 	ListUsers(ctx context.Context) (*[]models.User, error)
 	DeleteUser(ctx context.Context, userID uuid.UUID) error
@@ -223,7 +223,7 @@ func (r *usersRepository) UpdateUser(ctx context.Context, user models.User) erro
 	return nil
 }
 
-func (r *usersRepository) ListDepartmentUsers(ctx context.Context, userID string) ([]*models.User, error) {
+func (r *usersRepository) ListDepartmentUsers(ctx context.Context, userID string, filter models.DepartmentUserFilter) ([]*models.User, error) {
 	var depID uuid.UUID
 
 	err := r.db.GetContext(ctx, &depID, "SELECT department FROM users WHERE user_id = $1", userID)
@@ -231,40 +231,46 @@ func (r *usersRepository) ListDepartmentUsers(ctx context.Context, userID string
 		return nil, err
 	}
 
-	query := `
-						SELECT
-            u.user_id,
-            u.first_name,
-            u.last_name,
-            u.department,
-            u.email,
-            u.phone,
-            u.logo,
-            case
-                when u.latest_ticket is null then '{}'::jsonb
-                else jsonb_build_object(
-                    'status', t.status,
-                    'workstarted_at', t.workstarted_at,
-                    'workfinished_at', t.workfinished_at,
-                    'client_name', c.title
-                )
-            end as properties,
-            (
-                select count(*)
-                from tickets
-                where executor = u.user_id
-                and status not in ('closed', 'cancelled')
-            ) as active_tickets
-        from
-            users u
-        left join tickets t on u.latest_ticket = t.id
-        left join clients c on t.client = c.id
-        where
-            u.department = $1
+	// Active tickets and availability come from the workload CTEs so the
+	// listing and the workload dashboard can't disagree
+	query := workloadCTEs + `
+		SELECT
+			u.user_id,
+			u.first_name,
+			u.last_name,
+			u.department,
+			u.email,
+			u.phone,
+			u.logo,
+			CASE
+				WHEN u.latest_ticket IS NULL THEN '{}'::jsonb
+				ELSE jsonb_build_object(
+					'status', t.status,
+					'workstarted_at', t.workstarted_at,
+					'workfinished_at', t.workfinished_at,
+					'client_name', c.title
+				)
+			END AS properties,
+			COALESCE(bs.active_tickets, 0) AS active_tickets,
+			COALESCE(an.absence_type, 'available') AS availability,
+			an.ends_at AS absent_until
+		FROM department_users du
+		JOIN users u ON u.user_id = du.user_id
+		LEFT JOIN tickets t ON u.latest_ticket = t.id
+		LEFT JOIN clients c ON t.client = c.id
+		LEFT JOIN by_status bs ON bs.executor = du.user_id
+		LEFT JOIN absent_now an ON an.user_id = du.user_id
+		WHERE
+			($2::uuid IS NULL AND $3::uuid IS NULL)
+			OR EXISTS (
+				SELECT 1 FROM classificators cl
+				WHERE cl.id = COALESCE($2, (SELECT classificator FROM devices WHERE id = $3))
+				AND ` + qualifiedSkillCondition + `
+			)
 	`
 	var users []*models.User
 
-	err = r.db.SelectContext(ctx, &users, query, depID)
+	err = r.db.SelectContext(ctx, &users, query, depID, filter.Classificator, filter.Device)
 	if err != nil {
 		return nil, err
 	}
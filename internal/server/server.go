@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -18,6 +19,8 @@ type App struct {
 	Router     http.Handler
 	DB         *sqlx.DB
 	ImportFile *string
+	// stopJobs ends the background jobs
+	stopJobs context.CancelFunc
 }
 
 func NewApp(cfg *config.Config, importFile *string) (*App, error) {
@@ -34,7 +37,35 @@ func NewApp(cfg *config.Config, importFile *string) (*App, error) {
 
 	accountRepo := repository.NewAccountRepository(db)
 	accountService := services.NewAccountService(accountRepo)
-	authService := services.NewAuthService(accountService, cfg.Server.Secret)
+	authAuditRepo := repository.NewAuthAuditRepo(db)
+	loginGuardService := services.NewLoginGuardService(authAuditRepo)
+	auditRepo := repository.NewAuditRepo(db)
+	auditService := services.NewAuditService(auditRepo)
+	apiKeyRepo := repository.NewAPIKeyRepo(db)
+	apiKeyService := services.NewAPIKeyService(apiKeyRepo, loginGuardService)
+	mfaRepo := repository.NewMFARepo(db)
+	mfaService := services.NewMFAService(mfaRepo, cfg.MFA.RequiredRoles, cfg.MFA.Issuer)
+	keyRing, err := services.LoadKeyRing(cfg.Server.KeyDir, cfg.Server.Secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load token signing keys: %w", err)
+	}
+	authService := services.NewAuthService(accountService, loginGuardService, mfaService, keyRing)
+
+	// Single sign-on, nil unless an issuer is configured
+	oidcRepo := repository.NewOIDCRepo(db)
+	oidcService, err := services.NewOIDCService(cfg.OIDC, oidcRepo, accountService, authService)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure oidc: %w", err)
+	}
+
+	// Roles and permissions
+	rolesRepo := repository.NewRolesRepo(db)
+	roleService := services.NewRoleService(rolesRepo, accountService)
+
+	// Password reset
+	mailer := services.NewMailer(cfg.Mail.Host, cfg.Mail.Port, cfg.Mail.Username, cfg.Mail.Password, cfg.Mail.From)
+	passwordResetRepo := repository.NewPasswordResetRepo(db)
+	passwordResetService := services.NewPasswordResetService(passwordResetRepo, accountService, loginGuardService, mailer, cfg.Server.PasswordResetURL)
 
 	// User
 	userRepo := repository.NewUsersRepository(db)
@@ -48,6 +79,18 @@ func NewApp(cfg *config.Config, importFile *string) (*App, error) {
 	commentRepo := repository.NewCommentRepository(db)
 	commentService := services.NewCommentService(commentRepo)
 
+	// Laboratory information systems
+	laboratorySystemRepo := repository.NewLaboratorySystemRepo(db)
+	laboratorySystemService := services.NewLaboratorySystemService(laboratorySystemRepo)
+
+	// Geocoding of client addresses, the job only runs with a provider
+	geocoder, err := services.NewGeocoder(cfg.Geocoder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure geocoder: %w", err)
+	}
+	geocodingRepo := repository.NewGeocodingRepo(db)
+	geocodingService := services.NewGeocodingService(geocodingRepo, geocoder, cfg.Geocoder)
+
 	// Contact
 	contactRepo := repository.NewContactRepository(db)
 	contactService := services.NewContactService(contactRepo)
@@ -55,14 +98,31 @@ func NewApp(cfg *config.Config, importFile *string) (*App, error) {
 	// Devices
 	deviceRepo := repository.NewDeviceRepository(db)
 	deviceService := services.NewDeviceService(deviceRepo)
+	labelService := services.NewLabelService(deviceRepo, cfg.Server.DeviceLabelURL)
 
 	// Classificators
 	classificatorRepo := repository.NewClassificatorRepository(db)
 	classificatorService := services.NewClassificatorService(classificatorRepo)
 
+	// Absences
+	absenceRepo := repository.NewAbsenceRepo(db)
+	absenceService := services.NewAbsenceService(absenceRepo)
+
+	// Skills and certifications
+	skillRepo := repository.NewSkillRepo(db)
+	skillService := services.NewSkillService(skillRepo)
+
+	// Notifications
+	notificationRepo := repository.NewNotificationRepo(db)
+	notificationService := services.NewNotificationService(notificationRepo, mailer)
+
 	// Tickets
 	ticketRepo := repository.NewTicketRepository(db)
-	ticketService := services.NewTicketService(ticketRepo)
+	ticketService := services.NewTicketService(ticketRepo, absenceService, skillService, notificationService)
+
+	// Reports
+	reportRepo := repository.NewReportRepo(db)
+	reportService := services.NewReportService(reportRepo)
 
 	// Attachments
 	// minioClient, err := minio.New(cfg.Storage.Endpoint, &minio.Options{
@@ -100,6 +160,11 @@ func NewApp(cfg *config.Config, importFile *string) (*App, error) {
 	// Regions
 	regionsRepo := repository.NewRegionRepo(db)
 	regionService := services.NewRegionService(regionsRepo)
+
+	// Spreadsheet import of clients and contacts
+	clientImportRepo := repository.NewClientImportRepo(db)
+	clientImportService := services.NewClientImportService(clientImportRepo, regionsRepo)
+
 	// Research Types
 	researchTypeRepo := repository.NewResearchTypeRepo(db)
 	researchTypeService := services.NewResearchTypeService(researchTypeRepo)
@@ -147,11 +212,32 @@ func NewApp(cfg *config.Config, importFile *string) (*App, error) {
 		attachmentService,
 		departmentService,
 		agreementService,
+		roleService,
+		passwordResetService,
+		loginGuardService,
+		mfaService,
+		apiKeyService,
+		auditService,
+		oidcService,
+		absenceService,
+		skillService,
+		reportService,
+		notificationService,
+		laboratorySystemService,
+		geocodingService,
+		clientImportService,
+		labelService,
 	)
 
-	return &App{Router: r, DB: db}, nil
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	geocodingService.Start(jobsCtx)
+
+	return &App{Router: r, DB: db, stopJobs: stopJobs}, nil
 }
 
 func (a *App) Close() error {
+	if a.stopJobs != nil {
+		a.stopJobs()
+	}
 	return a.DB.Close()
 }
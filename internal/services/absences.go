@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/grintheone/foxygen-server/internal/repository"
+)
+
+// Kinds of absence
+const (
+	AbsenceVacation     = "vacation"
+	AbsenceSickLeave    = "sick_leave"
+	AbsenceBusinessTrip = "business_trip"
+	AbsenceOther        = "other"
+)
+
+// calendarDefaultSpan is the period listed when the calendar request has no end
+const calendarDefaultSpan = 31 * 24 * time.Hour
+
+var absenceTypes = []string{AbsenceVacation, AbsenceSickLeave, AbsenceBusinessTrip, AbsenceOther}
+
+var (
+	ErrUnknownAbsenceType   = errors.New("unknown absence type")
+	ErrInvalidAbsencePeriod = errors.New("absence must end after it starts")
+)
+
+type AbsenceService struct {
+	repo repository.AbsenceRepo
+}
+
+func NewAbsenceService(repo repository.AbsenceRepo) *AbsenceService {
+	return &AbsenceService{repo: repo}
+}
+
+func validateAbsence(absence models.Absence) error {
+	if !slices.Contains(absenceTypes, absence.Type) {
+		return ErrUnknownAbsenceType
+	}
+	if !absence.To.After(absence.From) {
+		return ErrInvalidAbsencePeriod
+	}
+
+	return nil
+}
+
+// authorize applies the absence policy for changes to userID's absences.
+func (s *AbsenceService) authorize(ctx context.Context, actor Actor, userID uuid.UUID) error {
+	userDepartment, exists, err := s.repo.GetUserDepartment(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("service error checking absence access: %w", err)
+	}
+	if !exists {
+		return ErrNotFound
+	}
+
+	actorDepartment, _, err := s.repo.GetUserDepartment(ctx, actor.UserID)
+	if err != nil {
+		return fmt.Errorf("service error fetching actor department: %w", err)
+	}
+
+	return canManageAbsence(actor, userID, userDepartment, actorDepartment)
+}
+
+// Calendar lists the absences overlapping the filter period. Without a user
+// or department it lists the actor's department, without an end it covers
+// calendarDefaultSpan from the start.
+func (s *AbsenceService) Calendar(ctx context.Context, actor Actor, filter models.AbsenceFilter) ([]*models.Absence, error) {
+	if filter.UserID == nil && filter.Department == nil {
+		department, _, err := s.repo.GetUserDepartment(ctx, actor.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("service error fetching actor department: %w", err)
+		}
+		if department == nil {
+			return []*models.Absence{}, nil
+		}
+		filter.Department = department
+	}
+
+	if filter.To.IsZero() {
+		filter.To = filter.From.Add(calendarDefaultSpan)
+	}
+	if !filter.To.After(filter.From) {
+		return nil, ErrInvalidAbsencePeriod
+	}
+
+	absences, err := s.repo.ListAbsences(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("service error listing absences: %w", err)
+	}
+
+	return absences, nil
+}
+
+// Conflicts returns the absences of userID overlapping [from, to).
+func (s *AbsenceService) Conflicts(ctx context.Context, userID uuid.UUID, from, to time.Time) ([]*models.Absence, error) {
+	absences, err := s.repo.ListAbsences(ctx, models.AbsenceFilter{UserID: &userID, From: from, To: to})
+	if err != nil {
+		return nil, fmt.Errorf("service error checking absences: %w", err)
+	}
+
+	return absences, nil
+}
+
+func (s *AbsenceService) CreateAbsence(ctx context.Context, actor Actor, absence models.Absence) (*models.Absence, error) {
+	if err := validateAbsence(absence); err != nil {
+		return nil, err
+	}
+
+	if err := s.authorize(ctx, actor, absence.UserID); err != nil {
+		return nil, err
+	}
+
+	if !actor.IsAPIKey() {
+		absence.CreatedBy = &actor.UserID
+	}
+
+	created, err := s.repo.CreateAbsence(ctx, absence)
+	if err != nil {
+		return nil, fmt.Errorf("service error creating absence: %w", err)
+	}
+
+	return created, nil
+}
+
+// UpdateAbsence replaces type, period and comment of an absence.
+func (s *AbsenceService) UpdateAbsence(ctx context.Context, actor Actor, absence models.Absence) (*models.Absence, error) {
+	if err := validateAbsence(absence); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.repo.GetAbsence(ctx, absence.ID)
+	if err != nil {
+		return nil, fmt.Errorf("service error updating absence: %w", err)
+	}
+	if existing == nil {
+		return nil, ErrNotFound
+	}
+
+	if err := s.authorize(ctx, actor, existing.UserID); err != nil {
+		return nil, err
+	}
+
+	updated, err := s.repo.UpdateAbsence(ctx, absence)
+	if err != nil {
+		return nil, fmt.Errorf("service error updating absence: %w", err)
+	}
+	if updated == nil {
+		return nil, ErrNotFound
+	}
+
+	return updated, nil
+}
+
+func (s *AbsenceService) DeleteAbsence(ctx context.Context, actor Actor, id uuid.UUID) error {
+	existing, err := s.repo.GetAbsence(ctx, id)
+	if err != nil {
+		return fmt.Errorf("service error deleting absence: %w", err)
+	}
+	if existing == nil {
+		return ErrNotFound
+	}
+
+	if err := s.authorize(ctx, actor, existing.UserID); err != nil {
+		return err
+	}
+
+	deleted, err := s.repo.DeleteAbsence(ctx, id)
+	if err != nil {
+		return fmt.Errorf("service error deleting absence: %w", err)
+	}
+	if !deleted {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -2,8 +2,12 @@ package services
 
 import (
 	"context"
+	"crypto/rand"
 	"errors"
 	"fmt"
+	"log"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/grintheone/foxygen-server/internal/models"
@@ -11,12 +15,47 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// accountStateTTL bounds how long a disabled account or revoked token
+// can keep working after the change was made.
+const accountStateTTL = 30 * time.Second
+
+const (
+	accountListDefaultLimit = 50
+	accountListMaxLimit     = 500
+)
+
+var (
+	ErrAccountDisabled = errors.New("account is disabled")
+	ErrTokenRevoked    = errors.New("token has been revoked")
+	ErrUsernameTaken   = repository.ErrUsernameTaken
+	ErrInvalidUsername = errors.New("username must be non-empty and contain no spaces")
+)
+
+// NewAccount is an account created by an administrator together with its
+// user profile. An empty password gets a generated one.
+type NewAccount struct {
+	Username string
+	Password string
+	Roles    []string
+	Profile  models.User
+}
+
+// CreatedAccount carries the generated password, if any. It is shown only once.
+type CreatedAccount struct {
+	*models.AccountSummary
+	GeneratedPassword string `json:"generated_password,omitempty"`
+}
+
 type AccountService struct {
-	repo repository.AccountRepository
+	repo       repository.AccountRepository
+	stateCache *ttlCache[uuid.UUID, models.AccountState]
 }
 
 func NewAccountService(r repository.AccountRepository) *AccountService {
-	return &AccountService{repo: r}
+	return &AccountService{
+		repo:       r,
+		stateCache: newTTLCache[uuid.UUID, models.AccountState](accountStateTTL),
+	}
 }
 
 func (s *AccountService) CreateUser(ctx context.Context, username, password, role string, userID *uuid.UUID) (*models.Account, error) {
@@ -25,19 +64,6 @@ func (s *AccountService) CreateUser(ctx context.Context, username, password, rol
 		return nil, err
 	}
 
-	var roleID int
-
-	switch role {
-	case "admin":
-		roleID = 1
-	case "coordinator":
-		roleID = 2
-	case "user":
-		roleID = 3
-	default:
-		return nil, errors.New("invalid role requested: " + role)
-	}
-
 	newAccount := &models.Account{
 		Username:     username,
 		PasswordHash: string(hashedPassword),
@@ -47,8 +73,11 @@ func (s *AccountService) CreateUser(ctx context.Context, username, password, rol
 		newAccount.UserID = *userID
 	}
 
-	createdAccount, err := s.repo.CreateAccountWithRoles(ctx, newAccount, roleID)
+	createdAccount, err := s.repo.CreateAccountWithRoles(ctx, newAccount, []string{role})
 	if err != nil {
+		if errors.Is(err, ErrUnknownRole) {
+			return nil, errors.New("invalid role requested: " + role)
+		}
 		return nil, err
 	}
 
@@ -72,7 +101,7 @@ func (s *AccountService) GetAccountByUsername(ctx context.Context, username stri
 	}
 
 	if account.Disabled {
-		return nil, fmt.Errorf("account is disabled")
+		return nil, ErrAccountDisabled
 	}
 
 	return account, nil
@@ -88,7 +117,7 @@ func (s *AccountService) GetUserByID(ctx context.Context, userID uuid.UUID) (*mo
 	}
 
 	if account.Disabled {
-		return nil, fmt.Errorf("account is disabled")
+		return nil, ErrAccountDisabled
 	}
 
 	return account, nil
@@ -99,13 +128,23 @@ func (s *AccountService) ChangeAccountPassword(ctx context.Context, userID uuid.
 	if err != nil {
 		return fmt.Errorf("service error fetching user by ID: %w", err)
 	}
+	if account == nil {
+		return ErrInvalidCredentials
+	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(account.PasswordHash), []byte(old))
 	if err != nil {
 		return ErrInvalidCredentials
 	}
 
+	if err := ValidatePassword(new, account.Username); err != nil {
+		return err
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(new), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
 
 	err = s.repo.ChangeAccountPassword(ctx, userID, string(hashedPassword))
 	if err != nil {
@@ -115,11 +154,249 @@ func (s *AccountService) ChangeAccountPassword(ctx context.Context, userID uuid.
 	return nil
 }
 
-func (s *AccountService) ChangeAccountStatus(ctx context.Context, userID uuid.UUID, disabled bool) error {
-	err := s.repo.ChangeAccountStatus(ctx, userID, disabled)
+// ChangeAccountStatus enables or disables another account. Administrators
+// can't disable their own account and lock everyone out by mistake.
+func (s *AccountService) ChangeAccountStatus(ctx context.Context, actor Actor, userID uuid.UUID, disabled bool) (*models.AccountSummary, error) {
+	if disabled && actor.UserID == userID {
+		return nil, forbidden("you can't disable your own account")
+	}
+
+	account, err := s.repo.GetAccountSummary(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("service error fetching account: %w", err)
+	}
+	if account == nil {
+		return nil, ErrNotFound
+	}
+
+	if err := s.repo.ChangeAccountStatus(ctx, userID, disabled); err != nil {
+		return nil, fmt.Errorf("service error changing account status: %w", err)
+	}
+
+	s.stateCache.Delete(userID)
+
+	account.Disabled = disabled
+	return account, nil
+}
+
+func (s *AccountService) ListAccounts(ctx context.Context, filter models.AccountFilter) ([]*models.AccountSummary, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = accountListDefaultLimit
+	}
+	filter.Limit = min(filter.Limit, accountListMaxLimit)
+
+	accounts, err := s.repo.ListAccounts(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("service error listing accounts: %w", err)
+	}
+
+	return accounts, nil
+}
+
+func (s *AccountService) GetAccountSummary(ctx context.Context, userID uuid.UUID) (*models.AccountSummary, error) {
+	account, err := s.repo.GetAccountSummary(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("service error fetching account: %w", err)
+	}
+	if account == nil {
+		return nil, ErrNotFound
+	}
+
+	return account, nil
+}
+
+func validUsername(username string) bool {
+	return username != "" && !strings.ContainsAny(username, " \t\r\n")
+}
+
+// generatePassword returns a random password that satisfies the password policy.
+func generatePassword() string {
+	return rand.Text()
+}
+
+// CreateAccount creates an account with its roles and user profile in one go.
+func (s *AccountService) CreateAccount(ctx context.Context, request NewAccount) (*CreatedAccount, error) {
+	request.Username = strings.TrimSpace(request.Username)
+	if !validUsername(request.Username) {
+		return nil, ErrInvalidUsername
+	}
+	if len(request.Roles) == 0 {
+		request.Roles = []string{"user"}
+	}
+
+	var generated string
+	if request.Password == "" {
+		generated = generatePassword()
+		request.Password = generated
+	} else if err := ValidatePassword(request.Password, request.Username); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	account := &models.Account{
+		Username:     request.Username,
+		PasswordHash: string(hashedPassword),
+	}
+
+	err = s.repo.CreateAccountWithProfile(ctx, account, normalizeNames(request.Roles), request.Profile)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrUnknownRole):
+			return nil, ErrUnknownRole
+		case errors.Is(err, ErrUsernameTaken):
+			return nil, ErrUsernameTaken
+		}
+		return nil, fmt.Errorf("service error creating account: %w", err)
+	}
+
+	summary, err := s.GetAccountSummary(ctx, account.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CreatedAccount{AccountSummary: summary, GeneratedPassword: generated}, nil
+}
+
+// RenameAccount changes the login name. Issued tokens stay valid.
+func (s *AccountService) RenameAccount(ctx context.Context, userID uuid.UUID, username string) (*models.AccountSummary, error) {
+	username = strings.TrimSpace(username)
+	if !validUsername(username) {
+		return nil, ErrInvalidUsername
+	}
+
+	if _, err := s.GetAccountSummary(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.RenameAccount(ctx, userID, username); err != nil {
+		if errors.Is(err, ErrUsernameTaken) {
+			return nil, ErrUsernameTaken
+		}
+		return nil, fmt.Errorf("service error renaming account: %w", err)
+	}
+
+	return s.GetAccountSummary(ctx, userID)
+}
+
+// ResetPassword replaces the password with a generated one, signs the account
+// out everywhere and returns the new password.
+func (s *AccountService) ResetPassword(ctx context.Context, userID uuid.UUID) (string, error) {
+	if _, err := s.GetAccountSummary(ctx, userID); err != nil {
+		return "", err
+	}
+
+	password := generatePassword()
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
+		return "", err
+	}
+
+	if err := s.repo.ResetAccountPassword(ctx, userID, string(hashedPassword)); err != nil {
+		return "", fmt.Errorf("service error resetting password: %w", err)
+	}
+
+	s.stateCache.Delete(userID)
+
+	return password, nil
+}
+
+// RecordLogin stores the time of a successful login. Failures are only logged.
+func (s *AccountService) RecordLogin(ctx context.Context, userID uuid.UUID) {
+	if err := s.repo.SetLastLogin(ctx, userID); err != nil {
+		log.Printf("failed to record last login of %s: %v", userID, err)
+	}
+}
+
+// SetAccountRoles replaces the roles held by an account and revokes its
+// tokens, so removed permissions stop working at once instead of surviving
+// until the access token expires.
+func (s *AccountService) SetAccountRoles(ctx context.Context, userID uuid.UUID, roles []string) (*models.Account, error) {
+	if len(roles) == 0 {
+		return nil, errors.New("an account must hold at least one role")
+	}
+
+	account, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("service error fetching user by ID: %w", err)
+	}
+	if account == nil {
+		return nil, nil
+	}
+
+	err = s.repo.SetAccountRoles(ctx, userID, normalizeNames(roles))
+	if err != nil {
+		if errors.Is(err, ErrUnknownRole) {
+			return nil, ErrUnknownRole
+		}
+		return nil, fmt.Errorf("service error setting account roles: %w", err)
+	}
+
+	if err := s.RevokeTokens(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	account, err = s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("service error fetching user by ID: %w", err)
+	}
+
+	account.PasswordHash = ""
+	return account, nil
+}
+
+// RevokeTokens invalidates every token issued to the account up to now.
+func (s *AccountService) RevokeTokens(ctx context.Context, userID uuid.UUID) error {
+	if _, err := s.GetAccountSummary(ctx, userID); err != nil {
 		return err
 	}
 
+	err := s.repo.RevokeTokens(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("service error revoking tokens: %w", err)
+	}
+
+	s.stateCache.Delete(userID)
+
+	return nil
+}
+
+// CheckTokenAllowed reports whether a token issued at issuedAt may still be used
+// by the account. Lookups are cached for accountStateTTL so that authenticated
+// requests don't hit the database every time.
+func (s *AccountService) CheckTokenAllowed(ctx context.Context, userID uuid.UUID, issuedAt time.Time) error {
+	state, ok := s.stateCache.Get(userID)
+	if !ok {
+		fetched, err := s.repo.GetAccountState(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("service error fetching account state: %w", err)
+		}
+		if fetched == nil {
+			return ErrInvalidToken
+		}
+
+		state = *fetched
+		s.stateCache.Set(userID, state)
+	}
+
+	if state.Disabled {
+		return ErrAccountDisabled
+	}
+
+	return checkTokenIssuedAt(state.TokensValidAfter, issuedAt)
+}
+
+// checkTokenIssuedAt rejects tokens minted before the revocation moment.
+// JWT timestamps have second precision, so tokens issued within the same
+// second as the revocation are still accepted.
+func checkTokenIssuedAt(validAfter *time.Time, issuedAt time.Time) error {
+	if validAfter != nil && issuedAt.Before(validAfter.Truncate(time.Second)) {
+		return ErrTokenRevoked
+	}
+
 	return nil
 }
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckTokenIssuedAt(t *testing.T) {
+	revokedAt := time.Date(2025, 3, 14, 9, 30, 15, 600_000_000, time.UTC)
+
+	tests := []struct {
+		name       string
+		validAfter *time.Time
+		issuedAt   time.Time
+		want       error
+	}{
+		{"never revoked", nil, revokedAt.Add(-time.Hour), nil},
+		{"issued before revocation", &revokedAt, revokedAt.Add(-2 * time.Second), ErrTokenRevoked},
+		{"issued in the previous second", &revokedAt, revokedAt.Truncate(time.Second).Add(-time.Nanosecond), ErrTokenRevoked},
+		{"issued in the same second", &revokedAt, revokedAt.Truncate(time.Second), nil},
+		{"issued after revocation", &revokedAt, revokedAt.Add(time.Minute), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkTokenIssuedAt(tt.validAfter, tt.issuedAt)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("checkTokenIssuedAt() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
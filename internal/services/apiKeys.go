@@ -0,0 +1,251 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/grintheone/foxygen-server/internal/repository"
+)
+
+// API keys look like "fxg_<43 characters>". The prefix makes leaked keys easy
+// to recognize in logs and secret scanners.
+const (
+	apiKeyPrefix       = "fxg_"
+	apiKeyDisplayChars = 12
+	// APIKeyRole is the role API key actors carry in place of account roles
+	APIKeyRole = "service"
+)
+
+var (
+	ErrInvalidAPIKey = errors.New("invalid api key")
+	ErrAPIKeyExpired = errors.New("api key expired")
+
+	ErrAPIKeyExpiryInPast = errors.New("expiry must be in the future")
+)
+
+// CreatedAPIKey is returned by create and rotate, the only times the key is shown.
+type CreatedAPIKey struct {
+	models.APIKey
+	Key string `json:"key"`
+}
+
+type APIKeyService struct {
+	repo  repository.APIKeyRepo
+	audit *LoginGuardService
+	// cache maps key hashes to keys so authenticated requests don't hit the database
+	cache *ttlCache[string, models.APIKey]
+}
+
+func NewAPIKeyService(repo repository.APIKeyRepo, audit *LoginGuardService) *APIKeyService {
+	return &APIKeyService{
+		repo:  repo,
+		audit: audit,
+		cache: newTTLCache[string, models.APIKey](accountStateTTL),
+	}
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func generateAPIKey() (key, prefix, hash string, err error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+
+	key = apiKeyPrefix + base64.RawURLEncoding.EncodeToString(raw)
+	return key, key[:apiKeyDisplayChars], hashAPIKey(key), nil
+}
+
+// Authenticate resolves a presented key. Revocations and rotations done on
+// this instance apply at once, on other instances within accountStateTTL.
+func (s *APIKeyService) Authenticate(ctx context.Context, rawKey, ip string) (*models.APIKey, error) {
+	if !strings.HasPrefix(rawKey, apiKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	hash := hashAPIKey(rawKey)
+
+	key, ok := s.cache.Get(hash)
+	if !ok {
+		found, err := s.repo.GetKeyByHash(ctx, hash)
+		if err != nil {
+			return nil, fmt.Errorf("service error authenticating api key: %w", err)
+		}
+		if found == nil {
+			s.audit.Audit(ctx, models.AuthAuditEntry{
+				Event:     AuthEventAPIKeyAuth,
+				ActorType: ActorTypeAPIKey,
+				IP:        ip,
+				Details:   "unknown key " + rawKey[:min(len(rawKey), apiKeyDisplayChars)],
+			})
+			return nil, ErrInvalidAPIKey
+		}
+
+		// last_used_at is refreshed on cache misses only, which is accurate
+		// enough for spotting unused keys
+		if err := s.repo.TouchKey(ctx, found.ID); err != nil {
+			log.Printf("failed to update last use of api key %s: %v", found.ID, err)
+		}
+
+		key = *found
+		s.cache.Set(hash, key)
+	}
+
+	if key.RevokedAt != nil {
+		return nil, ErrInvalidAPIKey
+	}
+	if key.ExpiresAt != nil && key.ExpiresAt.Before(time.Now().UTC()) {
+		return nil, ErrAPIKeyExpired
+	}
+
+	return &key, nil
+}
+
+func (s *APIKeyService) ListKeys(ctx context.Context) ([]*models.APIKey, error) {
+	keys, err := s.repo.ListKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("service error listing api keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// requireUserActor keeps API keys from minting or managing other keys.
+func requireUserActor(actor Actor) error {
+	if actor.IsAPIKey() {
+		return forbidden("api keys can't manage api keys")
+	}
+	return nil
+}
+
+// CreateKey issues a new key. A key can't be granted permissions its creator
+// doesn't hold.
+func (s *APIKeyService) CreateKey(ctx context.Context, actor Actor, name string, permissions []string, expiresAt *time.Time) (*CreatedAPIKey, error) {
+	if err := requireUserActor(actor); err != nil {
+		return nil, err
+	}
+
+	permissions = normalizeNames(permissions)
+	for _, permission := range permissions {
+		if !slices.Contains(actor.Permissions, permission) {
+			return nil, forbidden("you can't grant permission " + permission)
+		}
+	}
+
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, ErrAPIKeyExpiryInPast
+	}
+
+	rawKey, prefix, hash, err := generateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+
+	key := models.APIKey{
+		Name:        name,
+		Prefix:      prefix,
+		KeyHash:     hash,
+		Permissions: permissions,
+		CreatedBy:   &actor.UserID,
+	}
+	if expiresAt != nil {
+		utc := expiresAt.UTC()
+		key.ExpiresAt = &utc
+	}
+
+	created, err := s.repo.CreateKey(ctx, key)
+	if err != nil {
+		if errors.Is(err, ErrUnknownPermission) {
+			return nil, ErrUnknownPermission
+		}
+		return nil, fmt.Errorf("service error creating api key: %w", err)
+	}
+
+	s.auditKeyChange(ctx, actor, AuthEventAPIKeyCreate, created)
+
+	return &CreatedAPIKey{APIKey: *created, Key: rawKey}, nil
+}
+
+// RotateKey replaces the secret of a key and keeps its name and permissions.
+func (s *APIKeyService) RotateKey(ctx context.Context, actor Actor, id uuid.UUID) (*CreatedAPIKey, error) {
+	if err := requireUserActor(actor); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.repo.GetKeyByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("service error rotating api key: %w", err)
+	}
+	if existing == nil || existing.RevokedAt != nil {
+		return nil, ErrNotFound
+	}
+
+	rawKey, prefix, hash, err := generateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+
+	rotated, err := s.repo.RotateKey(ctx, id, prefix, hash)
+	if err != nil {
+		return nil, fmt.Errorf("service error rotating api key: %w", err)
+	}
+	if rotated == nil {
+		return nil, ErrNotFound
+	}
+
+	s.cache.Delete(existing.KeyHash)
+	s.auditKeyChange(ctx, actor, AuthEventAPIKeyRotate, rotated)
+
+	return &CreatedAPIKey{APIKey: *rotated, Key: rawKey}, nil
+}
+
+func (s *APIKeyService) RevokeKey(ctx context.Context, actor Actor, id uuid.UUID) error {
+	if err := requireUserActor(actor); err != nil {
+		return err
+	}
+
+	existing, err := s.repo.GetKeyByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("service error revoking api key: %w", err)
+	}
+	if existing == nil {
+		return ErrNotFound
+	}
+
+	revoked, err := s.repo.RevokeKey(ctx, id)
+	if err != nil {
+		return fmt.Errorf("service error revoking api key: %w", err)
+	}
+	if !revoked {
+		return ErrNotFound
+	}
+
+	s.cache.Delete(existing.KeyHash)
+	s.auditKeyChange(ctx, actor, AuthEventAPIKeyRevoke, existing)
+
+	return nil
+}
+
+func (s *APIKeyService) auditKeyChange(ctx context.Context, actor Actor, event string, key *models.APIKey) {
+	s.audit.Audit(ctx, models.AuthAuditEntry{
+		Event:     event,
+		Success:   true,
+		ActorType: actor.Type,
+		UserID:    &actor.UserID,
+		Details:   fmt.Sprintf("key %s (%s)", key.ID, key.Name),
+	})
+}
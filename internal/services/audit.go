@@ -0,0 +1,44 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/grintheone/foxygen-server/internal/repository"
+)
+
+type AuditService struct {
+	repo repository.AuditRepo
+}
+
+func NewAuditService(repo repository.AuditRepo) *AuditService {
+	return &AuditService{repo}
+}
+
+// Record stores an audit entry. Failures are logged and otherwise ignored
+// because the request they describe has already been served.
+func (s *AuditService) Record(ctx context.Context, entry models.AuditEntry) {
+	if entry.ActorType == "" {
+		entry.ActorType = ActorTypeUser
+	}
+
+	if err := s.repo.AddEntry(ctx, entry); err != nil {
+		log.Printf("failed to write audit entry for %s %s: %v", entry.Method, entry.Path, err)
+	}
+}
+
+func (s *AuditService) ListEntries(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEntry, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = authAuditDefaultLimit
+	}
+	filter.Limit = min(filter.Limit, authAuditMaxLimit)
+
+	entries, err := s.repo.ListEntries(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("service error listing audit log: %w", err)
+	}
+
+	return entries, nil
+}
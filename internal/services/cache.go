@@ -0,0 +1,73 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// ttlCache is a small concurrency-safe map whose entries expire after a fixed
+// duration. It is meant for hot lookups like per-request account checks where
+// slightly stale data is acceptable.
+type ttlCache[K comparable, V any] struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	items map[K]ttlEntry[V]
+}
+
+type ttlEntry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+// maxCacheSweepSize is the number of entries after which expired ones are
+// swept on insert, so the map can't grow without bounds.
+const maxCacheSweepSize = 1024
+
+func newTTLCache[K comparable, V any](ttl time.Duration) *ttlCache[K, V] {
+	return &ttlCache[K, V]{
+		ttl:   ttl,
+		items: make(map[K]ttlEntry[V]),
+	}
+}
+
+func (c *ttlCache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+
+	if time.Now().After(entry.expiresAt) {
+		delete(c.items, key)
+		var zero V
+		return zero, false
+	}
+
+	return entry.value, true
+}
+
+func (c *ttlCache[K, V]) Set(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if len(c.items) >= maxCacheSweepSize {
+		for k, entry := range c.items {
+			if now.After(entry.expiresAt) {
+				delete(c.items, k)
+			}
+		}
+	}
+
+	c.items[key] = ttlEntry[V]{value: value, expiresAt: now.Add(c.ttl)}
+}
+
+func (c *ttlCache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.items, key)
+}
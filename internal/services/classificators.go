@@ -53,7 +53,11 @@ func (s *ClassificatorService) NewClassificator(ctx context.Context, payload mod
 	return nil
 }
 
-func (s *ClassificatorService) RemoveClassificatorByID(ctx context.Context, uuid uuid.UUID) error {
+func (s *ClassificatorService) RemoveClassificatorByID(ctx context.Context, actor Actor, uuid uuid.UUID) error {
+	if err := requireAdmin(actor, "only admins may delete classificators"); err != nil {
+		return err
+	}
+
 	err := s.repo.RemoveClassificatorByID(ctx, uuid)
 	if err != nil {
 		return fmt.Errorf("service error deliting classificator: %w", err)
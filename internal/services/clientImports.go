@@ -0,0 +1,360 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/grintheone/foxygen-server/internal/repository"
+)
+
+const (
+	MaxImportFileSize = 10 << 20
+	maxImportRows     = 5000
+
+	importActionCreate = "create"
+	importActionUpdate = "update"
+)
+
+var (
+	ErrUnsupportedImportFile = errors.New("only .csv and .xlsx files can be imported")
+	ErrImportTooLarge        = fmt.Errorf("import is limited to %d rows", maxImportRows)
+	ErrEmptyImport           = errors.New("spreadsheet has no rows")
+	ErrImportTitleColumn     = errors.New("no column is mapped to the client title")
+	ErrUnknownImportField    = errors.New("unknown import field")
+	ErrUnknownImportColumn   = errors.New("column not found in spreadsheet")
+	ErrImportApplied         = repository.ErrImportApplied
+	ErrImportHasErrors       = repository.ErrImportHasErrors
+)
+
+// importFieldAliases are the headers a column is mapped by when no explicit
+// mapping is given, compared without case, spaces and punctuation.
+var importFieldAliases = map[string][]string{
+	models.ImportFieldClientID:        {"clientid", "id", "идентификатор"},
+	models.ImportFieldTitle:           {"title", "client", "name", "название", "наименование", "клиент", "организация"},
+	models.ImportFieldAddress:         {"address", "адрес"},
+	models.ImportFieldRegion:          {"region", "регион", "область"},
+	models.ImportFieldLat:             {"lat", "latitude", "широта"},
+	models.ImportFieldLng:             {"lng", "lon", "longitude", "долгота"},
+	models.ImportFieldContactName:     {"contact", "contactname", "контакт", "контактноелицо", "фио"},
+	models.ImportFieldContactPosition: {"position", "contactposition", "должность"},
+	models.ImportFieldContactPhone:    {"phone", "contactphone", "телефон"},
+	models.ImportFieldContactEmail:    {"email", "contactemail", "почта", "электроннаяпочта"},
+}
+
+type ClientImportService struct {
+	repo    repository.ClientImportRepo
+	regions repository.RegionsRepo
+}
+
+func NewClientImportService(repo repository.ClientImportRepo, regions repository.RegionsRepo) *ClientImportService {
+	return &ClientImportService{repo: repo, regions: regions}
+}
+
+// importKey is a header or value without case, spaces and punctuation.
+func importKey(value string) string {
+	return strings.ReplaceAll(normalizeAddress(value), " ", "")
+}
+
+// clientImportKey identifies a client by title and address, the same way
+// the repository matches existing clients.
+func clientImportKey(title, address string) string {
+	return importKey(title) + "|" + importKey(address)
+}
+
+// mapImportColumns returns the spreadsheet column index of every mapped field.
+// Without a mapping the headers are matched against importFieldAliases.
+func mapImportColumns(header []string, mapping models.ImportColumns) (map[string]int, models.ImportColumns, error) {
+	byHeader := make(map[string]int, len(header))
+	for i, title := range header {
+		key := importKey(title)
+		if _, ok := byHeader[key]; !ok && key != "" {
+			byHeader[key] = i
+		}
+	}
+
+	columns := map[string]int{}
+	resolved := models.ImportColumns{}
+
+	if len(mapping) > 0 {
+		for field, title := range mapping {
+			if _, ok := importFieldAliases[field]; !ok {
+				return nil, nil, fmt.Errorf("%w: %s", ErrUnknownImportField, field)
+			}
+			if strings.TrimSpace(title) == "" {
+				continue
+			}
+			i, ok := byHeader[importKey(title)]
+			if !ok {
+				return nil, nil, fmt.Errorf("%w: %s", ErrUnknownImportColumn, title)
+			}
+			columns[field] = i
+			resolved[field] = header[i]
+		}
+	} else {
+		for field, aliases := range importFieldAliases {
+			for _, alias := range aliases {
+				if i, ok := byHeader[alias]; ok {
+					columns[field] = i
+					resolved[field] = header[i]
+					break
+				}
+			}
+		}
+	}
+
+	if _, ok := columns[models.ImportFieldTitle]; !ok {
+		return nil, nil, ErrImportTitleColumn
+	}
+
+	return columns, resolved, nil
+}
+
+func parseImportCoordinate(value string) (*float64, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	f, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", "."), 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &f, nil
+}
+
+// parseImportRow reads one spreadsheet line and records everything wrong
+// with it in Errors.
+func parseImportRow(line int, record []string, columns map[string]int, regions map[string]*models.Region) *models.ClientImportRow {
+	value := func(field string) string {
+		i, ok := columns[field]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	row := &models.ClientImportRow{
+		Row:     line,
+		Title:   value(models.ImportFieldTitle),
+		Address: value(models.ImportFieldAddress),
+		Errors:  []string{},
+	}
+
+	if row.Title == "" {
+		row.Errors = append(row.Errors, "title is required")
+	}
+
+	if id := value(models.ImportFieldClientID); id != "" {
+		clientID, err := uuid.Parse(id)
+		if err != nil {
+			row.Errors = append(row.Errors, fmt.Sprintf("invalid client id %q", id))
+		} else {
+			row.ClientID = &clientID
+		}
+	}
+
+	if title := value(models.ImportFieldRegion); title != "" {
+		region, ok := regions[strings.ToLower(title)]
+		if !ok {
+			row.Errors = append(row.Errors, fmt.Sprintf("unknown region %q", title))
+		} else {
+			row.Region = &region.ID
+			row.RegionTitle = region.Title
+		}
+	}
+
+	lat, latErr := parseImportCoordinate(value(models.ImportFieldLat))
+	lng, lngErr := parseImportCoordinate(value(models.ImportFieldLng))
+	switch {
+	case latErr != nil || lngErr != nil:
+		row.Errors = append(row.Errors, "lat and lng must be numbers")
+	case (lat == nil) != (lng == nil):
+		row.Errors = append(row.Errors, "lat and lng must be given together")
+	case lat != nil && (*lat < -90 || *lat > 90 || *lng < -180 || *lng > 180):
+		row.Errors = append(row.Errors, ErrInvalidCoordinates.Error())
+	default:
+		row.Lat, row.Lng = lat, lng
+	}
+
+	contact := models.ClientImportContact{
+		Name:     value(models.ImportFieldContactName),
+		Position: value(models.ImportFieldContactPosition),
+		Phone:    value(models.ImportFieldContactPhone),
+		Email:    value(models.ImportFieldContactEmail),
+	}
+	if contact != (models.ClientImportContact{}) {
+		if contact.Name == "" {
+			row.Errors = append(row.Errors, "contact name is required")
+		}
+		if e164, display, err := normalizePhone(contact.Phone); err != nil {
+			row.Errors = append(row.Errors, fmt.Sprintf("invalid contact phone %q", contact.Phone))
+		} else {
+			contact.Phone, contact.PhoneE164 = display, e164
+		}
+		if email, err := normalizeEmail(contact.Email); err != nil {
+			row.Errors = append(row.Errors, fmt.Sprintf("invalid contact email %q", contact.Email))
+		} else {
+			contact.Email = email
+		}
+		row.Contact = &contact
+	}
+
+	return row
+}
+
+// PreviewImport validates an uploaded spreadsheet and stores it with a row
+// by row preview. Nothing is written to clients until it is applied.
+func (s *ClientImportService) PreviewImport(ctx context.Context, actor Actor, filename string, data []byte, mapping models.ImportColumns) (*models.ClientImport, error) {
+	if err := requireAdmin(actor, "only admins can import clients"); err != nil {
+		return nil, err
+	}
+
+	var records [][]string
+	var err error
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		records, err = readCSV(data)
+	case ".xlsx":
+		records, err = readXLSX(data)
+	default:
+		return nil, ErrUnsupportedImportFile
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) < 2 {
+		return nil, ErrEmptyImport
+	}
+	if len(records)-1 > maxImportRows {
+		return nil, ErrImportTooLarge
+	}
+
+	columns, resolved, err := mapImportColumns(records[0], mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	regionList, err := s.regions.ListAllRegions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("service error fetching regions: %w", err)
+	}
+	regions := make(map[string]*models.Region, len(regionList))
+	for _, region := range regionList {
+		regions[strings.ToLower(strings.TrimSpace(region.Title))] = region
+	}
+
+	rows := models.ClientImportRows{}
+	var ids []uuid.UUID
+	var keys []string
+
+	for i, record := range records[1:] {
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		row := parseImportRow(i+2, record, columns, regions)
+		rows = append(rows, row)
+
+		if row.ClientID != nil {
+			ids = append(ids, *row.ClientID)
+		} else {
+			keys = append(keys, clientImportKey(row.Title, row.Address))
+		}
+	}
+
+	if len(rows) == 0 {
+		return nil, ErrEmptyImport
+	}
+
+	existing, err := s.repo.ExistingClients(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("service error matching clients: %w", err)
+	}
+	matches, err := s.repo.MatchClientKeys(ctx, keys)
+	if err != nil {
+		return nil, fmt.Errorf("service error matching clients: %w", err)
+	}
+
+	errorRows := 0
+	for _, row := range rows {
+		if row.ClientID != nil && !existing[*row.ClientID] {
+			row.Errors = append(row.Errors, fmt.Sprintf("client %s does not exist", row.ClientID))
+		}
+
+		if len(row.Errors) > 0 {
+			errorRows++
+			continue
+		}
+
+		row.Action = importActionCreate
+		if row.ClientID != nil {
+			row.Action = importActionUpdate
+		} else if _, ok := matches[clientImportKey(row.Title, row.Address)]; ok {
+			row.Action = importActionUpdate
+		}
+	}
+
+	clientImport, err := s.repo.CreateImport(ctx, models.ClientImport{
+		Filename:  filepath.Base(filename),
+		Columns:   resolved,
+		Rows:      rows,
+		ErrorRows: errorRows,
+		CreatedBy: &actor.UserID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("service error saving import: %w", err)
+	}
+
+	return clientImport, nil
+}
+
+func (s *ClientImportService) GetImport(ctx context.Context, actor Actor, id uuid.UUID) (*models.ClientImport, error) {
+	if err := requireAdmin(actor, "only admins can import clients"); err != nil {
+		return nil, err
+	}
+
+	clientImport, err := s.repo.GetImport(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("service error fetching import: %w", err)
+	}
+	if clientImport == nil {
+		return nil, ErrNotFound
+	}
+
+	return clientImport, nil
+}
+
+// ApplyImport writes a previewed import to clients and contacts. An import
+// with invalid rows is only applied with skipInvalid, leaving those rows out.
+func (s *ClientImportService) ApplyImport(ctx context.Context, actor Actor, id uuid.UUID, skipInvalid bool) (*models.ClientImport, error) {
+	clientImport, err := s.GetImport(ctx, actor, id)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[int]string, len(clientImport.Rows))
+	for _, row := range clientImport.Rows {
+		keys[row.Row] = clientImportKey(row.Title, row.Address)
+	}
+
+	applied, err := s.repo.ApplyImport(ctx, id, actor.UserID, keys, skipInvalid)
+	if err != nil {
+		if errors.Is(err, ErrImportApplied) || errors.Is(err, ErrImportHasErrors) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("service error applying import: %w", err)
+	}
+	if applied == nil {
+		return nil, ErrNotFound
+	}
+
+	return applied, nil
+}
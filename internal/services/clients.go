@@ -2,7 +2,10 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/grintheone/foxygen-server/internal/models"
@@ -28,6 +31,9 @@ func (s *ClientService) ListClients(ctx context.Context, limit int, offset int,
 func (s *ClientService) CreateClient(ctx context.Context, payload models.Client) error {
 	err := s.repo.CreateClient(ctx, payload)
 	if err != nil {
+		if errors.Is(err, ErrUnknownClientReference) {
+			return err
+		}
 		return fmt.Errorf("service error creating a client: %w", err)
 	}
 
@@ -37,13 +43,20 @@ func (s *ClientService) CreateClient(ctx context.Context, payload models.Client)
 func (s *ClientService) UpdateClient(ctx context.Context, uuid uuid.UUID, payload models.ClientUpdate) (*models.Client, error) {
 	client, err := s.repo.UpdateClient(ctx, uuid, payload)
 	if err != nil {
+		if errors.Is(err, ErrUnknownClientReference) {
+			return nil, err
+		}
 		return nil, fmt.Errorf("service error updating a client: %w", err)
 	}
 
 	return client, nil
 }
 
-func (s *ClientService) DeleteClient(ctx context.Context, uuid uuid.UUID) error {
+func (s *ClientService) DeleteClient(ctx context.Context, actor Actor, uuid uuid.UUID) error {
+	if err := requireAdmin(actor, "only admins may delete clients"); err != nil {
+		return err
+	}
+
 	err := s.repo.DeleteClient(ctx, uuid)
 	if err != nil {
 		return fmt.Errorf("service error deleting a client: %w", err)
@@ -60,3 +73,202 @@ func (s *ClientService) GetClientByID(ctx context.Context, uuid uuid.UUID) (*mod
 
 	return client, nil
 }
+
+const (
+	earthRadiusKm = 6371.0088
+	// MaxNearbyRadiusKm bounds /clients/nearby searches
+	MaxNearbyRadiusKm = 1000
+	// maxMapPins bounds the pins returned for one viewport
+	maxMapPins = 2000
+)
+
+var ErrUnknownClientReference = repository.ErrUnknownClientReference
+
+var (
+	ErrInvalidCoordinates = errors.New("latitude must be within ±90 and longitude within ±180")
+	ErrInvalidRadius      = fmt.Errorf("radius must be positive and at most %d km", MaxNearbyRadiusKm)
+)
+
+func validCoordinates(lat, lng float64) bool {
+	return lat >= -90 && lat <= 90 && lng >= -180 && lng <= 180
+}
+
+// boundingBoxAround returns a box containing the circle, used to narrow the
+// distance computation. Near the poles it spans all longitudes.
+func boundingBoxAround(lat, lng, radiusKm float64) models.BoundingBox {
+	deltaLat := radiusKm / earthRadiusKm * 180 / math.Pi
+	box := models.BoundingBox{
+		MinLat: math.Max(lat-deltaLat, -90),
+		MaxLat: math.Min(lat+deltaLat, 90),
+		MinLng: -180,
+		MaxLng: 180,
+	}
+
+	if box.MinLat == -90 || box.MaxLat == 90 {
+		return box
+	}
+
+	deltaLng := deltaLat / math.Cos(lat*math.Pi/180)
+	if deltaLng >= 180 {
+		return box
+	}
+
+	box.MinLng = lng - deltaLng
+	if box.MinLng < -180 {
+		box.MinLng += 360
+	}
+	box.MaxLng = lng + deltaLng
+	if box.MaxLng > 180 {
+		box.MaxLng -= 360
+	}
+
+	return box
+}
+
+// NearbyClients lists clients within radiusKm of the point, closest first.
+func (s *ClientService) NearbyClients(ctx context.Context, lat, lng, radiusKm float64, limit int) ([]*models.NearbyClient, error) {
+	if !validCoordinates(lat, lng) {
+		return nil, ErrInvalidCoordinates
+	}
+	if radiusKm <= 0 || radiusKm > MaxNearbyRadiusKm {
+		return nil, ErrInvalidRadius
+	}
+
+	clients, err := s.repo.ListNearbyClients(ctx, lat, lng, radiusKm, boundingBoxAround(lat, lng, radiusKm), limit)
+	if err != nil {
+		return nil, fmt.Errorf("service error fetching nearby clients: %w", err)
+	}
+
+	return clients, nil
+}
+
+// ClientPins returns the map pins of the clients inside the box.
+func (s *ClientService) ClientPins(ctx context.Context, box models.BoundingBox) ([]*models.ClientPin, error) {
+	if !validCoordinates(box.MinLat, box.MinLng) || !validCoordinates(box.MaxLat, box.MaxLng) || box.MinLat > box.MaxLat {
+		return nil, ErrInvalidCoordinates
+	}
+
+	pins, err := s.repo.ListClientPins(ctx, box, maxMapPins)
+	if err != nil {
+		return nil, fmt.Errorf("service error fetching client pins: %w", err)
+	}
+
+	return pins, nil
+}
+
+// ListManagedClients lists the clients the user is a manager of.
+func (s *ClientService) ListManagedClients(ctx context.Context, managerID uuid.UUID) ([]*models.Client, error) {
+	clients, err := s.repo.ListManagedClients(ctx, managerID)
+	if err != nil {
+		return nil, fmt.Errorf("service error fetching managed clients: %w", err)
+	}
+
+	return clients, nil
+}
+
+var ErrSameManager = errors.New("from and to managers must differ")
+
+// ReassignManager hands the clients of a departing manager over to another
+// user, or just drops the manager when to is nil. It returns the number of
+// clients changed.
+func (s *ClientService) ReassignManager(ctx context.Context, actor Actor, payload models.ManagerReassignment) (int64, error) {
+	if err := requireAdmin(actor, "only admins may reassign client managers"); err != nil {
+		return 0, err
+	}
+	if payload.To != nil && *payload.To == payload.From {
+		return 0, ErrSameManager
+	}
+
+	count, err := s.repo.ReassignManager(ctx, payload.From, payload.To, payload.Clients)
+	if err != nil {
+		return 0, fmt.Errorf("service error reassigning client manager: %w", err)
+	}
+
+	return count, nil
+}
+
+// duplicateRadiusKm is how close two client locations must be for the pair to
+// be reported as a possible duplicate.
+const duplicateRadiusKm = 0.1
+
+var ErrInvalidMerge = errors.New("duplicates must be given and differ from the surviving client")
+
+// DuplicateCandidates lists pairs of clients that look like the same laboratory.
+func (s *ClientService) DuplicateCandidates(ctx context.Context, actor Actor) ([]*models.DuplicateCandidate, error) {
+	if err := requireAdmin(actor, "only admins may review duplicate clients"); err != nil {
+		return nil, err
+	}
+
+	candidates, err := s.repo.ListDuplicateCandidates(ctx, duplicateRadiusKm)
+	if err != nil {
+		return nil, fmt.Errorf("service error fetching duplicate clients: %w", err)
+	}
+
+	return candidates, nil
+}
+
+// MergeClients folds the duplicates into the target client. Their ids keep
+// resolving to the target afterwards.
+func (s *ClientService) MergeClients(ctx context.Context, actor Actor, targetID uuid.UUID, duplicateIDs []uuid.UUID) (*models.ClientMergeResult, error) {
+	if err := requireAdmin(actor, "only admins may merge clients"); err != nil {
+		return nil, err
+	}
+
+	seen := map[uuid.UUID]bool{}
+	var duplicates []uuid.UUID
+	for _, id := range duplicateIDs {
+		if id == uuid.Nil || id == targetID {
+			return nil, ErrInvalidMerge
+		}
+		if !seen[id] {
+			seen[id] = true
+			duplicates = append(duplicates, id)
+		}
+	}
+	if len(duplicates) == 0 {
+		return nil, ErrInvalidMerge
+	}
+
+	result, err := s.repo.MergeClients(ctx, targetID, duplicates, actor.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("service error merging clients: %w", err)
+	}
+	if result == nil {
+		return nil, ErrNotFound
+	}
+
+	return result, nil
+}
+
+// MaxOverviewClosedTickets bounds how many closed tickets the overview lists.
+const MaxOverviewClosedTickets = 50
+
+// ClientOverview gathers the client page: contacts, active agreements,
+// tickets and service history.
+func (s *ClientService) ClientOverview(ctx context.Context, id uuid.UUID, closedLimit int) (*models.ClientOverview, error) {
+	if closedLimit > MaxOverviewClosedTickets {
+		closedLimit = MaxOverviewClosedTickets
+	}
+
+	overview, err := s.repo.GetClientOverview(ctx, id, closedLimit)
+	if err != nil {
+		return nil, fmt.Errorf("service error fetching client overview: %w", err)
+	}
+	if overview == nil {
+		return nil, ErrNotFound
+	}
+
+	now := time.Now().UTC()
+	for _, agreement := range overview.Agreements {
+		switch {
+		case agreement.FinishedAt != nil && agreement.FinishedAt.Before(now):
+			overview.Warranty.Expired++
+		case agreement.OnWarranty != nil && *agreement.OnWarranty:
+			overview.Warranty.OnWarranty++
+		default:
+			overview.Warranty.OffWarranty++
+		}
+	}
+
+	return overview, nil
+}
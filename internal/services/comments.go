@@ -36,12 +36,29 @@ func (s *CommentService) NewComment(ctx context.Context, data models.Comment) (*
 	return comment, nil
 }
 
-func (s *CommentService) DeleteComment(ctx context.Context, id string) error {
+// authorizeCommentChange applies the comment policy for the actor.
+func (s *CommentService) authorizeCommentChange(ctx context.Context, actor Actor, id int) error {
+	comment, err := s.repo.GetCommentByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("service error fetching comment: %w", err)
+	}
+	if comment == nil {
+		return ErrNotFound
+	}
+
+	return canModifyComment(actor, comment.AuthorID)
+}
+
+func (s *CommentService) DeleteComment(ctx context.Context, actor Actor, id string) error {
 	numberId, err := strconv.Atoi(id)
 	if err != nil {
 		return fmt.Errorf("can't convert id to number: %w", err)
 	}
 
+	if err := s.authorizeCommentChange(ctx, actor, numberId); err != nil {
+		return err
+	}
+
 	err = s.repo.DeleteComment(ctx, numberId)
 	if err != nil {
 		return fmt.Errorf("service error deleting a comment: %w", err)
@@ -50,12 +67,16 @@ func (s *CommentService) DeleteComment(ctx context.Context, id string) error {
 	return nil
 }
 
-func (s *CommentService) UpdateComment(ctx context.Context, id string, payload models.CommentUpdate) error {
+func (s *CommentService) UpdateComment(ctx context.Context, actor Actor, id string, payload models.CommentUpdate) error {
 	numberId, err := strconv.Atoi(id)
 	if err != nil {
 		return fmt.Errorf("can't convert id to number: %w", err)
 	}
 
+	if err := s.authorizeCommentChange(ctx, actor, numberId); err != nil {
+		return err
+	}
+
 	err = s.repo.UpdateComment(ctx, numberId, payload)
 	if err != nil {
 		return fmt.Errorf("service error updating the comment: %w", err)
@@ -3,13 +3,101 @@ package services
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/grintheone/foxygen-server/internal/models"
 	"github.com/grintheone/foxygen-server/internal/repository"
 )
 
+// defaultPhoneCountryCode is assumed for numbers written without one.
+const defaultPhoneCountryCode = "7"
+
+var (
+	ErrInvalidPhone     = errors.New("phone must be a number with 10 digits or an international number starting with +")
+	ErrInvalidEmail     = errors.New("email is not a valid address")
+	ErrDuplicateContact = errors.New("client already has a contact with this phone or email")
+)
+
+// phoneExtension matches an extension written after the number, "доб. 123",
+// "ext 123", "x123" or "#123".
+var phoneExtension = regexp.MustCompile(`(?i)\s*(?:доб\.?|ext\.?|x|#)\s*(\d{1,6})\s*$`)
+
+// normalizePhone returns the E.164 form of a phone number and the form it is
+// displayed in. Numbers without a country code get defaultPhoneCountryCode,
+// so 10 digit and trunk prefixed 8XXXXXXXXXX numbers are read as Russian. An
+// extension is kept in the display form only.
+func normalizePhone(phone string) (e164, display string, err error) {
+	phone = strings.TrimSpace(phone)
+	if phone == "" {
+		return "", "", nil
+	}
+
+	var extension string
+	if m := phoneExtension.FindStringSubmatchIndex(phone); m != nil {
+		extension = phone[m[2]:m[3]]
+		phone = phone[:m[0]]
+	}
+
+	var b strings.Builder
+	for i, r := range phone {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' && i == 0, strings.ContainsRune(" -().\u00a0", r):
+		default:
+			return "", "", ErrInvalidPhone
+		}
+	}
+	digits := b.String()
+
+	switch {
+	case strings.HasPrefix(phone, "+"):
+	case strings.HasPrefix(digits, "00"):
+		digits = digits[2:]
+	case len(digits) == 11 && (digits[0] == '8' || digits[0] == '7'):
+		digits = defaultPhoneCountryCode + digits[1:]
+	case len(digits) == 10:
+		digits = defaultPhoneCountryCode + digits
+	default:
+		return "", "", ErrInvalidPhone
+	}
+
+	if len(digits) < 8 || len(digits) > 15 || digits[0] == '0' {
+		return "", "", ErrInvalidPhone
+	}
+
+	e164 = "+" + digits
+	display = e164
+	if digits[0] == '7' && len(digits) == 11 {
+		display = fmt.Sprintf("+7 (%s) %s-%s-%s", digits[1:4], digits[4:7], digits[7:9], digits[9:])
+	}
+	if extension != "" {
+		display += " доб. " + extension
+	}
+
+	return e164, display, nil
+}
+
+// normalizeEmail checks that email is a bare address and lowercases it.
+func normalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return "", nil
+	}
+
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return "", ErrInvalidEmail
+	}
+
+	return strings.ToLower(email), nil
+}
+
 type ContactService struct {
 	repo repository.ContactsRepository
 }
@@ -26,7 +114,58 @@ func (s *ContactService) GetAllByClientID(ctx context.Context, uuid uuid.UUID) (
 	return contacts, nil
 }
 
+// checkDuplicate fails when another contact of the client has the same
+// phone number or email.
+func (s *ContactService) checkDuplicate(ctx context.Context, contact models.Contact) error {
+	if contact.ClientID == uuid.Nil {
+		return nil
+	}
+
+	duplicate, err := s.repo.FindDuplicateContact(ctx, contact.ClientID, contact.ID, contact.PhoneE164, contact.Email)
+	if err != nil {
+		return fmt.Errorf("service error checking duplicate contacts: %w", err)
+	}
+	if duplicate != nil {
+		return fmt.Errorf("%w: %s", ErrDuplicateContact, duplicate.Name)
+	}
+
+	return nil
+}
+
 func (s *ContactService) CreateContact(ctx context.Context, payload models.Contact) error {
+	e164, display, err := normalizePhone(payload.Phone)
+	if err != nil {
+		return err
+	}
+	payload.Phone, payload.PhoneE164 = display, e164
+
+	payload.Email, err = normalizeEmail(payload.Email)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkDuplicate(ctx, payload); err != nil {
+		return err
+	}
+
+	err = s.repo.CreateContact(ctx, payload)
+	if err != nil {
+		return fmt.Errorf("service error creating new contact: %w", err)
+	}
+
+	return nil
+}
+
+// ImportContact stores a contact from the CouchDB import. Legacy phones and
+// emails are normalized where possible and kept as they are otherwise.
+func (s *ContactService) ImportContact(ctx context.Context, payload models.Contact) error {
+	if e164, display, err := normalizePhone(payload.Phone); err == nil {
+		payload.Phone, payload.PhoneE164 = display, e164
+	}
+	if email, err := normalizeEmail(payload.Email); err == nil {
+		payload.Email = email
+	}
+
 	err := s.repo.CreateContact(ctx, payload)
 	if err != nil {
 		return fmt.Errorf("service error creating new contact: %w", err)
@@ -35,7 +174,11 @@ func (s *ContactService) CreateContact(ctx context.Context, payload models.Conta
 	return nil
 }
 
-func (s *ContactService) DeleteContact(ctx context.Context, uuid uuid.UUID) error {
+func (s *ContactService) DeleteContact(ctx context.Context, actor Actor, uuid uuid.UUID) error {
+	if err := requireAdmin(actor, "only admins may delete contacts"); err != nil {
+		return err
+	}
+
 	err := s.repo.DeleteContact(ctx, uuid)
 	if err != nil {
 		return fmt.Errorf("service error deliting the contact: %w", err)
@@ -44,6 +187,40 @@ func (s *ContactService) DeleteContact(ctx context.Context, uuid uuid.UUID) erro
 }
 
 func (s *ContactService) UpdateContact(ctx context.Context, uuid uuid.UUID, payload models.ContactUpdate) (*models.Contact, error) {
+	existing, err := s.repo.GetContactByID(ctx, uuid)
+	if err != nil {
+		return nil, fmt.Errorf("service error updating the contact: %w", err)
+	}
+	if existing == nil {
+		return nil, nil
+	}
+
+	if payload.Phone != nil {
+		e164, display, err := normalizePhone(*payload.Phone)
+		if err != nil {
+			return nil, err
+		}
+		payload.Phone, payload.PhoneE164 = &display, &e164
+		existing.PhoneE164 = e164
+	}
+
+	if payload.Email != nil {
+		email, err := normalizeEmail(*payload.Email)
+		if err != nil {
+			return nil, err
+		}
+		payload.Email = &email
+		existing.Email = email
+	}
+
+	if payload.ClientID != nil {
+		existing.ClientID = *payload.ClientID
+	}
+
+	if err := s.checkDuplicate(ctx, *existing); err != nil {
+		return nil, err
+	}
+
 	updated, err := s.repo.UpdateContact(ctx, uuid, payload)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -53,3 +230,97 @@ func (s *ContactService) UpdateContact(ctx context.Context, uuid uuid.UUID, payl
 	}
 	return updated, nil
 }
+
+// SearchByPhone finds contacts by phone number in any format.
+func (s *ContactService) SearchByPhone(ctx context.Context, phone string) ([]models.ContactRow, error) {
+	e164, _, err := normalizePhone(phone)
+	if err != nil {
+		return nil, err
+	}
+	if e164 == "" {
+		return nil, ErrInvalidPhone
+	}
+
+	contacts, err := s.repo.SearchByPhone(ctx, e164)
+	if err != nil {
+		return nil, fmt.Errorf("service error searching contacts: %w", err)
+	}
+
+	return contacts, nil
+}
+
+func (s *ContactService) ListDuplicateContacts(ctx context.Context, clientID uuid.UUID) ([]*models.DuplicateContact, error) {
+	duplicates, err := s.repo.ListDuplicateContacts(ctx, clientID)
+	if err != nil {
+		return nil, fmt.Errorf("service error listing duplicate contacts: %w", err)
+	}
+
+	return duplicates, nil
+}
+
+func (s *ContactService) ContactVCard(ctx context.Context, id uuid.UUID) ([]byte, error) {
+	card, err := s.repo.GetContactCard(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("service error fetching contact: %w", err)
+	}
+	if card == nil {
+		return nil, ErrNotFound
+	}
+
+	return encodeVCards([]*models.ContactCard{card}), nil
+}
+
+// ClientContactsVCard returns every contact of a client in one vCard file.
+func (s *ContactService) ClientContactsVCard(ctx context.Context, clientID uuid.UUID) ([]byte, error) {
+	cards, err := s.repo.ListContactCards(ctx, clientID)
+	if err != nil {
+		return nil, fmt.Errorf("service error fetching contacts: %w", err)
+	}
+	if len(cards) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return encodeVCards(cards), nil
+}
+
+// NormalizeContacts rewrites the phones and emails of all stored contacts in
+// normalized form, for contacts saved before normalization was introduced.
+func (s *ContactService) NormalizeContacts(ctx context.Context, actor Actor) (*models.ContactNormalization, error) {
+	if err := requireAdmin(actor, "only admins can normalize contacts"); err != nil {
+		return nil, err
+	}
+
+	contacts, err := s.repo.ListAllContacts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("service error listing contacts: %w", err)
+	}
+
+	result := &models.ContactNormalization{}
+
+	for _, contact := range contacts {
+		phone, e164, email := contact.Phone, contact.PhoneE164, contact.Email
+
+		if normalized, display, err := normalizePhone(contact.Phone); err != nil {
+			result.InvalidPhones++
+		} else {
+			phone, e164 = display, normalized
+		}
+
+		if normalized, err := normalizeEmail(contact.Email); err != nil {
+			result.InvalidEmails++
+		} else {
+			email = normalized
+		}
+
+		if phone == contact.Phone && e164 == contact.PhoneE164 && email == contact.Email {
+			continue
+		}
+
+		if err := s.repo.SetContactFormat(ctx, contact.ID, phone, e164, email); err != nil {
+			return nil, fmt.Errorf("service error normalizing contact %s: %w", contact.ID, err)
+		}
+		result.Updated++
+	}
+
+	return result, nil
+}
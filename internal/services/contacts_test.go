@@ -0,0 +1,76 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		phone   string
+		e164    string
+		display string
+		err     error
+	}{
+		{"", "", "", nil},
+		{"8 (916) 123-45-67", "+79161234567", "+7 (916) 123-45-67", nil},
+		{"+7 916 123 45 67", "+79161234567", "+7 (916) 123-45-67", nil},
+		{"79161234567", "+79161234567", "+7 (916) 123-45-67", nil},
+		{"9161234567", "+79161234567", "+7 (916) 123-45-67", nil},
+		{"8 916 123 45 67", "+79161234567", "+7 (916) 123-45-67", nil},
+		{"+7 (495) 123-45-67 доб. 123", "+74951234567", "+7 (495) 123-45-67 доб. 123", nil},
+		{"8-495-123-45-67 ext 5", "+74951234567", "+7 (495) 123-45-67 доб. 5", nil},
+		{"84951234567#12", "+74951234567", "+7 (495) 123-45-67 доб. 12", nil},
+		{"+49 30 1234567", "+49301234567", "+49301234567", nil},
+		{"0049 30 1234567", "+49301234567", "+49301234567", nil},
+		{"+375 (17) 123-45-67", "+375171234567", "+375171234567", nil},
+		{"123-45-67", "", "", ErrInvalidPhone},
+		{"+7 916 123 45 6x", "", "", ErrInvalidPhone},
+		{"8 916 123 45 67, 8 916 765 43 21", "", "", ErrInvalidPhone},
+		{"7 916+1234567", "", "", ErrInvalidPhone},
+		{"+0 916 123 45 67", "", "", ErrInvalidPhone},
+		{"+1234567", "", "", ErrInvalidPhone},
+		{"+1234567890123456", "", "", ErrInvalidPhone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.phone, func(t *testing.T) {
+			e164, display, err := normalizePhone(tt.phone)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if e164 != tt.e164 || display != tt.display {
+				t.Errorf("got %q, %q, want %q, %q", e164, display, tt.e164, tt.display)
+			}
+		})
+	}
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		email string
+		want  string
+		err   error
+	}{
+		{"", "", nil},
+		{"  ", "", nil},
+		{"i.ivanov@gkb1.ru", "i.ivanov@gkb1.ru", nil},
+		{" I.Ivanov@GKB1.ru ", "i.ivanov@gkb1.ru", nil},
+		{"Иванов <i.ivanov@gkb1.ru>", "", ErrInvalidEmail},
+		{"i.ivanov@gkb1.ru, lab@gkb1.ru", "", ErrInvalidEmail},
+		{"i.ivanov", "", ErrInvalidEmail},
+		{"@gkb1.ru", "", ErrInvalidEmail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			got, err := normalizeEmail(tt.email)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -48,3 +48,31 @@ func (s *DepartmentService) AddNewDepartment(ctx context.Context, data models.De
 
 	return nil
 }
+
+// GetWorkload returns the workload dashboard of a department. Admins may view
+// any department, everyone else only their own.
+func (s *DepartmentService) GetWorkload(ctx context.Context, actor Actor, departmentID uuid.UUID) ([]*models.UserWorkload, error) {
+	if !actor.IsAdmin() {
+		department, err := s.repo.GetUserDepartment(ctx, actor.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("service error fetching actor department: %w", err)
+		}
+		if department == nil || *department != departmentID {
+			return nil, forbidden("only admins may view the workload of other departments")
+		}
+	}
+
+	if _, err := s.repo.GetDepartmentByID(ctx, departmentID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("service error fetching a department: %w", err)
+	}
+
+	workload, err := s.repo.GetWorkload(ctx, departmentID)
+	if err != nil {
+		return nil, fmt.Errorf("service error fetching department workload: %w", err)
+	}
+
+	return workload, nil
+}
@@ -2,13 +2,21 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/grintheone/foxygen-server/internal/models"
 	"github.com/grintheone/foxygen-server/internal/repository"
 )
 
+var (
+	ErrRelocationSameClient    = repository.ErrRelocationSameClient
+	ErrUnknownRelocationTarget = repository.ErrUnknownRelocationTarget
+	ErrInvalidRelocationPeriod = repository.ErrInvalidRelocationPeriod
+)
+
 type DeviceService struct {
 	repo repository.DevicesRepository
 }
@@ -34,7 +42,11 @@ func (s *DeviceService) GetDeviceByID(ctx context.Context, uuid uuid.UUID) (*mod
 	return device, nil
 }
 
-func (s *DeviceService) RemoveDeviceByID(ctx context.Context, uuid uuid.UUID) error {
+func (s *DeviceService) RemoveDeviceByID(ctx context.Context, actor Actor, uuid uuid.UUID) error {
+	if err := requireAdmin(actor, "only admins may delete devices"); err != nil {
+		return err
+	}
+
 	err := s.repo.RemoveDeviceByID(ctx, uuid)
 	if err != nil {
 		return fmt.Errorf("service error deleting device: %w", err)
@@ -52,8 +64,15 @@ func (s *DeviceService) CreateNewDevice(ctx context.Context, payload models.Devi
 	return nil
 }
 
-func (s *DeviceService) UpdateDeviceByID(ctx context.Context, uuid uuid.UUID, payload models.DeviceUpdates) (*models.DeviceSinglePage, error) {
-	updated, err := s.repo.UpdateDeviceByID(ctx, uuid, payload)
+// UpdateDeviceByID updates a device, recording the change on its timeline
+// as made by the actor.
+func (s *DeviceService) UpdateDeviceByID(ctx context.Context, actor Actor, id uuid.UUID, payload models.DeviceUpdates) (*models.DeviceSinglePage, error) {
+	var changedBy *uuid.UUID
+	if !actor.IsAPIKey() {
+		changedBy = &actor.UserID
+	}
+
+	updated, err := s.repo.UpdateDeviceByID(ctx, id, payload, changedBy)
 	if err != nil {
 		return nil, fmt.Errorf("service error updating device: %w", err)
 	}
@@ -69,3 +88,51 @@ func (s *DeviceService) GetDeviceRemoteOptions(ctx context.Context, uuid uuid.UU
 
 	return options, nil
 }
+
+func (s *DeviceService) GetDeviceTimeline(ctx context.Context, id uuid.UUID) ([]*models.DeviceEvent, error) {
+	events, exists, err := s.repo.GetDeviceTimeline(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("service error building device timeline: %w", err)
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	return events, nil
+}
+
+// RelocateDevice moves a device to another client, ending its current
+// agreement and starting a new one.
+func (s *DeviceService) RelocateDevice(ctx context.Context, id uuid.UUID, relocation models.DeviceRelocation) (*models.DeviceRelocationResult, error) {
+	if relocation.Client == uuid.Nil {
+		return nil, ErrUnknownRelocationTarget
+	}
+
+	// Agreement timestamps are stored in UTC
+	at := time.Now().UTC()
+	if relocation.At != nil {
+		at = relocation.At.UTC()
+	}
+	relocation.At = &at
+
+	if relocation.FinishedAt != nil {
+		finishedAt := relocation.FinishedAt.UTC()
+		if finishedAt.Before(at) {
+			return nil, ErrInvalidRelocationPeriod
+		}
+		relocation.FinishedAt = &finishedAt
+	}
+
+	result, err := s.repo.RelocateDevice(ctx, id, relocation)
+	if err != nil {
+		if errors.Is(err, ErrRelocationSameClient) || errors.Is(err, ErrUnknownRelocationTarget) || errors.Is(err, ErrInvalidRelocationPeriod) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("service error relocating device: %w", err)
+	}
+	if result == nil {
+		return nil, ErrNotFound
+	}
+
+	return result, nil
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/grintheone/foxygen-server/internal/config"
+	"github.com/grintheone/foxygen-server/internal/models"
+)
+
+const geocoderHTTPTimeout = 15 * time.Second
+
+// ErrGeocoderRateLimited means the provider asked us to slow down.
+var ErrGeocoderRateLimited = errors.New("geocoder rate limit exceeded")
+
+// Geocoder turns a postal address into coordinates. Geocode returns nil
+// without an error when nothing matches.
+type Geocoder interface {
+	Name() string
+	Geocode(ctx context.Context, address string) (*models.GeocodeResult, error)
+}
+
+// NewGeocoder builds the configured provider, or returns nil when geocoding
+// is disabled.
+func NewGeocoder(cfg config.GeocoderConfig) (Geocoder, error) {
+	switch cfg.Provider {
+	case "":
+		return nil, nil
+	case "nominatim":
+		return NewNominatimGeocoder(cfg.URL, cfg.UserAgent), nil
+	case "file":
+		return NewFileGeocoder(cfg.File)
+	default:
+		return nil, fmt.Errorf("unknown geocoder provider %q", cfg.Provider)
+	}
+}
+
+// normalizeAddress lowercases the address and reduces punctuation and runs
+// of spaces to single spaces, so trivially different spellings share a key.
+func normalizeAddress(address string) string {
+	fields := strings.FieldsFunc(strings.ToLower(address), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	return strings.Join(fields, " ")
+}
+
+// NominatimGeocoder queries the search endpoint of a Nominatim compatible API.
+type NominatimGeocoder struct {
+	baseURL   string
+	userAgent string
+	client    *http.Client
+}
+
+func NewNominatimGeocoder(baseURL, userAgent string) *NominatimGeocoder {
+	return &NominatimGeocoder{
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		userAgent: userAgent,
+		client:    &http.Client{Timeout: geocoderHTTPTimeout},
+	}
+}
+
+func (g *NominatimGeocoder) Name() string {
+	return "nominatim"
+}
+
+type nominatimPlace struct {
+	Lat         string  `json:"lat"`
+	Lon         string  `json:"lon"`
+	DisplayName string  `json:"display_name"`
+	PlaceRank   int     `json:"place_rank"`
+	Importance  float64 `json:"importance"`
+}
+
+func (g *NominatimGeocoder) Geocode(ctx context.Context, address string) (*models.GeocodeResult, error) {
+	query := url.Values{
+		"q":      {address},
+		"format": {"jsonv2"},
+		"limit":  {"1"},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.baseURL+"/search?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	// Nominatim's usage policy requires identifying the application
+	req.Header.Set("User-Agent", g.userAgent)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("geocoder request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return nil, ErrGeocoderRateLimited
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("geocoder responded with status %d", resp.StatusCode)
+	}
+
+	var places []nominatimPlace
+	if err := json.NewDecoder(resp.Body).Decode(&places); err != nil {
+		return nil, fmt.Errorf("failed to decode geocoder response: %w", err)
+	}
+	if len(places) == 0 {
+		return nil, nil
+	}
+
+	place := places[0]
+	lat, errLat := strconv.ParseFloat(place.Lat, 64)
+	lng, errLng := strconv.ParseFloat(place.Lon, 64)
+	if errLat != nil || errLng != nil || !validCoordinates(lat, lng) {
+		return nil, fmt.Errorf("geocoder returned invalid coordinates %q, %q", place.Lat, place.Lon)
+	}
+
+	// place_rank grows with precision up to 30 for a building. Providers that
+	// don't report it fall back to importance.
+	confidence := place.Importance
+	if place.PlaceRank > 0 {
+		confidence = float64(place.PlaceRank) / 30
+	}
+
+	return &models.GeocodeResult{
+		Lat:         lat,
+		Lng:         lng,
+		Confidence:  math.Max(0, math.Min(1, confidence)),
+		DisplayName: place.DisplayName,
+	}, nil
+}
+
+// FileGeocoder answers from a JSON file mapping addresses to results, e.g.
+// {"Moscow, Tverskaya 1": {"lat": 55.757, "lng": 37.613, "confidence": 1}}.
+// It stands in for a real provider in development and tests.
+type FileGeocoder struct {
+	results map[string]models.GeocodeResult
+}
+
+func NewFileGeocoder(path string) (*FileGeocoder, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read geocoder file: %w", err)
+	}
+
+	var entries map[string]struct {
+		Lat         float64  `json:"lat"`
+		Lng         float64  `json:"lng"`
+		Confidence  *float64 `json:"confidence"`
+		DisplayName string   `json:"display_name"`
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse geocoder file: %w", err)
+	}
+
+	results := make(map[string]models.GeocodeResult, len(entries))
+	for address, entry := range entries {
+		result := models.GeocodeResult{
+			Lat:         entry.Lat,
+			Lng:         entry.Lng,
+			Confidence:  1,
+			DisplayName: entry.DisplayName,
+		}
+		if entry.Confidence != nil {
+			result.Confidence = *entry.Confidence
+		}
+		if result.DisplayName == "" {
+			result.DisplayName = address
+		}
+		results[normalizeAddress(address)] = result
+	}
+
+	return &FileGeocoder{results: results}, nil
+}
+
+func (g *FileGeocoder) Name() string {
+	return "file"
+}
+
+func (g *FileGeocoder) Geocode(ctx context.Context, address string) (*models.GeocodeResult, error) {
+	result, ok := g.results[normalizeAddress(address)]
+	if !ok {
+		return nil, nil
+	}
+
+	return &result, nil
+}
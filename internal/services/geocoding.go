@@ -0,0 +1,311 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/config"
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/grintheone/foxygen-server/internal/repository"
+)
+
+// Outcomes of geocoding a client address.
+const (
+	GeocodeApplied  = "applied"
+	GeocodePending  = "pending"
+	GeocodeApproved = "approved"
+	GeocodeRejected = "rejected"
+	GeocodeNotFound = "not_found"
+	GeocodeFailed   = "failed"
+)
+
+const (
+	// geocodeCacheTTL is how long a match is reused, misses are retried sooner
+	geocodeCacheTTL     = 180 * 24 * time.Hour
+	geocodeMissCacheTTL = 30 * 24 * time.Hour
+)
+
+var (
+	ErrGeocodingDisabled   = errors.New("no geocoder is configured")
+	ErrGeocodingRunning    = errors.New("geocoding is already running")
+	ErrInvalidGeocodeState = errors.New("status must be one of applied, pending, approved, rejected, not_found or failed")
+)
+
+type GeocodingService struct {
+	repo     repository.GeocodingRepo
+	geocoder Geocoder
+
+	requestInterval time.Duration
+	runInterval     time.Duration
+	batchSize       int
+	minConfidence   float64
+
+	// jobCtx ends background runs on shutdown
+	jobCtx context.Context
+
+	// running serializes job runs, lastRequest paces provider calls
+	running     sync.Mutex
+	lastRequest time.Time
+}
+
+// NewGeocodingService works without a geocoder too, then only the review of
+// earlier matches is available.
+func NewGeocodingService(repo repository.GeocodingRepo, geocoder Geocoder, cfg config.GeocoderConfig) *GeocodingService {
+	return &GeocodingService{
+		repo:            repo,
+		geocoder:        geocoder,
+		requestInterval: cfg.RequestInterval,
+		runInterval:     cfg.RunInterval,
+		batchSize:       cfg.BatchSize,
+		minConfidence:   cfg.MinConfidence,
+		jobCtx:          context.Background(),
+	}
+}
+
+// Start runs the geocoding job now and then every run interval until ctx is
+// done. It does nothing without a geocoder.
+func (s *GeocodingService) Start(ctx context.Context) {
+	s.jobCtx = ctx
+	if s.geocoder == nil || s.runInterval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.runInterval)
+		defer ticker.Stop()
+
+		for {
+			if s.running.TryLock() {
+				s.runAndLog(ctx)
+				s.running.Unlock()
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *GeocodingService) runAndLog(ctx context.Context) {
+	run, err := s.run(ctx)
+	if err != nil {
+		log.Printf("geocoding job failed: %v", err)
+	}
+	if run != nil && run.Processed+run.Failed > 0 {
+		log.Printf("geocoding job: %d processed, %d applied, %d pending, %d not found, %d failed",
+			run.Processed, run.Applied, run.Pending, run.NotFound, run.Failed)
+	}
+}
+
+// run geocodes one batch of clients with an address but no location. Matches
+// at or above the confidence threshold become the client's location, weaker
+// ones wait for review. Provider errors are recorded as failed, later runs
+// retry them after the clients not tried yet. Rate limiting ends the batch.
+// The caller holds the running lock.
+func (s *GeocodingService) run(ctx context.Context) (*models.GeocodeRun, error) {
+	candidates, err := s.repo.ListClientsToGeocode(ctx, s.batchSize)
+	if err != nil {
+		return nil, fmt.Errorf("service error listing clients to geocode: %w", err)
+	}
+
+	run := &models.GeocodeRun{}
+	for _, candidate := range candidates {
+		result, err := s.geocode(ctx, candidate.Address)
+		if err != nil {
+			if errors.Is(err, ErrGeocoderRateLimited) || ctx.Err() != nil {
+				return run, nil
+			}
+			log.Printf("failed to geocode client %s: %v", candidate.ID, err)
+			failed := models.ClientGeocode{
+				ClientID: candidate.ID,
+				Address:  candidate.Address,
+				Status:   GeocodeFailed,
+			}
+			if err := s.repo.SaveClientGeocode(ctx, failed, false); err != nil {
+				return run, fmt.Errorf("service error saving geocode: %w", err)
+			}
+			run.Failed++
+			continue
+		}
+
+		geocode := models.ClientGeocode{
+			ClientID: candidate.ID,
+			Address:  candidate.Address,
+			Status:   GeocodeNotFound,
+		}
+		if result != nil {
+			geocode.Lat = &result.Lat
+			geocode.Lng = &result.Lng
+			geocode.Confidence = &result.Confidence
+			geocode.DisplayName = result.DisplayName
+			geocode.Status = GeocodePending
+			if result.Confidence >= s.minConfidence {
+				geocode.Status = GeocodeApplied
+			}
+		}
+
+		if err := s.repo.SaveClientGeocode(ctx, geocode, geocode.Status == GeocodeApplied); err != nil {
+			return run, fmt.Errorf("service error saving geocode: %w", err)
+		}
+
+		run.Processed++
+		switch geocode.Status {
+		case GeocodeApplied:
+			run.Applied++
+		case GeocodePending:
+			run.Pending++
+		default:
+			run.NotFound++
+		}
+	}
+
+	return run, nil
+}
+
+// geocode answers from the cache when possible, otherwise asks the provider,
+// no more often than the request interval, and caches the answer.
+func (s *GeocodingService) geocode(ctx context.Context, address string) (*models.GeocodeResult, error) {
+	key := normalizeAddress(address)
+	now := time.Now().UTC()
+
+	cached, err := s.repo.GetCachedGeocode(ctx, key, now.Add(-geocodeCacheTTL))
+	if err != nil {
+		return nil, fmt.Errorf("cache lookup: %w", err)
+	}
+	if cached != nil && (cached.Found || cached.CreatedAt.After(now.Add(-geocodeMissCacheTTL))) {
+		if !cached.Found || cached.Lat == nil || cached.Lng == nil {
+			return nil, nil
+		}
+		result := &models.GeocodeResult{Lat: *cached.Lat, Lng: *cached.Lng, DisplayName: cached.DisplayName}
+		if cached.Confidence != nil {
+			result.Confidence = *cached.Confidence
+		}
+		return result, nil
+	}
+
+	if wait := s.requestInterval - time.Since(s.lastRequest); wait > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+	s.lastRequest = time.Now()
+
+	result, err := s.geocoder.Geocode(ctx, strings.TrimSpace(address))
+	if err != nil {
+		return nil, err
+	}
+
+	entry := models.GeocodeCacheEntry{
+		AddressKey: key,
+		Provider:   s.geocoder.Name(),
+	}
+	if result != nil {
+		entry.Found = true
+		entry.Lat = &result.Lat
+		entry.Lng = &result.Lng
+		entry.Confidence = &result.Confidence
+		entry.DisplayName = result.DisplayName
+	}
+	if err := s.repo.CacheGeocode(ctx, entry); err != nil {
+		log.Printf("failed to cache geocode: %v", err)
+	}
+
+	return result, nil
+}
+
+// ListGeocodes lists client geocodes by status, the pending ones by default.
+func (s *GeocodingService) ListGeocodes(ctx context.Context, actor Actor, status string) ([]*models.ClientGeocode, error) {
+	if err := requireAdmin(actor, "only admins may review geocoding"); err != nil {
+		return nil, err
+	}
+
+	if status == "" {
+		status = GeocodePending
+	}
+	switch status {
+	case GeocodeApplied, GeocodePending, GeocodeApproved, GeocodeRejected, GeocodeNotFound, GeocodeFailed:
+	default:
+		return nil, ErrInvalidGeocodeState
+	}
+
+	geocodes, err := s.repo.ListClientGeocodes(ctx, status)
+	if err != nil {
+		return nil, fmt.Errorf("service error listing geocodes: %w", err)
+	}
+
+	return geocodes, nil
+}
+
+// ApproveGeocode sets a pending match as the client's location, optionally
+// with corrected coordinates.
+func (s *GeocodingService) ApproveGeocode(ctx context.Context, actor Actor, clientID uuid.UUID, approval models.GeocodeApproval) error {
+	if err := requireAdmin(actor, "only admins may review geocoding"); err != nil {
+		return err
+	}
+
+	if (approval.Lat == nil) != (approval.Lng == nil) {
+		return ErrInvalidCoordinates
+	}
+	if approval.Lat != nil && !validCoordinates(*approval.Lat, *approval.Lng) {
+		return ErrInvalidCoordinates
+	}
+
+	found, err := s.repo.ApproveClientGeocode(ctx, clientID, actor.UserID, approval.Lat, approval.Lng)
+	if err != nil {
+		return fmt.Errorf("service error approving geocode: %w", err)
+	}
+	if !found {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// RejectGeocode discards a pending match. The client isn't geocoded again
+// until its address changes.
+func (s *GeocodingService) RejectGeocode(ctx context.Context, actor Actor, clientID uuid.UUID) error {
+	if err := requireAdmin(actor, "only admins may review geocoding"); err != nil {
+		return err
+	}
+
+	found, err := s.repo.RejectClientGeocode(ctx, clientID, actor.UserID)
+	if err != nil {
+		return fmt.Errorf("service error rejecting geocode: %w", err)
+	}
+	if !found {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// RunNow starts a job run in the background on behalf of an admin.
+func (s *GeocodingService) RunNow(actor Actor) error {
+	if err := requireAdmin(actor, "only admins may run geocoding"); err != nil {
+		return err
+	}
+	if s.geocoder == nil {
+		return ErrGeocodingDisabled
+	}
+	if !s.running.TryLock() {
+		return ErrGeocodingRunning
+	}
+
+	go func() {
+		defer s.running.Unlock()
+		s.runAndLog(s.jobCtx)
+	}()
+
+	return nil
+}
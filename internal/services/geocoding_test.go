@@ -0,0 +1,323 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/config"
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/grintheone/foxygen-server/internal/repository"
+)
+
+// fakeGeocodingRepo keeps clients, their geocodes and the cache in memory and
+// picks candidates the way the SQL query does.
+type fakeGeocodingRepo struct {
+	repository.GeocodingRepo
+	clients   []*models.GeocodeCandidate
+	geocodes  map[uuid.UUID]models.ClientGeocode
+	locations map[uuid.UUID][2]float64
+	cache     map[string]models.GeocodeCacheEntry
+}
+
+func newFakeGeocodingRepo(addresses ...string) *fakeGeocodingRepo {
+	repo := &fakeGeocodingRepo{
+		geocodes:  map[uuid.UUID]models.ClientGeocode{},
+		locations: map[uuid.UUID][2]float64{},
+		cache:     map[string]models.GeocodeCacheEntry{},
+	}
+	for _, address := range addresses {
+		repo.clients = append(repo.clients, &models.GeocodeCandidate{ID: uuid.New(), Address: address})
+	}
+	return repo
+}
+
+func (r *fakeGeocodingRepo) ListClientsToGeocode(ctx context.Context, limit int) ([]*models.GeocodeCandidate, error) {
+	var candidates []*models.GeocodeCandidate
+	for _, client := range r.clients {
+		if _, ok := r.locations[client.ID]; ok {
+			continue
+		}
+		geocode, ok := r.geocodes[client.ID]
+		if ok && geocode.Address == client.Address && geocode.Status != GeocodeFailed {
+			continue
+		}
+		candidates = append(candidates, client)
+	}
+
+	lastAttempt := func(client *models.GeocodeCandidate) time.Time {
+		geocode, ok := r.geocodes[client.ID]
+		if !ok || geocode.Address != client.Address {
+			return time.Time{}
+		}
+		return geocode.CreatedAt
+	}
+	slices.SortStableFunc(candidates, func(a, b *models.GeocodeCandidate) int {
+		return lastAttempt(a).Compare(lastAttempt(b))
+	})
+
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates, nil
+}
+
+func (r *fakeGeocodingRepo) GetCachedGeocode(ctx context.Context, addressKey string, notOlderThan time.Time) (*models.GeocodeCacheEntry, error) {
+	entry, ok := r.cache[addressKey]
+	if !ok || entry.CreatedAt.Before(notOlderThan) {
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+func (r *fakeGeocodingRepo) CacheGeocode(ctx context.Context, entry models.GeocodeCacheEntry) error {
+	entry.CreatedAt = time.Now().UTC()
+	r.cache[entry.AddressKey] = entry
+	return nil
+}
+
+func (r *fakeGeocodingRepo) SaveClientGeocode(ctx context.Context, geocode models.ClientGeocode, apply bool) error {
+	geocode.Attempts = 1
+	if previous, ok := r.geocodes[geocode.ClientID]; ok && previous.Address == geocode.Address {
+		geocode.Attempts = previous.Attempts + 1
+	}
+	geocode.CreatedAt = time.Now().UTC()
+	r.geocodes[geocode.ClientID] = geocode
+
+	if apply && geocode.Lat != nil && geocode.Lng != nil {
+		if _, ok := r.locations[geocode.ClientID]; !ok {
+			r.locations[geocode.ClientID] = [2]float64{*geocode.Lat, *geocode.Lng}
+		}
+	}
+	return nil
+}
+
+// testGeocoder answers from a FileGeocoder, counts the provider calls and
+// fails for the addresses it is told to.
+type testGeocoder struct {
+	*FileGeocoder
+	calls       int
+	errors      map[string]error
+	rateLimited bool
+}
+
+func (g *testGeocoder) Geocode(ctx context.Context, address string) (*models.GeocodeResult, error) {
+	g.calls++
+	if g.rateLimited {
+		return nil, ErrGeocoderRateLimited
+	}
+	if err := g.errors[address]; err != nil {
+		return nil, err
+	}
+	return g.FileGeocoder.Geocode(ctx, address)
+}
+
+func newTestGeocoder(t *testing.T) *testGeocoder {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "geocodes.json")
+	data := `{
+		"Moscow, Tverskaya 1": {"lat": 55.757, "lng": 37.613, "confidence": 0.95},
+		"Kazan, Baumana 5": {"lat": 55.789, "lng": 49.118, "confidence": 0.5}
+	}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := NewFileGeocoder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testGeocoder{FileGeocoder: file, errors: map[string]error{}}
+}
+
+func newTestGeocodingService(repo *fakeGeocodingRepo, geocoder Geocoder) *GeocodingService {
+	return NewGeocodingService(repo, geocoder, config.GeocoderConfig{BatchSize: 10, MinConfidence: 0.8})
+}
+
+func TestGeocodingRunConfidence(t *testing.T) {
+	repo := newFakeGeocodingRepo("Moscow, Tverskaya 1", "Kazan, Baumana 5", "Nowhere 13")
+	applied, pending, missing := repo.clients[0].ID, repo.clients[1].ID, repo.clients[2].ID
+	s := newTestGeocodingService(repo, newTestGeocoder(t))
+
+	run, err := s.run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := models.GeocodeRun{Processed: 3, Applied: 1, Pending: 1, NotFound: 1}
+	if *run != want {
+		t.Errorf("run = %+v, want %+v", *run, want)
+	}
+
+	for id, status := range map[uuid.UUID]string{applied: GeocodeApplied, pending: GeocodePending, missing: GeocodeNotFound} {
+		if got := repo.geocodes[id].Status; got != status {
+			t.Errorf("client %s status = %q, want %q", id, got, status)
+		}
+	}
+
+	if location, ok := repo.locations[applied]; !ok || location != [2]float64{55.757, 37.613} {
+		t.Errorf("applied location = %v, %v", location, ok)
+	}
+	if _, ok := repo.locations[pending]; ok {
+		t.Error("a match below the confidence threshold became the location")
+	}
+}
+
+func TestGeocodingRunFailure(t *testing.T) {
+	repo := newFakeGeocodingRepo("Moscow, Tverskaya 1", "Kazan, Baumana 5")
+	broken := repo.clients[0].ID
+	geocoder := newTestGeocoder(t)
+	geocoder.errors["Moscow, Tverskaya 1"] = errors.New("provider unavailable")
+	s := newTestGeocodingService(repo, geocoder)
+
+	run, err := s.run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Failed != 1 || run.Processed != 1 {
+		t.Errorf("run = %+v, want 1 processed and 1 failed", *run)
+	}
+
+	failed := repo.geocodes[broken]
+	if failed.Status != GeocodeFailed || failed.Attempts != 1 {
+		t.Errorf("failed geocode = %q after %d attempts, want failed after 1", failed.Status, failed.Attempts)
+	}
+
+	// A client added since then is tried before the failed one
+	repo.clients = append(repo.clients, &models.GeocodeCandidate{ID: uuid.New(), Address: "Nowhere 13"})
+	candidates, err := repo.ListClientsToGeocode(context.Background(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 2 || candidates[0].Address != "Nowhere 13" || candidates[1].ID != broken {
+		t.Errorf("candidates = %v, want the new client before the failed one", candidates)
+	}
+
+	delete(geocoder.errors, "Moscow, Tverskaya 1")
+	if _, err := s.run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if retried := repo.geocodes[broken]; retried.Status != GeocodeApplied || retried.Attempts != 2 {
+		t.Errorf("retried geocode = %q after %d attempts, want applied after 2", retried.Status, retried.Attempts)
+	}
+}
+
+func TestGeocodingRunRateLimited(t *testing.T) {
+	repo := newFakeGeocodingRepo("Moscow, Tverskaya 1", "Kazan, Baumana 5", "Nowhere 13")
+	geocoder := newTestGeocoder(t)
+	geocoder.rateLimited = true
+	s := newTestGeocodingService(repo, geocoder)
+
+	run, err := s.run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *run != (models.GeocodeRun{}) {
+		t.Errorf("run = %+v, want nothing processed", *run)
+	}
+	if geocoder.calls != 1 {
+		t.Errorf("provider called %d times, want the batch to stop after 1", geocoder.calls)
+	}
+	if len(repo.geocodes) != 0 {
+		t.Errorf("%d geocodes saved, want rate limited clients left for the next run", len(repo.geocodes))
+	}
+}
+
+func TestGeocodingCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("match reused", func(t *testing.T) {
+		geocoder := newTestGeocoder(t)
+		s := newTestGeocodingService(newFakeGeocodingRepo(), geocoder)
+
+		first, err := s.geocode(ctx, "Moscow, Tverskaya 1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := s.geocode(ctx, "  moscow tverskaya, 1 ")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if geocoder.calls != 1 {
+			t.Errorf("provider called %d times, want 1", geocoder.calls)
+		}
+		if first == nil || second == nil || *first != *second {
+			t.Errorf("cached result = %v, want %v", second, first)
+		}
+	})
+
+	t.Run("old match reused", func(t *testing.T) {
+		repo := newFakeGeocodingRepo()
+		geocoder := newTestGeocoder(t)
+		s := newTestGeocodingService(repo, geocoder)
+
+		if _, err := s.geocode(ctx, "Moscow, Tverskaya 1"); err != nil {
+			t.Fatal(err)
+		}
+		key := normalizeAddress("Moscow, Tverskaya 1")
+		entry := repo.cache[key]
+		entry.CreatedAt = time.Now().Add(-geocodeMissCacheTTL - time.Hour)
+		repo.cache[key] = entry
+
+		result, err := s.geocode(ctx, "Moscow, Tverskaya 1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if geocoder.calls != 1 || result == nil {
+			t.Errorf("provider called %d times for a match past the miss TTL, want 1", geocoder.calls)
+		}
+	})
+
+	t.Run("miss cached then retried", func(t *testing.T) {
+		repo := newFakeGeocodingRepo()
+		geocoder := newTestGeocoder(t)
+		s := newTestGeocodingService(repo, geocoder)
+
+		for range 2 {
+			result, err := s.geocode(ctx, "Nowhere 13")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result != nil {
+				t.Fatalf("result = %v, want no match", result)
+			}
+		}
+		if geocoder.calls != 1 {
+			t.Errorf("provider called %d times for a fresh miss, want 1", geocoder.calls)
+		}
+
+		key := normalizeAddress("Nowhere 13")
+		entry := repo.cache[key]
+		entry.CreatedAt = time.Now().Add(-geocodeMissCacheTTL - time.Hour)
+		repo.cache[key] = entry
+
+		if _, err := s.geocode(ctx, "Nowhere 13"); err != nil {
+			t.Fatal(err)
+		}
+		if geocoder.calls != 2 {
+			t.Errorf("provider called %d times after the miss expired, want 2", geocoder.calls)
+		}
+	})
+
+	t.Run("errors not cached", func(t *testing.T) {
+		repo := newFakeGeocodingRepo()
+		geocoder := newTestGeocoder(t)
+		geocoder.errors["Moscow, Tverskaya 1"] = errors.New("provider unavailable")
+		s := newTestGeocodingService(repo, geocoder)
+
+		if _, err := s.geocode(ctx, "Moscow, Tverskaya 1"); err == nil {
+			t.Fatal("expected the provider error")
+		}
+		if len(repo.cache) != 0 {
+			t.Errorf("cache = %v, want provider errors left uncached", repo.cache)
+		}
+	})
+}
@@ -377,6 +377,8 @@ func (s *ImportService) processClient(docBytes []byte) error {
 	}
 
 	proxy.Client.ID = parsedID
+	// CouchDB has no laboratory system registry, its references would dangle
+	proxy.Client.LaboratorySystem = nil
 
 	return s.clientService.CreateClient(context.Background(), proxy.Client)
 }
@@ -402,7 +404,7 @@ func (s *ImportService) processContact(docBytes []byte) error {
 	proxy.Contact.ClientID = proxy.Ref
 	proxy.Contact.Name = proxy.FullName
 
-	return s.contactService.CreateContact(context.Background(), proxy.Contact)
+	return s.contactService.ImportContact(context.Background(), proxy.Contact)
 }
 
 func (s *ImportService) processClassificator(docBytes []byte) error {
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"image"
+	"image/color"
+	"strconv"
+)
+
+// Bitmap glyphs for label text: DejaVu Sans (Bitstream Vera license)
+// rendered at 20 px, one bit per pixel. Each glyph is its advance width and
+// labelFontHeight rows of that many bits, hex encoded from the top row.
+const (
+	labelFontHeight = 23
+	labelFontAscent = 19
+)
+
+type labelGlyph struct {
+	advance int
+	rows    string
+}
+
+var labelGlyphs = map[rune]labelGlyph{
+	' ':  {6, "0000000000000000000000000000000000000000000000"},
+	'!':  {8, "0000000018181818181818181818000018181800000000"},
+	'"':  {9, "00000000000004406c06c06c06c044000000000000000000000000000000000000000"},
+	'#':  {17, "00000000000000000000000000033000230006300066003ffc0046000c6000c4007ff80fff8018c001880019800118000000000000000000000"},
+	'$':  {13, "00000000000000000040004001f807f806400640064007c003f8007c004c004c004c07f803f00040004000400000"},
+	'%':  {19, "000000000000000000000e0201f06031840318c031880319801b3001e2380067c004c600cc6018c6010c6030440207c00000000000000000000"},
+	'&':  {16, "000000000000000007800fc01800180018001c001e003f04338c61cc60e8607830383cfc1fcc0000000000000000"},
+	'\'': {5, "0000000004060606060400000000000000000000000000"},
+	'(':  {8, "000000000c0818183030303030303030301018180c0400"},
+	')':  {8, "0000000030301818080c0c0c0c0c0c0c0c181810302000"},
+	'*':  {10, "0000000000000000301320fc0300781b6030030000000000000000000000000000000"},
+	'+':  {17, "00000000000000000000000000000000100003000030000300003000030007ffc07ff8003000030000300003000030000000000000000000000"},
+	',':  {6, "00000000000000000000000000000000040e0c0c080000"},
+	'-':  {7, "000000000000000000000000003e000000000000000000"},
+	'.':  {6, "00000000000000000000000000000000040c0c00000000"},
+	'/':  {7, "0000000002020606040c0c0c0818181030302060600000"},
+	'0':  {13, "000000000000000001e003f80718060c060c0e0c0c0c0c0e0c0c0c0c0e0c060c061c033801f00000000000000000"},
+	'1':  {13, "000000000000000000c007e00760006000600060006000600060006000600060006003fc07fc0000000000000000"},
+	'2':  {13, "000000000000000003e00ff80c18001c000c001800180030007000e001c00380070007fc0ffc0000000000000000"},
+	'3':  {13, "000000000000000003e007f8001c000c000c001801f801f00018000c000c000c000c0ff807f00000000000000000"},
+	'4':  {13, "000000000000000000300078007800f801b801380338063806380c380ffe0ffc0038003800380000000000000000"},
+	'5':  {13, "000000000000000003f807f806000600060007c007f00038001c000c000c000c001c0ff807f00000000000000000"},
+	'6':  {13, "000000000000000000f801fc03000600060006600ff80f1c0e0c0e0c060e060c060c039c01f00000000000000000"},
+	'7':  {13, "000000000000000007fc07fc001c001800180030003000300060006000c000c000c0018001800000000000000000"},
+	'8':  {13, "000000000000000001f003f8061c060c060c060c03f801f0071c060c0c0c0c0c060c071c03f80000000000000000"},
+	'9':  {13, "000000000000000001e003f006180e0c0c0c0c0c0c0c061c07fc03ec000c000c001807f007e00000000000000000"},
+	':':  {7, "0000000000000000001c1c0000000000081c1c00000000"},
+	';':  {7, "0000000000000000001c1c0000000000081c1818100000"},
+	'<':  {17, "00000000000000000000000000000000000000000001c000f8007c003e000700007c0000f80003e00007c0000c0000000000000000000000000"},
+	'=':  {17, "0000000000000000000000000000000000000000000000000007ffc07ff8000000000007ffc07ff800000000000000000000000000000000000"},
+	'>':  {17, "00000000000000000000000000000000000000000700003e00007c0000f80003c0007c003e001f0007c00060000000000000000000000000000"},
+	'?':  {11, "0000000000000f01fc10c00c00c01c038070060060060000040060060000000000000"},
+	'@':  {20, "000000000000000000000000001fc0078e00c038180083064c21fc4230c6630c6630c6630c4630cc219f830f7010000180000e06003fc000600"},
+	'A':  {14, "000000000000000000c001c001e00160033003300630061806180ff80ffc1c0c1806180630060000000000000000"},
+	'B':  {14, "00000000000000000fc00ff80c180c0c0c0c0c180ff80ff00c1c0c0c0c0c0c0c0c0c0ff80ff00000000000000000"},
+	'C':  {14, "000000000000000000f803fe07060c000c00180018001800180018001c000c000e02079e01fc0000000000000000"},
+	'D':  {15, "00000000000000001f801ff0183c180c180e180618061806180618061806180e181c1ff81fe00000000000000000"},
+	'E':  {13, "000000000000000007fc07fc060006000600060007fc07fc0600060006000600060007fc07fc0000000000000000"},
+	'F':  {12, "0000000000003fc3fc3003003003003f83fc300300300300300300300000000000000"},
+	'G':  {15, "000000000000000001f007fc0e061800180030003000303c303e3006380618061c060f1e03f80000000000000000"},
+	'H':  {15, "0000000000000000180c180c180c180c180c180c1ffc1ffc180c180c180c180c180c180c180c0000000000000000"},
+	'I':  {6, "000000000c0c0c0c0c0c0c0c0c0c0c0c0c0c0c00000000"},
+	'J':  {6, "000000000c0c0c0c0c0c0c0c0c0c0c0c0c0c0c0c1c3830"},
+	'K':  {13, "00000000000000000602060e061c0638067006e007c0078007c006e006700638061c060e06070000000000000000"},
+	'L':  {11, "0000000000001801801801801801801801801801801801801801ff1ff000000000000"},
+	'M':  {17, "000000000000000000000700c0701c0781c0783c0683c06c6c06c6c0664c066cc062cc0638c0638c0600c0600c0600c00000000000000000000"},
+	'N':  {15, "0000000000000000180c1c0c1e0c1e0c1b0c1b0c198c198c18cc18cc186c187c183c183c181c0000000000000000"},
+	'O':  {16, "000000000000000003c00ff01838301c300c600c600e600e600e600e700c300c38181e780fe00000000000000000"},
+	'P':  {12, "0000000000003f03fc30e3063063063063fe3fc300300300300300300000000000000"},
+	'Q':  {16, "000000000000000003c00ff01838301c300c600c600e600e600e600e700c300c38181e700fe00060003000100000"},
+	'R':  {14, "00000000000000000fc00ff00c380c180c180c180c180ff00ff00c300c180c1c0c0c0c0e0c060000000000000000"},
+	'S':  {13, "000000000000000001f007fc06040c000c000e0007c003f8007c000c000e000e000c0f3c07f80000000000000000"},
+	'T':  {12, "000000000000ffffff060060060060060060060060060060060060060000000000000"},
+	'U':  {15, "0000000000000000100c180c180c180c180c180c180c180c180c180c180c180c18180e3807f00000000000000000"},
+	'V':  {14, "0000000000000000100218061806180c0c0c0c0c0e180618063003300330036001e001e000c00000000000000000"},
+	'W':  {20, "000000000000000000004060660e0660f0660f0c30b0c3190c3198c31998191981b0981b0d81b0f00e0f00e0700e07000000000000000000000"},
+	'X':  {14, "000000000000000008040c0c06180618033001e001e000c001e00360033006180c180c0c18060000000000000000"},
+	'Y':  {12, "00000000000040360770630c19c1d80f0070060060060060060060060000000000000"},
+	'Z':  {14, "00000000000000001ffc1ffe000c001800380070006000c00180030007000e000c001ffe1ffe0000000000000000"},
+	'[':  {8, "000000003c3030303030303030303030303030303c3c00"},
+	'\\': {7, "00000000006020303010181818080c0c04060602020000"},
+	']':  {8, "000000003c0c0c0c0c0c0c0c0c0c0c0c0c0c0c0c3c3c00"},
+	'^':  {17, "00000000000000000000001000078000ec001c60038300201800000000000000000000000000000000000000000000000000000000000000000"},
+	'_':  {10, "0000000000000000000000000000000000000000000000000000000000000000003ff"},
+	'`':  {10, "0000000000c0060020010000000000000000000000000000000000000000000000000"},
+	'a':  {12, "0000000000000000000000003f831c00c0041fe3fe60660e60e31e3f6000000000000"},
+	'b':  {13, "0000000000000000060006000600060006f00798060c060c060606060606060c060c079c06f80000000000000000"},
+	'c':  {11, "00000000000000000000000007e1e61803003003003003001801c60fe000000000000"},
+	'd':  {13, "0000000000000000000c000c000c000c03ec073c061c0c0c0c0c0c0c0c0c0c0c061c073c03ec0000000000000000"},
+	'e':  {12, "0000000000000000000000000f839c3066067fe7fe6006003003860fc000000000000"},
+	'f':  {7, "000000000f1c18187f3f18181818181818181800000000"},
+	'g':  {13, "0000000000000000000000000000000003ec073c061c0c0c0c0c0c0c0c0c0c0c061c07fc03ec000c0018063803f0"},
+	'h':  {13, "0000000000000000060006000600060006f807b8060c060c060c060c060c060c060c060c060c0000000000000000"},
+	'i':  {6, "000000000c0c00000c0c0c0c0c0c0c0c0c0c0c00000000"},
+	'j':  {6, "000000000c0c00000c0c0c0c0c0c0c0c0c0c0c0c0c1838"},
+	'k':  {12, "00000000000030030030030030e31c3383603c03c03e033031830c306000000000000"},
+	'l':  {6, "000000000c0c0c0c0c0c0c0c0c0c0c0c0c0c0c00000000"},
+	'm':  {19, "00000000000000000000000000000000000000001bc781eedc18706183061830618306183061830618306183061830600000000000000000000"},
+	'n':  {13, "0000000000000000000000000000000006f807b8060c060c060c060c060c060c060c060c060c0000000000000000"},
+	'o':  {12, "0000000000000000000000001f839c30e60660660660660630e39c1f8000000000000"},
+	'p':  {13, "0000000000000000000000000000000006f00798060c060c060606060606060c060c079c06f80600060006000600"},
+	'q':  {13, "0000000000000000000000000000000003ec073c061c0c0c0c0c0c0c0c0c0c0c061c073c03ec000c000c000c000c"},
+	'r':  {8, "0000000000000000373f30303030303030303000000000"},
+	's':  {10, "0000000000000000000000000fe1c61801801e00fc00e00600618e1fc000000000000"},
+	't':  {8, "0000000000303030fe7e303030303030303e1e00000000"},
+	'u':  {13, "00000000000000000000000000000000060c060c060c060c060c060c060c060c061c073c03ec0000000000000000"},
+	'v':  {12, "00000000000000000000000060660660c30c30c1981981900f00f00e0000000000000"},
+	'w':  {16, "00000000000000000000000000000000618261c663c633463344326c366c1e2c1e381c380c380000000000000000"},
+	'x':  {12, "00000000000000000000000060e30c1981f00f00600f01b839830c606000000000000"},
+	'y':  {12, "00000000000000000000000060660620c30c3081981980b00f00e00600600c01c0780"},
+	'z':  {10, "0000000000000000000000001ff1ff00600c0180300700e00c01fe1ff000000000000"},
+	'{':  {13, "000000000000000000780060004000c000c000c000c000c00380038000c000c000c000c000c000c0006000780000"},
+	'|':  {7, "0000000018181818181818181818181818181818181818"},
+	'}':  {13, "0000000000000000078000c000c000c000c000c000c0006000780078006000c000c000c000c000c000c007800000"},
+	'~':  {17, "000000000000000000000000000000000000000000000000000000001c0407ffc061f8000000000000000000000000000000000000000000000"},
+	'А':  {14, "000000000000000000c001c001e00160033003300630061806180ff80ffc1c0c1806180630060000000000000000"},
+	'Б':  {14, "00000000000000000ff80ff80c000c000c000c000fe00ff80c1c0c0c0c0c0c0c0c0c0ff80ff00000000000000000"},
+	'В':  {14, "00000000000000000fc00ff80c180c0c0c0c0c180ff80ff00c1c0c0c0c0c0c0c0c0c0ff80ff00000000000000000"},
+	'Г':  {12, "0000000000003fe3fe300300300300300300300300300300300300300000000000000"},
+	'Д':  {16, "000000000000000007f80ff80c180c180c180c180c180c180c180c180c18181818187ffe7ffe6006600660060000"},
+	'Е':  {13, "000000000000000007fc07fc060006000600060007fc07fc0600060006000600060007fc07fc0000000000000000"},
+	'Ж':  {22, "0000000000000000000000000808040c0c1c060c38030c30038c6001ccc000edc001ffc001be60031c30060c30060c180c0c1c180c0c180c06000000000000000000000000"},
+	'З':  {13, "000000000000000003e00ff8001c000c000c000c01f801f8001c000c000e000e000c0ffc07f00000000000000000"},
+	'И':  {15, "0000000000000000180c181c183c183c186c186c18cc18cc198c1b8c1b0c1f0c1e0c1c0c1c0c0000000000000000"},
+	'Й':  {15, "000003e001c00000180c181c183c183c186c186c18cc18cc198c1b8c1b0c1f0c1e0c1c0c1c0c0000000000000000"},
+	'К':  {14, "00000000000000000c060c0c0c180c380c700ce00dc00fe00f600e300c180c180c0c0c060c070000000000000000"},
+	'Л':  {15, "000000000000000003fc07fc060c060c060c060c060c060c060c060c060c0e0c0c0c380c300c0000000000000000"},
+	'М':  {17, "000000000000000000000700c0701c0781c0783c0683c06c6c06c6c0664c066cc062cc0638c0638c0600c0600c0600c00000000000000000000"},
+	'Н':  {15, "0000000000000000180c180c180c180c180c180c1ffc1ffc180c180c180c180c180c180c180c0000000000000000"},
+	'О':  {16, "000000000000000003c00ff01838301c300c600c600e600e600e600e700c300c38181e780fe00000000000000000"},
+	'П':  {15, "00000000000000001ffc1ffc180c180c180c180c180c180c180c180c180c180c180c180c180c0000000000000000"},
+	'Р':  {12, "0000000000003f03fc30e3063063063063fe3fc300300300300300300000000000000"},
+	'С':  {14, "000000000000000000f803fe07060c000c00180018001800180018001c000c000e02079e01fc0000000000000000"},
+	'Т':  {12, "000000000000ffffff060060060060060060060060060060060060060000000000000"},
+	'У':  {12, "00000000000040260660630e30c38c1981980f00f00700600603c0380000000000000"},
+	'Ф':  {17, "00000000000000000000001000018001ff003ff80718c0618e0c1860c1860c1860e18e0618c03ff801ff0001800018000000000000000000000"},
+	'Х':  {14, "000000000000000008040c0c06180618033001e001e000c001e00360033006180c180c0c18060000000000000000"},
+	'Ц':  {16, "000000000000000030183018301830183018301830183018301830183018301830183ffe3ffe0006000600060000"},
+	'Ч':  {14, "000000000000000008080c0c0c0c0c0c0c0c0c0c0c0c0ffc07fc000c000c000c000c000c000c0000000000000000"},
+	'Ш':  {21, "00000000000000000000000006040406060e06060e06060e06060e06060e06060e06060e06060e06060e06060e06060e06060e07fffe07fffe000000000000000000000000"},
+	'Щ':  {22, "0000000000000000000000000c08080c0c1c0c0c1c0c0c1c0c0c1c0c0c1c0c0c1c0c0c1c0c0c1c0c0c1c0c0c1c0c0c1c0c0c1c0ffffe0ffffe000006000006000006000000"},
+	'Ъ':  {17, "000000000000000000000f8001fc0000c0000c0000c0000c0000fe000ff800c1c00c0c00c0c00c0c00c0c00ff800ff000000000000000000000"},
+	'Ы':  {18, "000000000000000000000c0080c00c0c00c0c00c0c00c0c00c0fe0c0ff8c0c1cc0c0cc0c0cc0c0cc0c0cc0ff8c0ff0c00000000000000000000"},
+	'Ь':  {14, "00000000000000000c000c000c000c000c000c000fe00ff80c1c0c0c0c0c0c0c0c0c0ff80ff00000000000000000"},
+	'Э':  {14, "000000000000000007c01ff01818000c000c000e000607fe07fe0006000c000c101c1e780fe00000000000000000"},
+	'Ю':  {22, "0000000000000000000000000c07c00c0ff00c18380c301c0c700c0c600c0c600c0fe00e0fe00e0c600c0c600c0c301c0c38180c1e700c0fe0000000000000000000000000"},
+	'Я':  {14, "000000000000000001fc07fc0e0c0c0c0c0c0c0c0e0c07fc01fc018c030c070c060c0c0c0c0c0000000000000000"},
+	'а':  {12, "0000000000000000000000003f831c00c0041fe3fe60660e60e31e3f6000000000000"},
+	'б':  {12, "0000000000040fc1c03006007f879c70e60660660660660630639c1f8000000000000"},
+	'в':  {12, "0000000000000000000000003f83fc30c30c3f83f830c30630e3fc3f8000000000000"},
+	'г':  {11, "0000000000000000000000001fe1fc180180180180180180180180180000000000000"},
+	'д':  {14, "0000000000000000000000000000000003f803f803180318031803180218061806181ffc1ffe1806180610060000"},
+	'е':  {12, "0000000000000000000000000f839c3066067fe7fe6006003003860fc000000000000"},
+	'ж':  {18, "00000000000000000000000000000000000000000c30c063180333803b7001b6003ff0037b0063180c30c0c30c1830600000000000000000000"},
+	'з':  {11, "0000000000000000000000001f819c00c00c0780f800c00400c31c3f8000000000000"},
+	'и':  {13, "00000000000000000000000000000000060c061c063c063c066c064c06cc078c078c070c060c0000000000000000"},
+	'й':  {13, "0000000000000000011001f000e00000060c061c063c063c066c064c06cc078c078c070c060c0000000000000000"},
+	'к':  {12, "00000000000000000000000030c3183303603e03f03b031830c30c306000000000000"},
+	'л':  {13, "0000000000000000000000000000000001fc01fc018c018c018c030c030c030c030c0e0c0c0c0000000000000000"},
+	'м':  {15, "000000000000000000000000000000001c1c1c1c1e1c1e3c1a2c1b6c194c19cc19cc180c180c0000000000000000"},
+	'н':  {13, "00000000000000000000000000000000060c060c060c060c07fc07fc060c060c060c060c060c0000000000000000"},
+	'о':  {12, "0000000000000000000000001f839c30e60660660660660630e39c1f8000000000000"},
+	'п':  {13, "0000000000000000000000000000000007fc07fc060c060c060c060c060c060c060c060c060c0000000000000000"},
+	'р':  {13, "0000000000000000000000000000000006f00798060c060c060606060606060c060c079c06f80600060006000600"},
+	'с':  {11, "00000000000000000000000007e1e61803003003003003001801c60fe000000000000"},
+	'т':  {12, "000000000000000000000000ffe7fe060060060060060060060060060000000000000"},
+	'у':  {12, "00000000000000000000000060660620c30c3081981980b00f00e00600600c01c0780"},
+	'ф':  {17, "000000000000000000000010000180001800018003df8077dc063860c1860c1860c1860c1860c18606386077cc03df800180001800018000180"},
+	'х':  {12, "00000000000000000000000060e30c1981f00f00600f01b839830c606000000000000"},
+	'ц':  {14, "000000000000000000000000000000000c180c180c180c180c180c180c180c180c180ffc0ffe0006000600060000"},
+	'ч':  {12, "00000000000000000000000060c60c60c60c30c3fc07c00c00c00c00c000000000000"},
+	'ш':  {18, "00000000000000000000000000000000000000000c3060c3060c3060c3060c3060c3060c3060c3060c3060fffe0fffe00000000000000000000"},
+	'щ':  {19, "00000000000000000000000000000000000000001860c1860c1860c1860c1860c1860c1860c1860c1860c1fffe1fffe00006000060000200000"},
+	'ъ':  {14, "000000000000000000000000000000003f001f000300030003f003fc030e0306030603fe03f80000000000000000"},
+	'ы':  {16, "00000000000000000000000000000000300c300c300c300c3e0c3fcc30cc306c30ec3fcc3f8c0000000000000000"},
+	'ь':  {12, "0000000000000000000000003003003003003e03fc30c30630e3fc3f8000000000000"},
+	'э':  {11, "0000000000000000000000003f033800c00c0fe1fe00600600c31c3f0000000000000"},
+	'ю':  {17, "0000000000000000000000000000000000000000061f00639c0660c0660607e0607e0606606066060660c0639c061f800000000000000000000"},
+	'я':  {12, "0000000000000000000000001fc3fc30c30c30c1fc0fc0cc18c30c60c000000000000"},
+	'Ё':  {13, "000001b80110000007fc07fc060006000600060007fc07fc0600060006000600060007fc07fc0000000000000000"},
+	'ё':  {12, "0000000000001d80880000000f839c3066067fe7fe6006003003860fc000000000000"},
+	'№':  {21, "0000000000000000000000000600800701c007830007830006c30006c30006633c06632c063324063324061b3c061f18060f000e0f001c077e000000000000000000000000"},
+	'«':  {12, "0000000000000000000000000000440cc1983303301980cc044000000000000000000"},
+	'»':  {12, "0000000000000000000000000002203301980cc0c618c338220000000000000000000"},
+	'–':  {10, "0000000000000000000000000000000000000001fe000000000000000000000000000"},
+	'—':  {20, "000000000000000000000000000000000000000000000000000000000000000007fffe000000000000000000000000000000000000000000000"},
+	'…':  {20, "00000000000000000000000000000000000000000000000000000000000000000000000000000000106083861c3861c00000000000000000000"},
+	'°':  {10, "0000000000000780fc0840840cc078000000000000000000000000000000000000000"},
+	'±':  {17, "0000000000000000000000000000000010000300003000030007ffc07ffc003000030000300001000000007ffc07ffc00000000000000000000"},
+}
+
+// labelGlyphFallback is drawn for characters without a glyph.
+const labelGlyphFallback = '?'
+
+func labelGlyphFor(r rune) labelGlyph {
+	if g, ok := labelGlyphs[r]; ok {
+		return g
+	}
+	return labelGlyphs[labelGlyphFallback]
+}
+
+// labelTextWidth is the width of text in pixels.
+func labelTextWidth(text string) int {
+	width := 0
+	for _, r := range text {
+		width += labelGlyphFor(r).advance
+	}
+	return width
+}
+
+// drawLabelText draws text in black with its top left corner at x, y.
+func drawLabelText(img *image.Gray, x, y int, text string) {
+	for _, r := range text {
+		g := labelGlyphFor(r)
+		digits := (g.advance + 3) / 4
+		for row := 0; row < labelFontHeight; row++ {
+			bits, err := strconv.ParseUint(g.rows[row*digits:(row+1)*digits], 16, 32)
+			if err != nil {
+				continue
+			}
+			for col := 0; col < g.advance; col++ {
+				if bits>>(g.advance-1-col)&1 == 1 {
+					img.SetGray(x+col, y+row, color.Gray{})
+				}
+			}
+		}
+		x += g.advance
+	}
+}
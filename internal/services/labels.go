@@ -0,0 +1,307 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/grintheone/foxygen-server/internal/repository"
+)
+
+const (
+	// A label is 58x40 mm printed at 203 dpi, the resolution of common
+	// thermal label printers
+	labelWidthMM  = 58
+	labelHeightMM = 40
+	labelWidth    = 464
+	labelHeight   = 320
+	labelMargin   = 12
+	// labelQuietZone is the blank border a QR code needs, in modules
+	labelQuietZone = 4
+	// labelMinTextWidth keeps room for the text next to the QR code
+	labelMinTextWidth = 200
+	// labelSerialLines is how many lines a long serial number may wrap to
+	labelSerialLines = 2
+
+	// Batch sheets are A4 with 3 columns of 7 labels, centered
+	sheetWidthMM  = 210
+	sheetHeightMM = 297
+	sheetColumns  = 3
+	sheetRows     = 7
+)
+
+var (
+	ErrEmptyScan       = errors.New("scanned code is empty")
+	ErrAmbiguousSerial = errors.New("several devices have this serial number, scan the label instead")
+)
+
+var uuidPattern = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+
+type LabelService struct {
+	repo     repository.DevicesRepository
+	labelURL string
+}
+
+func NewLabelService(repo repository.DevicesRepository, labelURL string) *LabelService {
+	return &LabelService{repo: repo, labelURL: labelURL}
+}
+
+// labelPayload is what the QR code of a device holds: the page labels open
+// with the device id added to its query, or the id alone when no valid page
+// is configured.
+func (s *LabelService) labelPayload(id uuid.UUID) string {
+	if s.labelURL != "" {
+		if page, err := url.Parse(s.labelURL); err == nil {
+			query := page.Query()
+			query.Set("device", id.String())
+			page.RawQuery = query.Encode()
+			return page.String()
+		}
+	}
+	return "urn:uuid:" + id.String()
+}
+
+// wrapLabelText splits text into lines no wider than width, breaking words
+// that don't fit on a line of their own. Text beyond maxLines is cut with an
+// ellipsis.
+func wrapLabelText(text string, width, maxLines int) []string {
+	var lines []string
+	var line string
+
+	for _, word := range strings.Fields(text) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if labelTextWidth(candidate) <= width {
+			line = candidate
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+		line = ""
+		for _, r := range word {
+			if line != "" && labelTextWidth(line+string(r)) > width {
+				lines = append(lines, line)
+				line = ""
+			}
+			line += string(r)
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+
+	if len(lines) > maxLines {
+		lines = lines[:maxLines]
+		last := []rune(lines[maxLines-1])
+		for len(last) > 0 && labelTextWidth(string(last)+"…") > width {
+			last = last[:len(last)-1]
+		}
+		lines[maxLines-1] = strings.TrimRight(string(last), " ") + "…"
+	}
+
+	return lines
+}
+
+// renderLabel draws the QR code on the left of the label and the
+// classificator title with the serial number on the right.
+func (s *LabelService) renderLabel(label *models.DeviceLabel) (*image.Gray, error) {
+	modules, err := encodeQR([]byte(s.labelPayload(label.ID)))
+	if err != nil {
+		return nil, err
+	}
+
+	img := image.NewGray(image.Rect(0, 0, labelWidth, labelHeight))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+
+	n := len(modules) + 2*labelQuietZone
+	scale := min(labelHeight/n, (labelWidth-labelMinTextWidth)/n)
+	qrSize := n * scale
+	top := (labelHeight - qrSize) / 2
+
+	for y, row := range modules {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			px, py := (labelQuietZone+x)*scale, top+(labelQuietZone+y)*scale
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetGray(px+dx, py+dy, color.Gray{})
+				}
+			}
+		}
+	}
+
+	textX := qrSize
+	textWidth := labelWidth - labelMargin - textX
+
+	var serial []string
+	if label.SerialNumber != "" {
+		serial = wrapLabelText("S/N "+label.SerialNumber, textWidth, labelSerialLines)
+	}
+	// one blank line between the title and the serial number
+	titleLines := (labelHeight-2*labelMargin)/labelFontHeight - len(serial) - 1
+
+	y := labelMargin
+	for _, line := range wrapLabelText(label.ClassificatorTitle, textWidth, titleLines) {
+		drawLabelText(img, textX, y, line)
+		y += labelFontHeight
+	}
+
+	y = labelHeight - labelMargin - len(serial)*labelFontHeight
+	for _, line := range serial {
+		drawLabelText(img, textX, y, line)
+		y += labelFontHeight
+	}
+
+	return img, nil
+}
+
+func (s *LabelService) deviceLabel(ctx context.Context, id uuid.UUID) (*image.Gray, error) {
+	label, err := s.repo.GetDeviceLabel(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("service error fetching device label: %w", err)
+	}
+	if label == nil {
+		return nil, ErrNotFound
+	}
+
+	img, err := s.renderLabel(label)
+	if err != nil {
+		return nil, fmt.Errorf("service error rendering device label: %w", err)
+	}
+
+	return img, nil
+}
+
+func (s *LabelService) DeviceLabelPNG(ctx context.Context, id uuid.UUID) ([]byte, error) {
+	img, err := s.deviceLabel(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("service error encoding device label: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// DeviceLabelPDF returns a one page PDF the size of the label.
+func (s *LabelService) DeviceLabelPDF(ctx context.Context, id uuid.UUID) ([]byte, error) {
+	img, err := s.deviceLabel(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	width, height := labelWidthMM*pointsPerMM, labelHeightMM*pointsPerMM
+
+	pdf, err := writePDF(width, height, [][]pdfImage{{{img: img, width: width, height: height}}})
+	if err != nil {
+		return nil, fmt.Errorf("service error encoding device label: %w", err)
+	}
+
+	return pdf, nil
+}
+
+// ClientLabelSheet returns A4 sheets with the labels of every device under
+// an active agreement of the client.
+func (s *LabelService) ClientLabelSheet(ctx context.Context, clientID uuid.UUID) ([]byte, error) {
+	labels, err := s.repo.ListClientDeviceLabels(ctx, clientID)
+	if err != nil {
+		return nil, fmt.Errorf("service error fetching device labels: %w", err)
+	}
+	if len(labels) == 0 {
+		return nil, ErrNotFound
+	}
+
+	pageWidth, pageHeight := sheetWidthMM*pointsPerMM, sheetHeightMM*pointsPerMM
+	width, height := labelWidthMM*pointsPerMM, labelHeightMM*pointsPerMM
+	left := (pageWidth - sheetColumns*width) / 2
+	top := (pageHeight - sheetRows*height) / 2
+
+	var pages [][]pdfImage
+	for i, label := range labels {
+		slot := i % (sheetColumns * sheetRows)
+		if slot == 0 {
+			pages = append(pages, nil)
+		}
+
+		img, err := s.renderLabel(label)
+		if err != nil {
+			return nil, fmt.Errorf("service error rendering device label: %w", err)
+		}
+
+		column, row := slot%sheetColumns, slot/sheetColumns
+		pages[len(pages)-1] = append(pages[len(pages)-1], pdfImage{
+			img:    img,
+			x:      left + float64(column)*width,
+			y:      pageHeight - top - float64(row+1)*height,
+			width:  width,
+			height: height,
+		})
+	}
+
+	pdf, err := writePDF(pageWidth, pageHeight, pages)
+	if err != nil {
+		return nil, fmt.Errorf("service error encoding label sheet: %w", err)
+	}
+
+	return pdf, nil
+}
+
+// ResolveDevice finds the device a scanned code refers to. The code is
+// either the payload of a device label, which carries the device id, or a
+// serial number typed or scanned from the nameplate.
+func (s *LabelService) ResolveDevice(ctx context.Context, code string) (*models.DeviceResolution, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return nil, ErrEmptyScan
+	}
+
+	if match := uuidPattern.FindString(code); match != "" {
+		resolution, err := s.repo.GetDeviceResolution(ctx, uuid.MustParse(match))
+		if err != nil {
+			return nil, fmt.Errorf("service error resolving device: %w", err)
+		}
+		if resolution != nil {
+			return resolution, nil
+		}
+	}
+
+	ids, err := s.repo.FindDevicesBySerial(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("service error resolving device: %w", err)
+	}
+	switch {
+	case len(ids) == 0:
+		return nil, ErrNotFound
+	case len(ids) > 1:
+		return nil, ErrAmbiguousSerial
+	}
+
+	resolution, err := s.repo.GetDeviceResolution(ctx, ids[0])
+	if err != nil {
+		return nil, fmt.Errorf("service error resolving device: %w", err)
+	}
+	if resolution == nil {
+		return nil, ErrNotFound
+	}
+
+	return resolution, nil
+}
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestLabelPayload(t *testing.T) {
+	id := uuid.MustParse("3f2c1d9e-8a4b-4c6d-9e0f-1a2b3c4d5e6f")
+
+	tests := []struct {
+		name     string
+		labelURL string
+		want     string
+	}{
+		{"no page", "", "urn:uuid:" + id.String()},
+		{"page", "https://service.foxygen.ru/scan", "https://service.foxygen.ru/scan?device=" + id.String()},
+		{"page with a query", "https://service.foxygen.ru/scan?lang=ru", "https://service.foxygen.ru/scan?device=" + id.String() + "&lang=ru"},
+		{"page with a device", "https://service.foxygen.ru/scan?device=old", "https://service.foxygen.ru/scan?device=" + id.String()},
+		{"page with a fragment", "https://service.foxygen.ru/#/scan", "https://service.foxygen.ru/?device=" + id.String() + "#/scan"},
+		{"invalid page", "://service.foxygen.ru", "urn:uuid:" + id.String()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewLabelService(nil, tt.labelURL)
+			if got := s.labelPayload(id); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
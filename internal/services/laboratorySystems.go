@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/grintheone/foxygen-server/internal/repository"
+)
+
+// LIS protocols a device connection can use.
+const (
+	LISProtocolASTM = "ASTM"
+	LISProtocolHL7  = "HL7"
+)
+
+var (
+	ErrInvalidLISProtocol         = errors.New("protocol must be ASTM or HL7")
+	ErrMissingLISTitle            = errors.New("laboratory system title is required")
+	ErrUnknownLISConnectionTarget = repository.ErrUnknownLISConnectionTarget
+	ErrDuplicateLISConnection     = repository.ErrDuplicateLISConnection
+)
+
+type LaboratorySystemService struct {
+	repo repository.LaboratorySystemRepo
+}
+
+func NewLaboratorySystemService(repo repository.LaboratorySystemRepo) *LaboratorySystemService {
+	return &LaboratorySystemService{repo: repo}
+}
+
+// normalizeLISProtocol accepts protocol names in any case.
+func normalizeLISProtocol(protocol string) (string, error) {
+	protocol = strings.ToUpper(strings.TrimSpace(protocol))
+	switch protocol {
+	case LISProtocolASTM, LISProtocolHL7:
+		return protocol, nil
+	default:
+		return "", ErrInvalidLISProtocol
+	}
+}
+
+func (s *LaboratorySystemService) ListLaboratorySystems(ctx context.Context, search string) ([]*models.LaboratorySystem, error) {
+	systems, err := s.repo.ListLaboratorySystems(ctx, search)
+	if err != nil {
+		return nil, fmt.Errorf("service error listing laboratory systems: %w", err)
+	}
+
+	return systems, nil
+}
+
+// GetLaboratorySystem returns the system with its clients and connections.
+func (s *LaboratorySystemService) GetLaboratorySystem(ctx context.Context, id uuid.UUID) (*models.LaboratorySystemDetails, error) {
+	system, err := s.repo.GetLaboratorySystem(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("service error fetching laboratory system: %w", err)
+	}
+	if system == nil {
+		return nil, ErrNotFound
+	}
+
+	clients, err := s.repo.ListSystemClients(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("service error fetching laboratory system clients: %w", err)
+	}
+
+	connections, err := s.repo.ListSystemConnections(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("service error fetching laboratory system connections: %w", err)
+	}
+
+	return &models.LaboratorySystemDetails{
+		LaboratorySystem: system,
+		ClientList:       clients,
+		ConnectionList:   connections,
+	}, nil
+}
+
+func (s *LaboratorySystemService) CreateLaboratorySystem(ctx context.Context, system models.LaboratorySystem) (*models.LaboratorySystem, error) {
+	system.Title = strings.TrimSpace(system.Title)
+	if system.Title == "" {
+		return nil, ErrMissingLISTitle
+	}
+
+	created, err := s.repo.CreateLaboratorySystem(ctx, system)
+	if err != nil {
+		return nil, fmt.Errorf("service error creating laboratory system: %w", err)
+	}
+
+	return created, nil
+}
+
+func (s *LaboratorySystemService) UpdateLaboratorySystem(ctx context.Context, id uuid.UUID, update models.LaboratorySystemUpdate) (*models.LaboratorySystem, error) {
+	if update.Title != nil {
+		title := strings.TrimSpace(*update.Title)
+		if title == "" {
+			return nil, ErrMissingLISTitle
+		}
+		update.Title = &title
+	}
+
+	updated, err := s.repo.UpdateLaboratorySystem(ctx, id, update)
+	if err != nil {
+		return nil, fmt.Errorf("service error updating laboratory system: %w", err)
+	}
+	if updated == nil {
+		return nil, ErrNotFound
+	}
+
+	return updated, nil
+}
+
+// DeleteLaboratorySystem removes the system. Clients keep existing without
+// one and its device connections are dropped.
+func (s *LaboratorySystemService) DeleteLaboratorySystem(ctx context.Context, actor Actor, id uuid.UUID) error {
+	if err := requireAdmin(actor, "only admins may delete laboratory systems"); err != nil {
+		return err
+	}
+
+	found, err := s.repo.DeleteLaboratorySystem(ctx, id)
+	if err != nil {
+		return fmt.Errorf("service error deleting laboratory system: %w", err)
+	}
+	if !found {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *LaboratorySystemService) CreateConnection(ctx context.Context, connection models.LISConnection) (*models.LISConnection, error) {
+	protocol, err := normalizeLISProtocol(connection.Protocol)
+	if err != nil {
+		return nil, err
+	}
+	connection.Protocol = protocol
+
+	if connection.Parameters == nil {
+		connection.Parameters = models.JSONB{}
+	}
+
+	created, err := s.repo.CreateConnection(ctx, connection)
+	if err != nil {
+		if errors.Is(err, ErrUnknownLISConnectionTarget) || errors.Is(err, ErrDuplicateLISConnection) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("service error creating lis connection: %w", err)
+	}
+
+	return created, nil
+}
+
+func (s *LaboratorySystemService) UpdateConnection(ctx context.Context, id uuid.UUID, update models.LISConnectionUpdate) (*models.LISConnection, error) {
+	if update.Protocol != nil {
+		protocol, err := normalizeLISProtocol(*update.Protocol)
+		if err != nil {
+			return nil, err
+		}
+		update.Protocol = &protocol
+	}
+
+	updated, err := s.repo.UpdateConnection(ctx, id, update)
+	if err != nil {
+		return nil, fmt.Errorf("service error updating lis connection: %w", err)
+	}
+	if updated == nil {
+		return nil, ErrNotFound
+	}
+
+	return updated, nil
+}
+
+func (s *LaboratorySystemService) DeleteConnection(ctx context.Context, id uuid.UUID) error {
+	found, err := s.repo.DeleteConnection(ctx, id)
+	if err != nil {
+		return fmt.Errorf("service error deleting lis connection: %w", err)
+	}
+	if !found {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// ClientSetup is the laboratory system of a client together with how the
+// devices installed there are connected.
+func (s *LaboratorySystemService) ClientSetup(ctx context.Context, clientID uuid.UUID) (*models.ClientLISSetup, error) {
+	system, exists, err := s.repo.GetClientSystem(ctx, clientID)
+	if err != nil {
+		return nil, fmt.Errorf("service error fetching client laboratory system: %w", err)
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	connections, err := s.repo.ListClientConnections(ctx, clientID)
+	if err != nil {
+		return nil, fmt.Errorf("service error fetching client lis connections: %w", err)
+	}
+
+	return &models.ClientLISSetup{
+		LaboratorySystem: system,
+		Connections:      connections,
+	}, nil
+}
@@ -0,0 +1,273 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/grintheone/foxygen-server/internal/repository"
+)
+
+// Brute-force protection thresholds. Usernames get a short grace period of
+// free attempts, then an exponentially growing delay, then a lockout. IP
+// addresses are allowed more failures because several engineers may share
+// one office NAT.
+const (
+	loginFreeAttempts      = 3
+	loginMaxDelay          = time.Minute
+	loginUserLockoutAfter  = 10
+	loginIPLockoutAfter    = 50
+	loginLockoutDuration   = 15 * time.Minute
+	loginFailureWindow     = time.Hour
+	resetUserRequestLimit  = 3
+	resetIPRequestLimit    = 20
+	resetRequestWindow     = time.Hour
+	authAuditDefaultLimit  = 100
+	authAuditMaxLimit      = 1000
+	AuthEventLogin         = "login"
+	AuthEventLoginThrottle = "login_throttled"
+	AuthEventMFAChallenge  = "mfa_challenge"
+	AuthEventLockoutClear  = "lockout_cleared"
+	AuthEventAPIKeyAuth    = "api_key_auth"
+	AuthEventAPIKeyCreate  = "api_key_created"
+	AuthEventAPIKeyRotate  = "api_key_rotated"
+	AuthEventAPIKeyRevoke  = "api_key_revoked"
+	AuthEventImpersonation = "impersonation_started"
+	AuthEventOIDCLogin     = "oidc_login"
+	AuthEventResetThrottle = "password_reset_throttled"
+)
+
+// ThrottledError is returned when a login is refused before the password is
+// checked because the username or the source address is blocked.
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+type LoginGuardService struct {
+	repo repository.AuthAuditRepo
+}
+
+func NewLoginGuardService(repo repository.AuthAuditRepo) *LoginGuardService {
+	return &LoginGuardService{repo}
+}
+
+func usernameThrottleKey(username string) string {
+	return "user:" + strings.ToLower(strings.TrimSpace(username))
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Check refuses the attempt with a ThrottledError while the username or the
+// source address is in a delay or lockout period.
+func (s *LoginGuardService) Check(ctx context.Context, username, ip string) error {
+	keys := []string{usernameThrottleKey(username)}
+	if ip != "" {
+		keys = append(keys, ipThrottleKey(ip))
+	}
+
+	throttles, err := s.repo.GetThrottles(ctx, keys)
+	if err != nil {
+		return fmt.Errorf("service error checking login throttle: %w", err)
+	}
+
+	now := time.Now().UTC()
+	var wait time.Duration
+	for _, t := range throttles {
+		if t.BlockedUntil != nil && t.BlockedUntil.After(now) {
+			wait = max(wait, t.BlockedUntil.Sub(now))
+		}
+	}
+
+	if wait > 0 {
+		s.Audit(ctx, models.AuthAuditEntry{
+			Event:    AuthEventLoginThrottle,
+			Username: username,
+			IP:       ip,
+			Details:  fmt.Sprintf("blocked for %s", wait.Round(time.Second)),
+		})
+		return &ThrottledError{RetryAfter: wait}
+	}
+
+	return nil
+}
+
+// CheckPasswordReset counts a password reset request against the username
+// and the source address and refuses it with a ThrottledError once either
+// made too many within resetRequestWindow. The counters live in
+// login_throttle under their own keys, so lockouts can be cleared the same way.
+func (s *LoginGuardService) CheckPasswordReset(ctx context.Context, username, ip string) error {
+	limits := map[string]int{"reset:" + usernameThrottleKey(username): resetUserRequestLimit}
+	if ip != "" {
+		limits["reset:"+ipThrottleKey(ip)] = resetIPRequestLimit
+	}
+
+	keys := make([]string, 0, len(limits))
+	for key := range limits {
+		keys = append(keys, key)
+	}
+
+	throttles, err := s.repo.GetThrottles(ctx, keys)
+	if err != nil {
+		return fmt.Errorf("service error checking password reset throttle: %w", err)
+	}
+
+	now := time.Now().UTC()
+	var wait time.Duration
+	for _, t := range throttles {
+		if t.BlockedUntil != nil && t.BlockedUntil.After(now) {
+			wait = max(wait, t.BlockedUntil.Sub(now))
+		}
+	}
+
+	if wait > 0 {
+		s.Audit(ctx, models.AuthAuditEntry{
+			Event:    AuthEventResetThrottle,
+			Username: username,
+			IP:       ip,
+			Details:  fmt.Sprintf("blocked for %s", wait.Round(time.Second)),
+		})
+		return &ThrottledError{RetryAfter: wait}
+	}
+
+	for key, limit := range limits {
+		requests, err := s.repo.RegisterFailure(ctx, key, resetRequestWindow)
+		if err != nil {
+			return fmt.Errorf("service error counting password reset request: %w", err)
+		}
+
+		if requests >= limit {
+			if err := s.repo.SetBlockedUntil(ctx, key, time.Now().Add(resetRequestWindow)); err != nil {
+				return fmt.Errorf("service error blocking password reset requests: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// blockDuration returns how long a key stays blocked after its n-th failure.
+func blockDuration(failures, lockoutAfter int) time.Duration {
+	if failures >= lockoutAfter {
+		return loginLockoutDuration
+	}
+	if failures < loginFreeAttempts {
+		return 0
+	}
+
+	delay := time.Duration(math.Pow(2, float64(failures-loginFreeAttempts))) * time.Second
+	return min(delay, loginMaxDelay)
+}
+
+func (s *LoginGuardService) registerFailure(ctx context.Context, key string, lockoutAfter int) error {
+	failures, err := s.repo.RegisterFailure(ctx, key, loginFailureWindow)
+	if err != nil {
+		return err
+	}
+
+	if d := blockDuration(failures, lockoutAfter); d > 0 {
+		return s.repo.SetBlockedUntil(ctx, key, time.Now().Add(d))
+	}
+
+	return nil
+}
+
+// RecordFailure counts a failed attempt against both the username and the
+// source address and writes it to the audit log.
+func (s *LoginGuardService) RecordFailure(ctx context.Context, username, ip, reason string) {
+	if err := s.registerFailure(ctx, usernameThrottleKey(username), loginUserLockoutAfter); err != nil {
+		log.Printf("failed to record login failure for %q: %v", username, err)
+	}
+	if ip != "" {
+		if err := s.registerFailure(ctx, ipThrottleKey(ip), loginIPLockoutAfter); err != nil {
+			log.Printf("failed to record login failure for %s: %v", ip, err)
+		}
+	}
+
+	s.Audit(ctx, models.AuthAuditEntry{
+		Event:    AuthEventLogin,
+		Username: username,
+		IP:       ip,
+		Details:  reason,
+	})
+}
+
+// RecordSuccess resets the username counter and logs the login. The address
+// counter is left alone so one valid account can't be used to reset it.
+func (s *LoginGuardService) RecordSuccess(ctx context.Context, username string, userID uuid.UUID, ip string) {
+	if _, err := s.repo.ClearThrottle(ctx, usernameThrottleKey(username)); err != nil {
+		log.Printf("failed to reset login throttle for %q: %v", username, err)
+	}
+
+	s.Audit(ctx, models.AuthAuditEntry{
+		Event:    AuthEventLogin,
+		Success:  true,
+		Username: username,
+		UserID:   &userID,
+		IP:       ip,
+	})
+}
+
+// Audit writes an entry to the auth audit log. Failures are only logged so
+// that a broken audit table never blocks authentication.
+func (s *LoginGuardService) Audit(ctx context.Context, entry models.AuthAuditEntry) {
+	if entry.ActorType == "" {
+		entry.ActorType = ActorTypeUser
+	}
+
+	if err := s.repo.AddAuditEntry(ctx, entry); err != nil {
+		log.Printf("failed to write auth audit entry %q: %v", entry.Event, err)
+	}
+}
+
+func (s *LoginGuardService) ListLockouts(ctx context.Context) ([]*models.LoginThrottle, error) {
+	throttles, err := s.repo.ListActiveThrottles(ctx, loginFreeAttempts)
+	if err != nil {
+		return nil, fmt.Errorf("service error listing lockouts: %w", err)
+	}
+
+	return throttles, nil
+}
+
+// ClearLockout removes the throttle state of a key and returns false if there was none.
+func (s *LoginGuardService) ClearLockout(ctx context.Context, actor Actor, key string) (bool, error) {
+	cleared, err := s.repo.ClearThrottle(ctx, key)
+	if err != nil {
+		return false, fmt.Errorf("service error clearing lockout: %w", err)
+	}
+
+	if cleared {
+		s.Audit(ctx, models.AuthAuditEntry{
+			Event:   AuthEventLockoutClear,
+			Success: true,
+			UserID:  &actor.UserID,
+			Details: key,
+		})
+	}
+
+	return cleared, nil
+}
+
+func (s *LoginGuardService) ListAuditEntries(ctx context.Context, username string, limit, offset int) ([]*models.AuthAuditEntry, error) {
+	if limit <= 0 {
+		limit = authAuditDefaultLimit
+	}
+	limit = min(limit, authAuditMaxLimit)
+
+	entries, err := s.repo.ListAuditEntries(ctx, username, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("service error listing auth audit log: %w", err)
+	}
+
+	return entries, nil
+}
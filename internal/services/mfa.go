@@ -0,0 +1,258 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/repository"
+)
+
+const recoveryCodeCount = 10
+
+var (
+	ErrInvalidMFACode        = errors.New("invalid two-factor code")
+	ErrMFAAlreadyEnabled     = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnabled         = errors.New("two-factor authentication is not enabled")
+	ErrMFAEnrollmentRequired = errors.New("two-factor enrollment is required for this account")
+)
+
+// TOTPEnrollment is returned when enrollment starts. The URI is meant to be
+// rendered as a QR code; the secret is shown for manual entry.
+type TOTPEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioningUri"`
+}
+
+type MFAStatus struct {
+	Enabled           bool `json:"enabled"`
+	Required          bool `json:"required"`
+	RecoveryCodesLeft int  `json:"recoveryCodesLeft"`
+}
+
+type MFAService struct {
+	repo          repository.MFARepo
+	requiredRoles []string
+	issuer        string
+}
+
+// NewMFAService creates the service. Accounts holding any of requiredRoles
+// can't sign in or refresh tokens without a confirmed second factor.
+func NewMFAService(repo repository.MFARepo, requiredRoles []string, issuer string) *MFAService {
+	return &MFAService{repo: repo, requiredRoles: requiredRoles, issuer: issuer}
+}
+
+// Required reports whether any of the roles makes a second factor mandatory.
+func (s *MFAService) Required(roles []string) bool {
+	for _, role := range roles {
+		if slices.Contains(s.requiredRoles, role) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *MFAService) Status(ctx context.Context, actor Actor) (*MFAStatus, error) {
+	state, err := s.repo.GetTOTPState(ctx, actor.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("service error fetching mfa status: %w", err)
+	}
+	if state == nil {
+		return nil, ErrNotFound
+	}
+
+	status := MFAStatus{
+		Enabled:  state.Enabled,
+		Required: s.Required(actor.Roles),
+	}
+
+	if state.Enabled {
+		status.RecoveryCodesLeft, err = s.repo.CountRecoveryCodes(ctx, actor.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("service error fetching mfa status: %w", err)
+		}
+	}
+
+	return &status, nil
+}
+
+// BeginEnrollment generates a new secret for the account. Calling it again
+// before confirming replaces the previous secret.
+func (s *MFAService) BeginEnrollment(ctx context.Context, userID uuid.UUID, username string) (*TOTPEnrollment, error) {
+	state, err := s.repo.GetTOTPState(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("service error starting mfa enrollment: %w", err)
+	}
+	if state == nil {
+		return nil, ErrNotFound
+	}
+	if state.Enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate totp secret: %w", err)
+	}
+
+	if err := s.repo.SetPendingTOTPSecret(ctx, userID, secret); err != nil {
+		return nil, fmt.Errorf("service error starting mfa enrollment: %w", err)
+	}
+
+	return &TOTPEnrollment{
+		Secret:          secret,
+		ProvisioningURI: totpProvisioningURI(s.issuer, username, secret),
+	}, nil
+}
+
+// ConfirmEnrollment enables the second factor once the user proves the app
+// was set up correctly and returns the recovery codes. They are shown once.
+func (s *MFAService) ConfirmEnrollment(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	state, err := s.repo.GetTOTPState(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("service error confirming mfa enrollment: %w", err)
+	}
+	if state == nil {
+		return nil, ErrNotFound
+	}
+	if state.Enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if state.Secret == nil {
+		return nil, ErrMFANotEnabled
+	}
+
+	step, ok := verifyTOTP(*state.Secret, normalizeCode(code), time.Now(), 0)
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.EnableTOTP(ctx, userID, step, hashes); err != nil {
+		return nil, fmt.Errorf("service error confirming mfa enrollment: %w", err)
+	}
+
+	return codes, nil
+}
+
+// Verify accepts either a current TOTP code or an unused recovery code.
+func (s *MFAService) Verify(ctx context.Context, userID uuid.UUID, code string) error {
+	state, err := s.repo.GetTOTPState(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("service error verifying mfa code: %w", err)
+	}
+	if state == nil || !state.Enabled || state.Secret == nil {
+		return ErrMFANotEnabled
+	}
+
+	code = normalizeCode(code)
+
+	if step, ok := verifyTOTP(*state.Secret, code, time.Now(), state.LastStep); ok {
+		advanced, err := s.repo.AdvanceTOTPStep(ctx, userID, step)
+		if err != nil {
+			return fmt.Errorf("service error verifying mfa code: %w", err)
+		}
+		if !advanced {
+			return ErrInvalidMFACode
+		}
+		return nil
+	}
+
+	used, err := s.repo.UseRecoveryCode(ctx, userID, hashRecoveryCode(code))
+	if err != nil {
+		return fmt.Errorf("service error verifying mfa code: %w", err)
+	}
+	if !used {
+		return ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+// Disable turns the second factor off after checking a code. Accounts whose
+// roles require it can only have it reset by an administrator.
+func (s *MFAService) Disable(ctx context.Context, actor Actor, code string) error {
+	if s.Required(actor.Roles) {
+		return forbidden("two-factor authentication is mandatory for your role")
+	}
+
+	if err := s.Verify(ctx, actor.UserID, code); err != nil {
+		return err
+	}
+
+	if err := s.repo.DisableTOTP(ctx, actor.UserID); err != nil {
+		return fmt.Errorf("service error disabling mfa: %w", err)
+	}
+
+	return nil
+}
+
+// Reset removes the second factor of another account, e.g. after a lost
+// phone. Accounts that require it will be asked to enroll on next login.
+func (s *MFAService) Reset(ctx context.Context, userID uuid.UUID) error {
+	if err := s.repo.DisableTOTP(ctx, userID); err != nil {
+		return fmt.Errorf("service error resetting mfa: %w", err)
+	}
+
+	return nil
+}
+
+func (s *MFAService) RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	if err := s.Verify(ctx, userID, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, fmt.Errorf("service error regenerating recovery codes: %w", err)
+	}
+
+	return codes, nil
+}
+
+// normalizeCode strips the separators users tend to type along with codes.
+func normalizeCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer(" ", "", "-", "").Replace(code)
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateRecoveryCodes returns codes formatted as "xxxxx-xxxxx" together
+// with the hashes to store. Hashes are taken over the normalized form.
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for range recoveryCodeCount {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+
+		code := strings.ToLower(totpEncoding.EncodeToString(raw))[:10]
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
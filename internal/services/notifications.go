@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/grintheone/foxygen-server/internal/repository"
+)
+
+const (
+	NotificationTicketClosed = "ticket_closed"
+
+	notificationMailTimeout = 30 * time.Second
+)
+
+type NotificationService struct {
+	repo   repository.NotificationRepo
+	mailer Mailer
+}
+
+func NewNotificationService(r repository.NotificationRepo, mailer Mailer) *NotificationService {
+	return &NotificationService{repo: r, mailer: mailer}
+}
+
+// Notify stores a notification for every user and emails those with an
+// address in the background. Mail failures are only logged.
+func (s *NotificationService) Notify(ctx context.Context, userIDs []uuid.UUID, kind string, referenceID *uuid.UUID, subject, message string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	recipients, err := s.repo.GetRecipients(ctx, userIDs)
+	if err != nil {
+		return fmt.Errorf("service error fetching notification recipients: %w", err)
+	}
+
+	notifications := make([]models.Notification, 0, len(recipients))
+	for _, recipient := range recipients {
+		notifications = append(notifications, models.Notification{
+			UserID:      recipient.UserID,
+			Kind:        kind,
+			ReferenceID: referenceID,
+			Message:     message,
+		})
+	}
+
+	if err := s.repo.AddNotifications(ctx, notifications); err != nil {
+		return fmt.Errorf("service error storing notifications: %w", err)
+	}
+
+	if s.mailer == nil {
+		return nil
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), notificationMailTimeout)
+		defer cancel()
+
+		for _, recipient := range recipients {
+			if recipient.Email == "" {
+				continue
+			}
+			if err := s.mailer.Send(ctx, recipient.Email, subject, message); err != nil {
+				log.Printf("failed to email notification to %s: %v", recipient.UserID, err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (s *NotificationService) ListNotifications(ctx context.Context, userID uuid.UUID, unreadOnly bool, limit, offset int) ([]*models.Notification, error) {
+	notifications, err := s.repo.ListNotifications(ctx, userID, unreadOnly, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("service error listing notifications: %w", err)
+	}
+
+	return notifications, nil
+}
+
+func (s *NotificationService) MarkRead(ctx context.Context, userID, id uuid.UUID) error {
+	found, err := s.repo.MarkRead(ctx, userID, id)
+	if err != nil {
+		return fmt.Errorf("service error marking notification read: %w", err)
+	}
+	if !found {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *NotificationService) MarkAllRead(ctx context.Context, userID uuid.UUID) (int64, error) {
+	count, err := s.repo.MarkAllRead(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("service error marking notifications read: %w", err)
+	}
+
+	return count, nil
+}
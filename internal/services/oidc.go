@@ -0,0 +1,579 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/config"
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/grintheone/foxygen-server/internal/repository"
+)
+
+// OIDCStateTTL is how long a login started with BeginLogin may take to complete
+const OIDCStateTTL = 10 * time.Minute
+
+const (
+	oidcDiscoveryTTL   = time.Hour
+	oidcJWKSRefreshMin = time.Minute
+	oidcHTTPTimeout    = 10 * time.Second
+	// oidcUsernameAttempts bounds the suffixes tried when a provisioned
+	// username is already taken
+	oidcUsernameAttempts = 5
+)
+
+var (
+	ErrInvalidOIDCState = repository.ErrInvalidOIDCState
+	ErrOIDCNoAccount    = errors.New("no account is linked to this identity")
+	ErrOIDCLogin        = errors.New("single sign-on failed")
+)
+
+// claimRule maps a claim value to a role or a department ID.
+// The textual form is "claim:value=target".
+type claimRule struct {
+	Claim  string
+	Value  string
+	Target string
+}
+
+func parseClaimRules(rules []string) ([]claimRule, error) {
+	parsed := make([]claimRule, 0, len(rules))
+	for _, rule := range rules {
+		match, target, ok := strings.Cut(rule, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid claim rule %q", rule)
+		}
+		claim, value, ok := strings.Cut(match, ":")
+		if !ok || claim == "" || target == "" {
+			return nil, fmt.Errorf("invalid claim rule %q", rule)
+		}
+		parsed = append(parsed, claimRule{Claim: claim, Value: value, Target: target})
+	}
+
+	return parsed, nil
+}
+
+// matches reports whether the claim equals the rule value or, for list
+// claims such as "groups", contains it.
+func (r claimRule) matches(claims jwt.MapClaims) bool {
+	switch value := claims[r.Claim].(type) {
+	case string:
+		return value == r.Value
+	case []any:
+		for _, item := range value {
+			if s, ok := item.(string); ok && s == r.Value {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcProvider caches the discovery document and signing keys of the IdP.
+type oidcProvider struct {
+	mu           sync.Mutex
+	discovery    *oidcDiscovery
+	discoveredAt time.Time
+	keys         map[string]any
+	keysFetched  time.Time
+}
+
+// OIDCService implements the authorization code flow with PKCE against the
+// configured identity provider. Any issuer URL works, including a mock IdP on
+// http://localhost, as long as it serves /.well-known/openid-configuration.
+type OIDCService struct {
+	cfg             config.OIDCConfig
+	repo            repository.OIDCRepo
+	accountService  *AccountService
+	authService     *AuthService
+	client          *http.Client
+	provider        *oidcProvider
+	roleRules       []claimRule
+	departmentRules []claimRule
+	defaultDept     *uuid.UUID
+}
+
+// NewOIDCService returns nil when no issuer is configured.
+func NewOIDCService(cfg config.OIDCConfig, repo repository.OIDCRepo, as *AccountService, auth *AuthService) (*OIDCService, error) {
+	if cfg.IssuerURL == "" {
+		return nil, nil
+	}
+	if cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, errors.New("OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required with OIDC_ISSUER_URL")
+	}
+
+	roleRules, err := parseClaimRules(cfg.RoleRules)
+	if err != nil {
+		return nil, err
+	}
+
+	departmentRules, err := parseClaimRules(cfg.DepartmentRules)
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range departmentRules {
+		if _, err := uuid.Parse(rule.Target); err != nil {
+			return nil, fmt.Errorf("department rule %s:%s must map to a department ID", rule.Claim, rule.Value)
+		}
+	}
+
+	s := &OIDCService{
+		cfg:             cfg,
+		repo:            repo,
+		accountService:  as,
+		authService:     auth,
+		client:          &http.Client{Timeout: oidcHTTPTimeout},
+		provider:        &oidcProvider{},
+		roleRules:       roleRules,
+		departmentRules: departmentRules,
+	}
+	s.cfg.IssuerURL = strings.TrimSuffix(cfg.IssuerURL, "/")
+
+	if cfg.DefaultDepartment != "" {
+		department, err := uuid.Parse(cfg.DefaultDepartment)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OIDC_DEFAULT_DEPARTMENT: %w", err)
+		}
+		s.defaultDept = &department
+	}
+
+	return s, nil
+}
+
+func randomURLToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func (s *OIDCService) getJSON(ctx context.Context, endpoint string, dst any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %s", endpoint, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(dst)
+}
+
+func (s *OIDCService) discover(ctx context.Context) (*oidcDiscovery, error) {
+	p := s.provider
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil && time.Since(p.discoveredAt) < oidcDiscoveryTTL {
+		return p.discovery, nil
+	}
+
+	var discovery oidcDiscovery
+	if err := s.getJSON(ctx, s.cfg.IssuerURL+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, fmt.Errorf("oidc discovery failed: %w", err)
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != s.cfg.IssuerURL {
+		return nil, fmt.Errorf("oidc discovery returned issuer %q", discovery.Issuer)
+	}
+
+	p.discovery = &discovery
+	p.discoveredAt = time.Now()
+
+	return p.discovery, nil
+}
+
+// signingKey returns the IdP key with the given kid, refetching the key set
+// when the kid is unknown (the IdP rotated its keys).
+func (s *OIDCService) signingKey(ctx context.Context, jwksURI, kid string) (any, error) {
+	p := s.provider
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+
+	if time.Since(p.keysFetched) < oidcJWKSRefreshMin {
+		return nil, fmt.Errorf("unknown oidc signing key %q", kid)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := s.getJSON(ctx, jwksURI, &set); err != nil {
+		return nil, fmt.Errorf("failed to fetch oidc keys: %w", err)
+	}
+
+	keys := map[string]any{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		switch k.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil {
+				continue
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch k.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+			x, errX := base64.RawURLEncoding.DecodeString(k.X)
+			y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		}
+	}
+
+	p.keys = keys
+	p.keysFetched = time.Now()
+
+	key, ok := keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown oidc signing key %q", kid)
+	}
+
+	return key, nil
+}
+
+// BeginLogin stores a new login state and returns the IdP URL to send the user to
+// together with the state, which the caller must bind to the browser.
+func (s *OIDCService) BeginLogin(ctx context.Context) (string, string, error) {
+	discovery, err := s.discover(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := randomURLToken()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomURLToken()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := randomURLToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	err = s.repo.SaveState(ctx, repository.OIDCLoginState{
+		State:        state,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		ExpiresAt:    time.Now().UTC().Add(OIDCStateTTL),
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("service error starting oidc login: %w", err)
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", s.cfg.ClientID)
+	params.Set("redirect_uri", s.cfg.RedirectURL)
+	params.Set("scope", strings.Join(s.cfg.Scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	params.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return discovery.AuthorizationEndpoint + separator + params.Encode(), state, nil
+}
+
+// exchangeCode redeems the authorization code and returns the verified ID token claims.
+func (s *OIDCService) exchangeCode(ctx context.Context, code string, loginState *repository.OIDCLoginState) (jwt.MapClaims, error) {
+	discovery, err := s.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", s.cfg.RedirectURL)
+	form.Set("client_id", s.cfg.ClientID)
+	form.Set("code_verifier", loginState.CodeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if s.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(s.cfg.ClientID), url.QueryEscape(s.cfg.ClientSecret))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc token endpoint returned %s", resp.Status)
+	}
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return nil, fmt.Errorf("invalid oidc token response: %w", err)
+	}
+	if tokens.IDToken == "" {
+		return nil, errors.New("oidc token response has no id_token")
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(tokens.IDToken, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return s.signingKey(ctx, discovery.JWKSURI, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(discovery.Issuer),
+		jwt.WithAudience(s.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id token: %w", err)
+	}
+
+	if nonce, _ := claims["nonce"].(string); nonce != loginState.Nonce {
+		return nil, errors.New("id token nonce mismatch")
+	}
+
+	return claims, nil
+}
+
+// CompleteLogin handles the IdP callback: it verifies the response, resolves
+// the account and finishes the login like a password login would. browserState
+// is the state the browser kept from BeginLogin; a callback that does not carry
+// it was not started by this browser and is refused.
+func (s *OIDCService) CompleteLogin(ctx context.Context, code, state, browserState, ip string) (*LoginResponse, *MFAChallenge, error) {
+	if browserState == "" || subtle.ConstantTimeCompare([]byte(browserState), []byte(state)) != 1 {
+		return nil, nil, ErrInvalidOIDCState
+	}
+
+	loginState, err := s.repo.ConsumeState(ctx, state)
+	if err != nil {
+		if errors.Is(err, ErrInvalidOIDCState) {
+			return nil, nil, ErrInvalidOIDCState
+		}
+		return nil, nil, fmt.Errorf("service error completing oidc login: %w", err)
+	}
+	if !loginState.ExpiresAt.After(time.Now()) {
+		return nil, nil, ErrInvalidOIDCState
+	}
+
+	claims, err := s.exchangeCode(ctx, code, loginState)
+	if err != nil {
+		s.audit(ctx, "", ip, err.Error())
+		return nil, nil, fmt.Errorf("%w: %v", ErrOIDCLogin, err)
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, nil, fmt.Errorf("%w: id token has no subject", ErrOIDCLogin)
+	}
+
+	userID, err := s.resolveAccount(ctx, subject, claims)
+	if err != nil {
+		if errors.Is(err, ErrOIDCNoAccount) {
+			s.audit(ctx, subject, ip, "no linked account")
+		}
+		return nil, nil, err
+	}
+
+	user, err := s.accountService.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, ErrAccountDisabled) {
+			s.audit(ctx, subject, ip, "account disabled")
+			return nil, nil, ErrAccountDisabled
+		}
+		return nil, nil, fmt.Errorf("service error completing oidc login: %w", err)
+	}
+	if user == nil {
+		return nil, nil, ErrOIDCNoAccount
+	}
+
+	return s.authService.finishPrimaryAuth(ctx, user, ip)
+}
+
+func (s *OIDCService) audit(ctx context.Context, subject, ip, details string) {
+	s.authService.loginGuard.Audit(ctx, models.AuthAuditEntry{
+		Event:    AuthEventOIDCLogin,
+		Username: subject,
+		IP:       ip,
+		Details:  details,
+	})
+}
+
+// resolveAccount finds the account linked to subject. Otherwise it links an
+// account by email when email_verified is true, or provisions one if that is
+// enabled.
+func (s *OIDCService) resolveAccount(ctx context.Context, subject string, claims jwt.MapClaims) (uuid.UUID, error) {
+	userID, err := s.repo.FindAccountBySubject(ctx, subject)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("service error resolving oidc account: %w", err)
+	}
+	if userID != uuid.Nil {
+		return userID, nil
+	}
+
+	// Only an address the IdP vouches for may take over an existing account
+	email, _ := claims["email"].(string)
+	verified, _ := claims["email_verified"].(bool)
+	if email != "" && verified {
+		userID, err = s.repo.FindUnlinkedAccountByEmail(ctx, email)
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("service error resolving oidc account: %w", err)
+		}
+		if userID != uuid.Nil {
+			if err := s.repo.LinkSubject(ctx, userID, subject); err != nil {
+				return uuid.Nil, fmt.Errorf("service error linking oidc account: %w", err)
+			}
+			return userID, nil
+		}
+	}
+
+	if !s.cfg.AutoProvision {
+		return uuid.Nil, ErrOIDCNoAccount
+	}
+
+	return s.provision(ctx, subject, claims)
+}
+
+// provision creates an account for a new identity. Roles and department come
+// from the first matching claim rules, or the configured defaults. The
+// generated password is discarded, the account signs in through the IdP.
+func (s *OIDCService) provision(ctx context.Context, subject string, claims jwt.MapClaims) (uuid.UUID, error) {
+	var roles []string
+	for _, rule := range s.roleRules {
+		if rule.matches(claims) && !slices.Contains(roles, rule.Target) {
+			roles = append(roles, rule.Target)
+		}
+	}
+	if len(roles) == 0 {
+		roles = []string{s.cfg.DefaultRole}
+	}
+
+	profile := models.User{Department: s.defaultDept}
+	for _, rule := range s.departmentRules {
+		if rule.matches(claims) {
+			department := uuid.MustParse(rule.Target)
+			profile.Department = &department
+			break
+		}
+	}
+	profile.FirstName, _ = claims["given_name"].(string)
+	profile.LastName, _ = claims["family_name"].(string)
+	profile.Email, _ = claims["email"].(string)
+
+	base := oidcUsername(subject, claims)
+
+	for attempt := 1; attempt <= oidcUsernameAttempts; attempt++ {
+		username := base
+		if attempt > 1 {
+			username = fmt.Sprintf("%s-%d", base, attempt)
+		}
+
+		created, err := s.accountService.CreateAccount(ctx, NewAccount{
+			Username: username,
+			Roles:    roles,
+			Profile:  profile,
+		})
+		if errors.Is(err, ErrUsernameTaken) {
+			continue
+		}
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("service error provisioning oidc account: %w", err)
+		}
+
+		if err := s.repo.LinkSubject(ctx, created.UserID, subject); err != nil {
+			return uuid.Nil, fmt.Errorf("service error linking oidc account: %w", err)
+		}
+
+		return created.UserID, nil
+	}
+
+	return uuid.Nil, fmt.Errorf("%w: no free username for %q", ErrOIDCLogin, base)
+}
+
+// oidcUsername picks a username from preferred_username, the email's local
+// part or, failing both, the subject.
+func oidcUsername(subject string, claims jwt.MapClaims) string {
+	candidate, _ := claims["preferred_username"].(string)
+	if candidate == "" {
+		email, _ := claims["email"].(string)
+		candidate, _, _ = strings.Cut(email, "@")
+	}
+	if candidate == "" {
+		candidate = "sso-" + subject
+	}
+
+	return strings.Join(strings.Fields(candidate), ".")
+}
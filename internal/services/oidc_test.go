@@ -0,0 +1,569 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/config"
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/grintheone/foxygen-server/internal/repository"
+)
+
+const (
+	mockClientID    = "foxygen"
+	mockRedirectURL = "http://localhost:3000/api/v1/auth/oidc/callback"
+	mockKID         = "mock-key"
+)
+
+// mockIdP is a minimal OpenID provider serving discovery, token and JWKS
+// endpoints. Authorization is skipped: a test registers the code the user
+// would have come back with, along with the PKCE challenge and nonce from
+// the authorization URL and the claims of the ID token.
+type mockIdP struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]mockGrant
+}
+
+type mockGrant struct {
+	challenge string
+	nonce     string
+	claims    jwt.MapClaims
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idp := &mockIdP{t: t, key: key, codes: map[string]mockGrant{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", idp.discovery)
+	mux.HandleFunc("POST /token", idp.token)
+	mux.HandleFunc("GET /jwks", idp.jwks)
+
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+
+	return idp
+}
+
+func (idp *mockIdP) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 idp.server.URL,
+		"authorization_endpoint": idp.server.URL + "/authorize",
+		"token_endpoint":         idp.server.URL + "/token",
+		"jwks_uri":               idp.server.URL + "/jwks",
+	})
+}
+
+func (idp *mockIdP) jwks(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": mockKID,
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(idp.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(idp.key.E)).Bytes()),
+		}},
+	})
+}
+
+// token redeems a code once, checking the PKCE verifier against the challenge.
+func (idp *mockIdP) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	idp.mu.Lock()
+	grant, ok := idp.codes[r.PostForm.Get("code")]
+	delete(idp.codes, r.PostForm.Get("code"))
+	idp.mu.Unlock()
+
+	if !ok || r.PostForm.Get("grant_type") != "authorization_code" ||
+		r.PostForm.Get("client_id") != mockClientID || r.PostForm.Get("redirect_uri") != mockRedirectURL {
+		http.Error(w, "invalid_grant", http.StatusBadRequest)
+		return
+	}
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+		http.Error(w, "invalid_grant", http.StatusBadRequest)
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"iss":   idp.server.URL,
+		"aud":   mockClientID,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"nonce": grant.nonce,
+	}
+	for name, value := range grant.claims {
+		claims[name] = value
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = mockKID
+	idToken, err := token.SignedString(idp.key)
+	if err != nil {
+		idp.t.Error(err)
+		http.Error(w, "server_error", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"id_token": idToken, "token_type": "Bearer"})
+}
+
+// authorize stands in for the user signing in at the IdP. It checks the
+// authorization URL and returns the code and state of the redirect back.
+func (idp *mockIdP) authorize(t *testing.T, authURL string, claims jwt.MapClaims) (string, string) {
+	t.Helper()
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("authorization url without an S256 code challenge: %s", authURL)
+	}
+	if query.Get("client_id") != mockClientID || query.Get("response_type") != "code" {
+		t.Fatalf("unexpected authorization url: %s", authURL)
+	}
+
+	code := rand.Text()
+
+	idp.mu.Lock()
+	idp.codes[code] = mockGrant{challenge: query.Get("code_challenge"), nonce: query.Get("nonce"), claims: claims}
+	idp.mu.Unlock()
+
+	return code, query.Get("state")
+}
+
+type fakeOIDCRepo struct {
+	states   map[string]repository.OIDCLoginState
+	subjects map[string]uuid.UUID
+	emails   map[string]uuid.UUID
+}
+
+func (r *fakeOIDCRepo) SaveState(ctx context.Context, state repository.OIDCLoginState) error {
+	r.states[state.State] = state
+	return nil
+}
+
+func (r *fakeOIDCRepo) ConsumeState(ctx context.Context, state string) (*repository.OIDCLoginState, error) {
+	loginState, ok := r.states[state]
+	if !ok {
+		return nil, repository.ErrInvalidOIDCState
+	}
+	delete(r.states, state)
+	return &loginState, nil
+}
+
+func (r *fakeOIDCRepo) FindAccountBySubject(ctx context.Context, subject string) (uuid.UUID, error) {
+	return r.subjects[subject], nil
+}
+
+func (r *fakeOIDCRepo) FindUnlinkedAccountByEmail(ctx context.Context, email string) (uuid.UUID, error) {
+	userID := r.emails[email]
+	for _, linked := range r.subjects {
+		if linked == userID {
+			return uuid.Nil, nil
+		}
+	}
+	return userID, nil
+}
+
+func (r *fakeOIDCRepo) LinkSubject(ctx context.Context, userID uuid.UUID, subject string) error {
+	r.subjects[subject] = userID
+	return nil
+}
+
+// fakeAccountRepo keeps accounts in memory. Methods the login doesn't use
+// are left to the embedded nil interface.
+type fakeAccountRepo struct {
+	repository.AccountRepository
+	accounts map[uuid.UUID]*models.Account
+	profiles map[uuid.UUID]models.User
+}
+
+func (r *fakeAccountRepo) GetByID(ctx context.Context, userID uuid.UUID) (*models.Account, error) {
+	return r.accounts[userID], nil
+}
+
+func (r *fakeAccountRepo) CreateAccountWithProfile(ctx context.Context, account *models.Account, roles []string, profile models.User) error {
+	for _, existing := range r.accounts {
+		if existing.Username == account.Username {
+			return repository.ErrUsernameTaken
+		}
+	}
+
+	account.UserID = uuid.New()
+	account.Roles = roles
+	account.Role = roles[0]
+	r.accounts[account.UserID] = account
+	r.profiles[account.UserID] = profile
+
+	return nil
+}
+
+func (r *fakeAccountRepo) GetAccountSummary(ctx context.Context, userID uuid.UUID) (*models.AccountSummary, error) {
+	account, ok := r.accounts[userID]
+	if !ok {
+		return nil, nil
+	}
+	return &models.AccountSummary{UserID: userID, Username: account.Username, Roles: account.Roles}, nil
+}
+
+func (r *fakeAccountRepo) SetLastLogin(ctx context.Context, userID uuid.UUID) error {
+	return nil
+}
+
+type fakeAuthAuditRepo struct {
+	repository.AuthAuditRepo
+	entries []models.AuthAuditEntry
+}
+
+func (r *fakeAuthAuditRepo) ClearThrottle(ctx context.Context, key string) (bool, error) {
+	return false, nil
+}
+
+func (r *fakeAuthAuditRepo) AddAuditEntry(ctx context.Context, entry models.AuthAuditEntry) error {
+	r.entries = append(r.entries, entry)
+	return nil
+}
+
+type oidcTestEnv struct {
+	idp      *mockIdP
+	service  *OIDCService
+	repo     *fakeOIDCRepo
+	accounts *fakeAccountRepo
+}
+
+func newOIDCTestEnv(t *testing.T, configure func(*config.OIDCConfig)) *oidcTestEnv {
+	t.Helper()
+
+	idp := newMockIdP(t)
+
+	cfg := config.OIDCConfig{
+		IssuerURL:   idp.server.URL,
+		ClientID:    mockClientID,
+		RedirectURL: mockRedirectURL,
+		Scopes:      []string{"openid", "profile", "email"},
+		DefaultRole: "user",
+	}
+	if configure != nil {
+		configure(&cfg)
+	}
+
+	repo := &fakeOIDCRepo{
+		states:   map[string]repository.OIDCLoginState{},
+		subjects: map[string]uuid.UUID{},
+		emails:   map[string]uuid.UUID{},
+	}
+	accounts := &fakeAccountRepo{accounts: map[uuid.UUID]*models.Account{}, profiles: map[uuid.UUID]models.User{}}
+
+	keys, err := LoadKeyRing("", testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accountService := NewAccountService(accounts)
+	authService := NewAuthService(accountService, NewLoginGuardService(&fakeAuthAuditRepo{}), NewMFAService(nil, nil, "foxygen"), keys)
+
+	service, err := NewOIDCService(cfg, repo, accountService, authService)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &oidcTestEnv{idp: idp, service: service, repo: repo, accounts: accounts}
+}
+
+func (e *oidcTestEnv) addAccount(username string) uuid.UUID {
+	userID := uuid.New()
+	e.accounts.accounts[userID] = &models.Account{UserID: userID, Username: username, Role: "user", Roles: []string{"user"}}
+	return userID
+}
+
+// login runs the whole flow for an IdP user with the given claims.
+func (e *oidcTestEnv) login(t *testing.T, claims jwt.MapClaims) (*LoginResponse, error) {
+	t.Helper()
+
+	authURL, _, err := e.service.BeginLogin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, state := e.idp.authorize(t, authURL, claims)
+
+	response, _, err := e.service.CompleteLogin(context.Background(), code, state, state, "127.0.0.1")
+	return response, err
+}
+
+func TestOIDCLoginLinkedSubject(t *testing.T) {
+	env := newOIDCTestEnv(t, nil)
+	userID := env.addAccount("ivanov")
+	env.repo.subjects["idp-ivanov"] = userID
+
+	response, err := env.login(t, jwt.MapClaims{"sub": "idp-ivanov", "email": "other@example.com", "email_verified": true})
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if response.UserData.UserID != userID {
+		t.Errorf("logged in as %s, want %s", response.UserData.UserID, userID)
+	}
+	if response.AuthData.AccessToken == "" || response.AuthData.RefreshToken == "" {
+		t.Error("no tokens issued")
+	}
+}
+
+func TestOIDCLoginEmailLinking(t *testing.T) {
+	tests := []struct {
+		name     string
+		claims   jwt.MapClaims
+		wantLink bool
+	}{
+		{"verified email", jwt.MapClaims{"email": "ivanov@example.com", "email_verified": true}, true},
+		{"unverified email", jwt.MapClaims{"email": "ivanov@example.com", "email_verified": false}, false},
+		{"email_verified missing", jwt.MapClaims{"email": "ivanov@example.com"}, false},
+		{"email_verified as a string", jwt.MapClaims{"email": "ivanov@example.com", "email_verified": "true"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newOIDCTestEnv(t, nil)
+			userID := env.addAccount("ivanov")
+			env.repo.emails["ivanov@example.com"] = userID
+
+			tt.claims["sub"] = "idp-ivanov"
+			response, err := env.login(t, tt.claims)
+
+			if tt.wantLink {
+				if err != nil {
+					t.Fatalf("login failed: %v", err)
+				}
+				if response.UserData.UserID != userID {
+					t.Errorf("logged in as %s, want %s", response.UserData.UserID, userID)
+				}
+				if env.repo.subjects["idp-ivanov"] != userID {
+					t.Error("subject was not linked")
+				}
+				return
+			}
+
+			if !errors.Is(err, ErrOIDCNoAccount) {
+				t.Errorf("login error = %v, want ErrOIDCNoAccount", err)
+			}
+			if _, linked := env.repo.subjects["idp-ivanov"]; linked {
+				t.Error("subject linked through an unverified email")
+			}
+		})
+	}
+}
+
+func TestOIDCLoginProvisioning(t *testing.T) {
+	department := uuid.New()
+	env := newOIDCTestEnv(t, func(cfg *config.OIDCConfig) {
+		cfg.AutoProvision = true
+		cfg.RoleRules = []string{"groups:foxygen-dispatch=coordinator", "groups:foxygen-engineers=user"}
+		cfg.DepartmentRules = []string{"groups:foxygen-engineers=" + department.String()}
+	})
+	taken := env.addAccount("petrov")
+	env.repo.emails["petrov@example.com"] = taken
+
+	response, err := env.login(t, jwt.MapClaims{
+		"sub":                "idp-petrov",
+		"preferred_username": "petrov",
+		"email":              "petrov@example.com",
+		"given_name":         "Пётр",
+		"family_name":        "Петров",
+		"groups":             []string{"foxygen-engineers", "foxygen-dispatch"},
+	})
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+
+	userID := response.UserData.UserID
+	if userID == taken {
+		t.Fatal("unverified email was linked to the existing account")
+	}
+
+	account := env.accounts.accounts[userID]
+	if account.Username != "petrov-2" {
+		t.Errorf("username = %q, want petrov-2", account.Username)
+	}
+	if !slices.Equal([]string(account.Roles), []string{"coordinator", "user"}) {
+		t.Errorf("roles = %v", account.Roles)
+	}
+
+	profile := env.accounts.profiles[userID]
+	if profile.Department == nil || *profile.Department != department {
+		t.Errorf("department = %v, want %s", profile.Department, department)
+	}
+	if profile.FirstName != "Пётр" || profile.LastName != "Петров" || profile.Email != "petrov@example.com" {
+		t.Errorf("profile = %+v", profile)
+	}
+	if env.repo.subjects["idp-petrov"] != userID {
+		t.Error("provisioned account was not linked to the subject")
+	}
+
+	// The next login goes through the subject
+	again, err := env.login(t, jwt.MapClaims{"sub": "idp-petrov"})
+	if err != nil {
+		t.Fatalf("second login failed: %v", err)
+	}
+	if again.UserData.UserID != userID {
+		t.Errorf("second login as %s, want %s", again.UserData.UserID, userID)
+	}
+}
+
+func TestOIDCLoginDefaultRole(t *testing.T) {
+	env := newOIDCTestEnv(t, func(cfg *config.OIDCConfig) {
+		cfg.AutoProvision = true
+	})
+
+	response, err := env.login(t, jwt.MapClaims{"sub": "4f2a", "email": "sidorov@example.com"})
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+
+	account := env.accounts.accounts[response.UserData.UserID]
+	if account.Username != "sidorov" {
+		t.Errorf("username = %q, want the email's local part", account.Username)
+	}
+	if !slices.Equal([]string(account.Roles), []string{"user"}) {
+		t.Errorf("roles = %v, want the default role", account.Roles)
+	}
+}
+
+func TestOIDCLoginState(t *testing.T) {
+	env := newOIDCTestEnv(t, nil)
+	env.repo.subjects["idp-ivanov"] = env.addAccount("ivanov")
+	claims := jwt.MapClaims{"sub": "idp-ivanov"}
+	ctx := context.Background()
+
+	t.Run("unknown state", func(t *testing.T) {
+		authURL, _, err := env.service.BeginLogin(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, _ := env.idp.authorize(t, authURL, claims)
+
+		if _, _, err := env.service.CompleteLogin(ctx, code, "forged", "forged", ""); !errors.Is(err, ErrInvalidOIDCState) {
+			t.Errorf("error = %v, want ErrInvalidOIDCState", err)
+		}
+	})
+
+	t.Run("callback without the login cookie", func(t *testing.T) {
+		authURL, _, err := env.service.BeginLogin(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, state := env.idp.authorize(t, authURL, claims)
+
+		// A callback link forwarded to another browser carries a valid
+		// state but not the cookie set by BeginLogin
+		for _, browserState := range []string{"", "other-login"} {
+			if _, _, err := env.service.CompleteLogin(ctx, code, state, browserState, ""); !errors.Is(err, ErrInvalidOIDCState) {
+				t.Errorf("browser state %q: error = %v, want ErrInvalidOIDCState", browserState, err)
+			}
+		}
+		if _, ok := env.repo.states[state]; !ok {
+			t.Error("refused callback consumed the login state")
+		}
+	})
+
+	t.Run("expired state", func(t *testing.T) {
+		authURL, _, err := env.service.BeginLogin(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, state := env.idp.authorize(t, authURL, claims)
+
+		expired := env.repo.states[state]
+		expired.ExpiresAt = time.Now().Add(-time.Second)
+		env.repo.states[state] = expired
+
+		if _, _, err := env.service.CompleteLogin(ctx, code, state, state, ""); !errors.Is(err, ErrInvalidOIDCState) {
+			t.Errorf("error = %v, want ErrInvalidOIDCState", err)
+		}
+	})
+
+	t.Run("state reused", func(t *testing.T) {
+		authURL, _, err := env.service.BeginLogin(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, state := env.idp.authorize(t, authURL, claims)
+
+		if _, _, err := env.service.CompleteLogin(ctx, code, state, state, ""); err != nil {
+			t.Fatalf("first use failed: %v", err)
+		}
+		if _, _, err := env.service.CompleteLogin(ctx, code, state, state, ""); !errors.Is(err, ErrInvalidOIDCState) {
+			t.Errorf("error = %v, want ErrInvalidOIDCState", err)
+		}
+	})
+}
+
+func TestOIDCLoginPKCE(t *testing.T) {
+	env := newOIDCTestEnv(t, nil)
+	env.repo.subjects["idp-ivanov"] = env.addAccount("ivanov")
+	ctx := context.Background()
+
+	authURL, _, err := env.service.BeginLogin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state := env.idp.authorize(t, authURL, jwt.MapClaims{"sub": "idp-ivanov"})
+
+	// A code intercepted by someone else is redeemed without the verifier
+	// of the login that started it
+	stolen := env.repo.states[state]
+	stolen.CodeVerifier = "not-the-original-verifier"
+	env.repo.states[state] = stolen
+
+	if _, _, err := env.service.CompleteLogin(ctx, code, state, state, ""); !errors.Is(err, ErrOIDCLogin) {
+		t.Errorf("error = %v, want ErrOIDCLogin", err)
+	}
+}
+
+func TestOIDCLoginNonceMismatch(t *testing.T) {
+	env := newOIDCTestEnv(t, nil)
+	env.repo.subjects["idp-ivanov"] = env.addAccount("ivanov")
+	ctx := context.Background()
+
+	authURL, _, err := env.service.BeginLogin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state := env.idp.authorize(t, authURL, jwt.MapClaims{"sub": "idp-ivanov", "nonce": "replayed"})
+
+	if _, _, err := env.service.CompleteLogin(ctx, code, state, state, ""); !errors.Is(err, ErrOIDCLogin) {
+		t.Errorf("error = %v, want ErrOIDCLogin", err)
+	}
+}
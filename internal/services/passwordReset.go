@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/grintheone/foxygen-server/internal/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const PasswordResetTokenExpiry = 30 * time.Minute
+
+var ErrInvalidResetToken = repository.ErrInvalidResetToken
+
+type PasswordResetService struct {
+	repo           repository.PasswordResetRepo
+	accountService *AccountService
+	loginGuard     *LoginGuardService
+	mailer         Mailer
+	resetURL       string
+}
+
+func NewPasswordResetService(repo repository.PasswordResetRepo, as *AccountService, guard *LoginGuardService, mailer Mailer, resetURL string) *PasswordResetService {
+	return &PasswordResetService{
+		repo:           repo,
+		accountService: as,
+		loginGuard:     guard,
+		mailer:         mailer,
+		resetURL:       resetURL,
+	}
+}
+
+// hashResetToken is what gets stored, so a leaked table can't be used to reset passwords.
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func generateResetToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// RequestReset issues a single-use token for the account and sends it to the
+// email from the user's profile, or logs it when no email is known. Unknown
+// and disabled accounts are silently ignored so callers can't probe usernames.
+// Requests are throttled per username and source address, including those
+// for unknown accounts, and earlier links stay valid until they expire.
+func (s *PasswordResetService) RequestReset(ctx context.Context, username, ip string) error {
+	if err := s.loginGuard.CheckPasswordReset(ctx, username, ip); err != nil {
+		return err
+	}
+
+	account, err := s.accountService.GetAccountByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, ErrAccountDisabled) {
+			return nil
+		}
+		return fmt.Errorf("service error requesting password reset: %w", err)
+	}
+	if account == nil {
+		return nil
+	}
+
+	token, err := generateResetToken()
+	if err != nil {
+		return fmt.Errorf("failed to generate reset token: %w", err)
+	}
+
+	err = s.repo.CreateToken(ctx, account.UserID, hashResetToken(token), PasswordResetTokenExpiry)
+	if err != nil {
+		return fmt.Errorf("service error storing reset token: %w", err)
+	}
+
+	email, err := s.repo.GetAccountEmail(ctx, account.UserID)
+	if err != nil {
+		return fmt.Errorf("service error fetching account email: %w", err)
+	}
+
+	link := token
+	if s.resetURL != "" {
+		link = s.resetURL + "?token=" + url.QueryEscape(token)
+	}
+
+	if email == "" {
+		log.Printf("password reset requested for %s without an email on file, reset token: %s", account.Username, link)
+		return nil
+	}
+
+	body := fmt.Sprintf(
+		"A password reset was requested for the account %s.\n\nUse this link within %d minutes to set a new password:\n%s\n\nIf you did not request it, ignore this message.",
+		account.Username, int(PasswordResetTokenExpiry.Minutes()), link,
+	)
+
+	if err := s.mailer.Send(ctx, email, "Password reset", body); err != nil {
+		return fmt.Errorf("service error sending reset email: %w", err)
+	}
+
+	return nil
+}
+
+// ConfirmReset sets a new password using a reset token and logs the
+// account out everywhere.
+func (s *PasswordResetService) ConfirmReset(ctx context.Context, token, newPassword string) error {
+	tokenHash := hashResetToken(token)
+
+	userID, err := s.repo.GetTokenOwner(ctx, tokenHash)
+	if err != nil {
+		if errors.Is(err, ErrInvalidResetToken) {
+			return ErrInvalidResetToken
+		}
+		return fmt.Errorf("service error checking reset token: %w", err)
+	}
+
+	account, err := s.accountService.repo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("service error fetching user by ID: %w", err)
+	}
+	if account == nil {
+		return ErrInvalidResetToken
+	}
+
+	if err := ValidatePassword(newPassword, account.Username); err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.repo.ResetPassword(ctx, tokenHash, string(hashedPassword))
+	if err != nil {
+		if errors.Is(err, ErrInvalidResetToken) {
+			return ErrInvalidResetToken
+		}
+		return fmt.Errorf("service error resetting password: %w", err)
+	}
+
+	s.accountService.stateCache.Delete(userID)
+
+	return nil
+}
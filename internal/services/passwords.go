@@ -0,0 +1,71 @@
+package services
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	PasswordMinLength = 10
+	// bcrypt ignores everything past 72 bytes
+	PasswordMaxBytes = 72
+)
+
+//go:embed data/breached_passwords.txt
+var breachedPasswordsFile string
+
+var (
+	breachedPasswords     map[string]struct{}
+	breachedPasswordsOnce sync.Once
+)
+
+// PasswordPolicyError explains why a password was rejected. Reason is safe to show to the client.
+type PasswordPolicyError struct {
+	Reason string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "password rejected: " + e.Reason
+}
+
+func loadBreachedPasswords() {
+	breachedPasswords = make(map[string]struct{})
+
+	scanner := bufio.NewScanner(strings.NewReader(breachedPasswordsFile))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		breachedPasswords[strings.ToLower(line)] = struct{}{}
+	}
+}
+
+// ValidatePassword enforces the password policy: a minimum length, the bcrypt
+// input limit, and not being one of the bundled breached passwords.
+func ValidatePassword(password, username string) error {
+	if utf8.RuneCountInString(password) < PasswordMinLength {
+		return &PasswordPolicyError{Reason: fmt.Sprintf("password must be at least %d characters long", PasswordMinLength)}
+	}
+
+	if len(password) > PasswordMaxBytes {
+		return &PasswordPolicyError{Reason: fmt.Sprintf("password must not be longer than %d bytes", PasswordMaxBytes)}
+	}
+
+	lowered := strings.ToLower(password)
+
+	if username != "" && strings.Contains(lowered, strings.ToLower(username)) {
+		return &PasswordPolicyError{Reason: "password must not contain the username"}
+	}
+
+	breachedPasswordsOnce.Do(loadBreachedPasswords)
+	if _, found := breachedPasswords[lowered]; found {
+		return &PasswordPolicyError{Reason: "password appears in a list of breached passwords"}
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestValidatePassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		username string
+		valid    bool
+	}{
+		{"long enough", "correct horse battery", "ivanov", true},
+		{"exactly the minimum length", "kx9!vb2@qz", "ivanov", true},
+		{"too short", "kx9!vb2@q", "ivanov", false},
+		{"minimum length in runes, not bytes", "дыр-бул-щл", "ivanov", true},
+		{"over the bcrypt limit", strings.Repeat("ж", 37), "ivanov", false},
+		{"contains the username", "my-ivanov-secret", "ivanov", false},
+		{"contains the username in another case", "my-IVANOV-secret", "ivanov", false},
+		{"breached", "password123", "ivanov", false},
+		{"breached in another case", "QwertyUiop", "ivanov", false},
+		{"no username to compare", "correct horse battery", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePassword(tt.password, tt.username)
+
+			var policyErr *PasswordPolicyError
+			switch {
+			case tt.valid && err != nil:
+				t.Errorf("ValidatePassword(%q) = %v, want nil", tt.password, err)
+			case !tt.valid && !errors.As(err, &policyErr):
+				t.Errorf("ValidatePassword(%q) = %v, want a PasswordPolicyError", tt.password, err)
+			}
+		})
+	}
+}
+
+// Entries shorter than the minimum length are dead weight, the length rule
+// already rejects them.
+func TestBreachedPasswordsMeetMinimumLength(t *testing.T) {
+	breachedPasswordsOnce.Do(loadBreachedPasswords)
+
+	if len(breachedPasswords) == 0 {
+		t.Fatal("no breached passwords loaded")
+	}
+
+	for password := range breachedPasswords {
+		if utf8.RuneCountInString(password) < PasswordMinLength {
+			t.Errorf("breached password %q is shorter than %d characters", password, PasswordMinLength)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+)
+
+// pointsPerMM converts millimetres to PDF points.
+const pointsPerMM = 72 / 25.4
+
+// pdfImage places a grayscale image on a page, in points from the bottom
+// left corner.
+type pdfImage struct {
+	img                 *image.Gray
+	x, y, width, height float64
+}
+
+// writePDF builds a PDF of equally sized pages showing images, the minimum
+// label printing needs.
+func writePDF(pageWidth, pageHeight float64, pages [][]pdfImage) ([]byte, error) {
+	var buf bytes.Buffer
+	var offsets []int
+
+	object := func(body func()) int {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n", len(offsets))
+		body()
+		buf.WriteString("\nendobj\n")
+		return len(offsets)
+	}
+	stream := func(dict string, data []byte) int {
+		return object(func() {
+			fmt.Fprintf(&buf, "<< %s /Length %d >>\nstream\n", dict, len(data))
+			buf.Write(data)
+			buf.WriteString("\nendstream")
+		})
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// the catalog and the page tree come first, their bodies are known
+	// once the pages are written
+	offsets = append(offsets, 0, 0)
+
+	var pageIDs []int
+	for _, placements := range pages {
+		var resources, content bytes.Buffer
+		for i, p := range placements {
+			pixels := p.img.Pix
+			bounds := p.img.Bounds()
+			if p.img.Stride != bounds.Dx() {
+				pixels = make([]byte, 0, bounds.Dx()*bounds.Dy())
+				for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+					offset := p.img.PixOffset(bounds.Min.X, y)
+					pixels = append(pixels, p.img.Pix[offset:offset+bounds.Dx()]...)
+				}
+			}
+
+			var compressed bytes.Buffer
+			zw := zlib.NewWriter(&compressed)
+			if _, err := zw.Write(pixels); err != nil {
+				return nil, err
+			}
+			if err := zw.Close(); err != nil {
+				return nil, err
+			}
+
+			id := stream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /FlateDecode",
+				bounds.Dx(), bounds.Dy()), compressed.Bytes())
+
+			fmt.Fprintf(&resources, "/Im%d %d 0 R ", i, id)
+			fmt.Fprintf(&content, "q %.2f 0 0 %.2f %.2f %.2f cm /Im%d Do Q\n", p.width, p.height, p.x, p.y, i)
+		}
+
+		contentID := stream("", content.Bytes())
+		pageIDs = append(pageIDs, object(func() {
+			fmt.Fprintf(&buf, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /XObject << %s>> >> /Contents %d 0 R >>",
+				pageWidth, pageHeight, resources.String(), contentID)
+		}))
+	}
+
+	offsets[0] = buf.Len()
+	buf.WriteString("1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+
+	offsets[1] = buf.Len()
+	buf.WriteString("2 0 obj\n<< /Type /Pages /Kids [")
+	for _, id := range pageIDs {
+		fmt.Fprintf(&buf, "%d 0 R ", id)
+	}
+	fmt.Fprintf(&buf, "] /Count %d >>\nendobj\n", len(pageIDs))
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// Kinds of authenticated callers
+const (
+	ActorTypeUser   = "user"
+	ActorTypeAPIKey = "api_key"
+)
+
+// Actor is the authenticated caller on whose behalf a service method runs.
+// Handlers build it from the token claims in the request context. For API
+// keys UserID holds the key ID and Type is ActorTypeAPIKey. ImpersonatorID is
+// the admin behind an impersonation token.
+type Actor struct {
+	Type           string
+	UserID         uuid.UUID
+	Role           string
+	Roles          []string
+	Permissions    []string
+	ImpersonatorID *uuid.UUID
+}
+
+func (a Actor) HasRole(role string) bool {
+	return a.Role == role || slices.Contains(a.Roles, role)
+}
+
+func (a Actor) IsAPIKey() bool {
+	return a.Type == ActorTypeAPIKey
+}
+
+func (a Actor) IsAdmin() bool {
+	return a.HasRole("admin")
+}
+
+// ForbiddenError is returned by policy checks. Reason is safe to show to the client.
+type ForbiddenError struct {
+	Reason string
+}
+
+func (e *ForbiddenError) Error() string {
+	return "forbidden: " + e.Reason
+}
+
+func forbidden(reason string) error {
+	return &ForbiddenError{Reason: reason}
+}
+
+// ErrNotFound is returned when the resource a policy check needs doesn't exist.
+var ErrNotFound = errors.New("resource not found")
+
+// requireAdmin guards destructive operations.
+func requireAdmin(actor Actor, reason string) error {
+	if actor.IsAdmin() {
+		return nil
+	}
+
+	return forbidden(reason)
+}
+
+// canModifyTicket implements the ticket policy: admins may modify any ticket,
+// coordinators only tickets of their own department and engineers only
+// tickets they execute. A user holding several roles gets the union.
+func canModifyTicket(actor Actor, executor, ticketDepartment, actorDepartment *uuid.UUID) error {
+	if actor.IsAdmin() {
+		return nil
+	}
+
+	if actor.HasRole("coordinator") && ticketDepartment != nil && actorDepartment != nil && *ticketDepartment == *actorDepartment {
+		return nil
+	}
+
+	if actor.HasRole("user") && executor != nil && *executor == actor.UserID {
+		return nil
+	}
+
+	switch {
+	case actor.HasRole("coordinator"):
+		return forbidden("coordinators may only modify tickets of their department")
+	case actor.HasRole("user"):
+		return forbidden("engineers may only modify tickets assigned to them")
+	default:
+		return forbidden("not allowed to modify this ticket")
+	}
+}
+
+// canModifyComment allows only the comment author or an admin.
+func canModifyComment(actor Actor, author uuid.UUID) error {
+	if actor.IsAdmin() || author == actor.UserID {
+		return nil
+	}
+
+	return forbidden("only the comment author or an admin may change this comment")
+}
+
+// canManageAbsence lets users manage their own absences, coordinators those
+// of their department and admins any.
+func canManageAbsence(actor Actor, userID uuid.UUID, userDepartment, actorDepartment *uuid.UUID) error {
+	if actor.IsAdmin() {
+		return nil
+	}
+
+	if !actor.IsAPIKey() && actor.UserID == userID {
+		return nil
+	}
+
+	if actor.HasRole("coordinator") && userDepartment != nil && actorDepartment != nil && *userDepartment == *actorDepartment {
+		return nil
+	}
+
+	return forbidden("only the user, a coordinator of their department or an admin may manage absences")
+}
+
+// canManageSkills lets coordinators manage the skills of their department
+// and admins any. Users can't certify themselves.
+func canManageSkills(actor Actor, userDepartment, actorDepartment *uuid.UUID) error {
+	if actor.IsAdmin() {
+		return nil
+	}
+
+	if actor.HasRole("coordinator") && userDepartment != nil && actorDepartment != nil && *userDepartment == *actorDepartment {
+		return nil
+	}
+
+	return forbidden("only a coordinator of the user's department or an admin may manage skills")
+}
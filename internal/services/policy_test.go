@@ -0,0 +1,152 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+var (
+	policyUser       = uuid.MustParse("6f1c2a1e-0b8e-4c39-9f27-1d3d1e0a7b01")
+	policyOtherUser  = uuid.MustParse("6f1c2a1e-0b8e-4c39-9f27-1d3d1e0a7b02")
+	policyDepartment = uuid.MustParse("0c7f4f52-71a4-4a63-8d0e-5a2f9b1e3c01")
+	policyOtherDept  = uuid.MustParse("0c7f4f52-71a4-4a63-8d0e-5a2f9b1e3c02")
+)
+
+func policyActor(roles ...string) Actor {
+	actor := Actor{Type: ActorTypeUser, UserID: policyUser, Roles: roles}
+	if len(roles) > 0 {
+		actor.Role = roles[0]
+	}
+	return actor
+}
+
+// checkPolicy fails the test unless err is nil when allowed and a
+// ForbiddenError otherwise.
+func checkPolicy(t *testing.T, err error, allowed bool) {
+	t.Helper()
+
+	var forbiddenErr *ForbiddenError
+	switch {
+	case allowed && err != nil:
+		t.Errorf("expected access, got %v", err)
+	case !allowed && !errors.As(err, &forbiddenErr):
+		t.Errorf("expected a ForbiddenError, got %v", err)
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	tests := []struct {
+		name    string
+		actor   Actor
+		allowed bool
+	}{
+		{"admin", policyActor("admin"), true},
+		{"admin among several roles", policyActor("user", "admin"), true},
+		{"coordinator", policyActor("coordinator"), false},
+		{"user", policyActor("user"), false},
+		{"api key", Actor{Type: ActorTypeAPIKey, Role: APIKeyRole}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkPolicy(t, requireAdmin(tt.actor, "admins only"), tt.allowed)
+		})
+	}
+}
+
+func TestCanModifyTicket(t *testing.T) {
+	tests := []struct {
+		name             string
+		actor            Actor
+		executor         *uuid.UUID
+		ticketDepartment *uuid.UUID
+		actorDepartment  *uuid.UUID
+		allowed          bool
+	}{
+		{"admin, any ticket", policyActor("admin"), &policyOtherUser, &policyOtherDept, &policyDepartment, true},
+		{"coordinator, own department", policyActor("coordinator"), &policyOtherUser, &policyDepartment, &policyDepartment, true},
+		{"coordinator, other department", policyActor("coordinator"), &policyOtherUser, &policyOtherDept, &policyDepartment, false},
+		{"coordinator without department", policyActor("coordinator"), nil, &policyDepartment, nil, false},
+		{"coordinator, ticket without department", policyActor("coordinator"), nil, nil, &policyDepartment, false},
+		{"engineer, own ticket", policyActor("user"), &policyUser, &policyOtherDept, &policyDepartment, true},
+		{"engineer, someone else's ticket", policyActor("user"), &policyOtherUser, &policyDepartment, &policyDepartment, false},
+		{"engineer, unassigned ticket", policyActor("user"), nil, &policyDepartment, &policyDepartment, false},
+		{"coordinator and engineer, own ticket elsewhere", policyActor("coordinator", "user"), &policyUser, &policyOtherDept, &policyDepartment, true},
+		{"no roles", policyActor(), &policyUser, &policyDepartment, &policyDepartment, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkPolicy(t, canModifyTicket(tt.actor, tt.executor, tt.ticketDepartment, tt.actorDepartment), tt.allowed)
+		})
+	}
+}
+
+func TestCanModifyComment(t *testing.T) {
+	tests := []struct {
+		name    string
+		actor   Actor
+		author  uuid.UUID
+		allowed bool
+	}{
+		{"author", policyActor("user"), policyUser, true},
+		{"admin", policyActor("admin"), policyOtherUser, true},
+		{"coordinator", policyActor("coordinator"), policyOtherUser, false},
+		{"someone else", policyActor("user"), policyOtherUser, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkPolicy(t, canModifyComment(tt.actor, tt.author), tt.allowed)
+		})
+	}
+}
+
+func TestCanManageAbsence(t *testing.T) {
+	tests := []struct {
+		name            string
+		actor           Actor
+		userID          uuid.UUID
+		userDepartment  *uuid.UUID
+		actorDepartment *uuid.UUID
+		allowed         bool
+	}{
+		{"own absence", policyActor("user"), policyUser, &policyOtherDept, &policyDepartment, true},
+		{"someone else's absence", policyActor("user"), policyOtherUser, &policyDepartment, &policyDepartment, false},
+		{"admin", policyActor("admin"), policyOtherUser, &policyOtherDept, nil, true},
+		{"coordinator, own department", policyActor("coordinator"), policyOtherUser, &policyDepartment, &policyDepartment, true},
+		{"coordinator, other department", policyActor("coordinator"), policyOtherUser, &policyOtherDept, &policyDepartment, false},
+		{"coordinator, user without department", policyActor("coordinator"), policyOtherUser, nil, &policyDepartment, false},
+		{"api key with the user's id", Actor{Type: ActorTypeAPIKey, UserID: policyUser, Role: APIKeyRole}, policyUser, nil, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkPolicy(t, canManageAbsence(tt.actor, tt.userID, tt.userDepartment, tt.actorDepartment), tt.allowed)
+		})
+	}
+}
+
+func TestCanManageSkills(t *testing.T) {
+	tests := []struct {
+		name            string
+		actor           Actor
+		userDepartment  *uuid.UUID
+		actorDepartment *uuid.UUID
+		allowed         bool
+	}{
+		{"admin", policyActor("admin"), &policyOtherDept, nil, true},
+		{"coordinator, own department", policyActor("coordinator"), &policyDepartment, &policyDepartment, true},
+		{"coordinator, other department", policyActor("coordinator"), &policyOtherDept, &policyDepartment, false},
+		{"coordinator without department", policyActor("coordinator"), &policyDepartment, nil, false},
+		{"engineer, own department", policyActor("user"), &policyDepartment, &policyDepartment, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkPolicy(t, canManageSkills(tt.actor, tt.userDepartment, tt.actorDepartment), tt.allowed)
+		})
+	}
+}
@@ -0,0 +1,421 @@
+package services
+
+import (
+	"errors"
+)
+
+// QR codes are encoded in byte mode at error correction level M, which
+// survives a scratched or dirty label, in versions 1 to 10.
+
+var ErrQRDataTooLong = errors.New("data does not fit in a QR code")
+
+// qrVersion is the block structure of a version at level M: the error
+// correction codewords of each block and the data codewords of each block.
+type qrVersion struct {
+	ecPerBlock int
+	blocks     []int
+	alignment  []int
+}
+
+var qrVersions = []qrVersion{
+	1:  {10, []int{16}, nil},
+	2:  {16, []int{28}, []int{6, 18}},
+	3:  {26, []int{44}, []int{6, 22}},
+	4:  {18, []int{32, 32}, []int{6, 26}},
+	5:  {24, []int{43, 43}, []int{6, 30}},
+	6:  {16, []int{27, 27, 27, 27}, []int{6, 34}},
+	7:  {18, []int{31, 31, 31, 31}, []int{6, 22, 38}},
+	8:  {22, []int{38, 38, 39, 39}, []int{6, 24, 42}},
+	9:  {22, []int{36, 36, 36, 37, 37}, []int{6, 26, 46}},
+	10: {26, []int{43, 43, 43, 43, 44}, []int{6, 28, 50}},
+}
+
+func (v qrVersion) dataCodewords() int {
+	total := 0
+	for _, n := range v.blocks {
+		total += n
+	}
+	return total
+}
+
+// gfMultiply multiplies in GF(256) modulo x^8 + x^4 + x^3 + x^2 + 1.
+func gfMultiply(x, y byte) byte {
+	var z byte
+	for i := 7; i >= 0; i-- {
+		carry := z >> 7
+		z = z<<1 ^ carry*0x1d
+		z ^= (y >> i & 1) * x
+	}
+	return z
+}
+
+// reedSolomon returns the degree error correction codewords of data.
+func reedSolomon(data []byte, degree int) []byte {
+	divisor := make([]byte, degree)
+	divisor[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range divisor {
+			divisor[j] = gfMultiply(divisor[j], root)
+			if j+1 < degree {
+				divisor[j] ^= divisor[j+1]
+			}
+		}
+		root = gfMultiply(root, 2)
+	}
+
+	result := make([]byte, degree)
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[degree-1] = 0
+		for i := range result {
+			result[i] ^= gfMultiply(divisor[i], factor)
+		}
+	}
+
+	return result
+}
+
+// qrCodewords picks the smallest version that holds data and returns it with
+// the interleaved data and error correction codewords.
+func qrCodewords(data []byte) (int, []byte, error) {
+	version := 0
+	for v := 1; v < len(qrVersions); v++ {
+		countBits := 8
+		if v >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(data) <= 8*qrVersions[v].dataCodewords() {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return 0, nil, ErrQRDataTooLong
+	}
+	spec := qrVersions[version]
+	capacity := spec.dataCodewords()
+
+	var bits []bool
+	appendBits := func(value, n int) {
+		for i := n - 1; i >= 0; i-- {
+			bits = append(bits, value>>i&1 == 1)
+		}
+	}
+	appendBits(0b0100, 4)
+	if version >= 10 {
+		appendBits(len(data), 16)
+	} else {
+		appendBits(len(data), 8)
+	}
+	for _, b := range data {
+		appendBits(int(b), 8)
+	}
+	appendBits(0, min(4, capacity*8-len(bits)))
+	appendBits(0, (8-len(bits)%8)%8)
+
+	codewords := make([]byte, 0, capacity)
+	for i := 0; i < len(bits); i += 8 {
+		var b byte
+		for _, bit := range bits[i : i+8] {
+			b <<= 1
+			if bit {
+				b |= 1
+			}
+		}
+		codewords = append(codewords, b)
+	}
+	for pad := byte(0xec); len(codewords) < capacity; pad ^= 0xec ^ 0x11 {
+		codewords = append(codewords, pad)
+	}
+
+	blocks := make([][]byte, len(spec.blocks))
+	ecBlocks := make([][]byte, len(spec.blocks))
+	offset := 0
+	for i, n := range spec.blocks {
+		blocks[i] = codewords[offset : offset+n]
+		ecBlocks[i] = reedSolomon(blocks[i], spec.ecPerBlock)
+		offset += n
+	}
+
+	result := make([]byte, 0, capacity+len(blocks)*spec.ecPerBlock)
+	for i := 0; i < spec.blocks[len(spec.blocks)-1]; i++ {
+		for _, block := range blocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := 0; i < spec.ecPerBlock; i++ {
+		for _, block := range ecBlocks {
+			result = append(result, block[i])
+		}
+	}
+
+	return version, result, nil
+}
+
+type qrMatrix struct {
+	size     int
+	modules  [][]bool
+	function [][]bool
+}
+
+func newQRMatrix(size int) *qrMatrix {
+	m := &qrMatrix{size: size, modules: make([][]bool, size), function: make([][]bool, size)}
+	for i := range m.modules {
+		m.modules[i] = make([]bool, size)
+		m.function[i] = make([]bool, size)
+	}
+	return m
+}
+
+// set marks a function module at column x, row y.
+func (m *qrMatrix) set(x, y int, dark bool) {
+	m.modules[y][x] = dark
+	m.function[y][x] = true
+}
+
+func (m *qrMatrix) drawFinder(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x < 0 || y < 0 || x >= m.size || y >= m.size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			m.set(x, y, dist != 2 && dist != 4)
+		}
+	}
+}
+
+func (m *qrMatrix) drawAlignment(cx, cy int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			m.set(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func (m *qrMatrix) drawFunctionPatterns(version int) {
+	for i := 0; i < m.size; i++ {
+		m.set(6, i, i%2 == 0)
+		m.set(i, 6, i%2 == 0)
+	}
+
+	m.drawFinder(3, 3)
+	m.drawFinder(m.size-4, 3)
+	m.drawFinder(3, m.size-4)
+
+	centers := qrVersions[version].alignment
+	for i, cx := range centers {
+		for j, cy := range centers {
+			last := len(centers) - 1
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+			m.drawAlignment(cx, cy)
+		}
+	}
+
+	// reserve the format areas, drawn for real once the mask is known
+	m.drawFormat(0)
+
+	if version >= 7 {
+		rem := version
+		for i := 0; i < 12; i++ {
+			rem = rem<<1 ^ (rem>>11)*0x1f25
+		}
+		bits := version<<12 | rem
+		for i := 0; i < 18; i++ {
+			dark := bits>>i&1 == 1
+			a, b := m.size-11+i%3, i/3
+			m.set(a, b, dark)
+			m.set(b, a, dark)
+		}
+	}
+}
+
+// drawFormat writes the error correction level, M, and the mask with their
+// BCH code in both copies.
+func (m *qrMatrix) drawFormat(mask int) {
+	data := 0b00<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return bits>>i&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		m.set(8, i, bit(i))
+	}
+	m.set(8, 7, bit(6))
+	m.set(8, 8, bit(7))
+	m.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		m.set(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		m.set(m.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		m.set(8, m.size-15+i, bit(i))
+	}
+	m.set(8, m.size-8, true)
+}
+
+// drawCodewords fills the data modules in the zigzag order of the standard,
+// two columns at a time from the bottom right corner.
+func (m *qrMatrix) drawCodewords(data []byte) {
+	i := 0
+	for right := m.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < m.size; vert++ {
+			y := vert
+			if upward {
+				y = m.size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if m.function[y][x] || i >= len(data)*8 {
+					continue
+				}
+				m.modules[y][x] = data[i>>3]>>(7-i&7)&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+func qrMask(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+func (m *qrMatrix) applyMask(mask int) {
+	for y := 0; y < m.size; y++ {
+		for x := 0; x < m.size; x++ {
+			if !m.function[y][x] && qrMask(mask, x, y) {
+				m.modules[y][x] = !m.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores how hard the symbol is to scan, by the four rules of the
+// standard: long runs, 2x2 blocks, finder-like patterns and dark balance.
+func (m *qrMatrix) penalty() int {
+	score := 0
+	finder := []bool{true, false, true, true, true, false, true, false, false, false, false}
+
+	line := func(at func(i int) bool) {
+		run := 1
+		for i := 1; i <= m.size; i++ {
+			if i < m.size && at(i) == at(i-1) {
+				run++
+				continue
+			}
+			if run >= 5 {
+				score += 3 + run - 5
+			}
+			run = 1
+		}
+
+		for i := 0; i+len(finder) <= m.size; i++ {
+			forward, backward := true, true
+			for k, dark := range finder {
+				if at(i+k) != dark {
+					forward = false
+				}
+				if at(i+len(finder)-1-k) != dark {
+					backward = false
+				}
+			}
+			if forward {
+				score += 40
+			}
+			if backward {
+				score += 40
+			}
+		}
+	}
+
+	dark := 0
+	for y := 0; y < m.size; y++ {
+		line(func(x int) bool { return m.modules[y][x] })
+		line(func(x int) bool { return m.modules[x][y] })
+
+		for x := 0; x < m.size; x++ {
+			if m.modules[y][x] {
+				dark++
+			}
+			if x+1 < m.size && y+1 < m.size {
+				c := m.modules[y][x]
+				if m.modules[y][x+1] == c && m.modules[y+1][x] == c && m.modules[y+1][x+1] == c {
+					score += 3
+				}
+			}
+		}
+	}
+
+	total := m.size * m.size
+	score += ((abs(dark*20-total*10)+total-1)/total - 1) * 10
+
+	return score
+}
+
+// encodeQR returns the modules of a QR code holding data, true for dark,
+// without the quiet zone.
+func encodeQR(data []byte) ([][]bool, error) {
+	version, codewords, err := qrCodewords(data)
+	if err != nil {
+		return nil, err
+	}
+
+	m := newQRMatrix(version*4 + 17)
+	m.drawFunctionPatterns(version)
+	m.drawCodewords(codewords)
+
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		m.applyMask(mask)
+		m.drawFormat(mask)
+		if p := m.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		m.applyMask(mask)
+	}
+	m.applyMask(best)
+	m.drawFormat(best)
+
+	return m.modules, nil
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// HELLO WORLD as version 1-M, from the worked example of the standard
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+
+	if got := reedSolomon(data, 10); !bytes.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// qrFormats are the format bits of level M by mask, as listed in the standard.
+var qrFormats = []int{0x5412, 0x5125, 0x5e7c, 0x5b4b, 0x45f9, 0x40ce, 0x4f97, 0x4aa0}
+
+// qrVersionInfo are the version bits of versions 7 to 10.
+var qrVersionInfo = map[int]int{7: 0x07c94, 8: 0x085bc, 9: 0x09a99, 10: 0x0a4d3}
+
+// readQR decodes a symbol made by encodeQR, checking the format and version
+// bits and the error correction of every block along the way.
+func readQR(t *testing.T, modules [][]bool) []byte {
+	t.Helper()
+
+	size := len(modules)
+	version := (size - 17) / 4
+	if version < 1 || version >= len(qrVersions) || version*4+17 != size {
+		t.Fatalf("unexpected size %d", size)
+	}
+	bit := func(x, y int) int {
+		if modules[y][x] {
+			return 1
+		}
+		return 0
+	}
+
+	format, second := 0, 0
+	for i := 0; i <= 5; i++ {
+		format |= bit(8, i) << i
+	}
+	format |= bit(8, 7)<<6 | bit(8, 8)<<7 | bit(7, 8)<<8
+	for i := 9; i < 15; i++ {
+		format |= bit(14-i, 8) << i
+	}
+	for i := 0; i < 8; i++ {
+		second |= bit(size-1-i, 8) << i
+	}
+	for i := 8; i < 15; i++ {
+		second |= bit(8, size-15+i) << i
+	}
+	if format != second {
+		t.Fatalf("format copies differ: %#x and %#x", format, second)
+	}
+	mask := -1
+	for m, f := range qrFormats {
+		if f == format {
+			mask = m
+		}
+	}
+	if mask < 0 {
+		t.Fatalf("format bits %#x are not a level M format", format)
+	}
+
+	if version >= 7 {
+		info := 0
+		for i := 0; i < 18; i++ {
+			a, b := size-11+i%3, i/3
+			if bit(a, b) != bit(b, a) {
+				t.Fatalf("version copies differ at bit %d", i)
+			}
+			info |= bit(a, b) << i
+		}
+		if info != qrVersionInfo[version] {
+			t.Fatalf("version bits %#x, want %#x", info, qrVersionInfo[version])
+		}
+	}
+
+	layout := newQRMatrix(size)
+	layout.drawFunctionPatterns(version)
+
+	var codewords []byte
+	var current byte
+	n := 0
+	for right := size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < size; vert++ {
+			y := vert
+			if upward {
+				y = size - 1 - vert
+			}
+			for x := right; x >= right-1; x-- {
+				if layout.function[y][x] {
+					continue
+				}
+				current <<= 1
+				if modules[y][x] != qrMask(mask, x, y) {
+					current |= 1
+				}
+				if n++; n%8 == 0 {
+					codewords = append(codewords, current)
+				}
+			}
+		}
+	}
+
+	spec := qrVersions[version]
+	blocks := make([][]byte, len(spec.blocks))
+	pos := 0
+	for i := 0; i < spec.blocks[len(spec.blocks)-1]; i++ {
+		for b, length := range spec.blocks {
+			if i < length {
+				blocks[b] = append(blocks[b], codewords[pos])
+				pos++
+			}
+		}
+	}
+	ecBlocks := make([][]byte, len(spec.blocks))
+	for i := 0; i < spec.ecPerBlock; i++ {
+		for b := range spec.blocks {
+			ecBlocks[b] = append(ecBlocks[b], codewords[pos])
+			pos++
+		}
+	}
+
+	var data []byte
+	for b, block := range blocks {
+		if !bytes.Equal(reedSolomon(block, spec.ecPerBlock), ecBlocks[b]) {
+			t.Fatalf("error correction of block %d doesn't match its data", b)
+		}
+		data = append(data, block...)
+	}
+
+	read := func(offset, bits int) int {
+		value := 0
+		for i := offset; i < offset+bits; i++ {
+			value = value<<1 | int(data[i/8]>>(7-i%8)&1)
+		}
+		return value
+	}
+	if mode := read(0, 4); mode != 0b0100 {
+		t.Fatalf("mode %04b, want byte mode", mode)
+	}
+	countBits := 8
+	if version >= 10 {
+		countBits = 16
+	}
+	length := read(4, countBits)
+	payload := make([]byte, length)
+	for i := range payload {
+		payload[i] = byte(read(4+countBits+8*i, 8))
+	}
+
+	return payload
+}
+
+func TestEncodeQRRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		version int
+	}{
+		{"single byte", "x", 1},
+		{"device urn", "urn:uuid:3f2c1d9e-8a4b-4c6d-9e0f-1a2b3c4d5e6f", 4},
+		{"label page", "https://service.foxygen.ru/devices/scan?device=3f2c1d9e-8a4b-4c6d-9e0f-1a2b3c4d5e6f", 5},
+		{"version information", strings.Repeat("Анализатор ", 6), 8},
+		{"two byte length", strings.Repeat("0123456789", 20), 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modules, err := encodeQR([]byte(tt.payload))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if version := (len(modules) - 17) / 4; version != tt.version {
+				t.Errorf("version %d, want %d", version, tt.version)
+			}
+			if got := string(readQR(t, modules)); got != tt.payload {
+				t.Errorf("decoded %q", got)
+			}
+		})
+	}
+}
+
+func TestEncodeQRTooLong(t *testing.T) {
+	if _, err := encodeQR(bytes.Repeat([]byte{'a'}, 214)); !errors.Is(err, ErrQRDataTooLong) {
+		t.Errorf("got %v, want ErrQRDataTooLong", err)
+	}
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/grintheone/foxygen-server/internal/repository"
+)
+
+var ErrInvalidReportPeriod = errors.New("report period must end after it starts")
+
+type ReportService struct {
+	repo repository.ReportRepo
+}
+
+func NewReportService(repo repository.ReportRepo) *ReportService {
+	return &ReportService{repo: repo}
+}
+
+// scopeReport restricts coordinators to their own department. Admins may
+// report on any department or, without one, on all of them.
+func (s *ReportService) scopeReport(ctx context.Context, actor Actor, filter *models.ReportFilter) error {
+	if actor.IsAdmin() {
+		return nil
+	}
+
+	if !actor.HasRole("coordinator") {
+		return forbidden("only coordinators and admins may view reports")
+	}
+
+	department, err := s.repo.GetUserDepartment(ctx, actor.UserID)
+	if err != nil {
+		return fmt.Errorf("service error fetching actor department: %w", err)
+	}
+	if department == nil {
+		return forbidden("coordinators without a department can't view reports")
+	}
+
+	if filter.Department != nil && *filter.Department != *department {
+		return forbidden("coordinators may only view reports of their department")
+	}
+	filter.Department = department
+
+	return nil
+}
+
+// EngineerReports returns the KPIs of every executor with tickets closed in
+// the period, including the per-reason breakdown.
+func (s *ReportService) EngineerReports(ctx context.Context, actor Actor, filter models.ReportFilter) ([]*models.EngineerReport, error) {
+	if !filter.To.After(filter.From) {
+		return nil, ErrInvalidReportPeriod
+	}
+
+	if err := s.scopeReport(ctx, actor, &filter); err != nil {
+		return nil, err
+	}
+
+	reports, err := s.repo.EngineerKPIs(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("service error building engineer report: %w", err)
+	}
+
+	reasons, err := s.repo.EngineerReasons(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("service error building engineer report: %w", err)
+	}
+
+	byUser := make(map[uuid.UUID]*models.EngineerReport, len(reports))
+	for _, report := range reports {
+		report.Reasons = map[string]int{}
+		byUser[report.UserID] = report
+	}
+	for _, reason := range reasons {
+		if report, ok := byUser[reason.UserID]; ok {
+			report.Reasons[reason.Reason] = reason.Count
+		}
+	}
+
+	return reports, nil
+}
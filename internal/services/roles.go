@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/grintheone/foxygen-server/internal/repository"
+)
+
+// Permissions checked by the API. Roles are bundles of these and
+// are managed at runtime through the admin endpoints.
+const (
+	PermTicketsAssign = "tickets:assign"
+	PermTicketsDelete = "tickets:delete"
+	PermClientsWrite  = "clients:write"
+	PermDevicesWrite  = "devices:write"
+	PermAdminAccounts = "admin:accounts"
+)
+
+var (
+	ErrUnknownRole       = repository.ErrUnknownRole
+	ErrUnknownPermission = repository.ErrUnknownPermission
+	ErrRoleNameTaken     = repository.ErrRoleNameTaken
+)
+
+type RoleService struct {
+	repo           repository.RolesRepo
+	accountService *AccountService
+}
+
+func NewRoleService(repo repository.RolesRepo, accountService *AccountService) *RoleService {
+	return &RoleService{repo, accountService}
+}
+
+// normalizeNames drops duplicates so that the repository can verify every
+// requested name was matched by comparing row counts.
+func normalizeNames(names []string) []string {
+	normalized := slices.Clone(names)
+	slices.Sort(normalized)
+	return slices.Compact(normalized)
+}
+
+func (s *RoleService) ListPermissions(ctx context.Context) ([]*models.Permission, error) {
+	permissions, err := s.repo.ListPermissions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("service error fetching permissions: %w", err)
+	}
+
+	return permissions, nil
+}
+
+func (s *RoleService) ListRoles(ctx context.Context) ([]*models.RoleWithPermissions, error) {
+	roles, err := s.repo.ListRoles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("service error fetching roles: %w", err)
+	}
+
+	return roles, nil
+}
+
+func (s *RoleService) CreateRole(ctx context.Context, role models.Role, permissions []string) (*models.RoleWithPermissions, error) {
+	if role.Name == "" {
+		return nil, errors.New("role name cannot be empty")
+	}
+
+	created, err := s.repo.CreateRole(ctx, role, normalizeNames(permissions))
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrUnknownPermission):
+			return nil, ErrUnknownPermission
+		case errors.Is(err, ErrRoleNameTaken):
+			return nil, ErrRoleNameTaken
+		}
+		return nil, fmt.Errorf("service error creating role: %w", err)
+	}
+
+	return created, nil
+}
+
+// SetRolePermissions replaces the permissions bundled in a role and revokes
+// the tokens of every account holding it, so they sign in again with the new
+// permissions.
+func (s *RoleService) SetRolePermissions(ctx context.Context, roleID int, permissions []string) (*models.RoleWithPermissions, error) {
+	role, err := s.repo.GetRoleByID(ctx, roleID)
+	if err != nil {
+		return nil, fmt.Errorf("service error fetching role: %w", err)
+	}
+	if role == nil {
+		return nil, nil
+	}
+
+	err = s.repo.SetRolePermissions(ctx, roleID, normalizeNames(permissions))
+	if err != nil {
+		if errors.Is(err, ErrUnknownPermission) {
+			return nil, ErrUnknownPermission
+		}
+		return nil, fmt.Errorf("service error updating role permissions: %w", err)
+	}
+
+	members, err := s.repo.ListRoleMembers(ctx, roleID)
+	if err != nil {
+		return nil, fmt.Errorf("service error fetching role members: %w", err)
+	}
+	for _, userID := range members {
+		if err := s.accountService.RevokeTokens(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.repo.GetRoleByID(ctx, roleID)
+}
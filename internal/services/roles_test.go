@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/grintheone/foxygen-server/internal/repository"
+)
+
+type fakeRolesRepo struct {
+	repository.RolesRepo
+	roles   map[int]*models.RoleWithPermissions
+	members map[int][]uuid.UUID
+}
+
+func (r *fakeRolesRepo) GetRoleByID(ctx context.Context, id int) (*models.RoleWithPermissions, error) {
+	return r.roles[id], nil
+}
+
+func (r *fakeRolesRepo) SetRolePermissions(ctx context.Context, roleID int, permissions []string) error {
+	r.roles[roleID].Permissions = permissions
+	return nil
+}
+
+func (r *fakeRolesRepo) ListRoleMembers(ctx context.Context, roleID int) ([]uuid.UUID, error) {
+	return r.members[roleID], nil
+}
+
+// revokingAccountRepo records the accounts whose tokens were revoked.
+type revokingAccountRepo struct {
+	*fakeAccountRepo
+	revoked []uuid.UUID
+}
+
+func (r *revokingAccountRepo) RevokeTokens(ctx context.Context, userID uuid.UUID) error {
+	r.revoked = append(r.revoked, userID)
+	return nil
+}
+
+func TestSetRolePermissionsRevokesTokens(t *testing.T) {
+	coordinator, engineer := uuid.New(), uuid.New()
+	accounts := &revokingAccountRepo{fakeAccountRepo: &fakeAccountRepo{
+		accounts: map[uuid.UUID]*models.Account{
+			coordinator: {UserID: coordinator, Username: "petrov", Roles: []string{"coordinator"}},
+			engineer:    {UserID: engineer, Username: "ivanov", Roles: []string{"user"}},
+		},
+	}}
+	roles := &fakeRolesRepo{
+		roles: map[int]*models.RoleWithPermissions{
+			2: {Role: models.Role{ID: 2, Name: "coordinator"}, Permissions: []string{PermClientsWrite, PermDevicesWrite}},
+		},
+		members: map[int][]uuid.UUID{2: {coordinator}},
+	}
+	s := NewRoleService(roles, NewAccountService(accounts))
+
+	role, err := s.SetRolePermissions(context.Background(), 2, []string{PermClientsWrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(role.Permissions, []string{PermClientsWrite}) {
+		t.Errorf("permissions = %v, want only %s", role.Permissions, PermClientsWrite)
+	}
+	if !slices.Equal(accounts.revoked, []uuid.UUID{coordinator}) {
+		t.Errorf("revoked = %v, want only the role holder %s", accounts.revoked, coordinator)
+	}
+}
@@ -0,0 +1,276 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// KeyRing holds the keys tokens are signed and verified with.
+//
+// Keys live in JWT_KEY_DIR as PEM files named after their key ID, which ends
+// up in the "kid" header of every token:
+//
+//	<kid>.pem      PKCS#8 Ed25519 private key, signs and verifies
+//	<kid>.pub.pem  PKIX Ed25519 public key, verifies only
+//
+// The private key with the greatest kid signs new tokens, so kids should sort
+// by creation date (which is what -generate-jwt-key produces). Rotation:
+//
+//  1. Run the server with -generate-jwt-key to add a new private key and copy
+//     it to the key directory of every instance.
+//  2. Restart the instances. New tokens are signed with the new key, tokens
+//     signed with older keys keep verifying and /.well-known/jwks.json lists
+//     all of them.
+//  3. Once RefreshTokenExpiry has passed, delete the old key files (or keep
+//     only their .pub.pem) and restart again.
+//
+// Without a key directory tokens are signed with HS256 and JWT_SECRET as
+// before. Switching to keys takes two steps:
+//
+//  1. Set JWT_KEY_DIR and keep JWT_SECRET. HS256 tokens without a kid that
+//     were issued before the keys were loaded keep verifying for
+//     RefreshTokenExpiry, so nobody is logged out.
+//  2. Once that has passed, remove JWT_SECRET. A restart moves the cutoff,
+//     so the secret stays trusted until it is removed.
+type KeyRing struct {
+	signingKID string
+	private    map[string]ed25519.PrivateKey
+	public     map[string]ed25519.PublicKey
+	secret     []byte
+	// loadedAt is when the keys took over from the secret
+	loadedAt time.Time
+}
+
+// JWK is a public key in JSON Web Key format (RFC 8037 for Ed25519).
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// LoadKeyRing reads the keys in dir. An empty dir falls back to HS256 with secret.
+func LoadKeyRing(dir, secret string) (*KeyRing, error) {
+	ring := &KeyRing{
+		private:  map[string]ed25519.PrivateKey{},
+		public:   map[string]ed25519.PublicKey{},
+		secret:   []byte(secret),
+		loadedAt: time.Now(),
+	}
+
+	if dir == "" {
+		if secret == "" {
+			return nil, errors.New("neither JWT_KEY_DIR nor JWT_SECRET is set")
+		}
+		return ring, nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range paths {
+		name := filepath.Base(path)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key %s: %w", name, err)
+		}
+
+		if kid, ok := strings.CutSuffix(name, ".pub.pem"); ok {
+			key, err := parseEd25519PublicKey(data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse key %s: %w", name, err)
+			}
+			if _, exists := ring.public[kid]; !exists {
+				ring.public[kid] = key
+			}
+			continue
+		}
+
+		kid := strings.TrimSuffix(name, ".pem")
+		key, err := parseEd25519PrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse key %s: %w", name, err)
+		}
+		ring.private[kid] = key
+		ring.public[kid] = key.Public().(ed25519.PublicKey)
+	}
+
+	kids := make([]string, 0, len(ring.private))
+	for kid := range ring.private {
+		kids = append(kids, kid)
+	}
+	if len(kids) == 0 {
+		return nil, fmt.Errorf("no private signing key found in %s", dir)
+	}
+	sort.Strings(kids)
+	ring.signingKID = kids[len(kids)-1]
+
+	log.Printf("Signing tokens with key %s, %d verification keys loaded", ring.signingKID, len(ring.public))
+
+	return ring, nil
+}
+
+func parseEd25519PrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an Ed25519 private key")
+	}
+
+	return edKey, nil
+}
+
+func parseEd25519PublicKey(data []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	edKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("not an Ed25519 public key")
+	}
+
+	return edKey, nil
+}
+
+// GenerateSigningKey writes a new private key named after the current time
+// to dir and returns its path.
+func GenerateSigningKey(dir string) (string, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, time.Now().UTC().Format("20060102T150405Z")+".pem")
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if err := pem.Encode(file, &pem.Block{Type: "PRIVATE KEY", Bytes: der}); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// sign signs claims with the current signing key.
+func (k *KeyRing) sign(claims jwt.Claims) (string, error) {
+	if k.signingKID == "" {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(k.secret)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = k.signingKID
+	return token.SignedString(k.private[k.signingKID])
+}
+
+// verificationKey is the jwt.Keyfunc choosing the key by the token's kid.
+func (k *KeyRing) verificationKey(token *jwt.Token) (any, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		if _, hasKID := token.Header["kid"]; hasKID || len(k.secret) == 0 {
+			return nil, errors.New("unexpected signing method")
+		}
+		if err := k.checkLegacyToken(token, time.Now()); err != nil {
+			return nil, err
+		}
+		return k.secret, nil
+	}
+
+	if _, ok := token.Method.(*jwt.SigningMethodEd25519); !ok {
+		return nil, errors.New("unexpected signing method")
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := k.public[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	return key, nil
+}
+
+// checkLegacyToken limits HS256 tokens once signing keys are loaded: only
+// those issued before the switch are accepted, and only until they could
+// have expired.
+func (k *KeyRing) checkLegacyToken(token *jwt.Token, now time.Time) error {
+	if k.signingKID == "" {
+		return nil
+	}
+
+	if now.After(k.loadedAt.Add(RefreshTokenExpiry)) {
+		return errors.New("HS256 tokens are no longer accepted")
+	}
+
+	issuedAt, err := token.Claims.GetIssuedAt()
+	if err != nil || issuedAt == nil || issuedAt.After(k.loadedAt) {
+		return errors.New("HS256 token issued after the switch to signing keys")
+	}
+
+	return nil
+}
+
+// JWKS returns the public keys for /.well-known/jwks.json, sorted by kid.
+func (k *KeyRing) JWKS() JWKSet {
+	set := JWKSet{Keys: make([]JWK, 0, len(k.public))}
+	for kid, key := range k.public {
+		set.Keys = append(set.Keys, JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(key),
+			Kid: kid,
+			Alg: "EdDSA",
+			Use: "sig",
+		})
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+
+	return set
+}
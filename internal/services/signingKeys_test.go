@@ -0,0 +1,133 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "test-secret"
+
+func signHS256(t *testing.T, issuedAt time.Time) string {
+	t.Helper()
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": "user",
+		"iat": issuedAt.Unix(),
+		"exp": issuedAt.Add(RefreshTokenExpiry).Unix(),
+	}).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed
+}
+
+func TestKeyRingRotation(t *testing.T) {
+	dir := t.TempDir()
+
+	oldPath, err := GenerateSigningKey(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ring, err := LoadKeyRing(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldToken, err := ring.sign(jwt.MapClaims{"sub": "user", "exp": time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A second key named after a later time takes over signing
+	newKID := "99991231T235959Z"
+	data, err := os.ReadFile(oldPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newPath := filepath.Join(dir, newKID+".pem")
+	if err := os.WriteFile(newPath, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ring, err = LoadKeyRing(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ring.signingKID != newKID {
+		t.Errorf("signing with %s, want %s", ring.signingKID, newKID)
+	}
+	if _, err := jwt.Parse(oldToken, ring.verificationKey); err != nil {
+		t.Errorf("token of the previous key rejected: %v", err)
+	}
+	if got := len(ring.JWKS().Keys); got != 2 {
+		t.Errorf("JWKS lists %d keys, want 2", got)
+	}
+
+	// With the old private key gone the token is unknown
+	if err := os.Remove(oldPath); err != nil {
+		t.Fatal(err)
+	}
+	ring, err = LoadKeyRing(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.Parse(oldToken, ring.verificationKey); err == nil {
+		t.Error("token of a removed key accepted")
+	}
+}
+
+func TestKeyRingLegacyTokens(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := GenerateSigningKey(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	ring, err := LoadKeyRing(dir, testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := ring.loadedAt.Add(-time.Hour)
+	after := ring.loadedAt.Add(time.Hour)
+
+	tests := []struct {
+		name  string
+		token string
+		now   time.Time
+		valid bool
+	}{
+		{"issued before the switch", signHS256(t, before), ring.loadedAt.Add(time.Minute), true},
+		{"issued after the switch", signHS256(t, after), after.Add(time.Minute), false},
+		{"past the cutoff", signHS256(t, before), ring.loadedAt.Add(RefreshTokenExpiry + time.Minute), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, _, err := jwt.NewParser().ParseUnverified(tt.token, jwt.MapClaims{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = ring.checkLegacyToken(token, tt.now)
+			if tt.valid && err != nil {
+				t.Errorf("rejected: %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("accepted")
+			}
+		})
+	}
+
+	withoutKeys, err := LoadKeyRing("", testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.Parse(signHS256(t, time.Now()), withoutKeys.verificationKey); err != nil {
+		t.Errorf("HS256 token rejected without signing keys: %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/models"
+	"github.com/grintheone/foxygen-server/internal/repository"
+)
+
+var (
+	ErrInvalidSkillTarget = errors.New("a skill needs exactly one of classificator, manufacturer or research_type")
+	ErrInvalidSkillPeriod = errors.New("certification can't expire before it was issued")
+	ErrUnknownSkillTarget = repository.ErrUnknownSkillTarget
+)
+
+type SkillService struct {
+	repo repository.SkillRepo
+}
+
+func NewSkillService(repo repository.SkillRepo) *SkillService {
+	return &SkillService{repo: repo}
+}
+
+func validateSkill(skill models.Skill) error {
+	targets := 0
+	for _, target := range []*uuid.UUID{skill.Classificator, skill.Manufacturer, skill.ResearchType} {
+		if target != nil {
+			targets++
+		}
+	}
+	if targets != 1 {
+		return ErrInvalidSkillTarget
+	}
+
+	if skill.CertifiedAt != nil && skill.ExpiresAt != nil && skill.ExpiresAt.Before(*skill.CertifiedAt) {
+		return ErrInvalidSkillPeriod
+	}
+
+	return nil
+}
+
+func (s *SkillService) authorize(ctx context.Context, actor Actor, userID uuid.UUID) error {
+	userDepartment, exists, err := s.repo.GetUserDepartment(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("service error checking skill access: %w", err)
+	}
+	if !exists {
+		return ErrNotFound
+	}
+
+	actorDepartment, _, err := s.repo.GetUserDepartment(ctx, actor.UserID)
+	if err != nil {
+		return fmt.Errorf("service error fetching actor department: %w", err)
+	}
+
+	return canManageSkills(actor, userDepartment, actorDepartment)
+}
+
+// ListUserSkills returns the skills of a user with their certificate scans.
+func (s *SkillService) ListUserSkills(ctx context.Context, userID uuid.UUID) ([]*models.Skill, error) {
+	skills, err := s.repo.ListUserSkills(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("service error listing skills: %w", err)
+	}
+
+	if len(skills) == 0 {
+		return skills, nil
+	}
+
+	ids := make([]uuid.UUID, len(skills))
+	byID := make(map[uuid.UUID]*models.Skill, len(skills))
+	for i, skill := range skills {
+		ids[i] = skill.ID
+		skill.Certificates = []*models.Attachment{}
+		byID[skill.ID] = skill
+	}
+
+	certificates, err := s.repo.ListCertificates(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("service error listing certificates: %w", err)
+	}
+	for _, certificate := range certificates {
+		if skill, ok := byID[certificate.RefID]; ok {
+			skill.Certificates = append(skill.Certificates, certificate)
+		}
+	}
+
+	return skills, nil
+}
+
+func (s *SkillService) CreateSkill(ctx context.Context, actor Actor, skill models.Skill) (*models.Skill, error) {
+	if err := validateSkill(skill); err != nil {
+		return nil, err
+	}
+
+	if err := s.authorize(ctx, actor, skill.UserID); err != nil {
+		return nil, err
+	}
+
+	created, err := s.repo.CreateSkill(ctx, skill)
+	if err != nil {
+		if errors.Is(err, ErrUnknownSkillTarget) {
+			return nil, ErrUnknownSkillTarget
+		}
+		return nil, fmt.Errorf("service error creating skill: %w", err)
+	}
+
+	created.Certificates = []*models.Attachment{}
+
+	return created, nil
+}
+
+// UpdateSkill replaces target, certification dates and comment of a skill.
+func (s *SkillService) UpdateSkill(ctx context.Context, actor Actor, skill models.Skill) (*models.Skill, error) {
+	if err := validateSkill(skill); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.repo.GetSkill(ctx, skill.ID)
+	if err != nil {
+		return nil, fmt.Errorf("service error updating skill: %w", err)
+	}
+	if existing == nil {
+		return nil, ErrNotFound
+	}
+
+	if err := s.authorize(ctx, actor, existing.UserID); err != nil {
+		return nil, err
+	}
+
+	updated, err := s.repo.UpdateSkill(ctx, skill)
+	if err != nil {
+		if errors.Is(err, ErrUnknownSkillTarget) {
+			return nil, ErrUnknownSkillTarget
+		}
+		return nil, fmt.Errorf("service error updating skill: %w", err)
+	}
+	if updated == nil {
+		return nil, ErrNotFound
+	}
+
+	certificates, err := s.repo.ListCertificates(ctx, []uuid.UUID{updated.ID})
+	if err != nil {
+		return nil, fmt.Errorf("service error listing certificates: %w", err)
+	}
+	updated.Certificates = append([]*models.Attachment{}, certificates...)
+
+	return updated, nil
+}
+
+func (s *SkillService) DeleteSkill(ctx context.Context, actor Actor, id uuid.UUID) error {
+	existing, err := s.repo.GetSkill(ctx, id)
+	if err != nil {
+		return fmt.Errorf("service error deleting skill: %w", err)
+	}
+	if existing == nil {
+		return ErrNotFound
+	}
+
+	if err := s.authorize(ctx, actor, existing.UserID); err != nil {
+		return err
+	}
+
+	deleted, err := s.repo.DeleteSkill(ctx, id)
+	if err != nil {
+		return fmt.Errorf("service error deleting skill: %w", err)
+	}
+	if !deleted {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Qualified reports whether the user holds a valid certification for the device.
+func (s *SkillService) Qualified(ctx context.Context, userID, deviceID uuid.UUID) (bool, error) {
+	qualified, err := s.repo.HasValidCertification(ctx, userID, deviceID)
+	if err != nil {
+		return false, fmt.Errorf("service error checking certification: %w", err)
+	}
+
+	return qualified, nil
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxSpreadsheetPartSize bounds each decompressed part of an XLSX file
+	maxSpreadsheetPartSize = 64 << 20
+	// maxXLSXColumns is the column limit of Excel, XFD
+	maxXLSXColumns = 16384
+)
+
+var ErrUnreadableSpreadsheet = errors.New("file is not a readable CSV or XLSX spreadsheet")
+
+// readCSV reads a comma or semicolon separated file, the delimiter is picked
+// from the header line. A UTF-8 byte order mark is skipped.
+func readCSV(data []byte) ([][]string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	header, _, _ := bytes.Cut(data, []byte("\n"))
+	reader := csv.NewReader(bytes.NewReader(data))
+	if bytes.Count(header, []byte(";")) > bytes.Count(header, []byte(",")) {
+		reader.Comma = ';'
+	}
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnreadableSpreadsheet, err)
+	}
+
+	return rows, nil
+}
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		RelID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+
+	var b strings.Builder
+	for _, run := range t.Runs {
+		b.WriteString(run.T)
+	}
+	return b.String()
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+type xlsxSheet struct {
+	Rows []struct {
+		Cells []struct {
+			Ref    string   `xml:"r,attr"`
+			Type   string   `xml:"t,attr"`
+			Value  string   `xml:"v"`
+			Inline xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// readXLSX returns the cell values of the first worksheet. Numbers come back
+// as written, without exponent notation for whole numbers.
+func readXLSX(data []byte) ([][]string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnreadableSpreadsheet, err)
+	}
+
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, file := range archive.File {
+		files[strings.TrimPrefix(file.Name, "/")] = file
+	}
+
+	decode := func(name string, dst any) error {
+		file, ok := files[name]
+		if !ok {
+			return fmt.Errorf("%w: missing %s", ErrUnreadableSpreadsheet, name)
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrUnreadableSpreadsheet, err)
+		}
+		defer rc.Close()
+
+		if err := xml.NewDecoder(io.LimitReader(rc, maxSpreadsheetPartSize)).Decode(dst); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrUnreadableSpreadsheet, name, err)
+		}
+		return nil
+	}
+
+	var workbook xlsxWorkbook
+	if err := decode("xl/workbook.xml", &workbook); err != nil {
+		return nil, err
+	}
+	if len(workbook.Sheets) == 0 {
+		return nil, fmt.Errorf("%w: workbook has no sheets", ErrUnreadableSpreadsheet)
+	}
+
+	var rels xlsxRelationships
+	if err := decode("xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+
+	sheetPath := ""
+	for _, rel := range rels.Relationships {
+		if rel.ID == workbook.Sheets[0].RelID {
+			if strings.HasPrefix(rel.Target, "/") {
+				sheetPath = strings.TrimPrefix(rel.Target, "/")
+			} else {
+				sheetPath = path.Join("xl", rel.Target)
+			}
+		}
+	}
+	if sheetPath == "" {
+		return nil, fmt.Errorf("%w: first sheet not found", ErrUnreadableSpreadsheet)
+	}
+
+	var shared xlsxSharedStrings
+	if _, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := decode("xl/sharedStrings.xml", &shared); err != nil {
+			return nil, err
+		}
+	}
+
+	var sheet xlsxSheet
+	if err := decode(sheetPath, &sheet); err != nil {
+		return nil, err
+	}
+
+	rows := make([][]string, 0, len(sheet.Rows))
+	for _, sheetRow := range sheet.Rows {
+		var row []string
+		for _, cell := range sheetRow.Cells {
+			column := len(row)
+			if ref := xlsxColumn(cell.Ref); ref >= 0 {
+				column = ref
+			}
+			if column >= maxXLSXColumns {
+				return nil, fmt.Errorf("%w: cell %s out of range", ErrUnreadableSpreadsheet, cell.Ref)
+			}
+			for len(row) <= column {
+				row = append(row, "")
+			}
+
+			switch cell.Type {
+			case "s":
+				index, err := strconv.Atoi(cell.Value)
+				if err != nil || index < 0 || index >= len(shared.Items) {
+					return nil, fmt.Errorf("%w: bad shared string in %s", ErrUnreadableSpreadsheet, cell.Ref)
+				}
+				row[column] = shared.Items[index].String()
+			case "inlineStr":
+				row[column] = cell.Inline.String()
+			case "e":
+				row[column] = ""
+			case "", "n":
+				row[column] = formatXLSXNumber(cell.Value)
+			default:
+				row[column] = cell.Value
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// xlsxColumn turns the letters of a cell reference like "AB12" into a zero
+// based column index, -1 without letters.
+func xlsxColumn(ref string) int {
+	column := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' || column > maxXLSXColumns {
+			break
+		}
+		column = column*26 + int(r-'A'+1)
+	}
+
+	return column - 1
+}
+
+func formatXLSXNumber(value string) string {
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return value
+	}
+
+	return strconv.FormatFloat(number, 'f', -1, 64)
+}
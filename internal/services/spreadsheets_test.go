@@ -0,0 +1,177 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestReadCSV(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want [][]string
+	}{
+		{"comma", "title,address\nГКБ №1,Москва\n", [][]string{{"title", "address"}, {"ГКБ №1", "Москва"}}},
+		{"semicolon", "title;address\nГКБ №1;Москва, ул. Ленина, 1\n", [][]string{{"title", "address"}, {"ГКБ №1", "Москва, ул. Ленина, 1"}}},
+		{"byte order mark", "\xef\xbb\xbftitle;region\nГКБ №1;Москва", [][]string{{"title", "region"}, {"ГКБ №1", "Москва"}}},
+		{"quoted", "title;address\n\"ООО \"\"Лаб\"\"\";\"Тверь;\nкорпус 2\"\n", [][]string{{"title", "address"}, {"ООО \"Лаб\"", "Тверь;\nкорпус 2"}}},
+		{"stray quote", "title,address\nЛаборатория \"Гемотест,Казань\n", [][]string{{"title", "address"}, {"Лаборатория \"Гемотест", "Казань"}}},
+		{"ragged rows", "a,b,c\n1\n1,2,3,4\n", [][]string{{"a", "b", "c"}, {"1"}, {"1", "2", "3", "4"}}},
+		{"crlf", "a;b\r\n1;2\r\n", [][]string{{"a", "b"}, {"1", "2"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readCSV([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+const (
+	testWorkbook = `<?xml version="1.0" encoding="UTF-8"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Клиенты" sheetId="1" r:id="rId2"/><sheet name="Архив" sheetId="2" r:id="rId1"/></sheets>
+</workbook>`
+	testWorkbookRels = `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet2.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+</Relationships>`
+	testSharedStrings = `<?xml version="1.0" encoding="UTF-8"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" count="3" uniqueCount="3">
+<si><t>title</t></si>
+<si><t>phone</t></si>
+<si><r><t>ГКБ </t></r><r><rPr><b/></rPr><t>№1</t></r></si>
+</sst>`
+	testSheet = `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<sheetData>
+<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="D1" t="inlineStr"><is><t>note</t></is></c></row>
+<row r="2"><c r="A2" t="s"><v>2</v></c><c r="B2"><v>79161234567</v></c><c r="C2" t="e"><v>#N/A</v></c><c r="D2" t="b"><v>1</v></c></row>
+<row r="3"><c r="B3" t="n"><v>1.5E3</v></c><c r="C3"><v>0.1</v></c></row>
+</sheetData>
+</worksheet>`
+	testOtherSheet = `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<sheetData><row r="1"><c r="A1" t="inlineStr"><is><t>archive</t></is></c></row></sheetData>
+</worksheet>`
+)
+
+// buildXLSX zips the given parts into a workbook file.
+func buildXLSX(t *testing.T, parts map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, content := range parts {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func testXLSXParts() map[string]string {
+	return map[string]string{
+		"xl/workbook.xml":            testWorkbook,
+		"xl/_rels/workbook.xml.rels": testWorkbookRels,
+		"xl/sharedStrings.xml":       testSharedStrings,
+		"xl/worksheets/sheet1.xml":   testSheet,
+		"xl/worksheets/sheet2.xml":   testOtherSheet,
+	}
+}
+
+func TestReadXLSX(t *testing.T) {
+	want := [][]string{
+		{"title", "phone", "", "note"},
+		{"ГКБ №1", "79161234567", "", "1"},
+		{"", "1500", "0.1"},
+	}
+
+	got, err := readXLSX(buildXLSX(t, testXLSXParts()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Absolute relationship targets and workbooks without shared strings
+	parts := testXLSXParts()
+	delete(parts, "xl/sharedStrings.xml")
+	parts["xl/_rels/workbook.xml.rels"] = `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId2" Target="/xl/worksheets/sheet2.xml"/>
+</Relationships>`
+
+	got, err = readXLSX(buildXLSX(t, parts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]string{{"archive"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestReadXLSXUnreadable(t *testing.T) {
+	withPart := func(name, content string) []byte {
+		parts := testXLSXParts()
+		if content == "" {
+			delete(parts, name)
+		} else {
+			parts[name] = content
+		}
+		return buildXLSX(t, parts)
+	}
+	sheet := func(cells string) string {
+		return `<worksheet><sheetData><row>` + cells + `</row></sheetData></worksheet>`
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"not a zip", []byte("title;address\n")},
+		{"no workbook", withPart("xl/workbook.xml", "")},
+		{"no sheets", withPart("xl/workbook.xml", `<workbook><sheets/></workbook>`)},
+		{"unknown sheet", withPart("xl/_rels/workbook.xml.rels", `<Relationships/>`)},
+		{"missing sheet part", withPart("xl/worksheets/sheet1.xml", "")},
+		{"broken xml", withPart("xl/worksheets/sheet1.xml", `<worksheet><sheetData>`)},
+		{"bad shared string", withPart("xl/worksheets/sheet1.xml", sheet(`<c r="A1" t="s"><v>3</v></c>`))},
+		{"column out of range", withPart("xl/worksheets/sheet1.xml", sheet(`<c r="XFE1"><v>1</v></c>`))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := readXLSX(tt.data); !errors.Is(err, ErrUnreadableSpreadsheet) {
+				t.Errorf("got %v, want ErrUnreadableSpreadsheet", err)
+			}
+		})
+	}
+}
+
+func TestXLSXColumn(t *testing.T) {
+	tests := map[string]int{"A1": 0, "Z9": 25, "AA10": 26, "AB12": 27, "XFD1": 16383, "12": -1, "": -1}
+
+	for ref, want := range tests {
+		if got := xlsxColumn(ref); got != want {
+			t.Errorf("xlsxColumn(%q) = %d, want %d", ref, got, want)
+		}
+	}
+}
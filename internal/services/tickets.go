@@ -3,6 +3,8 @@ package services
 import (
 	"context"
 	"fmt"
+	"log"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/grintheone/foxygen-server/internal/models"
@@ -10,11 +12,14 @@ import (
 )
 
 type TicketService struct {
-	repo repository.TicketsRepository
+	repo          repository.TicketsRepository
+	absences      *AbsenceService
+	skills        *SkillService
+	notifications *NotificationService
 }
 
-func NewTicketService(r repository.TicketsRepository) *TicketService {
-	return &TicketService{repo: r}
+func NewTicketService(r repository.TicketsRepository, absences *AbsenceService, skills *SkillService, notifications *NotificationService) *TicketService {
+	return &TicketService{repo: r, absences: absences, skills: skills, notifications: notifications}
 }
 
 func (s *TicketService) ListAllTickets(ctx context.Context, currentUserID string, role string, limit int, offset int, sortByTitle bool, search string) ([]*models.TicketCard, error) {
@@ -48,7 +53,33 @@ func (s *TicketService) GetTicketByID(ctx context.Context, uuid uuid.UUID) (*mod
 	return ticket, nil
 }
 
-func (s *TicketService) DeleteTicketByID(ctx context.Context, uuid uuid.UUID) error {
+// authorizeTicketChange applies the ticket policy for the actor.
+func (s *TicketService) authorizeTicketChange(ctx context.Context, actor Actor, ticketID uuid.UUID) error {
+	access, err := s.repo.GetTicketAccess(ctx, ticketID)
+	if err != nil {
+		return fmt.Errorf("service error checking ticket access: %w", err)
+	}
+	if access == nil {
+		return ErrNotFound
+	}
+
+	if actor.IsAdmin() {
+		return nil
+	}
+
+	department, err := s.repo.GetUserDepartment(ctx, actor.UserID)
+	if err != nil {
+		return fmt.Errorf("service error fetching actor department: %w", err)
+	}
+
+	return canModifyTicket(actor, access.Executor, access.Department, department)
+}
+
+func (s *TicketService) DeleteTicketByID(ctx context.Context, actor Actor, uuid uuid.UUID) error {
+	if err := requireAdmin(actor, "only admins may delete tickets"); err != nil {
+		return err
+	}
+
 	err := s.repo.DeleteTicketByID(ctx, uuid)
 	if err != nil {
 		return fmt.Errorf("service error deleting ticket by ID: %w", err)
@@ -66,6 +97,53 @@ func (s *TicketService) CreateNewTicket(ctx context.Context, payload models.RawT
 	return created, nil
 }
 
+// AssignmentWarnings describes why the ticket's executor may be a poor pick:
+// absences overlapping the assigned interval and a missing certification for
+// the device. Without a start the interval begins now, without an end it
+// lasts a day.
+func (s *TicketService) AssignmentWarnings(ctx context.Context, ticketID uuid.UUID) ([]string, error) {
+	assignment, err := s.repo.GetTicketAssignment(ctx, ticketID)
+	if err != nil {
+		return nil, fmt.Errorf("service error fetching ticket assignment: %w", err)
+	}
+	if assignment == nil || assignment.Executor == nil || *assignment.Executor == uuid.Nil {
+		return nil, nil
+	}
+
+	start := time.Now().UTC()
+	if assignment.AssignedStart != nil {
+		start = *assignment.AssignedStart
+	}
+
+	end := start.Add(24 * time.Hour)
+	if assignment.AssignedEnd != nil && assignment.AssignedEnd.After(start) {
+		end = *assignment.AssignedEnd
+	}
+
+	conflicts, err := s.absences.Conflicts(ctx, *assignment.Executor, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	var warnings []string
+	for _, absence := range conflicts {
+		warnings = append(warnings, fmt.Sprintf("executor is absent (%s) from %s to %s",
+			absence.Type, absence.From.Format(time.RFC3339), absence.To.Format(time.RFC3339)))
+	}
+
+	if assignment.Device != nil {
+		qualified, err := s.skills.Qualified(ctx, *assignment.Executor, *assignment.Device)
+		if err != nil {
+			return nil, err
+		}
+		if !qualified {
+			warnings = append(warnings, "executor has no valid certification for this device")
+		}
+	}
+
+	return warnings, nil
+}
+
 func (s *TicketService) GetTicketReasons(ctx context.Context) ([]*models.TicketReason, error) {
 	reasons, err := s.repo.GetTicketReasons(ctx)
 	if err != nil {
@@ -75,8 +153,12 @@ func (s *TicketService) GetTicketReasons(ctx context.Context) ([]*models.TicketR
 	return reasons, nil
 }
 
-func (s *TicketService) UpdateTicketInfo(ctx context.Context, payload models.TicketUpdates, userID string) error {
-	err := s.repo.UpdateTicketInfo(ctx, payload, userID)
+func (s *TicketService) UpdateTicketInfo(ctx context.Context, actor Actor, payload models.TicketUpdates) error {
+	if err := s.authorizeTicketChange(ctx, actor, payload.ID); err != nil {
+		return err
+	}
+
+	err := s.repo.UpdateTicketInfo(ctx, payload, actor.UserID.String())
 	if err != nil {
 		return fmt.Errorf("service error updating ticket info: %w", err)
 	}
@@ -84,15 +166,64 @@ func (s *TicketService) UpdateTicketInfo(ctx context.Context, payload models.Tic
 	return nil
 }
 
-func (s *TicketService) CloseTicket(ctx context.Context, ticketInfo models.CloseTicket, currentUserID uuid.UUID) error {
-	err := s.repo.CloseTicket(ctx, ticketInfo, currentUserID)
+func (s *TicketService) CloseTicket(ctx context.Context, actor Actor, ticketInfo models.CloseTicket) error {
+	if err := s.authorizeTicketChange(ctx, actor, ticketInfo.ID); err != nil {
+		return err
+	}
+
+	err := s.repo.CloseTicket(ctx, ticketInfo, actor.UserID)
 	if err != nil {
 		return fmt.Errorf("service error closing ticket: %w", err)
 	}
 
+	// The ticket is closed at this point, failing to notify must not undo that
+	if err := s.notifyClientManagers(ctx, actor, ticketInfo.ID); err != nil {
+		log.Printf("failed to notify client managers of closed ticket %s: %v", ticketInfo.ID, err)
+	}
+
 	return nil
 }
 
+// notifyClientManagers tells the managers of the ticket's client, other than
+// the actor, that the ticket was closed.
+func (s *TicketService) notifyClientManagers(ctx context.Context, actor Actor, ticketID uuid.UUID) error {
+	if s.notifications == nil {
+		return nil
+	}
+
+	info, err := s.repo.GetTicketClientManagers(ctx, ticketID)
+	if err != nil {
+		return err
+	}
+	if info == nil {
+		return nil
+	}
+
+	var managers []uuid.UUID
+	for _, raw := range info.Managers {
+		id, err := uuid.Parse(raw)
+		if err != nil || id == actor.UserID {
+			continue
+		}
+		managers = append(managers, id)
+	}
+
+	subject := fmt.Sprintf("Ticket #%s closed", info.Number)
+	message := fmt.Sprintf("Ticket #%s for %s has been closed.", info.Number, info.ClientTitle)
+
+	return s.notifications.Notify(ctx, managers, NotificationTicketClosed, &info.ID, subject, message)
+}
+
+// ListManagedClientTickets is the ticket feed of the clients the user manages.
+func (s *TicketService) ListManagedClientTickets(ctx context.Context, managerID uuid.UUID, openOnly bool, limit int, offset int) ([]*models.TicketCard, error) {
+	tickets, err := s.repo.ListManagedClientTickets(ctx, managerID, openOnly, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("service error getting managed client tickets: %w", err)
+	}
+
+	return tickets, nil
+}
+
 func (s *TicketService) GetReasonInfoByID(ctx context.Context, id string) (*models.TicketReason, error) {
 	reasonInfo, err := s.repo.GetReasonInfoByID(ctx, id)
 	if err != nil {
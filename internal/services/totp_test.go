@@ -0,0 +1,97 @@
+package services
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// rfc6238Key is the SHA1 seed of the RFC 6238 appendix B test vectors.
+var rfc6238Key = []byte("12345678901234567890")
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	// The RFC lists 8 digit codes, 6 digit codes are their last six digits.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		if got := totpCode(rfc6238Key, uint64(tt.unix/totpPeriod)); got != tt.want {
+			t.Errorf("totpCode(T=%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	secret := totpEncoding.EncodeToString(rfc6238Key)
+	now := time.Unix(1111111111, 0)
+	step := now.Unix() / totpPeriod
+
+	tests := []struct {
+		name     string
+		code     string
+		lastStep int64
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current period", "050471", 0, step, true},
+		{"previous period", totpCode(rfc6238Key, uint64(step-1)), 0, step - 1, true},
+		{"next period", totpCode(rfc6238Key, uint64(step+1)), 0, step + 1, true},
+		{"outside the skew", totpCode(rfc6238Key, uint64(step-2)), 0, 0, false},
+		{"already used", "050471", step, 0, false},
+		{"wrong code", "000000", 0, 0, false},
+		{"wrong length", "50471", 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, gotOK := verifyTOTP(secret, tt.code, now, tt.lastStep)
+			if gotOK != tt.wantOK || gotStep != tt.wantStep {
+				t.Errorf("verifyTOTP() = (%d, %v), want (%d, %v)", gotStep, gotOK, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestVerifyTOTPLowercaseSecret(t *testing.T) {
+	secret := "gezdgnbvgy3tqojqgezdgnbvgy3tqojq"
+
+	if _, ok := verifyTOTP(secret, "287082", time.Unix(59, 0), 0); !ok {
+		t.Error("lowercase secret was not accepted")
+	}
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	uri, err := url.Parse(totpProvisioningURI("Foxygen Service", "i.ivanov", "GEZDGNBVGY3TQOJQ"))
+	if err != nil {
+		t.Fatalf("invalid uri: %v", err)
+	}
+
+	if uri.Scheme != "otpauth" || uri.Host != "totp" {
+		t.Errorf("unexpected uri %s", uri)
+	}
+	if uri.Path != "/Foxygen Service:i.ivanov" {
+		t.Errorf("label = %q", uri.Path)
+	}
+
+	query := uri.Query()
+	for key, want := range map[string]string{
+		"secret":    "GEZDGNBVGY3TQOJQ",
+		"issuer":    "Foxygen Service",
+		"algorithm": "SHA1",
+		"digits":    "6",
+		"period":    "30",
+	} {
+		if got := query.Get(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+}
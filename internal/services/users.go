@@ -73,8 +73,10 @@ func (s *UserService) UpdateUser(ctx context.Context, user models.User) error {
 	return nil
 }
 
-func (s *UserService) ListDepartmentUsers(ctx context.Context, userID string) ([]*models.User, error) {
-	users, err := s.userRepo.ListDepartmentUsers(ctx, userID)
+// ListDepartmentUsers lists the department of userID, optionally only the
+// users certified for a device or classificator.
+func (s *UserService) ListDepartmentUsers(ctx context.Context, userID string, filter models.DepartmentUserFilter) ([]*models.User, error) {
+	users, err := s.userRepo.ListDepartmentUsers(ctx, userID, filter)
 	if err != nil {
 		return nil, fmt.Errorf("service error retrieving the list of users: %w", err)
 	}
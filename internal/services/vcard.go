@@ -0,0 +1,63 @@
+package services
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/grintheone/foxygen-server/internal/models"
+)
+
+// vcardLineLength is the longest line RFC 6350 allows, in octets.
+const vcardLineLength = 75
+
+var vcardEscaper = strings.NewReplacer(`\`, `\\`, ",", `\,`, ";", `\;`, "\r\n", `\n`, "\n", `\n`)
+
+// writeVCardLine writes a content line folded at vcardLineLength octets
+// without splitting UTF-8 sequences.
+func writeVCardLine(b *strings.Builder, line string) {
+	limit := vcardLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// continuation lines start with a space
+		limit = vcardLineLength - 1
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+// encodeVCards writes contacts as vCard 3.0, the version phones import
+// most reliably.
+func encodeVCards(cards []*models.ContactCard) []byte {
+	var b strings.Builder
+
+	for _, card := range cards {
+		writeVCardLine(&b, "BEGIN:VCARD")
+		writeVCardLine(&b, "VERSION:3.0")
+		writeVCardLine(&b, "UID:urn:uuid:"+card.ID.String())
+		writeVCardLine(&b, "FN:"+vcardEscaper.Replace(card.Name))
+		writeVCardLine(&b, "N:"+vcardEscaper.Replace(card.Name)+";;;;")
+		if card.ClientTitle != "" {
+			writeVCardLine(&b, "ORG:"+vcardEscaper.Replace(card.ClientTitle))
+		}
+		if card.Position != "" {
+			writeVCardLine(&b, "TITLE:"+vcardEscaper.Replace(card.Position))
+		}
+		if phone := card.PhoneE164; phone != "" {
+			writeVCardLine(&b, "TEL;TYPE=WORK,VOICE:"+phone)
+		} else if card.Phone != "" {
+			writeVCardLine(&b, "TEL;TYPE=WORK,VOICE:"+vcardEscaper.Replace(card.Phone))
+		}
+		if card.Email != "" {
+			writeVCardLine(&b, "EMAIL;TYPE=INTERNET,WORK:"+vcardEscaper.Replace(card.Email))
+		}
+		writeVCardLine(&b, "END:VCARD")
+	}
+
+	return []byte(b.String())
+}
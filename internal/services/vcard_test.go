@@ -0,0 +1,92 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/grintheone/foxygen-server/internal/models"
+)
+
+func TestEncodeVCards(t *testing.T) {
+	cards := []*models.ContactCard{
+		{
+			ID:          uuid.MustParse("3f2c1d9e-8a4b-4c6d-9e0f-1a2b3c4d5e6f"),
+			Name:        "Петрова Анна",
+			Position:    "Зав. лабораторией; КДЛ",
+			Phone:       "8 (916) 123-45-67",
+			PhoneE164:   "+79161234567",
+			Email:       "a.petrova@gkb1.ru",
+			ClientTitle: "ГКБ №1, корпус 2",
+		},
+		{
+			ID:    uuid.MustParse("3f2c1d9e-8a4b-4c6d-9e0f-1a2b3c4d5e70"),
+			Name:  `Сидоров\Лаб`,
+			Phone: "внутр. 12,34",
+		},
+	}
+
+	want := "BEGIN:VCARD\r\n" +
+		"VERSION:3.0\r\n" +
+		"UID:urn:uuid:3f2c1d9e-8a4b-4c6d-9e0f-1a2b3c4d5e6f\r\n" +
+		"FN:Петрова Анна\r\n" +
+		"N:Петрова Анна;;;;\r\n" +
+		"ORG:ГКБ №1\\, корпус 2\r\n" +
+		"TITLE:Зав. лабораторией\\; КДЛ\r\n" +
+		"TEL;TYPE=WORK,VOICE:+79161234567\r\n" +
+		"EMAIL;TYPE=INTERNET,WORK:a.petrova@gkb1.ru\r\n" +
+		"END:VCARD\r\n" +
+		"BEGIN:VCARD\r\n" +
+		"VERSION:3.0\r\n" +
+		"UID:urn:uuid:3f2c1d9e-8a4b-4c6d-9e0f-1a2b3c4d5e70\r\n" +
+		"FN:Сидоров\\\\Лаб\r\n" +
+		"N:Сидоров\\\\Лаб;;;;\r\n" +
+		"TEL;TYPE=WORK,VOICE:внутр. 12\\,34\r\n" +
+		"END:VCARD\r\n"
+
+	if got := string(encodeVCards(cards)); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	if got := encodeVCards(nil); len(got) != 0 {
+		t.Errorf("got %q for no contacts", got)
+	}
+}
+
+func TestEncodeVCardsFolding(t *testing.T) {
+	title := strings.Repeat("Городская клиническая больница, ", 5)
+	card := &models.ContactCard{
+		ID:          uuid.MustParse("3f2c1d9e-8a4b-4c6d-9e0f-1a2b3c4d5e6f"),
+		Name:        "Иванов",
+		ClientTitle: title,
+	}
+
+	encoded := strings.TrimSuffix(string(encodeVCards([]*models.ContactCard{card})), "\r\n")
+
+	var unfolded []string
+	for _, line := range strings.Split(encoded, "\r\n") {
+		if len(line) > vcardLineLength {
+			t.Errorf("line of %d octets: %q", len(line), line)
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("line splits a character: %q", line)
+		}
+		if strings.HasPrefix(line, " ") {
+			unfolded[len(unfolded)-1] += line[1:]
+			continue
+		}
+		unfolded = append(unfolded, line)
+	}
+
+	want := "ORG:" + vcardEscaper.Replace(title)
+	found := false
+	for _, line := range unfolded {
+		if line == want {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("no %q after unfolding %q", want, unfolded)
+	}
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/grintheone/foxygen-server/internal/config"
 	"github.com/grintheone/foxygen-server/internal/server"
+	"github.com/grintheone/foxygen-server/internal/services"
 	"github.com/joho/godotenv"
 )
 
@@ -24,9 +25,22 @@ func init() {
 func main() {
 	// Command line flags
 	importFile := flag.String("import", "", "JSON file to import on startup")
+	generateKey := flag.Bool("generate-jwt-key", false, "Write a new token signing key to JWT_KEY_DIR and exit")
 	flag.Parse()
 
 	cfg := config.Load()
+
+	if *generateKey {
+		if cfg.Server.KeyDir == "" {
+			log.Fatal("JWT_KEY_DIR is not set")
+		}
+		path, err := services.GenerateSigningKey(cfg.Server.KeyDir)
+		if err != nil {
+			log.Fatalf("Failed to generate signing key: %v", err)
+		}
+		log.Printf("Generated signing key %s", path)
+		return
+	}
 	app, err := server.NewApp(cfg, importFile)
 	if err != nil {
 		log.Fatalf("Failed to initialize application: %v", err)